@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"goapi/internal/api/config"
+	"os"
+)
+
+const configUsage = `usage: api config [flags] print
+
+  print  show the effective configuration with passwords redacted
+
+flags:`
+
+// * runConfig implements the config subcommand and returns the process exit code *
+func runConfig(args []string) int {
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), configUsage)
+		flags.PrintDefaults()
+	}
+	cfg, err := config.Load(flags, args, os.Getenv)
+	if err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		return 2
+	}
+	if flags.NArg() != 1 || flags.Arg(0) != "print" {
+		flags.Usage()
+		return 2
+	}
+
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "Error printing configuration:", err)
+		return 1
+	}
+	return 0
+}
@@ -2,84 +2,241 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"goapi/internal/api/config"
+	"goapi/internal/api/logging"
+	"goapi/internal/api/metrics"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/DAL/PostgreSQL"
 	"goapi/internal/api/repository/DAL/SQLite"
 	"goapi/internal/api/server"
 	"goapi/internal/api/service"
+	dataservice "goapi/internal/api/service/data"
+	"goapi/internal/api/tracing"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
-// NewSimpleLogger creates a new log.Logger that writes to a file.
+// NewLogger creates a structured logger that writes to a file and to os.Stdout.
 // The file is created if it does not exist, and append to it if it does.
 // The file is created with mode 0644.
-// The logger also writes to os.Stdout.
-// The logger prefixes each log entry with the current date and time and the file name and line number of the calling code.
-// The flags argument defines the logging properties.
-// The flags are Ldate, Ltime, and Lshortfile.
-// The Ldate flag causes the logger to write the current date in the local time zone: 2009/01/23.
-// The Ltime flag causes the logger to write the current time in the local time zone: 01:23:23.
-// The Lshortfile flag causes the logger to write the file name and line number: logger.go:24.
-func NewSimpleLogger(logFile string) *log.Logger {
-
-	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatal(err)
+// The file is rotated when it grows over settings.MaxSize megabytes or is older than settings.MaxAge,
+// at most settings.MaxBackups rotated files are kept, gzipped if settings.Compress is set.
+// Each line carries the time, level, message and the file name and line number of the calling code: main.go:24.
+// settings.Level is the lowest level written and settings.Format selects text or JSON lines.
+func NewLogger(settings config.Log) (*slog.Logger, *logging.RotatingFile, error) {
 
+	file, err := logging.OpenRotatingFile(settings.File, logging.Rotation{
+		MaxSize:    int64(settings.MaxSize) * 1024 * 1024,
+		MaxAge:     settings.MaxAge.Std(),
+		MaxBackups: settings.MaxBackups,
+		Compress:   settings.Compress,
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	return log.New(io.MultiWriter(file, os.Stdout), "", log.Ldate|log.Ltime|log.Lshortfile)
+	logger, err := logging.New(io.MultiWriter(file, os.Stdout), settings.Level, settings.Format)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return logger, file, nil
 }
 
 func main() {
 
+	// * "api migrate ..." manages the database schema and "api config ..." shows the configuration *
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			os.Exit(runMigrate(os.Args[2:]))
+		case "config":
+			os.Exit(runConfig(os.Args[2:]))
+		}
+	}
+
+	// * Settings come from defaults, the configuration file, GOAPI_* environment variables and flags *
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
 	// * Timeout is used to gracefully shutdown the server *
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// * Create a logger and database connection *
-	logger := NewSimpleLogger("production.log")
-	db, err := SQLite.NewSqlite("production.db")
+	logger, logFile, err := NewLogger(cfg.Log)
 	if err != nil {
-		logger.Println("Error setting up database:", err)
+		log.Fatal(err)
+	}
+	defer logFile.Close()
+	slog.SetDefault(logger)
+	db, serviceType, err := openDatabase(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		logger.Error("Error setting up database", "error", err)
 		return
 	}
-	defer db.Close()
+	if db != nil {
+		defer db.Close()
+	}
 
-	// * Create a service factory and API server *
-	sf := service.NewServiceFactory(db, logger, ctx)
+	// * Bring the schema up to date before any repository prepares its statements *
+	migrator := newMigrator(db, serviceType)
+	if migrator == nil {
+		logger.Warn("Using the in-memory database, nothing will be persisted")
+	} else if cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		}
+		if err != nil {
+			logger.Error("Error applying migrations", "error", err)
+			return
+		}
+	} else {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			logger.Error("Error reading migration status", "error", err)
+			return
+		}
+		if pending > 0 {
+			logger.Error("Database schema is not up to date, run \"api migrate up\"", "pending", pending)
+			return
+		}
+	}
+
+	// * Send the spans of requests, services and repositories to the configured exporter *
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		logger.Error("Error setting up tracing", "error", err)
+		return
+	}
+	defer func() {
+		// The context is already cancelled on shutdown, the remaining spans get a few seconds of their own
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("Error flushing spans", "error", err)
+		}
+	}()
+
+	// * Collect metrics of requests, repositories and the connection pool *
+	m := metrics.New()
+	if db != nil {
+		if err := m.RegisterDB(db.Connection(), cfg.Database.Driver); err != nil {
+			logger.Error("Error registering database metrics", "error", err)
+			return
+		}
+	}
+
+	// * Create the services once, the API server and the purge job share them *
+	services, err := service.NewServiceFactory(db, logger, m, ctx).CreateServices(serviceType)
+	if err != nil {
+		logger.Error("Error setting up services", "error", err)
+		return
+	}
 
 	// * Create the API server *
-	server := server.NewServer(ctx, sf, logger)
+	server, err := server.NewServer(ctx, services, cfg, m, logger)
+	if err != nil {
+		logger.Error("Error setting up API server", "error", err)
+		return
+	}
+
+	// * /readyz fails while the database can't be reached or the schema is behind *
+	if db != nil {
+		server.Health.AddCheck("database", db.Ping)
+	}
+	if migrator != nil {
+		server.Health.AddCheck("migrations", func(ctx context.Context) error {
+			pending, err := migrator.Pending(ctx)
+			if err != nil {
+				return err
+			}
+			if pending > 0 {
+				return fmt.Errorf("%d pending", pending)
+			}
+			return nil
+		})
+	}
+
+	// * Start the purge job for soft-deleted rows *
+	server.Health.Go("purge", func() {
+		dataservice.RunPurgeJob(ctx, services.Data, cfg.Purge.Grace.Std(), cfg.Purge.Interval.Std(), logger)
+	})
 
 	// * Setup graceful shutdown *
-	gracefullShutdown(server, cancel, logger)
+	shutdownDone := gracefullShutdown(server, cancel, logFile, logger)
 
 	// * Start the server *
-	logger.Println("Starting server on :8080...")
-	if err := server.ListenAndServe(":8080"); err != nil {
+	logger.Info("Starting server...", "addr", cfg.Server.Addr, "tls", cfg.TLS.Enabled())
+	if err := server.ListenAndServe(cfg.Server.Addr); err != nil {
 		// If the server was shutdown gracefully, don't log a startup error
 		if err != http.ErrServerClosed {
-			logger.Println("Server startup error:", err)
+			logger.Error("Server startup error", "error", err)
+			return
 		}
-		logger.Println("Server gracefully shutdown complete.")
+		// The listener is closed at once, the open requests are waited for
+		<-shutdownDone
+		logger.Info("Server gracefully shutdown complete.")
 		return
 	}
 }
 
-func gracefullShutdown(server *server.Server, cancel context.CancelFunc, logger *log.Logger) {
+// * openDatabase connects to the selected backend and returns the matching service type *
+func openDatabase(driver string, dsn string) (DAL.SQLDatabase, service.DataServiceType, error) {
+	switch driver {
+	case "sqlite":
+		db, err := SQLite.NewSqlite(dsn)
+		return db, service.SQLiteDataService, err
+	case "postgres":
+		db, err := PostgreSQL.NewPostgres(dsn)
+		return db, service.PostgresDataService, err
+	case "memory":
+		// Nothing is stored on disk, the DSN is ignored
+		return nil, service.MemoryDataService, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown database driver %q", driver)
+	}
+}
+
+// * gracefullShutdown shuts the server down on SIGINT or SIGTERM, the returned channel is closed once it is done *
+func gracefullShutdown(server *server.Server, cancel context.CancelFunc, logFile *logging.RotatingFile, logger *slog.Logger) <-chan struct{} {
 
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
 
+	// * SIGHUP reopens the log file, e.g. after logrotate has moved it *
+	hangupCh := make(chan os.Signal, 1)
+	signal.Notify(hangupCh, syscall.SIGHUP)
+	go func() {
+		for range hangupCh {
+			if err := logFile.Reopen(); err != nil {
+				logger.Error("Error reopening log file", "error", err)
+				continue
+			}
+			logger.Info("Reopened log file")
+		}
+	}()
+
 	// * Listen for signals to shutdown the server gracefully *
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		<-signalCh
-		cancel()
+		// The background workers keep running until the open requests have finished
 		if err := server.Shutdown(); err != nil {
-			logger.Println("Error shutting down API Server:", err)
+			logger.Error("Error shutting down API Server", "error", err)
 		}
+		cancel()
 	}()
+	return done
 }
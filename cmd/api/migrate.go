@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"goapi/internal/api/config"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/DAL/PostgreSQL"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/DAL/migrate"
+	"goapi/internal/api/service"
+	"os"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: api migrate [flags] up|down|status
+
+  up      apply all pending migrations
+  down    revert the most recently applied migrations, see -steps
+  status  list the migrations and whether they are applied
+
+flags:`
+
+// * runMigrate implements the migrate subcommand and returns the process exit code *
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "number of migrations reverted by down")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	cfg, err := config.Load(flags, args, os.Getenv)
+	if err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:", err)
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	db, serviceType, err := openDatabase(cfg.Database.Driver, cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error setting up database:", err)
+		return 1
+	}
+	migrator := newMigrator(db, serviceType)
+	if migrator == nil {
+		fmt.Fprintln(os.Stderr, "The in-memory database has no schema to migrate")
+		return 2
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	switch flags.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error applying migrations:", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(os.Stderr, "-steps must be at least 1")
+			return 2
+		}
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reverting migrations:", err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading migration status:", err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		flags.Usage()
+		return 2
+	}
+	return 0
+}
+
+// * newMigrator returns the migrator holding the schema of the selected backend, nil if it has none *
+func newMigrator(db DAL.SQLDatabase, serviceType service.DataServiceType) *migrate.Migrator {
+	switch serviceType {
+	case service.PostgresDataService:
+		return PostgreSQL.NewMigrator(db)
+	case service.MemoryDataService:
+		return nil
+	default:
+		return SQLite.NewMigrator(db)
+	}
+}
@@ -0,0 +1,80 @@
+package auth
+
+import "context"
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	trackerKey
+)
+
+// * DefaultTenant owns the rows of callers that aren't bound to a tenant and the rows written before tenants existed *
+const DefaultTenant = "default"
+
+// * Principal is the authenticated caller of a request *
+type Principal struct {
+	Username string
+	Admin    bool
+	// Device is set when the caller authenticated with a client certificate registered for a device_id
+	Device string
+	// Tenant is the organization whose rows the caller reads and writes, empty for DefaultTenant
+	Tenant string
+}
+
+// * WithPrincipal returns a copy of ctx carrying the authenticated principal, it is also reported to the tracker of ctx *
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	if t, ok := ctx.Value(trackerKey).(*tracker); ok {
+		t.principal, t.ok = p, true
+	}
+	return context.WithValue(ctx, principalKey, p)
+}
+
+type tracker struct {
+	principal Principal
+	ok        bool
+}
+
+// * Track returns a copy of ctx and a function that reports the principal later stored by WithPrincipal in a context derived from it *
+// * It lets middlewares that run before authentication, e.g. auditing, see who the caller turned out to be *
+func Track(ctx context.Context) (context.Context, func() (Principal, bool)) {
+	t := &tracker{}
+	return context.WithValue(ctx, trackerKey, t), func() (Principal, bool) { return t.principal, t.ok }
+}
+
+// * FromContext returns the principal stored by the authentication middleware, if any *
+func FromContext(ctx context.Context) (Principal, bool) {
+	if ctx == nil {
+		return Principal{}, false
+	}
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
+
+// * Username returns the name of the authenticated caller, or "anonymous" *
+func Username(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok && p.Username != "" {
+		return p.Username
+	}
+	return "anonymous"
+}
+
+// * IsAdmin reports whether the authenticated caller has administrative rights *
+func IsAdmin(ctx context.Context) bool {
+	p, ok := FromContext(ctx)
+	return ok && p.Admin
+}
+
+// * Device returns the device_id of a caller authenticated by client certificate *
+func Device(ctx context.Context) (string, bool) {
+	p, ok := FromContext(ctx)
+	return p.Device, ok && p.Device != ""
+}
+
+// * Tenant returns the tenant repositories scope every query by, DefaultTenant for callers without one *
+func Tenant(ctx context.Context) string {
+	if p, ok := FromContext(ctx); ok && p.Tenant != "" {
+		return p.Tenant
+	}
+	return DefaultTenant
+}
@@ -0,0 +1,596 @@
+// Package config holds the typed configuration of the API.
+//
+// Settings are resolved in this order, later sources override earlier ones:
+//
+//  1. the defaults returned by Default
+//  2. the configuration file given by -config or GOAPI_CONFIG (.yaml, .yml or .json)
+//  3. environment variables, GOAPI_ followed by the flag name in upper case with _ for -
+//  4. command-line flags
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server   Server   `json:"server" yaml:"server"`
+	TLS      TLS      `json:"tls" yaml:"tls"`
+	Database Database `json:"database" yaml:"database"`
+	Log      Log      `json:"log" yaml:"log"`
+	Tracing  Tracing  `json:"tracing" yaml:"tracing"`
+	Auth     Auth     `json:"auth" yaml:"auth"`
+	Tenants  Tenants  `json:"tenants" yaml:"tenants"`
+	Purge    Purge    `json:"purge" yaml:"purge"`
+	Rules    Rules    `json:"rules" yaml:"rules"`
+	// Validation holds the rules readings are checked against, per sensor type
+	Validation Validation `json:"validation" yaml:"validation"`
+}
+
+type Server struct {
+	Addr           string   `json:"addr" yaml:"addr"`
+	RequestTimeout Duration `json:"request_timeout" yaml:"request_timeout"`
+	PageSize       int      `json:"page_size" yaml:"page_size"`
+	// ValidateResponses logs responses that don't match the OpenAPI specification, requests are always validated
+	ValidateResponses bool `json:"validate_responses" yaml:"validate_responses"`
+	// RequireIfMatch rejects PUT, PATCH and DELETE requests without an If-Match header
+	RequireIfMatch bool `json:"require_if_match" yaml:"require_if_match"`
+	// ThresholdMaxAge is how long devices may cache the thresholds they fetch, 0 makes them revalidate every time
+	ThresholdMaxAge Duration `json:"threshold_max_age" yaml:"threshold_max_age"`
+	// ShutdownDelay is how long readiness fails before the server stops accepting connections, so that load balancers notice
+	ShutdownDelay Duration `json:"shutdown_delay" yaml:"shutdown_delay"`
+	// ShutdownTimeout is how long open requests may take to finish on shutdown
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
+// * TLS is served when CertFile and KeyFile are set, both files are reloaded when they change *
+type TLS struct {
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+	// ClientCAFile holds the CAs client certificates are verified against
+	ClientCAFile string `json:"client_ca_file" yaml:"client_ca_file"`
+	// ClientAuth is none, optional or require
+	ClientAuth string `json:"client_auth" yaml:"client_auth"`
+	// Devices maps a client certificate subject common name or SAN to the device_id it may post data for
+	Devices map[string]string `json:"devices" yaml:"devices"`
+}
+
+// * Enabled reports whether the server listens with HTTPS *
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type Database struct {
+	Driver      string `json:"driver" yaml:"driver"`
+	DSN         string `json:"dsn" yaml:"dsn"`
+	AutoMigrate bool   `json:"auto_migrate" yaml:"auto_migrate"`
+}
+
+type Log struct {
+	File string `json:"file" yaml:"file"`
+	// Level is debug, info, warn or error
+	Level string `json:"level" yaml:"level"`
+	// Format is text or json
+	Format string `json:"format" yaml:"format"`
+	// MaxSize is the size in megabytes the file is rotated at, 0 disables size rotation
+	MaxSize int `json:"max_size" yaml:"max_size"`
+	// MaxAge is how long a file is written to before it is rotated, 0 disables age rotation
+	MaxAge Duration `json:"max_age" yaml:"max_age"`
+	// MaxBackups is the number of rotated files kept, 0 keeps all of them
+	MaxBackups int `json:"max_backups" yaml:"max_backups"`
+	// Compress gzips rotated files
+	Compress bool `json:"compress" yaml:"compress"`
+}
+
+type Tracing struct {
+	// Exporter is none, otlp or stdout
+	Exporter string `json:"exporter" yaml:"exporter"`
+	// Endpoint is the host:port of the OTLP/HTTP collector
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Insecure sends spans to the collector over plain HTTP
+	Insecure bool `json:"insecure" yaml:"insecure"`
+	// SampleRatio is the share of new traces recorded, traces started by the caller follow its decision
+	SampleRatio float64 `json:"sample_ratio" yaml:"sample_ratio"`
+}
+
+type Auth struct {
+	// Users maps user names to Basic authentication passwords
+	Users  map[string]string `json:"users" yaml:"users"`
+	Admins []string          `json:"admins" yaml:"admins"`
+}
+
+// * Tenants binds users and devices to the organization whose readings, thresholds, rules and audit log they read and write *
+// * Callers that aren't listed belong to the default tenant, which also owns every row written before tenants existed *
+type Tenants struct {
+	// Users maps Basic authentication user names to their tenant
+	Users map[string]string `json:"users" yaml:"users"`
+	// Devices maps the device_id of a client certificate to its tenant
+	Devices map[string]string `json:"devices" yaml:"devices"`
+}
+
+type Purge struct {
+	Grace    Duration `json:"grace" yaml:"grace"`
+	Interval Duration `json:"interval" yaml:"interval"`
+}
+
+type Rules struct {
+	// GroupWindow is how long a device of a grouped rule counts as active after its last reading,
+	// rules with a longer duration qualifier keep their devices for that long instead
+	GroupWindow Duration `json:"group_window" yaml:"group_window"`
+}
+
+// * Default returns the settings used when nothing else is configured *
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8080",
+			RequestTimeout:  Duration(2 * time.Second),
+			PageSize:        10,
+			ThresholdMaxAge: Duration(5 * time.Minute),
+			ShutdownDelay:   Duration(5 * time.Second),
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		TLS: TLS{
+			ClientAuth: "none",
+		},
+		Database: Database{
+			Driver:      "sqlite",
+			DSN:         "production.db",
+			AutoMigrate: true,
+		},
+		Log: Log{
+			File:   "production.log",
+			Level:  "info",
+			Format: "text",
+			// Rotated at 100 MB, ten compressed files are kept
+			MaxSize:    100,
+			MaxBackups: 10,
+			Compress:   true,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			SampleRatio: 1,
+		},
+		Auth: Auth{
+			Users:  map[string]string{"saurav": "amatya"},
+			Admins: []string{"saurav"},
+		},
+		Purge: Purge{
+			Grace:    Duration(30 * 24 * time.Hour),
+			Interval: Duration(time.Hour),
+		},
+		Rules: Rules{
+			GroupWindow: Duration(15 * time.Minute),
+		},
+		Validation: defaultValidation(),
+	}
+}
+
+// * setting binds one configuration value to a flag and an environment variable *
+type setting struct {
+	name  string
+	usage string
+	value func(c *Config) flag.Value
+}
+
+var settings = []setting{
+	{"addr", "address the server listens on", func(c *Config) flag.Value { return (*stringValue)(&c.Server.Addr) }},
+	{"request-timeout", "timeout of a single database request made by a handler", func(c *Config) flag.Value { return &c.Server.RequestTimeout }},
+	{"page-size", "number of rows returned per page", func(c *Config) flag.Value { return (*intValue)(&c.Server.PageSize) }},
+	{"validate-responses", "log responses that don't match the OpenAPI specification", func(c *Config) flag.Value { return (*boolValue)(&c.Server.ValidateResponses) }},
+	{"require-if-match", "reject PUT, PATCH and DELETE requests without an If-Match header", func(c *Config) flag.Value { return (*boolValue)(&c.Server.RequireIfMatch) }},
+	{"threshold-max-age", "how long devices may cache the thresholds they fetch", func(c *Config) flag.Value { return &c.Server.ThresholdMaxAge }},
+	{"shutdown-delay", "how long readiness fails before the server stops accepting connections on shutdown", func(c *Config) flag.Value { return &c.Server.ShutdownDelay }},
+	{"shutdown-timeout", "how long open requests may take to finish on shutdown", func(c *Config) flag.Value { return &c.Server.ShutdownTimeout }},
+	{"tls-cert", "certificate file, HTTPS is served when set", func(c *Config) flag.Value { return (*stringValue)(&c.TLS.CertFile) }},
+	{"tls-key", "private key file of the certificate", func(c *Config) flag.Value { return (*stringValue)(&c.TLS.KeyFile) }},
+	{"tls-client-ca", "CA file client certificates are verified against", func(c *Config) flag.Value { return (*stringValue)(&c.TLS.ClientCAFile) }},
+	{"tls-client-auth", "client certificates: none, optional or require", func(c *Config) flag.Value { return (*stringValue)(&c.TLS.ClientAuth) }},
+	{"tls-devices", "client certificate names and their device as name=device_id,name=device_id", func(c *Config) flag.Value { return (*devicesValue)(&c.TLS.Devices) }},
+	{"db-driver", "database backend: sqlite, postgres or memory", func(c *Config) flag.Value { return (*stringValue)(&c.Database.Driver) }},
+	{"db-dsn", "database data source name, a file for sqlite and a connection string for postgres", func(c *Config) flag.Value { return (*stringValue)(&c.Database.DSN) }},
+	{"auto-migrate", "apply pending database migrations on startup", func(c *Config) flag.Value { return (*boolValue)(&c.Database.AutoMigrate) }},
+	{"log-file", "file the log is appended to", func(c *Config) flag.Value { return (*stringValue)(&c.Log.File) }},
+	{"log-level", "lowest level logged: debug, info, warn or error", func(c *Config) flag.Value { return (*stringValue)(&c.Log.Level) }},
+	{"log-format", "log line format: text or json", func(c *Config) flag.Value { return (*stringValue)(&c.Log.Format) }},
+	{"log-max-size", "size in megabytes the log file is rotated at, 0 disables", func(c *Config) flag.Value { return (*intValue)(&c.Log.MaxSize) }},
+	{"log-max-age", "how long a log file is written to before it is rotated, 0 disables", func(c *Config) flag.Value { return &c.Log.MaxAge }},
+	{"log-max-backups", "number of rotated log files kept, 0 keeps all", func(c *Config) flag.Value { return (*intValue)(&c.Log.MaxBackups) }},
+	{"log-compress", "gzip rotated log files", func(c *Config) flag.Value { return (*boolValue)(&c.Log.Compress) }},
+	{"tracing-exporter", "where spans are sent: none, otlp or stdout", func(c *Config) flag.Value { return (*stringValue)(&c.Tracing.Exporter) }},
+	{"tracing-endpoint", "host:port of the OTLP/HTTP collector", func(c *Config) flag.Value { return (*stringValue)(&c.Tracing.Endpoint) }},
+	{"tracing-insecure", "send spans to the collector over plain HTTP", func(c *Config) flag.Value { return (*boolValue)(&c.Tracing.Insecure) }},
+	{"tracing-sample-ratio", "share of new traces recorded, between 0 and 1", func(c *Config) flag.Value { return (*floatValue)(&c.Tracing.SampleRatio) }},
+	{"auth-users", "Basic authentication users as user:password,user:password", func(c *Config) flag.Value { return (*usersValue)(&c.Auth.Users) }},
+	{"auth-admins", "comma separated users with administrator rights", func(c *Config) flag.Value { return (*listValue)(&c.Auth.Admins) }},
+	{"tenant-users", "users and their tenant as user=tenant,user=tenant", func(c *Config) flag.Value { return (*tenantsValue)(&c.Tenants.Users) }},
+	{"tenant-devices", "devices and their tenant as device_id=tenant,device_id=tenant", func(c *Config) flag.Value { return (*tenantsValue)(&c.Tenants.Devices) }},
+	{"purge-grace", "how long soft-deleted rows are kept before they are purged", func(c *Config) flag.Value { return &c.Purge.Grace }},
+	{"purge-interval", "how often the purge job runs", func(c *Config) flag.Value { return &c.Purge.Interval }},
+	{"rule-group-window", "how long a device of a grouped rule counts as active after its last reading", func(c *Config) flag.Value { return &c.Rules.GroupWindow }},
+	{"validation-max-future-skew", "how far the date_time of a reading may lie ahead of the server clock, 0 disables", func(c *Config) flag.Value { return &c.Validation.Default.MaxFutureSkew }},
+}
+
+// * EnvName returns the environment variable of a flag, e.g. db-dsn becomes GOAPI_DB_DSN *
+func EnvName(flagName string) string {
+	return "GOAPI_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// * Load registers the configuration flags on fs, parses args and resolves the configuration *
+// * getenv is os.Getenv outside of tests *
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	path := fs.String("config", "", "configuration file (.yaml, .yml or .json), also "+EnvName("config"))
+
+	// Flags are parsed into their own copy, only the flags given on the command line are applied at the end
+	// The default users are left out so that -h doesn't print passwords
+	flags := Default()
+	flags.Auth.Users = nil
+	for _, s := range settings {
+		fs.Var(s.value(flags), s.name, s.usage+", also "+EnvName(s.name))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *path == "" {
+		*path = getenv(EnvName("config"))
+	}
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if env := getenv(EnvName(s.name)); env != "" {
+			if err := s.value(cfg).Set(env); err != nil {
+				return nil, fmt.Errorf("%s: %w", EnvName(s.name), err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.name == f.Name && err == nil {
+				err = s.value(cfg).Set(f.Value.String())
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// Maps are merged by the decoders, a file listing users replaces the default ones
+	defaultUsers := c.Auth.Users
+	c.Auth.Users = nil
+	defer func() {
+		if c.Auth.Users == nil {
+			c.Auth.Users = defaultUsers
+		}
+	}()
+
+	// Unknown keys are rejected so that typos don't go unnoticed
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+		if err == io.EOF {
+			err = nil
+		}
+	case ".json":
+		decoder := json.NewDecoder(strings.NewReader(string(content)))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	default:
+		return fmt.Errorf("%s: unsupported configuration file type, use .yaml, .yml or .json", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// * Validate reports every invalid setting at once *
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("server.request_timeout must be positive"))
+	}
+	if c.Server.ThresholdMaxAge < 0 {
+		errs = append(errs, errors.New("server.threshold_max_age must not be negative"))
+	}
+	if c.Server.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("server.shutdown_delay must not be negative"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.PageSize < 1 || c.Server.PageSize > 1000 {
+		errs = append(errs, errors.New("server.page_size must be between 1 and 1000"))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file must be set together"))
+	}
+	if !slices.Contains([]string{"none", "optional", "require"}, c.TLS.ClientAuth) {
+		errs = append(errs, fmt.Errorf("tls.client_auth %q is unknown, use none, optional or require", c.TLS.ClientAuth))
+	}
+	if c.TLS.ClientAuth != "none" && (!c.TLS.Enabled() || c.TLS.ClientCAFile == "") {
+		errs = append(errs, errors.New("tls.client_auth needs tls.cert_file, tls.key_file and tls.client_ca_file"))
+	}
+	if len(c.TLS.Devices) > 0 && c.TLS.ClientAuth == "none" {
+		errs = append(errs, errors.New("tls.devices needs tls.client_auth optional or require"))
+	}
+	for name, deviceID := range c.TLS.Devices {
+		if name == "" || deviceID == "" {
+			errs = append(errs, fmt.Errorf("tls.devices: %q needs a certificate name and a device_id", name))
+		}
+	}
+	if !slices.Contains([]string{"sqlite", "postgres", "memory"}, c.Database.Driver) {
+		errs = append(errs, fmt.Errorf("database.driver %q is unknown, use sqlite, postgres or memory", c.Database.Driver))
+	}
+	if c.Database.DSN == "" && c.Database.Driver != "memory" {
+		errs = append(errs, errors.New("database.dsn must not be empty"))
+	}
+	if c.Log.File == "" {
+		errs = append(errs, errors.New("log.file must not be empty"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level %q is unknown, use debug, info, warn or error", c.Log.Level))
+	}
+	if !slices.Contains([]string{"text", "json"}, c.Log.Format) {
+		errs = append(errs, fmt.Errorf("log.format %q is unknown, use text or json", c.Log.Format))
+	}
+	if c.Log.MaxSize < 0 || c.Log.MaxAge < 0 || c.Log.MaxBackups < 0 {
+		errs = append(errs, errors.New("log.max_size, log.max_age and log.max_backups must not be negative"))
+	}
+	if !slices.Contains([]string{"none", "otlp", "stdout"}, c.Tracing.Exporter) {
+		errs = append(errs, fmt.Errorf("tracing.exporter %q is unknown, use none, otlp or stdout", c.Tracing.Exporter))
+	}
+	if c.Tracing.Exporter == "otlp" && c.Tracing.Endpoint == "" {
+		errs = append(errs, errors.New("tracing.endpoint must not be empty"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if len(c.Auth.Users) == 0 {
+		errs = append(errs, errors.New("auth.users must contain at least one user"))
+	}
+	for username, password := range c.Auth.Users {
+		if username == "" || strings.Contains(username, ":") || password == "" {
+			errs = append(errs, fmt.Errorf("auth.users: user %q needs a name without ':' and a password", username))
+		}
+	}
+	for _, admin := range c.Auth.Admins {
+		if _, ok := c.Auth.Users[admin]; !ok {
+			errs = append(errs, fmt.Errorf("auth.admins: %q is not a user", admin))
+		}
+	}
+	for username, tenant := range c.Tenants.Users {
+		if _, ok := c.Auth.Users[username]; !ok {
+			errs = append(errs, fmt.Errorf("tenants.users: %q is not a user", username))
+		}
+		if tenant == "" {
+			errs = append(errs, fmt.Errorf("tenants.users: user %q needs a tenant", username))
+		}
+	}
+	devices := map[string]bool{}
+	for _, deviceID := range c.TLS.Devices {
+		devices[deviceID] = true
+	}
+	for deviceID, tenant := range c.Tenants.Devices {
+		if !devices[deviceID] {
+			errs = append(errs, fmt.Errorf("tenants.devices: %q is not a device of tls.devices", deviceID))
+		}
+		if tenant == "" {
+			errs = append(errs, fmt.Errorf("tenants.devices: device %q needs a tenant", deviceID))
+		}
+	}
+	if c.Purge.Grace <= 0 || c.Purge.Interval <= 0 {
+		errs = append(errs, errors.New("purge.grace and purge.interval must be positive"))
+	}
+	if c.Rules.GroupWindow <= 0 {
+		errs = append(errs, errors.New("rules.group_window must be positive"))
+	}
+	errs = append(errs, c.Validation.validate()...)
+	return errors.Join(errs...)
+}
+
+// * Same placeholder as url.URL.Redacted
+const redacted = "xxxxx"
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// * Redacted returns a copy of the configuration with passwords replaced *
+func (c *Config) Redacted() *Config {
+	r := *c
+	r.Auth.Users = map[string]string{}
+	for username := range c.Auth.Users {
+		r.Auth.Users[username] = redacted
+	}
+	r.Auth.Admins = slices.Clone(c.Auth.Admins)
+
+	// Both URL and key=value connection strings may carry a password
+	if u, err := url.Parse(c.Database.DSN); err == nil && u.User != nil {
+		r.Database.DSN = u.Redacted()
+	}
+	r.Database.DSN = dsnPassword.ReplaceAllString(r.Database.DSN, "${1}"+redacted)
+	return &r
+}
+
+// * Print writes the configuration as YAML with secrets redacted *
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// * Duration is a time.Duration written as "2s" or "720h" in files, environment variables and flags *
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+type stringValue string
+
+func (v *stringValue) String() string     { return string(*v) }
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+
+type intValue int
+
+func (v *intValue) String() string { return fmt.Sprint(int(*v)) }
+func (v *intValue) Set(s string) error {
+	var i int
+	if _, err := fmt.Sscan(s, &i); err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = intValue(i)
+	return nil
+}
+
+type floatValue float64
+
+func (v *floatValue) String() string { return fmt.Sprint(float64(*v)) }
+func (v *floatValue) Set(s string) error {
+	var f float64
+	if _, err := fmt.Sscan(s, &f); err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string   { return fmt.Sprint(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+func (v *boolValue) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "true", "1", "yes":
+		*v = true
+	case "false", "0", "no":
+		*v = false
+	default:
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	return nil
+}
+
+type listValue []string
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+func (v *listValue) Set(s string) error {
+	*v = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v = append(*v, item)
+		}
+	}
+	return nil
+}
+
+type usersValue map[string]string
+
+func (v *usersValue) String() string { return formatPairs(*v, ":") }
+func (v *usersValue) Set(s string) error {
+	return parsePairs(s, ":", "user:password", (*map[string]string)(v))
+}
+
+// * Certificate names may be URIs, the device is separated by = instead of : *
+type devicesValue map[string]string
+
+func (v *devicesValue) String() string { return formatPairs(*v, "=") }
+func (v *devicesValue) Set(s string) error {
+	return parsePairs(s, "=", "name=device_id", (*map[string]string)(v))
+}
+
+type tenantsValue map[string]string
+
+func (v *tenantsValue) String() string { return formatPairs(*v, "=") }
+func (v *tenantsValue) Set(s string) error {
+	return parsePairs(s, "=", "name=tenant", (*map[string]string)(v))
+}
+
+func formatPairs(m map[string]string, sep string) string {
+	var pairs []string
+	for key, value := range m {
+		pairs = append(pairs, key+sep+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func parsePairs(s string, sep string, format string, m *map[string]string) error {
+	pairs := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, sep)
+		if !ok {
+			return fmt.Errorf("invalid entry %q, use %s", item, format)
+		}
+		pairs[key] = value
+	}
+	*m = pairs
+	return nil
+}
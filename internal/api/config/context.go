@@ -0,0 +1,52 @@
+package config
+
+import (
+	"context"
+	"time"
+)
+
+// * Request holds the settings handlers need, they travel in the request context *
+type Request struct {
+	Timeout        time.Duration
+	PageSize       int
+	RequireIfMatch bool
+	// ThresholdMaxAge is the max-age of the Cache-Control header of thresholds fetched by devices
+	ThresholdMaxAge time.Duration
+}
+
+type requestKey struct{}
+
+// * WithRequest returns a context carrying the request settings *
+func WithRequest(ctx context.Context, settings Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, settings)
+}
+
+// * RequestTimeout returns the timeout for the database calls of a handler, 2 seconds if not configured *
+func RequestTimeout(ctx context.Context) time.Duration {
+	if settings, ok := ctx.Value(requestKey{}).(Request); ok && settings.Timeout > 0 {
+		return settings.Timeout
+	}
+	return Default().Server.RequestTimeout.Std()
+}
+
+// * RequireIfMatch reports whether writes must name the version they change with If-Match, false if not configured *
+func RequireIfMatch(ctx context.Context) bool {
+	settings, _ := ctx.Value(requestKey{}).(Request)
+	return settings.RequireIfMatch
+}
+
+// * PageSize returns the number of rows per page, 10 if not configured *
+func PageSize(ctx context.Context) int {
+	if settings, ok := ctx.Value(requestKey{}).(Request); ok && settings.PageSize > 0 {
+		return settings.PageSize
+	}
+	return Default().Server.PageSize
+}
+
+// * ThresholdMaxAge returns how long devices may cache the thresholds they fetch, 5 minutes if not configured *
+func ThresholdMaxAge(ctx context.Context) time.Duration {
+	if settings, ok := ctx.Value(requestKey{}).(Request); ok {
+		return settings.ThresholdMaxAge
+	}
+	return Default().Server.ThresholdMaxAge.Std()
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"goapi/internal/api/units"
+	"slices"
+	"sort"
+	"time"
+)
+
+// * Validation holds the rules readings are checked against on create and update *
+type Validation struct {
+	// Default applies to readings of every type
+	Default SensorRules `json:"default" yaml:"default"`
+	// Sensors maps a reading type to its own rules, they are added to the default ones field by field
+	Sensors map[string]SensorRules `json:"sensors" yaml:"sensors"`
+}
+
+// * SensorRules declares what a valid reading of one sensor type looks like *
+type SensorRules struct {
+	// Required lists the fields a reading must set, a value of 0 counts as not set
+	Required []string `json:"required,omitempty" yaml:"required,omitempty"`
+	// Ranges maps temp_value and humi_value to the values the sensor can physically measure, in the canonical units °C and %
+	Ranges map[string]Range `json:"ranges,omitempty" yaml:"ranges,omitempty"`
+	// Units maps temp_value and humi_value to the units they may be sent in, any known unit when not set
+	Units map[string][]string `json:"units,omitempty" yaml:"units,omitempty"`
+	// MaxFutureSkew is how far date_time may lie ahead of the server clock
+	// 0 disables the check in the default rules and keeps the default in the rules of a sensor
+	MaxFutureSkew Duration `json:"max_future_skew,omitempty" yaml:"max_future_skew,omitempty"`
+}
+
+// * Range bounds a value, both ends are inclusive and may be left open *
+type Range struct {
+	Min *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// * Fields that can be required and the numeric ones that can be ranged, by their JSON name *
+var (
+	RequiredFields = []string{"device_name", "temp_value", "humi_value", "type"}
+	RangedFields   = []string{"temp_value", "humi_value"}
+	// UnitFields maps the numeric fields to the quantity they measure
+	UnitFields = map[string]units.Quantity{"temp_value": units.Temperature, "humi_value": units.Humidity}
+)
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// * defaultValidation allows what a physical sensor can measure: down to absolute zero and 0-100 % humidity *
+func defaultValidation() Validation {
+	return Validation{
+		Default: SensorRules{
+			Ranges: map[string]Range{
+				"temp_value": {Min: bound(-273.15), Max: bound(100)},
+				"humi_value": {Min: bound(0), Max: bound(100)},
+			},
+			MaxFutureSkew: Duration(5 * time.Minute),
+		},
+		Sensors: map[string]SensorRules{},
+	}
+}
+
+// * For returns the rules of sensorType: the default ones with the sensor's ranges, required fields and skew on top *
+func (v Validation) For(sensorType string) SensorRules {
+	rules := SensorRules{
+		Required:      slices.Clone(v.Default.Required),
+		Ranges:        map[string]Range{},
+		Units:         map[string][]string{},
+		MaxFutureSkew: v.Default.MaxFutureSkew,
+	}
+	for field, r := range v.Default.Ranges {
+		rules.Ranges[field] = r
+	}
+	for field, allowed := range v.Default.Units {
+		rules.Units[field] = allowed
+	}
+
+	sensor, ok := v.Sensors[sensorType]
+	if !ok {
+		return rules
+	}
+	for _, field := range sensor.Required {
+		if !slices.Contains(rules.Required, field) {
+			rules.Required = append(rules.Required, field)
+		}
+	}
+	for field, r := range sensor.Ranges {
+		rules.Ranges[field] = r
+	}
+	for field, allowed := range sensor.Units {
+		rules.Units[field] = allowed
+	}
+	if sensor.MaxFutureSkew > 0 {
+		rules.MaxFutureSkew = sensor.MaxFutureSkew
+	}
+	return rules
+}
+
+func (v Validation) validate() []error {
+	var errs []error
+	check := func(key string, rules SensorRules) {
+		for _, field := range rules.Required {
+			if !slices.Contains(RequiredFields, field) {
+				errs = append(errs, fmt.Errorf("%s.required: %q can't be required, use one of %v", key, field, RequiredFields))
+			}
+		}
+
+		// Sorted so that the errors come in the same order every time
+		fields := make([]string, 0, len(rules.Ranges))
+		for field := range rules.Ranges {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			r := rules.Ranges[field]
+			if !slices.Contains(RangedFields, field) {
+				errs = append(errs, fmt.Errorf("%s.ranges: %q has no range, use one of %v", key, field, RangedFields))
+			} else if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+				errs = append(errs, fmt.Errorf("%s.ranges.%s: min must not be greater than max", key, field))
+			}
+		}
+
+		fields = fields[:0]
+		for field := range rules.Units {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			quantity, ok := UnitFields[field]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s.units: %q has no unit, use one of %v", key, field, RangedFields))
+				continue
+			}
+			for _, symbol := range rules.Units[field] {
+				if _, err := units.Lookup(quantity, symbol); err != nil {
+					errs = append(errs, fmt.Errorf("%s.units.%s: %w", key, field, err))
+				}
+			}
+		}
+		if rules.MaxFutureSkew < 0 {
+			errs = append(errs, fmt.Errorf("%s.max_future_skew must not be negative", key))
+		}
+	}
+
+	check("validation.default", v.Default)
+	types := make([]string, 0, len(v.Sensors))
+	for sensorType := range v.Sensors {
+		types = append(types, sensorType)
+	}
+	sort.Strings(types)
+	for _, sensorType := range types {
+		check("validation.sensors."+sensorType, v.Sensors[sensorType])
+	}
+	return errs
+}
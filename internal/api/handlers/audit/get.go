@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/audit"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// * adminOnly writes a 403 response and returns false unless the caller is an administrator *
+// * The audit log shows the actions of every principal of the tenant, so it is reserved for administrators *
+func adminOnly(w http.ResponseWriter, r *http.Request) bool {
+	if !auth.IsAdmin(r.Context()) {
+		problem.Forbidden(w, r, "The audit log is only available to administrators.")
+		return false
+	}
+	return true
+}
+
+// * storedTime writes a from or to bound in the layout the entries are stored in, "" when there is no bound *
+func storedTime(t models.Timestamp) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(models.StorageLayout)
+}
+
+// * Returns audit entries, newest first, filtered by principal, method, resource_id, from and to *
+// * from and to are RFC 3339 or Unix time, e.g. ?from=2024-01-01T00:00:00%2B02:00&to=1704153600
+// * curl -X GET "http://127.0.0.1:8080/audit?method=DELETE&resource_id=1&page=1" -i -u admin:password -H "Content-Type: application/json"
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, as service.AuditService) {
+	if !adminOnly(w, r) {
+		return
+	}
+	query := r.URL.Query()
+
+	page := 1
+	if p := query.Get("page"); p != "" {
+		var err error
+		if page, err = strconv.Atoi(p); err != nil {
+			problem.BadRequest(w, r, "Invalid page specified.")
+			return
+		}
+	}
+
+	from, err := models.ParseTimestamp(query.Get("from"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid from parameter, use RFC 3339 or Unix time.")
+		return
+	}
+	to, err := models.ParseTimestamp(query.Get("to"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid to parameter, use RFC 3339 or Unix time.")
+		return
+	}
+
+	filter := models.AuditFilter{
+		Principal:  query.Get("principal"),
+		Method:     strings.ToUpper(query.Get("method")),
+		ResourceID: query.Get("resource_id"),
+		From:       storedTime(from),
+		To:         storedTime(to),
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	entries, err := as.ReadMany(filter, page, config.PageSize(ctx), ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error retrieving audit entries")
+		return
+	}
+	if len(entries) == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding audit entries", "error", err)
+		return
+	}
+}
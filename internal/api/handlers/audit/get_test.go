@@ -0,0 +1,71 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/handlers/audit"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/audit"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func asPrincipal(admin bool, handler func(http.ResponseWriter, *http.Request, *slog.Logger, service.AuditService), as service.AuditService, path string) *httptest.ResponseRecorder {
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Username: "saurav", Admin: admin})
+	req := httptest.NewRequest("GET", path, nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handler(rr, req, slog.Default(), as)
+	return rr
+}
+
+// * Callers without administrator rights can neither list nor verify the audit log *
+func TestAuditRequiresAdmin(t *testing.T) {
+	as := service.NewRepositoryAuditService(Memory.NewAuditRepository())
+	entry := &models.AuditEntry{Principal: "saurav", Method: "POST", Path: "/data", Status: http.StatusCreated}
+	if err := as.Record(entry, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := time.Parse(models.StorageLayout, entry.Time); err != nil {
+		t.Errorf("expected the time to be recorded in the storage layout, got %q", entry.Time)
+	}
+
+	for _, handler := range []func(http.ResponseWriter, *http.Request, *slog.Logger, service.AuditService){audit.GetHandler, audit.VerifyHandler} {
+		if rr := asPrincipal(false, handler, as, "/audit"); rr.Code != http.StatusForbidden {
+			t.Errorf("expected a user to get %v, got %v: %s", http.StatusForbidden, rr.Code, rr.Body.String())
+		}
+		if rr := asPrincipal(true, handler, as, "/audit"); rr.Code != http.StatusOK {
+			t.Errorf("expected an administrator to get %v, got %v: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+// * from and to compare instants, also when the stored times have a different number of fractional digits *
+func TestAuditTimeRange(t *testing.T) {
+	repo := Memory.NewAuditRepository()
+	for _, at := range []string{"2024-01-01T10:00:05.000000000Z", "2024-01-01T10:00:05.500000000Z", "2024-01-01T10:00:06.000000000Z"} {
+		if err := repo.Create(&models.AuditEntry{Time: at, Principal: "saurav", Method: "POST", Path: "/data", Status: http.StatusCreated}, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	as := service.NewRepositoryAuditService(repo)
+
+	rr := asPrincipal(true, audit.GetHandler, as, "/audit?from=2024-01-01T10:00:05.2Z&to=2024-01-01T12:00:05.7%2B02:00")
+	var entries []models.AuditEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("%v: %v: %s", rr.Code, err, rr.Body.String())
+	}
+	if rr.Code != http.StatusOK || len(entries) != 1 || entries[0].Time != "2024-01-01T10:00:05.500000000Z" {
+		t.Errorf("expected the entry between from and to, got %v %+v", rr.Code, entries)
+	}
+
+	for _, query := range []string{"?from=yesterday", "?to=2024-13-01"} {
+		if rr := asPrincipal(true, audit.GetHandler, as, "/audit"+query); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %v, got %v: %s", query, http.StatusBadRequest, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/audit"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// * Verifies the hash chain of the audit log of the caller's tenant, responds 409 Conflict when an entry was tampered with *
+// * curl -X GET http://127.0.0.1:8080/audit/verify -i -u admin:password -H "Content-Type: application/json"
+func VerifyHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, as service.AuditService) {
+	if !adminOnly(w, r) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := as.Verify(ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error verifying audit log")
+		return
+	}
+
+	if result.Valid {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		logger.ErrorContext(r.Context(), "Audit log hash chain broken", "entry_id", result.FirstInvalidID)
+		w.WriteHeader(http.StatusConflict)
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding audit verification", "error", err)
+		return
+	}
+}
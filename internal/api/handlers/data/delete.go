@@ -2,39 +2,43 @@ package data
 
 import (
 	"context"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // * The DELETE method removes a resource identified by a URI *
 // * curl -X DELETE http://127.0.0.1:8080/data/1 -i -u admin:password -H "Content-Type: application/json"
-func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		// * This is a User Error: format of id is invalid, response in JSON and with a 400 status code
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Missconfigured ID."}`))
+		problem.BadRequest(w, r, "Missconfigured ID.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	// * With If-Match the write only applies to the version it names, else it is answered with 412 *
+	ctx, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
 	defer cancel()
 
 	aff, err := ds.Delete(&models.Data{ID: id}, ctx)
 	if err != nil {
-		//logger.Println("Could not delete data:", err, id)
-		http.Error(w, "Internal Server error", http.StatusInternalServerError)
+		problem.Respond(w, r, logger, err, "Could not delete data", "id", id)
 		return
 	}
 
 	// * Check if the data was found and deleted
 	if aff == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Resource not found."}`))
+		problem.NotFound(w, r)
 		return
 	}
 
@@ -2,11 +2,11 @@ package data_test
 
 import (
 	handlers "goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"strings"
 	"testing"
 )
 
@@ -21,13 +21,11 @@ func TestDeleteInvalidID(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
-	if rr.Body.String() != `{"error": "Missconfigured ID."}` {
-		t.Errorf("handler returned unexpected body: got %v want empty body", rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "Missconfigured ID.")
 }
 
 func TestDeleteError(t *testing.T) {
@@ -41,13 +39,11 @@ func TestDeleteError(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
-	if strings.TrimSpace(rr.Body.String()) != `Internal Server error` {
-		t.Errorf("handler returned unexpected body: got %v want 'Internal Server error'", rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeInternal, "Internal server error.")
 }
 
 func TestDeleteNotFound(t *testing.T) {
@@ -60,13 +56,11 @@ func TestDeleteNotFound(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
-	if rr.Body.String() != `{"error": "Resource not found."}` {
-		t.Errorf("handler returned unexpected body: got %v want empty body", rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeNotFound, "Resource not found.")
 }
 
 func TestDeleteSuccessful(t *testing.T) {
@@ -79,7 +73,7 @@ func TestDeleteSuccessful(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusNoContent {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
 	}
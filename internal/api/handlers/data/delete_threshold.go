@@ -2,34 +2,45 @@ package data
 
 import (
 	"context"
-	"log"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
-	service "goapi/internal/api/service/data"
 )
 
 // DeleteThresholdHandler deletes a threshold by ID.
-func DeleteThresholdHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
-	// Get the ID from the URL
-	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+// curl -X DELETE http://127.0.0.1:8080/threshold/1 -i -u admin:password -H "Content-Type: application/json"
+func DeleteThresholdHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	// Get the ID from the URL path: /threshold/{id}
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		// If the ID is not valid, return a 400 Bad Request
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid ID parameter."}`))
+		problem.BadRequest(w, r, "Invalid ID parameter.")
+		return
+	}
+
+	// With If-Match the write only applies to the version it names, else it is answered with 412
+	ctx, ok := ifMatch(w, r)
+	if !ok {
 		return
 	}
 
 	// Set a context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
 	defer cancel()
 
 	// Call the service method to delete the threshold
-	_, err = ds.DeleteThreshold(id, ctx)
+	aff, err := ds.DeleteThreshold(id, ctx)
 	if err != nil {
 		// If the deletion fails, log and return an internal server error
-		logger.Println("Error deleting threshold:", err)
-		http.Error(w, "Internal Server error.", http.StatusInternalServerError)
+		problem.Respond(w, r, logger, err, "Error deleting threshold")
+		return
+	}
+	if aff == 0 {
+		// No threshold with the ID, or it belongs to another tenant
+		problem.NotFound(w, r)
 		return
 	}
 
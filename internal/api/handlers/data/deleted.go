@@ -0,0 +1,22 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"net/http"
+)
+
+// * includeDeleted applies the include_deleted=true query option, which is reserved for administrators *
+// * It writes a 403 response and returns false when a non-admin asks for soft-deleted rows *
+func includeDeleted(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	if r.URL.Query().Get("include_deleted") != "true" {
+		return r.Context(), true
+	}
+	if !auth.IsAdmin(r.Context()) {
+		problem.Forbidden(w, r, "include_deleted is only available to administrators.")
+		return nil, false
+	}
+	return models.WithDeleted(r.Context()), true
+}
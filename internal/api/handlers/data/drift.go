@@ -0,0 +1,50 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// * Without from, drift is reported over the readings of the last day *
+const defaultDriftWindow = 24 * time.Hour
+
+// * DriftHandler reports per device how far the clock of the device is off from the server's *
+// * from and to are RFC 3339 or Unix time, e.g. ?from=2024-01-01T00:00:00%2B02:00&to=1704153600
+// * curl -X GET http://127.0.0.1:8080/devices/drift -i -u admin:password -H "Content-Type: application/json"
+func DriftHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	from, err := models.ParseTimestamp(r.URL.Query().Get("from"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid from parameter, use RFC 3339 or Unix time.")
+		return
+	}
+	to, err := models.ParseTimestamp(r.URL.Query().Get("to"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid to parameter, use RFC 3339 or Unix time.")
+		return
+	}
+	if from.IsZero() {
+		from = models.NewTimestamp(time.Now().Add(-defaultDriftWindow))
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	drift, err := ds.ClockDrift(from.Time, to.Time, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Could not compute clock drift", "from", from, "to", to)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(drift); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding clock drift", "error", err)
+		return
+	}
+}
@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// * versionTag is the ETag of a stored reading or threshold, its version in quotes, e.g. "3" *
+func versionTag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// * ifMatch applies the If-Match header of a write, the returned context makes the write apply only to the version it names *
+// * It writes a 428 response and returns false when the header is missing but required by server.require_if_match *
+func ifMatch(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if config.RequireIfMatch(r.Context()) {
+			problem.Write(w, r, service.DataError{Code: service.CodePreconditionRequired, Message: "The If-Match header is required, send the ETag of the resource you change."})
+			return nil, false
+		}
+		return r.Context(), true
+	}
+	if header == "*" {
+		return r.Context(), true
+	}
+
+	// * A weak tag, a list or anything else that is not one of our tags matches no version, the write fails with 412 *
+	version := -1
+	if len(header) > 2 && header[0] == '"' && header[len(header)-1] == '"' {
+		if n, err := strconv.Atoi(header[1 : len(header)-1]); err == nil && n > 0 {
+			version = n
+		}
+	}
+	return models.WithExpectedVersion(r.Context(), version), true
+}
+
+// * notModified sets the ETag of a response and answers 304 when If-None-Match names it, weak tags match like strong ones *
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// * writeList answers a list, or anything else that is not one stored row, with an ETag hashed from its JSON *
+// * so a client polling an unchanged answer gets a 304 without a body *
+func writeList(w http.ResponseWriter, r *http.Request, logger *slog.Logger, list any) {
+	body, err := json.Marshal(list)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error encoding list")
+		return
+	}
+	sum := sha256.Sum256(body)
+	if notModified(w, r, `"`+hex.EncodeToString(sum[:8])+`"`) {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,163 @@
+package data_test
+
+import (
+	"context"
+	"goapi/internal/api/config"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func conditional(t *testing.T, handler func(http.ResponseWriter, *http.Request, *slog.Logger, service.DataService), ds service.DataService, method string, path string, id string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.SetPathValue("id", id) // * Required for routing *
+	rr := httptest.NewRecorder()
+	handler(rr, req, slog.Default(), ds)
+	return rr
+}
+
+func thresholdService(t *testing.T) service.DataService {
+	t.Helper()
+	ds := service.NewRepositoryDataService(nil, Memory.NewThresholdRepository())
+	if err := ds.CreateThreshold(&models.Threshold{SensorType: "Temperature", MinValue: 10, MaxValue: 20}, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return ds
+}
+
+// * A threshold is tagged with its version, a device polling with the tag gets a 304 until the threshold changes *
+func TestThresholdETag(t *testing.T) {
+	ds := thresholdService(t)
+
+	rr := conditional(t, data.GetThresholdByIDHandler, ds, "GET", "/threshold/1", "1", "", nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected 200 with ETag \"1\", got %v %q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	rr = conditional(t, data.GetThresholdByIDHandler, ds, "GET", "/threshold/1", "1", "", map[string]string{"If-None-Match": `W/"1"`})
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Fatalf("expected 304 without a body, got %v %s", rr.Code, rr.Body.String())
+	}
+
+	rr = conditional(t, data.UpdateThresholdHandler, ds, "PUT", "/threshold/1", "1", `{"sensor_type": "Temperature", "min_value": 15, "max_value": 25}`, map[string]string{"If-Match": `"1"`})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %v %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+
+	rr = conditional(t, data.GetThresholdByIDHandler, ds, "GET", "/threshold/1", "1", "", map[string]string{"If-None-Match": `"1"`})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %v %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+// * A write with a stale If-Match is refused with 412 and changes nothing *
+func TestThresholdIfMatchStale(t *testing.T) {
+	ds := thresholdService(t)
+
+	tests := []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request, *slog.Logger, service.DataService)
+		method  string
+		body    string
+		ifMatch string
+	}{
+		{"PUT", data.UpdateThresholdHandler, "PUT", `{"sensor_type": "Temperature", "min_value": 15, "max_value": 25}`, `"2"`},
+		{"PATCH", data.PatchThresholdHandler, "PATCH", `{"max_value": 30}`, `"2"`},
+		{"PATCH weak", data.PatchThresholdHandler, "PATCH", `{"max_value": 30}`, `W/"1"`},
+		{"DELETE", data.DeleteThresholdHandler, "DELETE", "", `"7"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := conditional(t, tt.handler, ds, tt.method, "/threshold/1", "1", tt.body, map[string]string{"If-Match": tt.ifMatch})
+			problemtest.Expect(t, rr, service.CodePreconditionFailed, "The resource has been changed since it was read.")
+		})
+	}
+
+	threshold, err := ds.ReadThreshold(1, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold == nil || threshold.Version != 1 || threshold.MaxValue != 20 {
+		t.Errorf("expected the threshold to be unchanged, got %+v", threshold)
+	}
+
+	// * A matching tag or * lets the write through *
+	rr := conditional(t, data.PatchThresholdHandler, ds, "PATCH", "/threshold/1", "1", `{"max_value": 30}`, map[string]string{"If-Match": `"1"`})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %v %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+	rr = conditional(t, data.DeleteThresholdHandler, ds, "DELETE", "/threshold/1", "1", "", map[string]string{"If-Match": "*"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %v: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// * A stale If-Match on a reading that does not exist is still a 404 *
+func TestIfMatchNotFound(t *testing.T) {
+	ds := service.NewRepositoryDataService(Memory.NewDataRepository(), nil)
+	rr := conditional(t, data.DeleteHandler, ds, "DELETE", "/data/1", "1", "", map[string]string{"If-Match": `"1"`})
+	problemtest.Expect(t, rr, service.CodeNotFound, "Resource not found.")
+}
+
+// * With server.require_if_match a write without If-Match is answered with 428 *
+func TestIfMatchRequired(t *testing.T) {
+	ds := service.NewRepositoryDataService(Memory.NewDataRepository(), nil)
+	stored := &models.Data{DeviceID: "dev1", TemperatureValue: 21.5, HumidityValue: 40, DateTime: models.NewTimestamp(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))}
+	if err := ds.Create(stored, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("PATCH", "/data/1", strings.NewReader(`{"temp_value": 22}`))
+	req = req.WithContext(config.WithRequest(req.Context(), config.Request{Timeout: time.Second, RequireIfMatch: true}))
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+	data.PatchHandler(rr, req, slog.Default(), ds)
+	problemtest.Expect(t, rr, service.CodePreconditionRequired, "The If-Match header is required, send the ETag of the resource you change.")
+
+	req = httptest.NewRequest("PATCH", "/data/1", strings.NewReader(`{"temp_value": 22}`))
+	req = req.WithContext(config.WithRequest(req.Context(), config.Request{Timeout: time.Second, RequireIfMatch: true}))
+	req.Header.Set("If-Match", `"1"`)
+	req.SetPathValue("id", "1")
+	rr = httptest.NewRecorder()
+	data.PatchHandler(rr, req, slog.Default(), ds)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %v %q: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+}
+
+// * A list is tagged with a hash of its content, so an unchanged list is answered with 304 *
+func TestListETag(t *testing.T) {
+	ds := thresholdService(t)
+
+	rr := conditional(t, data.GetThresholdHandler, ds, "GET", "/threshold", "", "", nil)
+	tag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || tag == "" {
+		t.Fatalf("expected 200 with an ETag, got %v %q", rr.Code, tag)
+	}
+
+	rr = conditional(t, data.GetThresholdHandler, ds, "GET", "/threshold", "", "", map[string]string{"If-None-Match": tag})
+	if rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %v", rr.Code)
+	}
+
+	if err := ds.CreateThreshold(&models.Threshold{SensorType: "Humidity", MinValue: 30, MaxValue: 60}, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rr = conditional(t, data.GetThresholdHandler, ds, "GET", "/threshold", "", "", map[string]string{"If-None-Match": tag})
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == tag {
+		t.Fatalf("expected 200 with a new ETag, got %v %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// ExportThresholdsHandler returns every threshold as a file that ImportThresholdsHandler takes back, JSON by default or CSV with ?format=csv or Accept: text/csv.
+// curl -X GET "http://127.0.0.1:8080/threshold/export?format=csv" -u admin:password -H "Content-Type: application/json" -o thresholds.csv
+func ExportThresholdsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	// The format query parameter takes precedence over the Accept header
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Accept"), middleware.CSVContentType) {
+			format = "csv"
+		}
+	}
+	if format != "json" && format != "csv" {
+		problem.BadRequest(w, r, "Invalid format, use json or csv.")
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	thresholds, err := ds.ExportThresholds(ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error exporting thresholds")
+		return
+	}
+	// An empty export is still a file that can be imported
+	if thresholds == nil {
+		thresholds = []*models.Threshold{}
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="thresholds.`+format+`"`)
+	if format == "csv" {
+		w.Header().Set("Content-Type", middleware.CSVContentType)
+		w.WriteHeader(http.StatusOK)
+		if err := writeThresholdsCSV(w, thresholds); err != nil {
+			logger.ErrorContext(r.Context(), "Error encoding thresholds", "error", err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(thresholds); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding thresholds", "error", err)
+		return
+	}
+}
@@ -2,17 +2,17 @@ package data
 
 import (
 	"context"
-	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // * The GET method retrieves all resources identified by a URI *
 // * curl -X GET http://127.0.0.1:8080/data -i -u admin:password -H "Content-Type: application/json"
-func GetHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
 	if err != nil {
 		if err == err.(*strconv.NumError) {
@@ -20,32 +20,38 @@ func GetHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds s
 			page = 0
 		} else {
 			// * Invalid page specified, return a 400 status code *
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Invalid page specified."}`))
+			problem.BadRequest(w, r, "Invalid page specified.")
 			return
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	preference, ok := outputUnits(w, r)
+	if !ok {
+		return
+	}
+
+	// * Administrators may include soft-deleted data with ?include_deleted=true *
+	ctx, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
 	defer cancel()
 
-	data, err := ds.ReadMany(page, 10, ctx)
+	data, err := ds.ReadMany(page, config.PageSize(ctx), ctx)
 	if err != nil {
-		//logger.Println("Could not get data:", err, data)
-		http.Error(w, "Internal Server error.", http.StatusInternalServerError)
+		problem.Respond(w, r, logger, err, "Could not get data", "page", page)
 		return
 	}
 	if len(data) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Resource not found."}`))
+		problem.NotFound(w, r)
 		return
 	}
 
-	// * Return the data to the user as JSON with a 200 OK status code
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		logger.Println("Error encoding data:", err, data)
-		http.Error(w, "Internal Server error.", http.StatusInternalServerError)
-		return
+	// * Return the data to the user as JSON with a 200 OK status code, or a 304 if the client has it already
+	for _, d := range data {
+		service.Express(d, preference)
 	}
+	writeList(w, r, logger, data)
 }
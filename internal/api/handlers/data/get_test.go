@@ -3,8 +3,9 @@ package data_test
 import (
 	"encoding/json"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -21,7 +22,7 @@ func TestGetHandlerSuccessful(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// * GetHanler should call the ReadMany method of the DataService *
-	data.GetHandler(rr, req, log.Default(), mockDataService)
+	data.GetHandler(rr, req, slog.Default(), mockDataService)
 	// * Response code should be 200 OK *
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
@@ -29,6 +30,9 @@ func TestGetHandlerSuccessful(t *testing.T) {
 
 	// * We know what the MockDataService will return, so we can compare the response body to the expected value *
 	data, _ := mockDataService.ReadMany(0, 10, nil)
+	for _, d := range data {
+		service.Express(d, nil)
+	}
 	expected, _ := json.Marshal(data)
 	if strings.TrimSpace(rr.Body.String()) != string(expected) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), string(expected))
@@ -44,15 +48,13 @@ func TestGetHandlerNotFound(t *testing.T) {
 	}
 	rr := httptest.NewRecorder()
 	// * GetHanler should call the ReadMany method of the DataService *
-	data.GetHandler(rr, req, log.Default(), mockDataService)
+	data.GetHandler(rr, req, slog.Default(), mockDataService)
 	// * Response code should be 404 Not Found *
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 	// * We know what the MockDataService will return, so we can compare the response body to the expected value *
-	if strings.TrimSpace(rr.Body.String()) != `{"error": "Resource not found."}` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `{"error": "Resource not found."}`)
-	}
+	problemtest.Expect(t, rr, service.CodeNotFound, "Resource not found.")
 }
 
 // * This ONLY test that the GetHandler returns the expected response code and body in case of an unsuccesfull (500) multiple resource retrieval without the use of a database and the page parameter *
@@ -67,13 +69,11 @@ func TestGetHandlerError(t *testing.T) {
 	}
 	rr := httptest.NewRecorder()
 
-	data.GetHandler(rr, req, log.Default(), mockDataService)
+	data.GetHandler(rr, req, slog.Default(), mockDataService)
 	// * Response code should be 500 Internal Server Error *
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 	// * We know what the MockDataService will return, so we can compare the response body to the expected value *
-	if strings.TrimSpace(rr.Body.String()) != `Internal Server error.` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `Internal Server error.`)
-	}
+	problemtest.Expect(t, rr, service.CodeInternal, "Internal server error.")
 }
@@ -2,16 +2,16 @@ package data
 
 import (
 	"context"
-	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // GetThresholdHandler retrieves a list of thresholds, supporting pagination.
-func GetThresholdHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+func GetThresholdHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	// Get the page number from the query parameters, default to 0 if not provided
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
 	if err != nil {
@@ -20,49 +20,45 @@ func GetThresholdHandler(w http.ResponseWriter, r *http.Request, logger *log.Log
 			page = 0
 		} else {
 			// If the page parameter is invalid, return a 400 Bad Request
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Invalid page specified."}`))
+			problem.BadRequest(w, r, "Invalid page specified.")
 			return
 		}
 	}
 
-	// Get the number of items per page (rowsPerPage), defaulting to 10
-	rowsPerPage := 10
+	// Get the number of items per page (rowsPerPage), defaulting to the configured page size
+	rowsPerPage := config.PageSize(r.Context())
 	if query := r.URL.Query().Get("rowsPerPage"); query != "" {
 		rowsPerPage, err = strconv.Atoi(query)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Invalid rowsPerPage specified."}`))
+			problem.BadRequest(w, r, "Invalid rowsPerPage specified.")
 			return
 		}
 	}
 
+	// Administrators may include soft-deleted thresholds with ?include_deleted=true
+	ctx, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
 	// Set a context with timeout to avoid blocking indefinitely
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
 	defer cancel()
 
 	// Fetch the list of thresholds with pagination using GetAllThresholds method
 	thresholds, err := ds.GetAllThresholds(page, rowsPerPage, ctx)
 	if err != nil {
 		// Log and return internal server error if data retrieval fails
-		logger.Println("Error retrieving thresholds:", err)
-		http.Error(w, "Internal Server error.", http.StatusInternalServerError)
+		problem.Respond(w, r, logger, err, "Error retrieving thresholds")
 		return
 	}
 
 	// If no thresholds are found, return a 404 Not Found
 	if len(thresholds) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "No thresholds found."}`))
+		problem.NotFound(w, r)
 		return
 	}
 
-	// Return the list of thresholds as a JSON response with a 200 OK status
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(thresholds); err != nil {
-		// Log and return an internal error if encoding fails
-		logger.Println("Error encoding thresholds:", err)
-		http.Error(w, "Internal Server error.", http.StatusInternalServerError)
-		return
-	}
+	// Return the list of thresholds as a JSON response with a 200 OK status, or a 304 if the client has it already
+	writeList(w, r, logger, thresholds)
 }
@@ -3,8 +3,9 @@ package data_test
 import (
 	"encoding/json"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -20,14 +21,12 @@ func TestGetByIDInvalidID(t *testing.T) {
 	req.SetPathValue("id", "invalid") // * Required for routing *
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	if strings.TrimSpace(rr.Body.String()) != `{"error": "Missconfigured ID."}` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `{"error": "Missconfigured ID."}`)
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "Missconfigured ID.")
 }
 
 func TestGetByIdInternalError(t *testing.T) {
@@ -40,14 +39,12 @@ func TestGetByIdInternalError(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 
-	if strings.TrimSpace(rr.Body.String()) != `Internal server error.` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `Internal server error.`)
-	}
+	problemtest.Expect(t, rr, service.CodeInternal, "Internal server error.")
 }
 
 func TestGetByIdNotFound(t *testing.T) {
@@ -60,14 +57,12 @@ func TestGetByIdNotFound(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 
-	if strings.TrimSpace(rr.Body.String()) != `{"error": "Resource not found."}` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `{"error": "Resource not found."}`)
-	}
+	problemtest.Expect(t, rr, service.CodeNotFound, "Resource not found.")
 }
 
 func TestGetByIdSuccessful(t *testing.T) {
@@ -80,12 +75,13 @@ func TestGetByIdSuccessful(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
 	data, _ := mockDataService.ReadOne(1, nil)
+	service.Express(data, nil)
 	expected, _ := json.Marshal(data)
 
 	if strings.TrimSpace(rr.Body.String()) != string(expected) {
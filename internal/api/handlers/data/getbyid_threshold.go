@@ -0,0 +1,52 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GetThresholdByIDHandler retrieves a single threshold by ID.
+// curl -X GET http://127.0.0.1:8080/threshold/1 -i -u admin:password -H "Content-Type: application/json"
+func GetThresholdByIDHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid ID parameter.")
+		return
+	}
+
+	// Administrators may read a soft-deleted threshold with ?include_deleted=true
+	ctx, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
+	defer cancel()
+
+	threshold, err := ds.ReadThreshold(id, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error retrieving threshold", "id", id)
+		return
+	}
+	if threshold == nil {
+		problem.NotFound(w, r)
+		return
+	}
+
+	// Devices polling their threshold send its ETag in If-None-Match and get a 304 while it is unchanged
+	if notModified(w, r, versionTag(threshold.Version)) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(threshold); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding threshold", "error", err)
+		return
+	}
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// ThresholdHistoryHandler returns every recorded change of a threshold, oldest version first.
+// curl -X GET http://127.0.0.1:8080/threshold/1/history -i -u admin:password -H "Content-Type: application/json"
+func ThresholdHistoryHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid ID parameter.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	history, err := ds.ThresholdHistory(id, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error retrieving threshold history", "id", id)
+		return
+	}
+
+	// An unknown threshold has no history
+	if len(history) == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding threshold history", "error", err)
+		return
+	}
+}
+
+// RollbackRequest selects the history version to restore.
+type RollbackRequest struct {
+	Version int `json:"version"`
+}
+
+// RollbackThresholdHandler restores a threshold to the values it had after the given version.
+// The rollback itself is recorded as a new version.
+// curl -X POST http://127.0.0.1:8080/threshold/1/rollback -i -u admin:password -H "Content-Type: application/json" -d '{"version": 1}'
+func RollbackThresholdHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid ID parameter.")
+		return
+	}
+
+	var req RollbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version < 1 {
+		problem.BadRequest(w, r, "Invalid JSON body.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	threshold, err := ds.RollbackThreshold(id, req.Version, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error rolling back threshold", "id", id, "version", req.Version)
+		return
+	}
+	if threshold == nil {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(threshold); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding threshold", "error", err)
+		return
+	}
+}
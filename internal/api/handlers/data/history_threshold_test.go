@@ -0,0 +1,74 @@
+package data_test
+
+import (
+	"goapi/internal/api/handlers/data"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestThresholdHistoryNotFound(t *testing.T) {
+	req, err := http.NewRequest("GET", "/threshold/1/history", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	rr := httptest.NewRecorder()
+
+	data.ThresholdHistoryHandler(rr, req, slog.Default(), &service.MockDataServiceNotFound{})
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestThresholdHistorySuccessful(t *testing.T) {
+	req, err := http.NewRequest("GET", "/threshold/1/history", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	rr := httptest.NewRecorder()
+
+	data.ThresholdHistoryHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `"version":2,"action":"update","changed_by":"user1"`) {
+		t.Errorf("handler returned unexpected body: got %v", rr.Body.String())
+	}
+}
+
+func TestRollbackThresholdInvalidVersion(t *testing.T) {
+	req, err := http.NewRequest("POST", "/threshold/1/rollback", strings.NewReader(`{"version": 0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	rr := httptest.NewRecorder()
+
+	data.RollbackThresholdHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestRollbackThresholdSuccessful(t *testing.T) {
+	req, err := http.NewRequest("POST", "/threshold/1/rollback", strings.NewReader(`{"version": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	rr := httptest.NewRecorder()
+
+	data.RollbackThresholdHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	expected := `{"id":1,"sensor_type":"Temperature","min_value":10,"max_value":50,"updated_at":""}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
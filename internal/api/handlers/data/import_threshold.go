@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ImportThresholdsHandler writes the thresholds of a JSON array or CSV file in one transaction and reports the changes.
+// ?mode= is create-only (the default), upsert or replace-all, ?dry_run=true reports the changes without writing them.
+// curl -X POST "http://127.0.0.1:8080/threshold/import?mode=upsert&dry_run=true" -u admin:password -H "Content-Type: text/csv" --data-binary @thresholds.csv
+func ImportThresholdsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = service.ImportCreateOnly
+	}
+	dryRun := false
+	if query := r.URL.Query().Get("dry_run"); query != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(query); err != nil {
+			problem.BadRequest(w, r, "Invalid dry_run, use true or false.")
+			return
+		}
+	}
+
+	// The file is CSV or, like everywhere else, JSON
+	var thresholds []*models.Threshold
+	if strings.HasPrefix(r.Header.Get("Content-Type"), middleware.CSVContentType) {
+		var err error
+		if thresholds, err = readThresholdsCSV(r.Body); err != nil {
+			problem.Respond(w, r, logger, err, "Error reading thresholds")
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&thresholds); err != nil {
+		problem.BadRequest(w, r, "Invalid JSON body, send an array of thresholds.")
+		return
+	}
+	if len(thresholds) == 0 {
+		problem.BadRequest(w, r, "The file contains no thresholds.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	report, err := ds.ImportThresholds(thresholds, mode, dryRun, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error importing thresholds", "mode", mode)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding import report", "error", err)
+		return
+	}
+}
@@ -0,0 +1,144 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func importThresholds(t *testing.T, ds service.DataService, query string, contentType string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/threshold/import?"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	data.ImportThresholdsHandler(rr, req, slog.Default(), ds)
+	return rr
+}
+
+func importReport(t *testing.T, rr *httptest.ResponseRecorder) models.ThresholdImportReport {
+	t.Helper()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var report models.ThresholdImportReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+// * An export in CSV can be imported again and changes nothing *
+func TestExportImportThresholdsCSV(t *testing.T) {
+	ds := service.NewRepositoryDataService(nil, Memory.NewThresholdRepository())
+	for _, threshold := range []*models.Threshold{
+		{SensorType: "Temperature", MinValue: 10, MaxValue: 20, Unit: "F"},
+		{SensorType: "Humidity", DeviceID: "dev1", MinValue: 30, MaxValue: 60},
+	} {
+		if err := ds.CreateThreshold(threshold, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/threshold/export", nil)
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	data.ExportThresholdsHandler(rr, req, slog.Default(), ds)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected a CSV file, got %v %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "id,sensor_type,device_id,min_value,max_value,unit,updated_at,version" || !strings.HasPrefix(lines[2], "2,Humidity,dev1,30,60,%,") {
+		t.Fatalf("unexpected export %q", lines)
+	}
+
+	report := importReport(t, importThresholds(t, ds, "mode=upsert", "text/csv", rr.Body.String()))
+	if report.Unchanged != 2 || len(report.Changes) != 0 {
+		t.Errorf("expected the export to import without changes, got %+v", report)
+	}
+}
+
+// * Modes decide what happens to thresholds that exist, a dry run reports the changes without writing them *
+func TestImportThresholdsModes(t *testing.T) {
+	ds := service.NewRepositoryDataService(nil, Memory.NewThresholdRepository())
+	if err := ds.CreateThreshold(&models.Threshold{SensorType: "Temperature", MinValue: 10, MaxValue: 20}, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.CreateThreshold(&models.Threshold{SensorType: "Pressure", MinValue: 900, MaxValue: 1100}, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	file := `[{"sensor_type": "temperature", "min_value": 15, "max_value": 25}, {"sensor_type": "Humidity", "min_value": 30, "max_value": 60}]`
+
+	rr := importThresholds(t, ds, "", "application/json", file)
+	problemtest.Expect(t, rr, service.CodeConflict, "Thresholds already exist, import them with mode upsert or replace-all.")
+
+	report := importReport(t, importThresholds(t, ds, "mode=replace-all&dry_run=true", "application/json", file))
+	if !report.DryRun || report.Created != 1 || report.Updated != 1 || report.Deleted != 1 || report.Changes[0].Before.ID != 1 {
+		t.Errorf("unexpected dry run %+v", report)
+	}
+	if thresholds, _ := ds.ExportThresholds(context.Background()); len(thresholds) != 2 || thresholds[0].MaxValue != 20 {
+		t.Fatalf("expected the dry run to write nothing, got %+v", thresholds)
+	}
+
+	report = importReport(t, importThresholds(t, ds, "mode=upsert", "application/json", file))
+	if report.Created != 1 || report.Updated != 1 || report.Deleted != 0 {
+		t.Errorf("unexpected upsert %+v", report)
+	}
+	report = importReport(t, importThresholds(t, ds, "mode=replace-all", "application/json", file))
+	if report.Unchanged != 2 || report.Deleted != 1 || report.Changes[0].Before.SensorType != "Pressure" {
+		t.Errorf("unexpected replace %+v", report)
+	}
+
+	thresholds, err := ds.ExportThresholds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thresholds) != 2 || thresholds[0].SensorType != "temperature" || thresholds[0].MaxValue != 25 || thresholds[1].SensorType != "Humidity" {
+		t.Errorf("expected the thresholds of the file, got %+v", thresholds)
+	}
+	history, err := ds.ThresholdHistory(1, context.Background())
+	if err != nil || len(history) != 2 || history[1].Action != models.ThresholdUpdated {
+		t.Errorf("expected the import to be recorded in the history, got %+v, %v", history, err)
+	}
+}
+
+// * Nothing is written when one threshold of the file is invalid *
+func TestImportThresholdsInvalid(t *testing.T) {
+	ds := service.NewRepositoryDataService(nil, Memory.NewThresholdRepository())
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		code        service.ErrorCode
+		detail      string
+	}{
+		{"mode", "mode=merge", "application/json", `[{"sensor_type": "Temperature", "min_value": 10, "max_value": 20}]`, service.CodeInvalidRequest, "Invalid mode, use create-only, upsert or replace-all."},
+		{"dry run", "dry_run=maybe", "application/json", `[]`, service.CodeInvalidRequest, "Invalid dry_run, use true or false."},
+		{"empty", "", "application/json", `[]`, service.CodeInvalidRequest, "The file contains no thresholds."},
+		{"not an array", "", "application/json", `{"sensor_type": "Temperature"}`, service.CodeInvalidRequest, "Invalid JSON body, send an array of thresholds."},
+		{"limits", "", "application/json", `[{"sensor_type": "Temperature", "min_value": 10, "max_value": 20}, {"sensor_type": "Humidity", "min_value": 60, "max_value": 30}]`, service.CodeInvalidData, "Invalid thresholds."},
+		{"duplicate", "", "text/csv", "sensor_type,min_value,max_value\nTemperature,10,20\ntemperature,15,25\n", service.CodeInvalidData, "Invalid thresholds."},
+		{"number", "", "text/csv", "sensor_type,min_value,max_value\nTemperature,ten,20\n", service.CodeInvalidData, "Invalid thresholds."},
+		{"header", "", "text/csv", "sensor_type,min\nTemperature,10\n", service.CodeInvalidRequest, "Invalid CSV header."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := importThresholds(t, ds, tt.query, tt.contentType, tt.body)
+			problemtest.Expect(t, rr, tt.code, tt.detail)
+		})
+	}
+
+	if thresholds, err := ds.ExportThresholds(context.Background()); err != nil || len(thresholds) != 0 {
+		t.Errorf("expected nothing to be written, got %+v, %v", thresholds, err)
+	}
+}
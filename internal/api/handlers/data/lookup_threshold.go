@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// ThresholdByTypeHandler returns the threshold of the sensor type in the URL that applies to ?device_id=, the device's own or else the one of every device.
+// curl -X GET "http://127.0.0.1:8080/threshold/by-type/temperature?device_id=device1" -i -u admin:password -H "Content-Type: application/json"
+func ThresholdByTypeHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	sensorType := r.PathValue("sensor_type")
+	deviceID := r.URL.Query().Get("device_id")
+	if !ownDevice(w, r, deviceID) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	threshold, err := ds.ThresholdByType(sensorType, deviceID, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error retrieving threshold", "sensor_type", sensorType, "device_id", deviceID)
+		return
+	}
+	if threshold == nil {
+		problem.NotFound(w, r)
+		return
+	}
+
+	// The threshold that applies can change from the one of every device to the device's own, so the tag is a hash and not the version
+	cacheThresholds(w, r)
+	writeList(w, r, logger, threshold)
+}
+
+// DeviceThresholdsHandler returns every threshold that applies to the device in the URL in a compact form, keyed by sensor type.
+// Devices may cache the answer for server.threshold_max_age and then revalidate it with its ETag.
+// curl -X GET http://127.0.0.1:8080/devices/device1/thresholds -i -u admin:password -H "Content-Type: application/json"
+func DeviceThresholdsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	deviceID := r.PathValue("device_id")
+	if !ownDevice(w, r, deviceID) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	thresholds, err := ds.DeviceThresholds(deviceID, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error retrieving thresholds", "device_id", deviceID)
+		return
+	}
+
+	cacheThresholds(w, r)
+	writeList(w, r, logger, thresholds)
+}
+
+// * ownDevice answers 403 when a device authenticated by its client certificate asks for the thresholds of another device *
+func ownDevice(w http.ResponseWriter, r *http.Request, deviceID string) bool {
+	if device, ok := auth.Device(r.Context()); ok && deviceID != device {
+		problem.Forbidden(w, r, "Forbidden: the client certificate is not registered for this device_id.")
+		return false
+	}
+	return true
+}
+
+// * cacheThresholds lets clients reuse thresholds for server.threshold_max_age, they are private to the authenticated caller *
+func cacheThresholds(w http.ResponseWriter, r *http.Request) {
+	maxAge := int(config.ThresholdMaxAge(r.Context()).Seconds())
+	if maxAge <= 0 {
+		w.Header().Set("Cache-Control", "private, no-cache")
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+}
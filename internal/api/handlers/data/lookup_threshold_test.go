@@ -0,0 +1,126 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/config"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func lookupService(t *testing.T) service.DataService {
+	t.Helper()
+	ds := thresholdService(t)
+	for _, threshold := range []*models.Threshold{
+		{SensorType: "temperature", DeviceID: "dev1", MinValue: 5, MaxValue: 25},
+		{SensorType: "Humidity", MinValue: 30, MaxValue: 60},
+		{SensorType: "pressure", DeviceID: "dev2", MinValue: 900, MaxValue: 1100},
+	} {
+		if err := ds.CreateThreshold(threshold, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ds
+}
+
+// * A device gets its own threshold of a sensor type, other devices the one of every device *
+func TestThresholdByType(t *testing.T) {
+	ds := lookupService(t)
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"?device_id=dev1", 2},
+		{"?device_id=dev2", 1},
+		{"", 1},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/threshold/by-type/TEMPERATURE"+tt.query, nil)
+		req.SetPathValue("sensor_type", "TEMPERATURE") // * Required for routing *
+		rr := httptest.NewRecorder()
+		data.ThresholdByTypeHandler(rr, req, slog.Default(), ds)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v", tt.query, rr.Code, http.StatusOK)
+		}
+		var threshold models.Threshold
+		if err := json.Unmarshal(rr.Body.Bytes(), &threshold); err != nil {
+			t.Fatal(err)
+		}
+		if threshold.ID != tt.expected {
+			t.Errorf("%s: expected threshold %d, got %+v", tt.query, tt.expected, threshold)
+		}
+		if rr.Header().Get("Cache-Control") != "private, max-age=300" || rr.Header().Get("ETag") == "" {
+			t.Errorf("%s: expected a cacheable answer, got %v", tt.query, rr.Header())
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/threshold/by-type/pressure", nil)
+	req.SetPathValue("sensor_type", "pressure")
+	rr := httptest.NewRecorder()
+	data.ThresholdByTypeHandler(rr, req, slog.Default(), ds)
+	problemtest.Expect(t, rr, service.CodeNotFound, "Resource not found.")
+}
+
+// * The compact thresholds of a device can be cached and revalidated with their ETag *
+func TestDeviceThresholds(t *testing.T) {
+	ds := lookupService(t)
+	get := func(deviceID string, ctx context.Context, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/devices/"+deviceID+"/thresholds", nil).WithContext(ctx)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		req.SetPathValue("device_id", deviceID) // * Required for routing *
+		rr := httptest.NewRecorder()
+		data.DeviceThresholdsHandler(rr, req, slog.Default(), ds)
+		return rr
+	}
+
+	rr := get("dev1", context.Background(), nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var compact models.DeviceThresholds
+	if err := json.Unmarshal(rr.Body.Bytes(), &compact); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]models.Limits{"temperature": {Min: 5, Max: 25, Unit: "C"}, "humidity": {Min: 30, Max: 60, Unit: "%"}}
+	if compact.DeviceID != "dev1" || len(compact.Thresholds) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, compact)
+	}
+	for sensorType, limits := range expected {
+		if compact.Thresholds[sensorType] != limits {
+			t.Errorf("expected %s to be %+v, got %+v", sensorType, limits, compact.Thresholds[sensorType])
+		}
+	}
+
+	// * A revalidation gets a 304 that may be cached again, until a threshold changes
+	tag := rr.Header().Get("ETag")
+	ctx := config.WithRequest(context.Background(), config.Request{ThresholdMaxAge: time.Minute})
+	rr = get("dev1", ctx, map[string]string{"If-None-Match": tag})
+	if rr.Code != http.StatusNotModified || rr.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Errorf("expected a cacheable 304, got %v %v", rr.Code, rr.Header())
+	}
+	if _, err := ds.UpsertThreshold(&models.Threshold{SensorType: "humidity", MinValue: 35, MaxValue: 60}, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx = config.WithRequest(context.Background(), config.Request{})
+	if rr = get("dev1", ctx, map[string]string{"If-None-Match": tag}); rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("expected the changed thresholds, got %v %v", rr.Code, rr.Header())
+	}
+
+	// * A device authenticated by its certificate only reads its own thresholds
+	device := auth.WithPrincipal(context.Background(), auth.Principal{Username: "device:dev1", Device: "dev1"})
+	problemtest.Expect(t, get("dev2", device, nil), service.CodeForbidden, "Forbidden: the client certificate is not registered for this device_id.")
+	if rr := get("dev1", device, nil); rr.Code != http.StatusOK {
+		t.Errorf("expected the device to read its thresholds, got %v", rr.Code)
+	}
+}
@@ -7,7 +7,8 @@ import "net/http"
 func OptionsHandler(w http.ResponseWriter, r *http.Request) {
 	// Preflight request: server returns a 200 OK status code and the allowed methods and headers in the response headers.
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
 	w.WriteHeader(http.StatusOK)
 }
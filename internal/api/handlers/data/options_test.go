@@ -24,12 +24,12 @@ func TestOptions(t *testing.T) {
 		t.Errorf("handler returned unexpected header: got %v want %v", rr.Header().Get("Access-Control-Allow-Origin"), "*")
 	}
 
-	if rr.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE" {
-		t.Errorf("handler returned unexpected header: got %v want %v", rr.Header().Get("Access-Control-Allow-Methods"), "GET, POST, PUT, DELETE")
+	if rr.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, PATCH, DELETE" {
+		t.Errorf("handler returned unexpected header: got %v want %v", rr.Header().Get("Access-Control-Allow-Methods"), "GET, POST, PUT, PATCH, DELETE")
 	}
 
-	if rr.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" {
-		t.Errorf("handler returned unexpected header: got %v want %v", rr.Header().Get("Access-Control-Allow-Headers"), "Content-Type, Authorization")
+	if rr.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization, If-Match, If-None-Match" {
+		t.Errorf("handler returned unexpected header: got %v want %v", rr.Header().Get("Access-Control-Allow-Headers"), "Content-Type, Authorization, If-Match, If-None-Match")
 	}
 
 	if rr.Body.String() != "" {
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * PATCH changes only the fields that are sent, as a JSON merge patch (RFC 7396): a member replaces the field and null clears it *
+// * curl -X PATCH http://127.0.0.1:8080/data/1 -i -u admin:password -H "Content-Type: application/merge-patch+json" -d '{"temp_value": 22.5, "device_name": null}'
+func PatchHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		// * This is a User Error: format of id is invalid, response in JSON and with a 400 status code
+		problem.BadRequest(w, r, "Missconfigured ID.")
+		return
+	}
+
+	preference, ok := outputUnits(w, r)
+	if !ok {
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// * With If-Match the write only applies to the version it names, else it is answered with 412 *
+	ctx, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
+	defer cancel()
+
+	// * The reading is read, patched, validated and written back in one transaction
+	data, err := ds.Patch(id, patch, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error patching data", "id", id)
+		return
+	}
+	if data == nil {
+		// * This is a User Error, response in JSON and with a 404 status code
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", versionTag(data.Version))
+	service.Express(data, preference)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err, "data", data)
+		return
+	}
+}
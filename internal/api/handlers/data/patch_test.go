@@ -0,0 +1,149 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func patch(t *testing.T, handler func(http.ResponseWriter, *http.Request, *slog.Logger, service.DataService), ds service.DataService, path string, id string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("PATCH", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.SetPathValue("id", id) // * Required for routing *
+	rr := httptest.NewRecorder()
+	handler(rr, req, slog.Default(), ds)
+	return rr
+}
+
+// * Fields that are not in the patch keep their values, null clears a field *
+func TestPatchData(t *testing.T) {
+	ds := service.NewRepositoryDataService(Memory.NewDataRepository(), nil)
+	stored := &models.Data{DeviceID: "dev1", DeviceName: "Device 1", TemperatureValue: 21.5, HumidityValue: 40, Type: "sensor",
+		DateTime: models.NewTimestamp(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))}
+	if err := ds.Create(stored, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := patch(t, data.PatchHandler, ds, "/data/1?units=F", "1", `{"temp_value": 77, "temp_unit": "F", "device_name": null}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var patched models.Data
+	if err := json.Unmarshal(rr.Body.Bytes(), &patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.TemperatureValue != 77 || patched.TemperatureUnit != "F" || patched.DeviceName != "" || patched.HumidityValue != 40 ||
+		patched.DeviceID != "dev1" || !patched.ReceivedAt.Equal(stored.ReceivedAt.Time) {
+		t.Errorf("unexpected reading %+v", patched)
+	}
+
+	read, err := ds.ReadOne(1, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.TemperatureValue != 25 || read.DeviceName != "" {
+		t.Errorf("expected 25 C to be stored without a device name, got %+v", read)
+	}
+}
+
+// * The merged reading is validated as a whole and nothing is written when it is invalid *
+func TestPatchDataInvalid(t *testing.T) {
+	ds := service.NewRepositoryDataService(Memory.NewDataRepository(), nil)
+	stored := &models.Data{DeviceID: "dev1", TemperatureValue: 21.5, HumidityValue: 40, DateTime: models.NewTimestamp(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))}
+	if err := ds.Create(stored, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body   string
+		code   service.ErrorCode
+		detail string
+	}{
+		{`{"humi_value": 140}`, service.CodeInvalidData, "Invalid data."},
+		{`{"temp_value": "warm"}`, service.CodeInvalidData, "Invalid patch."},
+		{`{"id": 2}`, service.CodeInvalidData, "Invalid patch."},
+		{`[{"op": "replace"}]`, service.CodeInvalidRequest, "The patch must be a JSON object."},
+		{`null`, service.CodeInvalidRequest, "The patch must be a JSON object."},
+	}
+	for _, tt := range tests {
+		rr := patch(t, data.PatchHandler, ds, "/data/1", "1", tt.body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: handler returned wrong status code: got %v want %v", tt.body, rr.Code, http.StatusBadRequest)
+		}
+		problemtest.Expect(t, rr, tt.code, tt.detail)
+	}
+
+	read, err := ds.ReadOne(1, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.HumidityValue != 40 || read.TemperatureValue != 21.5 {
+		t.Errorf("expected the reading to be unchanged, got %+v", read)
+	}
+}
+
+func TestPatchDataNotFound(t *testing.T) {
+	rr := patch(t, data.PatchHandler, &service.MockDataServiceNotFound{}, "/data/1", "1", `{"temp_value": 20}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+
+	rr = patch(t, data.PatchHandler, &service.MockDataServiceNotFound{}, "/data/x", "x", `{"temp_value": 20}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+// * A patched threshold keeps the fields that were not sent and records the change in its history *
+func TestPatchThreshold(t *testing.T) {
+	ds := service.NewRepositoryDataService(nil, Memory.NewThresholdRepository())
+	threshold := &models.Threshold{SensorType: "Temperature", MinValue: 10, MaxValue: 20}
+	if err := ds.CreateThreshold(threshold, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := patch(t, data.PatchThresholdHandler, ds, "/threshold/1", "1", `{"max_value": 30}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var patched models.Threshold
+	if err := json.Unmarshal(rr.Body.Bytes(), &patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.SensorType != "Temperature" || patched.MinValue != 10 || patched.MaxValue != 30 || patched.Unit != "C" {
+		t.Errorf("unexpected threshold %+v", patched)
+	}
+
+	// * An omitted max_value is kept, so only a min_value above it is rejected
+	rr = patch(t, data.PatchThresholdHandler, ds, "/threshold/1", "1", `{"min_value": 40}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	problemtest.Expect(t, rr, service.CodeInvalidData, "MinValue must be less than MaxValue")
+
+	history, err := ds.ThresholdHistory(1, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[1].Action != models.ThresholdUpdated || history[1].Before.MaxValue != 20 || history[1].After.MaxValue != 30 {
+		t.Errorf("expected the creation and one update, got %+v", history)
+	}
+}
+
+func TestPatchThresholdNotFound(t *testing.T) {
+	rr := patch(t, data.PatchThresholdHandler, &service.MockDataServiceNotFound{}, "/threshold/1", "1", `{"max_value": 30}`)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
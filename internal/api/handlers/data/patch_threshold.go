@@ -0,0 +1,55 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// PatchThresholdHandler changes the fields of a threshold that are sent as a JSON merge patch and returns the threshold.
+// curl -X PATCH http://127.0.0.1:8080/threshold/1 -i -u admin:password -H "Content-Type: application/merge-patch+json" -d '{"max_value": 30}'
+func PatchThresholdHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid ID parameter.")
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid JSON body.")
+		return
+	}
+
+	// With If-Match the write only applies to the version it names, else it is answered with 412
+	ctx, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
+	defer cancel()
+
+	// Fields that are not in the patch keep their stored values
+	threshold, err := ds.PatchThreshold(id, patch, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error patching threshold", "id", id)
+		return
+	}
+	if threshold == nil {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", versionTag(threshold.Version))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(threshold); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding threshold", "error", err)
+		return
+	}
+}
@@ -3,51 +3,53 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 // * User sends a POST request to /data with a JSON payload in the request body *
-// * curl -X POST http://127.0.0.1:8080/data -i -u admin:password -H "Content-Type: application/json" -d '{"device_id": "device1", "device_name": "device1", "value": 1.0, "type": "type1", "date_time": "2021-01-01T00:00:00Z", "description": "description1"}'
-func PostHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+// * curl -X POST http://127.0.0.1:8080/data -i -u admin:password -H "Content-Type: application/json" -d '{"device_id": "device1", "device_name": "device1", "temp_value": 21.5, "humi_value": 40, "type": "temperature", "date_time": "2021-01-01T00:00:00Z"}'
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	var data models.Data
 
+	preference, ok := outputUnits(w, r)
+	if !ok {
+		return
+	}
+
 	// * Decode the JSON payload from the request body into the data struct
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 
 		// * This is a User Error: format of body is invalid, response in JSON and with a 400 status code
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		problem.BadRequest(w, r, "Invalid request data. Please check your input.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	// * A device authenticated by its client certificate may only post its own readings
+	if device, ok := auth.Device(r.Context()); ok && data.DeviceID != device {
+		problem.Forbidden(w, r, "Forbidden: the client certificate is not registered for this device_id.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
 	defer cancel()
 
 	// * Try to create the data in the database
 	if err := ds.Create(&data, ctx); err != nil {
-		switch err.(type) {
-		case service.DataError:
-			// * If the error is a DataError, handle it as a client error
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
-			return
-		default:
-			// * If it is not a DataError, handle it as a server error
-			logger.Println("Error creating data:", err, data)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
-			return
-		}
+		problem.Respond(w, r, logger, err, "Error creating data", "data", data)
+		return
 	}
 
 	// * Return the data to the user as JSON with a 201 Created status code
+	service.Express(&data, preference)
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		logger.Println("Error encoding data:", err, data)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err, "data", data)
 		return
 	}
 }
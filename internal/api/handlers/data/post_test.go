@@ -2,15 +2,18 @@ package data_test
 
 import (
 	"encoding/json"
+	"goapi/internal/api/auth"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPostInvalidRequestBody(t *testing.T) {
@@ -22,16 +25,13 @@ func TestPostInvalidRequestBody(t *testing.T) {
 
 	req.Body = io.NopCloser(strings.NewReader(`Plain text, not JSON`))
 	rr := httptest.NewRecorder()
-	data.PostHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Invalid request data. Please check your input."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "Invalid request data. Please check your input.")
 }
 
 func TestPostErrorCreatingData(t *testing.T) {
@@ -48,23 +48,20 @@ func TestPostErrorCreatingData(t *testing.T) {
 		TemperatureValue:       10.0,
 		HumidityValue: 10.0,
 		Type:        "type1",
-		DateTime:    "2021-01-01 00:00:00",
+		DateTime:    models.NewTimestamp(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
 
 	})
 
 	req.Body = io.NopCloser(strings.NewReader(string(dataJSON)))
 	rr := httptest.NewRecorder()
 
-	data.PostHandler(rr, req, log.Default(), &service.MockDataServiceError{})
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceError{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Error creating data."}` // * This message is passed from the MockDataServiceError
-	if strings.TrimSpace(rr.Body.String()) != expected {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidData, "Error creating data.")
 }
 func TestPostSuccessful(t *testing.T) {
 	req, err := http.NewRequest("POST", "/data", nil)
@@ -78,19 +75,50 @@ func TestPostSuccessful(t *testing.T) {
 		TemperatureValue: 10.0,  
 		HumidityValue:    10.0,  
 		Type:             "type1",
-		DateTime:         "2021-01-01 00:00:00",
+		DateTime:         models.NewTimestamp(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
 	})
 	req.Body = io.NopCloser(strings.NewReader(string(dataJSON)))
 	rr := httptest.NewRecorder()
-	data.PostHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 	// Check the status code is HTTP 201 Created
 	if status := rr.Code; status != http.StatusCreated {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
 	}
-	expected := `{"id":1,"device_id":"device1","device_name":"device1","temp_value":10,"humi_value":10,"type":"type1","date_time":"2021-01-01 00:00:00"}`
+	expected := `{"id":1,"device_id":"device1","device_name":"device1","temp_value":10,"humi_value":10,"temp_unit":"C","humi_unit":"%","type":"type1","date_time":"2021-01-01T00:00:00Z","received_at":null}`
 
 	// Check if the response body matches the expected output
 	if strings.TrimSpace(rr.Body.String()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
+
+func TestPostDeviceMismatch(t *testing.T) {
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(`{"device_id": "device2", "type": "type1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// * The caller authenticated with the client certificate of device1
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Username: "device:device1", Device: "device1"}))
+	rr := httptest.NewRecorder()
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+}
+
+func TestPostDeviceMatch(t *testing.T) {
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(`{"device_id": "device1", "type": "type1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Username: "device:device1", Device: "device1"}))
+	rr := httptest.NewRecorder()
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+}
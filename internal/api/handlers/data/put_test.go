@@ -3,14 +3,16 @@ package data_test
 import (
 	"encoding/json"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestPutInvalidRequestBody(t *testing.T) {
@@ -22,16 +24,13 @@ func TestPutInvalidRequestBody(t *testing.T) {
 
 	req.Body = io.NopCloser(strings.NewReader(`Plain text, not JSON`))
 	rr := httptest.NewRecorder()
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Invalid request data. Please check your input."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "Invalid request data. Please check your input.")
 }
 
 func TestPutHandlerError(t *testing.T) {
@@ -42,16 +41,13 @@ func TestPutHandlerError(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceError{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceError{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Error updating data."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidData, "Error updating data.")
 }
 
 func TestPutDataNotFound(t *testing.T) {
@@ -70,16 +66,13 @@ func TestPutDataNotFound(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceNotFound{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceNotFound{})
 
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 
-	expected := `{"error": "Resource not found."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
-	}
+	problemtest.Expect(t, rr, service.CodeNotFound, "Resource not found.")
 }
 
 func TestPutHandlerSuccess(t *testing.T) {
@@ -96,7 +89,7 @@ func TestPutHandlerSuccess(t *testing.T) {
 		TemperatureValue: 10.0,  // Keep as float in input
 		HumidityValue:    10.0,  // Keep as float in input
 		Type:             "type",
-		DateTime:         "2020-01-01T00:00:00Z",
+		DateTime:         models.NewTimestamp(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)),
 	})
 
 	req.Body = io.NopCloser(strings.NewReader(string(dataJSON)))
@@ -104,7 +97,7 @@ func TestPutHandlerSuccess(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Call the handler
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 
 	// Check for the right status code
 	if status := rr.Code; status != http.StatusOK {
@@ -112,7 +105,7 @@ func TestPutHandlerSuccess(t *testing.T) {
 	}
 
 	// Modify the expected to use integers for temp_value and humi_value
-	expected := `{"id":1,"device_id":"device_id","device_name":"device_name","temp_value":10,"humi_value":10,"type":"type","date_time":"2020-01-01T00:00:00Z"}`
+	expected := `{"id":1,"device_id":"device_id","device_name":"device_name","temp_value":10,"humi_value":10,"temp_unit":"C","humi_unit":"%","type":"type","date_time":"2020-01-01T00:00:00Z","received_at":null}`
 
 	// Check if the response body matches the expected output
 	if strings.TrimSpace(rr.Body.String()) != expected {
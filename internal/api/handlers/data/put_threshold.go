@@ -3,42 +3,41 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 )
 
-func UpdateThresholdHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
-	// Get the ID from the URL path
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 { // Expecting "/threshold/{id}" in URL
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid URL structure."}`))
+// UpdateThresholdHandler replaces the values of the threshold identified by the URL.
+// curl -X PUT http://127.0.0.1:8080/threshold/1 -i -u admin:password -H "Content-Type: application/json" -d '{"sensor_type": "temperature", "min_value": 18, "max_value": 28}'
+func UpdateThresholdHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	// Get the ID from the URL path: /threshold/{id}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		// If the ID is invalid, return a 400 Bad Request
+		problem.BadRequest(w, r, "Invalid ID parameter.")
 		return
 	}
 
-	id, err := strconv.Atoi(parts[2]) // The ID is the 3rd part of the path
-	if err != nil {
-		// If the ID is invalid, return a 400 Bad Request
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid ID parameter."}`))
+	// With If-Match the write only applies to the version it names, else it is answered with 412
+	ctx, ok := ifMatch(w, r)
+	if !ok {
 		return
 	}
 
 	// Set a context with timeout for the request
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
 	defer cancel()
 
 	// Parse the request body to get the updated threshold data
 	var threshold models.Threshold
 	if err := json.NewDecoder(r.Body).Decode(&threshold); err != nil {
 		// If there's an issue with the JSON decoding, return a 400 Bad Request
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid JSON body."}`))
+		problem.BadRequest(w, r, "Invalid JSON body.")
 		return
 	}
 
@@ -46,24 +45,20 @@ func UpdateThresholdHandler(w http.ResponseWriter, r *http.Request, logger *log.
 	threshold.ID = id
 
 	// Call the service method to update the threshold
-	_, err = ds.UpdateThreshold(&threshold, ctx)
+	aff, err := ds.UpdateThreshold(&threshold, ctx)
 	if err != nil {
 		// Handle specific error cases and return an appropriate HTTP status
-		switch err.(type) {
-		case service.DataError:
-			// If a DataError is encountered, return a 400 Bad Request
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
-			return
-		default:
-			// For any other unexpected errors, log and return a 500 Internal Server Error
-			logger.Println("Error updating threshold:", err)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
-			return
-		}
+		problem.Respond(w, r, logger, err, "Error updating threshold")
+		return
+	}
+	if aff == 0 {
+		// No threshold with the ID, or it belongs to another tenant
+		problem.NotFound(w, r)
+		return
 	}
 
-	// If the update is successful, send a success message
+	// If the update is successful, send a success message with the ETag of the new version
+	w.Header().Set("ETag", versionTag(threshold.Version))
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message": "Threshold updated successfully."}`))
 }
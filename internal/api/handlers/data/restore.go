@@ -0,0 +1,39 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * Restores a soft-deleted resource identified by a URI *
+// * curl -X POST http://127.0.0.1:8080/data/1/restore -i -u admin:password -H "Content-Type: application/json"
+func RestoreHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		// * This is a User Error: format of id is invalid, response in JSON and with a 400 status code
+		problem.BadRequest(w, r, "Missconfigured ID.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	aff, err := ds.Restore(id, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Could not restore data", "id", id)
+		return
+	}
+
+	// * Only soft-deleted data can be restored
+	if aff == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
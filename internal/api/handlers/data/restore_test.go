@@ -0,0 +1,86 @@
+package data_test
+
+import (
+	"goapi/internal/api/auth"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestoreNotFound(t *testing.T) {
+	req, err := http.NewRequest("POST", "/data/1/restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	rr := httptest.NewRecorder()
+
+	data.RestoreHandler(rr, req, slog.Default(), &service.MockDataServiceNotFound{})
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestRestoreSuccessful(t *testing.T) {
+	req, err := http.NewRequest("POST", "/data/1/restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	rr := httptest.NewRecorder()
+
+	data.RestoreHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+}
+
+// * include_deleted is reserved for administrators *
+func TestGetByIDIncludeDeletedForbidden(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/1?include_deleted=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Username: "user1"}))
+	rr := httptest.NewRecorder()
+
+	data.GetByIDHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+	problemtest.Expect(t, rr, service.CodeForbidden, "include_deleted is only available to administrators.")
+}
+
+func TestGetByIDIncludeDeletedAdmin(t *testing.T) {
+	req, err := http.NewRequest("GET", "/data/1?include_deleted=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Username: "admin", Admin: true}))
+	rr := httptest.NewRecorder()
+
+	data.GetByIDHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestRestoreThresholdSuccessful(t *testing.T) {
+	req, err := http.NewRequest("POST", "/threshold/1/restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	rr := httptest.NewRecorder()
+
+	data.RestoreThresholdHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusNoContent || rr.Body.Len() != 0 {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+}
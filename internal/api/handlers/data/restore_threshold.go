@@ -0,0 +1,39 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// RestoreThresholdHandler restores a soft-deleted threshold by ID.
+// curl -X POST http://127.0.0.1:8080/threshold/1/restore -i -u admin:password -H "Content-Type: application/json"
+func RestoreThresholdHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid ID parameter.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	aff, err := ds.RestoreThreshold(id, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error restoring threshold")
+		return
+	}
+
+	// Only soft-deleted thresholds can be restored
+	if aff == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	// Answered like the restore of a reading, with no body
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// * Runs the handlers against the in-memory repositories, so what is written can be read back *
+func TestDataRoundTrip(t *testing.T) {
+	ds := service.NewRepositoryDataService(Memory.NewDataRepository(), Memory.NewThresholdRepository())
+
+	req := httptest.NewRequest("POST", "/data", strings.NewReader(`{"device_id": "dev1", "device_name": "Device 1", "temp_value": 21.5, "humi_value": 40, "type": "sensor", "date_time": "2024-01-01T10:00:00Z"}`))
+	rr := httptest.NewRecorder()
+	data.PostHandler(rr, req, slog.Default(), ds)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+	var created models.Data
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.Itoa(created.ID)
+
+	req = httptest.NewRequest("GET", "/data/"+id, nil)
+	req.SetPathValue("id", id) // * Required for routing *
+	rr = httptest.NewRecorder()
+	data.GetByIDHandler(rr, req, slog.Default(), ds)
+	var read models.Data
+	if err := json.Unmarshal(rr.Body.Bytes(), &read); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || read != created {
+		t.Fatalf("expected %+v, got %v %s", created, rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("DELETE", "/data/"+id, nil)
+	req.SetPathValue("id", id)
+	rr = httptest.NewRecorder()
+	data.DeleteHandler(rr, req, slog.Default(), ds)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+	}
+
+	req = httptest.NewRequest("GET", "/data/"+id, nil)
+	req.SetPathValue("id", id)
+	rr = httptest.NewRecorder()
+	data.GetByIDHandler(rr, req, slog.Default(), ds)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,101 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func asTenant(tenant string, handler func(http.ResponseWriter, *http.Request, *slog.Logger, service.DataService), ds service.DataService, method string, path string, id string, body string) *httptest.ResponseRecorder {
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Username: tenant + "-user", Tenant: tenant})
+	req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if method == "PATCH" {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+	req.SetPathValue("id", id) // * Required for routing *
+	rr := httptest.NewRecorder()
+	handler(rr, req, slog.Default(), ds)
+	return rr
+}
+
+// * A caller of one tenant can neither read nor change the readings and thresholds of another tenant *
+func TestCrossTenantAccess(t *testing.T) {
+	ds := service.NewRepositoryDataService(Memory.NewDataRepository(), Memory.NewThresholdRepository())
+
+	rr := asTenant("acme", data.PostHandler, ds, "POST", "/data", "",
+		`{"device_id": "dev1", "device_name": "Device 1", "temp_value": 21.5, "humi_value": 40, "type": "sensor", "date_time": "2024-01-01T10:00:00Z"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected acme to create a reading, got %v: %s", rr.Code, rr.Body.String())
+	}
+	rr = asTenant("acme", data.PostThresholdHandler, ds, "POST", "/threshold", "", `{"sensor_type": "Temperature", "min_value": 10, "max_value": 20}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected acme to create a threshold, got %v: %s", rr.Code, rr.Body.String())
+	}
+	if rr = asTenant("acme", data.UpdateThresholdHandler, ds, "PUT", "/threshold/1", "1", `{"sensor_type": "Temperature", "min_value": 5, "max_value": 20}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected acme to update its threshold, got %v: %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request, *slog.Logger, service.DataService)
+		method  string
+		path    string
+		body    string
+	}{
+		{"read reading", data.GetByIDHandler, "GET", "/data/1", ""},
+		{"replace reading", data.PutHandler, "PUT", "/data/1",
+			`{"device_id": "dev1", "temp_value": 30, "humi_value": 40, "type": "sensor", "date_time": "2024-01-01T10:00:00Z"}`},
+		{"patch reading", data.PatchHandler, "PATCH", "/data/1", `{"temp_value": 30}`},
+		{"delete reading", data.DeleteHandler, "DELETE", "/data/1", ""},
+		{"read threshold", data.GetThresholdByIDHandler, "GET", "/threshold/1", ""},
+		{"replace threshold", data.UpdateThresholdHandler, "PUT", "/threshold/1", `{"sensor_type": "Temperature", "min_value": 0, "max_value": 100}`},
+		{"patch threshold", data.PatchThresholdHandler, "PATCH", "/threshold/1", `{"max_value": 100}`},
+		{"delete threshold", data.DeleteThresholdHandler, "DELETE", "/threshold/1", ""},
+		{"threshold history", data.ThresholdHistoryHandler, "GET", "/threshold/1/history", ""},
+		{"roll back threshold", data.RollbackThresholdHandler, "POST", "/threshold/1/rollback", `{"version": 1}`},
+	}
+	for _, tt := range tests {
+		if rr := asTenant("globex", tt.handler, ds, tt.method, tt.path, "1", tt.body); rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected another tenant to get %v, got %v: %s", tt.name, http.StatusNotFound, rr.Code, rr.Body.String())
+		}
+	}
+
+	// * Lists of another tenant are empty, the rows of acme are untouched
+	for _, handler := range []func(http.ResponseWriter, *http.Request, *slog.Logger, service.DataService){data.GetHandler, data.GetThresholdHandler} {
+		if rr := asTenant("globex", handler, ds, "GET", "/", "", ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected another tenant to list nothing, got %v: %s", rr.Code, rr.Body.String())
+		}
+	}
+	rr = asTenant("acme", data.GetByIDHandler, ds, "GET", "/data/1", "1", "")
+	var reading models.Data
+	if err := json.Unmarshal(rr.Body.Bytes(), &reading); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || reading.TemperatureValue != 21.5 || reading.HumidityValue != 40 || !reading.DateTime.Equal(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the reading of acme to be unchanged, got %v %+v", rr.Code, reading)
+	}
+	rr = asTenant("acme", data.GetThresholdByIDHandler, ds, "GET", "/threshold/1", "1", "")
+	var threshold models.Threshold
+	if err := json.Unmarshal(rr.Body.Bytes(), &threshold); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || threshold.MinValue != 5 || threshold.MaxValue != 20 {
+		t.Errorf("expected the threshold of acme to be unchanged, got %v %+v", rr.Code, threshold)
+	}
+
+	// * The same sensor type can be configured by every tenant
+	if rr := asTenant("globex", data.PostThresholdHandler, ds, "POST", "/threshold", "", `{"sensor_type": "temperature", "min_value": 0, "max_value": 30}`); rr.Code != http.StatusCreated {
+		t.Errorf("expected globex to create its own threshold, got %v: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// * thresholdColumns are the columns of an exported CSV file, an import reads them by name and ignores id, updated_at and version *
+var thresholdColumns = []string{"id", "sensor_type", "device_id", "min_value", "max_value", "unit", "updated_at", "version"}
+
+// * writeThresholdsCSV writes thresholds as CSV with a header line *
+func writeThresholdsCSV(w io.Writer, thresholds []*models.Threshold) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(thresholdColumns); err != nil {
+		return err
+	}
+	for _, t := range thresholds {
+		if err := out.Write([]string{
+			strconv.Itoa(t.ID),
+			t.SensorType,
+			t.DeviceID,
+			strconv.FormatFloat(t.MinValue, 'f', -1, 64),
+			strconv.FormatFloat(t.MaxValue, 'f', -1, 64),
+			t.Unit,
+			t.UpdatedAt,
+			strconv.Itoa(t.Version),
+		}); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// * readThresholdsCSV reads a CSV file with a header line, sensor_type, min_value and max_value are required and device_id and unit optional *
+// * Errors are DataErrors whose fields are named like those of the service, e.g. thresholds[0].min_value for the first line after the header *
+func readThresholdsCSV(r io.Reader) ([]*models.Threshold, error) {
+	in := csv.NewReader(r)
+	in.TrimLeadingSpace = true
+	header, err := in.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, service.DataError{Code: service.CodeInvalidRequest, Message: "Invalid CSV: " + err.Error() + "."}
+	}
+
+	column := map[string]int{}
+	for i, name := range header {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var missing []service.FieldError
+	for _, name := range []string{"sensor_type", "min_value", "max_value"} {
+		if _, ok := column[name]; !ok {
+			missing = append(missing, service.FieldError{Field: name, Message: "column is required"})
+		}
+	}
+	if len(missing) > 0 {
+		return nil, service.DataError{Code: service.CodeInvalidRequest, Message: "Invalid CSV header.", Fields: missing}
+	}
+
+	var thresholds []*models.Threshold
+	var fields []service.FieldError
+	for i := 0; ; i++ {
+		record, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, service.DataError{Code: service.CodeInvalidRequest, Message: "Invalid CSV: " + err.Error() + "."}
+		}
+		value := func(name string) string {
+			if j, ok := column[name]; ok && j < len(record) {
+				return strings.TrimSpace(record[j])
+			}
+			return ""
+		}
+		number := func(name string) float64 {
+			n, err := strconv.ParseFloat(value(name), 64)
+			if err != nil {
+				fields = append(fields, service.FieldError{Field: fmt.Sprintf("thresholds[%d].%s", i, name), Message: "must be a number"})
+			}
+			return n
+		}
+		thresholds = append(thresholds, &models.Threshold{
+			SensorType: value("sensor_type"),
+			DeviceID:   value("device_id"),
+			MinValue:   number("min_value"),
+			MaxValue:   number("max_value"),
+			Unit:       value("unit"),
+		})
+	}
+	if len(fields) > 0 {
+		return nil, service.DataError{Message: "Invalid thresholds.", Fields: fields}
+	}
+	return thresholds, nil
+}
@@ -0,0 +1,27 @@
+package data
+
+import (
+	"goapi/internal/api/problem"
+	"goapi/internal/api/units"
+	"net/http"
+)
+
+// * UnitsHeader asks for the values of readings in other units, like the units query parameter, e.g. X-Units: F *
+const UnitsHeader = "X-Units"
+
+// * outputUnits reads the units the client wants readings in from ?units= or else the X-Units header, e.g. units=F,%25 *
+// * It writes a 400 response and returns false when a unit is unknown *
+func outputUnits(w http.ResponseWriter, r *http.Request) (units.Preference, bool) {
+	w.Header().Add("Vary", UnitsHeader)
+
+	requested := r.URL.Query().Get("units")
+	if requested == "" {
+		requested = r.Header.Get(UnitsHeader)
+	}
+	preference, err := units.ParsePreference(requested)
+	if err != nil {
+		problem.BadRequest(w, r, "Invalid units: "+err.Error()+".")
+		return nil, false
+	}
+	return preference, true
+}
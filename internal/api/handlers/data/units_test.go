@@ -0,0 +1,60 @@
+package data_test
+
+import (
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// * Stored readings are in °C, the query parameter takes precedence over the header *
+func TestOutputUnits(t *testing.T) {
+	tests := []struct {
+		name, query, header string
+		expected            string
+	}{
+		{"canonical", "", "", `"temp_value":0,"humi_value":0,"temp_unit":"C","humi_unit":"%"`},
+		{"query", "?units=F", "", `"temp_value":32,"humi_value":0,"temp_unit":"F","humi_unit":"%"`},
+		{"header", "", "kelvin", `"temp_value":273.15,"humi_value":0,"temp_unit":"K","humi_unit":"%"`},
+		{"query wins", "?units=F,%25", "K", `"temp_value":32,"humi_value":0,"temp_unit":"F","humi_unit":"%"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/data/1"+tt.query, nil)
+			req.SetPathValue("id", "1")
+			if tt.header != "" {
+				req.Header.Set(data.UnitsHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			data.GetByIDHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+			if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), tt.expected) {
+				t.Errorf("expected 200 with %s, got %d %s", tt.expected, rr.Code, rr.Body.String())
+			}
+			if vary := rr.Header().Get("Vary"); vary != data.UnitsHeader {
+				t.Errorf("expected the response to vary by %s, got %q", data.UnitsHeader, vary)
+			}
+		})
+	}
+}
+
+func TestOutputUnitsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"?units=R":   `Invalid units: "R" is not a known unit.`,
+		"?units=F,K": "Invalid units: both F and K are given for temperature.",
+	}
+	for query, detail := range tests {
+		req := httptest.NewRequest("GET", "/data"+query, nil)
+		rr := httptest.NewRecorder()
+		data.GetHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+		problemtest.Expect(t, rr, service.CodeInvalidRequest, detail)
+	}
+}
@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// UpsertThresholdHandler creates or replaces the threshold of the sensor type in the URL, so that configuration tools can write the thresholds they want without looking up IDs.
+// ?device_id= scopes the threshold to one device. The answer is 201 when the threshold was created and 200 otherwise, with the stored threshold in both cases.
+// curl -X PUT http://127.0.0.1:8080/threshold/by-type/temperature -i -u admin:password -H "Content-Type: application/json" -d '{"min_value": 18, "max_value": 28}'
+func UpsertThresholdHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	sensorType := r.PathValue("sensor_type")
+	deviceID := r.URL.Query().Get("device_id")
+
+	// With If-Match the write only applies to the version it names, else it is answered with 412
+	ctx, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	// Set a context with timeout for the request
+	ctx, cancel := context.WithTimeout(ctx, config.RequestTimeout(ctx))
+	defer cancel()
+
+	var threshold models.Threshold
+	if err := json.NewDecoder(r.Body).Decode(&threshold); err != nil {
+		problem.BadRequest(w, r, "Invalid JSON body.")
+		return
+	}
+
+	// The URL names the threshold, the body may repeat it but not name another one
+	var fields []service.FieldError
+	if threshold.SensorType != "" && !strings.EqualFold(threshold.SensorType, sensorType) {
+		fields = append(fields, service.FieldError{Field: "sensor_type", Message: "must match the sensor type of the URL"})
+	}
+	if threshold.DeviceID != "" && threshold.DeviceID != deviceID {
+		fields = append(fields, service.FieldError{Field: "device_id", Message: "must match the device_id query parameter"})
+	}
+	if len(fields) > 0 {
+		problem.Write(w, r, service.DataError{Code: service.CodeInvalidRequest, Message: "The body names another threshold than the URL.", Fields: fields})
+		return
+	}
+	if threshold.SensorType == "" {
+		threshold.SensorType = sensorType
+	}
+	threshold.ID, threshold.DeviceID = 0, deviceID
+
+	created, err := ds.UpsertThreshold(&threshold, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error upserting threshold", "sensor_type", sensorType, "device_id", deviceID)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		w.Header().Set("Location", "/threshold/"+strconv.Itoa(threshold.ID))
+		status = http.StatusCreated
+	}
+	w.Header().Set("ETag", versionTag(threshold.Version))
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(threshold); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding threshold", "error", err)
+		return
+	}
+}
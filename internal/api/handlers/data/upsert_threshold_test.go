@@ -0,0 +1,156 @@
+package data_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/problem/problemtest"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func upsertThreshold(t *testing.T, ds service.DataService, sensorType string, query string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("PUT", "/threshold/by-type/"+sensorType+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.SetPathValue("sensor_type", sensorType) // * Required for routing *
+	rr := httptest.NewRecorder()
+	data.UpsertThresholdHandler(rr, req, slog.Default(), ds)
+	return rr
+}
+
+func upsertedThreshold(t *testing.T, rr *httptest.ResponseRecorder, status int) models.Threshold {
+	t.Helper()
+	if rr.Code != status {
+		t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, status, rr.Body.String())
+	}
+	var threshold models.Threshold
+	if err := json.Unmarshal(rr.Body.Bytes(), &threshold); err != nil {
+		t.Fatal(err)
+	}
+	return threshold
+}
+
+// * The first PUT creates the threshold of a sensor type and device, the next ones replace its limits *
+func TestUpsertThreshold(t *testing.T) {
+	ds := thresholdService(t)
+
+	rr := upsertThreshold(t, ds, "temperature", "", `{"min_value": 15, "max_value": 25}`, nil)
+	threshold := upsertedThreshold(t, rr, http.StatusOK)
+	if threshold.ID != 1 || threshold.SensorType != "temperature" || threshold.MaxValue != 25 || rr.Header().Get("ETag") != `"2"` {
+		t.Errorf("expected threshold 1 to be updated, got %+v with ETag %s", threshold, rr.Header().Get("ETag"))
+	}
+
+	rr = upsertThreshold(t, ds, "temperature", "?device_id=dev1", `{"sensor_type": "Temperature", "min_value": 5, "max_value": 20}`, nil)
+	threshold = upsertedThreshold(t, rr, http.StatusCreated)
+	if threshold.ID != 2 || threshold.DeviceID != "dev1" || rr.Header().Get("Location") != "/threshold/2" {
+		t.Errorf("expected a threshold for dev1, got %+v at %s", threshold, rr.Header().Get("Location"))
+	}
+
+	// * Sending the same limits again changes nothing
+	threshold = upsertedThreshold(t, upsertThreshold(t, ds, "temperature", "?device_id=dev1", `{"sensor_type": "Temperature", "min_value": 5, "max_value": 20}`, nil), http.StatusOK)
+	if threshold.Version != 1 {
+		t.Errorf("expected an unchanged threshold to keep version 1, got %+v", threshold)
+	}
+	history, err := ds.ThresholdHistory(2, context.Background())
+	if err != nil || len(history) != 1 {
+		t.Errorf("expected one history entry, got %+v, %v", history, err)
+	}
+}
+
+func TestUpsertThresholdInvalid(t *testing.T) {
+	ds := thresholdService(t)
+
+	rr := upsertThreshold(t, ds, "temperature", "", `{"sensor_type": "humidity", "device_id": "dev1", "min_value": 15, "max_value": 25}`, nil)
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "The body names another threshold than the URL.")
+
+	rr = upsertThreshold(t, ds, "temperature", "", `{"min_value": 25, "max_value": 15}`, nil)
+	problemtest.Expect(t, rr, service.CodeInvalidData, "MinValue must be less than MaxValue")
+
+	rr = upsertThreshold(t, ds, "temperature", "", `{"min_value": 15, "max_value": 25}`, map[string]string{"If-Match": `"2"`})
+	problemtest.Expect(t, rr, service.CodePreconditionFailed, "The resource has been changed since it was read.")
+
+	rr = upsertThreshold(t, ds, "humidity", "", `{"min_value": 30, "max_value": 60}`, map[string]string{"If-Match": `"1"`})
+	problemtest.Expect(t, rr, service.CodePreconditionFailed, "The resource has been changed since it was read.")
+}
+
+// * A sensor type has one threshold per device, creating or restoring a second one is answered with 409 *
+func TestThresholdConflict(t *testing.T) {
+	ds := thresholdService(t)
+
+	rr := conditional(t, data.PostThresholdHandler, ds, "POST", "/threshold", "", `{"sensor_type": "TEMPERATURE", "min_value": 15, "max_value": 25}`, nil)
+	problemtest.Expect(t, rr, service.CodeConflict, "A threshold for the sensor type and device exists already.")
+
+	rr = conditional(t, data.PostThresholdHandler, ds, "POST", "/threshold", "", `{"sensor_type": "temperature", "device_id": "dev1", "min_value": 15, "max_value": 25}`, nil)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected a threshold scoped to a device to be created, got %v %s", rr.Code, rr.Body.String())
+	}
+	rr = conditional(t, data.PatchThresholdHandler, ds, "PATCH", "/threshold/2", "2", `{"device_id": null}`, nil)
+	problemtest.Expect(t, rr, service.CodeConflict, "A threshold for the sensor type and device exists already.")
+
+	if _, err := ds.DeleteThreshold(1, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	upsertedThreshold(t, upsertThreshold(t, ds, "temperature", "", `{"min_value": 15, "max_value": 25}`, nil), http.StatusCreated)
+	rr = conditional(t, data.RestoreThresholdHandler, ds, "POST", "/threshold/1/restore", "1", "", nil)
+	problemtest.Expect(t, rr, service.CodeConflict, "A threshold for the sensor type and device exists already.")
+}
+
+// * Parallel PUTs of a new sensor type create it once and update it with every other request, none answers 409 *
+func TestUpsertThresholdConcurrently(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SQLite.NewMigrator(db).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := SQLite.NewThresholdRepository(db, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := service.NewRepositoryDataService(nil, repo)
+
+	const requests = 8
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := upsertThreshold(t, ds, "pressure", "", fmt.Sprintf(`{"min_value": 0, "max_value": %d}`, 100+i), nil)
+			codes <- rr.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	created := 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusOK:
+		default:
+			t.Errorf("expected every upsert to create or update the threshold, got %v", code)
+		}
+	}
+	thresholds, err := ds.GetAllThresholds(0, 10, ctx)
+	if created != 1 || err != nil || len(thresholds) != 1 || thresholds[0].Version != requests {
+		t.Errorf("expected one creation and %d updates, got %d creations and %+v, %v", requests-1, created, thresholds, err)
+	}
+}
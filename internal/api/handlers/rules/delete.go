@@ -0,0 +1,37 @@
+package rules
+
+import (
+	"context"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * The DELETE method removes a rule identified by a URI *
+// * curl -X DELETE http://127.0.0.1:8080/rule/1 -i -u admin:password -H "Content-Type: application/json"
+func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Missconfigured ID.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	aff, err := rs.Delete(&models.Rule{ID: id}, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Could not delete rule", "id", id)
+		return
+	}
+	if aff == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
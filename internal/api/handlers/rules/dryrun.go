@@ -0,0 +1,59 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// * DryRunRequest selects a stored rule by ID or carries an unsaved rule, and the time range of readings to replay, at most 31 days *
+type DryRunRequest struct {
+	RuleID int              `json:"rule_id"`
+	Rule   *models.Rule     `json:"rule"`
+	From   models.Timestamp `json:"from"`
+	To     models.Timestamp `json:"to"`
+}
+
+// * Evaluates a rule against historical readings and returns the events it would have emitted, nothing is stored *
+// * curl -X POST http://127.0.0.1:8080/rule/dry-run -i -u admin:password -H "Content-Type: application/json" -d '{"rule_id": 1, "from": "2024-01-01T00:00:00Z", "to": "2024-02-01T00:00:00Z"}'
+func DryRunHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	var req DryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Rule == nil && req.RuleID == 0) {
+		problem.BadRequest(w, r, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// * Replaying history can take longer than a regular request
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	rule := req.Rule
+	if rule == nil {
+		var err error
+		if rule, err = rs.ReadOne(req.RuleID, ctx); err != nil {
+			problem.Respond(w, r, logger, err, "Could not read rule", "rule_id", req.RuleID)
+			return
+		}
+		if rule == nil {
+			problem.NotFound(w, r)
+			return
+		}
+	}
+
+	result, err := rs.DryRun(rule, req.From.Time, req.To.Time, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error running rule", "rule", rule)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding dry-run result", "error", err)
+		return
+	}
+}
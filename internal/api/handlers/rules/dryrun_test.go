@@ -0,0 +1,54 @@
+package rules_test
+
+import (
+	"goapi/internal/api/handlers/rules"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDryRunInvalidRequestBody(t *testing.T) {
+	req, err := http.NewRequest("POST", "/rule/dry-run", strings.NewReader(`{"from": "2021-01-01T00:00:00Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.DryRunHandler(rr, req, slog.Default(), &service.MockRuleServiceSuccessful{})
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestDryRunRuleNotFound(t *testing.T) {
+	req, err := http.NewRequest("POST", "/rule/dry-run", strings.NewReader(`{"rule_id": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.DryRunHandler(rr, req, slog.Default(), &service.MockRuleServiceNotFound{})
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestDryRunSuccessful(t *testing.T) {
+	req, err := http.NewRequest("POST", "/rule/dry-run", strings.NewReader(`{"rule_id": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.DryRunHandler(rr, req, slog.Default(), &service.MockRuleServiceSuccessful{})
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	expected := `{"readings":2,"events":[{"rule_id":1,"rule_name":"rule1","device_ids":["device1"],"date_time":"2021-01-01T00:00:00Z"}]}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
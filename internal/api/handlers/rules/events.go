@@ -0,0 +1,44 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * The GET method retrieves the events of rules that started firing, newest first, supporting pagination *
+// * curl -X GET http://127.0.0.1:8080/rule/events?page=1 -i -u admin:password -H "Content-Type: application/json"
+func GetEventsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	page := 1
+	if query := r.URL.Query().Get("page"); query != "" {
+		var err error
+		if page, err = strconv.Atoi(query); err != nil {
+			problem.BadRequest(w, r, "Invalid page specified.")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	events, err := rs.ReadEvents(page, config.PageSize(ctx), ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error retrieving rule events")
+		return
+	}
+	if len(events) == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding rule events", "error", err)
+		return
+	}
+}
@@ -0,0 +1,44 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * The GET method retrieves all rules, supporting pagination *
+// * curl -X GET http://127.0.0.1:8080/rule?page=1 -i -u admin:password -H "Content-Type: application/json"
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	page := 1
+	if query := r.URL.Query().Get("page"); query != "" {
+		var err error
+		if page, err = strconv.Atoi(query); err != nil {
+			problem.BadRequest(w, r, "Invalid page specified.")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	rules, err := rs.ReadMany(page, config.PageSize(ctx), ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Error retrieving rules")
+		return
+	}
+	if len(rules) == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding rules", "error", err)
+		return
+	}
+}
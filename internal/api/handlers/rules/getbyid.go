@@ -0,0 +1,41 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * The GET method retrieves a rule identified by a URI *
+// * curl -X GET http://127.0.0.1:8080/rule/1 -i -u admin:password -H "Content-Type: application/json"
+func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Missconfigured ID.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	rule, err := rs.ReadOne(id, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Could not read rule", "id", id)
+		return
+	}
+	if rule == nil {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding rule", "error", err, "rule", rule)
+		return
+	}
+}
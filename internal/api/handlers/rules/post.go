@@ -0,0 +1,36 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+)
+
+// * User sends a POST request to /rule with the rule as JSON payload *
+// * curl -X POST http://127.0.0.1:8080/rule -i -u admin:password -H "Content-Type: application/json" -d '{"name": "humid and warm", "enabled": true, "for": "15m", "condition": {"op": "and", "conditions": [{"metric": "humidity", "op": ">", "value": 80}, {"metric": "temperature", "op": ">", "value": 25}]}}'
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	var rule models.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		problem.BadRequest(w, r, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	if err := rs.Create(&rule, ctx); err != nil {
+		problem.Respond(w, r, logger, err, "Error creating rule", "rule", rule)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding rule", "error", err, "rule", rule)
+		return
+	}
+}
@@ -0,0 +1,57 @@
+package rules_test
+
+import (
+	"goapi/internal/api/handlers/rules"
+	"goapi/internal/api/problem/problemtest"
+	dataservice "goapi/internal/api/service/data"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostRuleInvalidRequestBody(t *testing.T) {
+	req, err := http.NewRequest("POST", "/rule", strings.NewReader(`Plain text, not JSON`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.PostHandler(rr, req, slog.Default(), &service.MockRuleServiceSuccessful{})
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestPostRuleError(t *testing.T) {
+	req, err := http.NewRequest("POST", "/rule", strings.NewReader(`{"name": "rule1", "condition": {"metric": "humidity", "op": ">", "value": 80}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.PostHandler(rr, req, slog.Default(), &service.MockRuleServiceError{})
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+	problemtest.Expect(t, rr, dataservice.CodeInvalidData, "Error creating rule.")
+}
+
+func TestPostRuleSuccessful(t *testing.T) {
+	req, err := http.NewRequest("POST", "/rule", strings.NewReader(`{"name": "rule1", "enabled": true, "condition": {"metric": "humidity", "op": ">", "value": 80}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+
+	rules.PostHandler(rr, req, slog.Default(), &service.MockRuleServiceSuccessful{})
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	expected := `{"id":1,"name":"rule1","condition":{"op":"\u003e","metric":"humidity","value":80},"for":"","group_tag":"","min_devices":0,"enabled":true,"updated_at":""}`
+	if strings.TrimSpace(rr.Body.String()) != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
+	}
+}
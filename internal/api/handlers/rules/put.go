@@ -0,0 +1,47 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * PUT replaces the whole rule identified by the URI *
+// * curl -X PUT http://127.0.0.1:8080/rule/1 -i -u admin:password -H "Content-Type: application/json" -d '{"name": "too humid", "enabled": true, "condition": {"metric": "humidity", "op": ">", "value": 90}}'
+func PutHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.BadRequest(w, r, "Missconfigured ID.")
+		return
+	}
+
+	var rule models.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		problem.BadRequest(w, r, "Invalid request data. Please check your input.")
+		return
+	}
+	rule.ID = id
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	if aff, err := rs.Update(&rule, ctx); err != nil {
+		problem.Respond(w, r, logger, err, "Error updating rule", "rule", rule)
+		return
+	} else if aff == 0 {
+		problem.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding rule", "error", err, "rule", rule)
+		return
+	}
+}
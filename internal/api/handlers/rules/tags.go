@@ -0,0 +1,58 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/rules"
+	"log/slog"
+	"net/http"
+)
+
+// * Returns the tags of a device, used to group devices in rules *
+// * curl -X GET http://127.0.0.1:8080/tags/device1 -i -u admin:password -H "Content-Type: application/json"
+func GetTagsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	deviceID := r.PathValue("device_id")
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	tags, err := rs.ReadTags(deviceID, ctx)
+	if err != nil {
+		problem.Respond(w, r, logger, err, "Could not read tags", "device_id", deviceID)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding tags", "error", err, "tags", tags)
+		return
+	}
+}
+
+// * Replaces the tags of a device *
+// * curl -X PUT http://127.0.0.1:8080/tags/device1 -i -u admin:password -H "Content-Type: application/json" -d '["zone-a", "greenhouse"]'
+func PutTagsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, rs service.RuleService) {
+	deviceID := r.PathValue("device_id")
+
+	var tags []string
+	if err := json.NewDecoder(r.Body).Decode(&tags); err != nil {
+		problem.BadRequest(w, r, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), config.RequestTimeout(r.Context()))
+	defer cancel()
+
+	if err := rs.SetTags(deviceID, tags, ctx); err != nil {
+		problem.Respond(w, r, logger, err, "Error setting tags", "device_id", deviceID)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding tags", "error", err, "tags", tags)
+		return
+	}
+}
@@ -0,0 +1,174 @@
+// Package health answers the liveness and readiness probes of load balancers
+// and orchestrators. /healthz only tells that the process serves requests,
+// /readyz runs the registered checks, requires every background worker to be
+// running and fails as soon as shutdown begins so that no new traffic is sent.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapi/internal/api/problem"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// * Check reports why a dependency is not ready, nil when it is *
+type Check func(ctx context.Context) error
+
+// * How long every check may take before readiness fails
+const checkTimeout = 2 * time.Second
+
+type Checker struct {
+	mu           sync.Mutex
+	checks       map[string]Check
+	workers      map[string]bool
+	shuttingDown bool
+}
+
+func New() *Checker {
+	return &Checker{
+		checks:  map[string]Check{},
+		workers: map[string]bool{},
+	}
+}
+
+// * AddCheck registers a readiness check, e.g. a database ping, under name *
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// * Go runs a background worker, the service is not ready once it has returned *
+func (c *Checker) Go(name string, worker func()) {
+	c.mu.Lock()
+	c.workers[name] = true
+	c.mu.Unlock()
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			c.workers[name] = false
+			c.mu.Unlock()
+		}()
+		worker()
+	}()
+}
+
+// * Shutdown makes readiness fail from now on, liveness is not affected *
+func (c *Checker) Shutdown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shuttingDown = true
+}
+
+// * Report is the body of both probes, Checks maps every check and worker to "ok" or the reason it failed *
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// * Ready runs every check and returns the report, err is non-nil if the service should not get traffic *
+func (c *Checker) Ready(ctx context.Context) (Report, error) {
+	c.mu.Lock()
+	shuttingDown := c.shuttingDown
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	workers := make(map[string]bool, len(c.workers))
+	for name, running := range c.workers {
+		workers[name] = running
+	}
+	c.mu.Unlock()
+
+	report := Report{Status: "ready", Checks: map[string]string{}}
+	var errs []error
+	fail := func(name string, err error) {
+		report.Checks[name] = err.Error()
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+
+	if shuttingDown {
+		fail("shutdown", errors.New("shutting down"))
+	}
+
+	// Checks run one at a time in name order so that the report is stable
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := checks[name](checkCtx)
+		cancel()
+		if err != nil {
+			fail(name, err)
+		} else {
+			report.Checks[name] = "ok"
+		}
+	}
+
+	for name, running := range workers {
+		if running {
+			report.Checks["worker:"+name] = "ok"
+		} else {
+			fail("worker:"+name, errors.New("not running"))
+		}
+	}
+
+	if len(errs) > 0 {
+		report.Status = "unavailable"
+		return report, errors.Join(errs...)
+	}
+	return report, nil
+}
+
+// * LivenessHandler serves /healthz, it answers as long as the process can serve requests *
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(w, r) {
+			return
+		}
+		writeReport(w, r, http.StatusOK, Report{Status: "ok"})
+	})
+}
+
+// * ReadinessHandler serves /readyz, 200 when ready and 503 with the failed checks otherwise *
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(w, r) {
+			return
+		}
+		report, err := c.Ready(r.Context())
+		if err != nil {
+			writeReport(w, r, http.StatusServiceUnavailable, report)
+			return
+		}
+		writeReport(w, r, http.StatusOK, report)
+	})
+}
+
+func allowed(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		problem.MethodNotAllowed(w, r)
+		return false
+	}
+	return true
+}
+
+func writeReport(w http.ResponseWriter, r *http.Request, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	// Probes must see the current state, never a cached one
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,106 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/health"
+	"goapi/internal/api/problem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(t *testing.T, handler http.Handler) (int, health.Report) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report health.Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid body %q: %v", rr.Body.String(), err)
+	}
+	return rr.Code, report
+}
+
+func TestReadiness(t *testing.T) {
+	checker := health.New()
+	var dbErr error
+	checker.AddCheck("database", func(ctx context.Context) error { return dbErr })
+
+	stop := make(chan struct{})
+	checker.Go("purge", func() { <-stop })
+
+	status, report := probe(t, checker.ReadinessHandler())
+	if status != http.StatusOK || report.Status != "ready" {
+		t.Fatalf("expected ready, got %d %+v", status, report)
+	}
+	if report.Checks["database"] != "ok" || report.Checks["worker:purge"] != "ok" {
+		t.Errorf("unexpected checks %v", report.Checks)
+	}
+
+	// A failing check is reported with its reason
+	dbErr = errors.New("connection refused")
+	status, report = probe(t, checker.ReadinessHandler())
+	if status != http.StatusServiceUnavailable || report.Checks["database"] != "connection refused" {
+		t.Fatalf("expected the database check to fail, got %d %+v", status, report)
+	}
+	dbErr = nil
+
+	// A worker that has returned makes the service unready
+	close(stop)
+	deadline := time.Now().Add(time.Second)
+	for {
+		status, report = probe(t, checker.ReadinessHandler())
+		if status == http.StatusServiceUnavailable || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status != http.StatusServiceUnavailable || report.Checks["worker:purge"] != "not running" {
+		t.Errorf("expected the stopped worker to fail readiness, got %d %+v", status, report)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	checker := health.New()
+	checker.Shutdown()
+
+	status, report := probe(t, checker.ReadinessHandler())
+	if status != http.StatusServiceUnavailable || report.Checks["shutdown"] == "" {
+		t.Errorf("expected readiness to fail during shutdown, got %d %+v", status, report)
+	}
+
+	// The process is still alive while it drains
+	status, report = probe(t, checker.LivenessHandler())
+	if status != http.StatusOK || report.Status != "ok" {
+		t.Errorf("expected liveness to pass during shutdown, got %d %+v", status, report)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	checker := health.New()
+	checker.AddCheck("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := checker.Ready(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the check to be cancelled, got %v", err)
+	}
+}
+
+// * The probes only answer GET and HEAD, other methods get the problem every route answers with *
+func TestProbeMethodNotAllowed(t *testing.T) {
+	checker := health.New()
+	for _, handler := range []http.Handler{checker.LivenessHandler(), checker.ReadinessHandler()} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/readyz", nil))
+		if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Content-Type") != problem.ContentType {
+			t.Errorf("expected a %d problem, got %d %q: %s", http.StatusMethodNotAllowed, rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package logging builds the structured logger of the API and carries the
+// request being served in the context, so that every line logged while
+// handling a request names its request ID, route and latency.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// * New returns a logger writing text or JSON lines at the given level and above *
+func New(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q, use debug, info, warn or error", level)
+	}
+
+	options := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       lvl,
+		ReplaceAttr: shortSource,
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, use text or json", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// * shortSource logs the calling file as file.go:24 instead of the full path *
+func shortSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey && len(groups) == 0 {
+		if source, ok := a.Value.Any().(*slog.Source); ok {
+			return slog.String(slog.SourceKey, filepath.Base(source.File)+":"+strconv.Itoa(source.Line))
+		}
+	}
+	return a
+}
+
+// * contextHandler adds the request and the trace of the context to every record *
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if req, ok := FromContext(ctx); ok {
+		record.AddAttrs(
+			slog.String("request_id", req.ID),
+			slog.String("route", req.Route),
+			slog.Duration("latency", time.Since(req.Start)),
+		)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type contextKey int
+
+const requestKey contextKey = iota
+
+// * Request identifies the request being served *
+type Request struct {
+	ID    string
+	Route string
+	Start time.Time
+}
+
+// * WithRequest returns a copy of ctx carrying the request *
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, requestKey, req)
+}
+
+// * FromContext returns the request stored by the request ID middleware, if any *
+func FromContext(ctx context.Context) (Request, bool) {
+	if ctx == nil {
+		return Request{}, false
+	}
+	req, ok := ctx.Value(requestKey).(Request)
+	return req, ok
+}
+
+// * RequestID returns the ID of the request being served, or "" *
+func RequestID(ctx context.Context) string {
+	req, _ := FromContext(ctx)
+	return req.ID
+}
@@ -0,0 +1,46 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"goapi/internal/api/logging"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLogIncludesRequest(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := logging.WithRequest(context.Background(), logging.Request{ID: "abc", Route: "/data/{id}", Start: time.Now()})
+	logger.ErrorContext(ctx, "Could not read data", "id", 7)
+	logger.DebugContext(ctx, "Not logged below the level")
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", out.String(), err)
+	}
+	if line["level"] != "ERROR" || line["msg"] != "Could not read data" || line["id"] != float64(7) {
+		t.Errorf("unexpected line: %v", line)
+	}
+	if line["request_id"] != "abc" || line["route"] != "/data/{id}" || line["latency"] == nil {
+		t.Errorf("expected the request to be logged: %v", line)
+	}
+	if source, _ := line["source"].(string); !strings.HasPrefix(source, "logging_test.go:") {
+		t.Errorf("expected a short source, got %v", line["source"])
+	}
+}
+
+func TestNewRejectsInvalidSettings(t *testing.T) {
+	if _, err := logging.New(&bytes.Buffer{}, "loud", "text"); err == nil {
+		t.Error("expected an error for the level")
+	}
+	if _, err := logging.New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("expected an error for the format")
+	}
+}
@@ -0,0 +1,252 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// * Rotation decides when the log file is rotated and how many rotated files are kept, zero values disable a limit *
+type Rotation struct {
+	// MaxSize is the size in bytes a file may grow to before it is rotated
+	MaxSize int64
+	// MaxAge is how long a file is written to before it is rotated
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, older ones are removed
+	MaxBackups int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// * RotatingFile appends to a log file and moves it aside as name-<time>.ext when it grows too large or too old *
+type RotatingFile struct {
+	path     string
+	rotation Rotation
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// Rotated files are compressed and pruned in the background, one at a time
+	cleanup sync.Mutex
+	pending sync.WaitGroup
+}
+
+// * Timestamp of rotated files, sorts in rotation order
+const backupTimeFormat = "20060102T150405.000000000"
+
+// * OpenRotatingFile opens or creates the log file at path *
+func OpenRotatingFile(path string, rotation Rotation) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.openedAt = file, info.Size(), startedAt(f.path, info)
+	return nil
+}
+
+// * startedAt is when an existing file was started, so that MaxAge holds across restarts and Reopen *
+// * It is the time of the first log line, or the modification time for files of other formats *
+func startedAt(path string, info os.FileInfo) time.Time {
+	if info.Size() == 0 {
+		return time.Now()
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return info.ModTime()
+	}
+	defer file.Close()
+
+	head := make([]byte, 4096)
+	n, _ := io.ReadFull(file, head)
+	line, _, _ := strings.Cut(string(head[:n]), "\n")
+	for _, key := range []string{`"time":"`, "time="} {
+		if _, value, ok := strings.Cut(line, key); ok {
+			value, _, _ = strings.Cut(value, `"`)
+			value, _, _ = strings.Cut(value, " ")
+			if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				return t
+			}
+		}
+	}
+	return info.ModTime()
+}
+
+// * Write appends p, rotating first if p would take the file over MaxSize or the file is older than MaxAge *
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	tooLarge := f.rotation.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.rotation.MaxSize
+	tooOld := f.rotation.MaxAge > 0 && time.Since(f.openedAt) >= f.rotation.MaxAge
+	if tooLarge || tooOld {
+		if err := f.rotate(); err != nil {
+			// Keep logging to the current file rather than losing lines
+			fmt.Fprintln(os.Stderr, "Error rotating log file:", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// * Rotate moves the current file aside and starts a new one *
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate()
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + time.Now().Format(backupTimeFormat) + ext
+	renameErr := os.Rename(f.path, backup)
+
+	// The file is reopened even if it couldn't be moved aside so that logging goes on
+	if err := f.open(); err != nil {
+		f.file = nil
+		return err
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	f.pending.Add(1)
+	go func() {
+		defer f.pending.Done()
+		f.cleanup.Lock()
+		defer f.cleanup.Unlock()
+		if err := f.compressAndPrune(backup); err != nil {
+			fmt.Fprintln(os.Stderr, "Error cleaning up rotated log files:", err)
+		}
+	}()
+	return nil
+}
+
+// * Reopen closes and reopens the file, used after an external tool such as logrotate has moved it *
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file != nil {
+		f.file.Close()
+	}
+	if err := f.open(); err != nil {
+		f.file = nil
+		return err
+	}
+	return nil
+}
+
+// * Close closes the file once rotated files are compressed and pruned *
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) compressAndPrune(backup string) error {
+	if f.rotation.Compress {
+		if err := compress(backup); err != nil {
+			return err
+		}
+	}
+	if f.rotation.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > f.rotation.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// * backups lists the rotated files, oldest first *
+func (f *RotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(f.path, ext) + "-"
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(match, prefix), ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// * compress replaces path by path.gz *
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	in.Close()
+	return os.Remove(path)
+}
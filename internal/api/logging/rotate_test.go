@@ -0,0 +1,160 @@
+package logging_test
+
+import (
+	"compress/gzip"
+	"goapi/internal/api/logging"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readBackups(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "api-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+
+	var contents []string
+	for _, match := range matches {
+		file, err := os.Open(match)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = file
+		if strings.HasSuffix(match, ".gz") {
+			if r, err = gzip.NewReader(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		content, err := io.ReadAll(r)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(content))
+	}
+	return contents
+}
+
+func TestRotateBySizeCompressAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.log")
+	f, err := logging.OpenRotatingFile(path, logging.Rotation{MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every line takes the file over 10 bytes, so each following line starts a new file
+	for _, line := range []string{"line 1...\n", "line 2...\n", "line 3...\n", "line 4...\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := os.ReadFile(path)
+	if string(current) != "line 4...\n" {
+		t.Errorf("expected the last line in the current file, got %q", current)
+	}
+	backups := readBackups(t, dir)
+	if len(backups) != 2 || backups[0] != "line 2...\n" || backups[1] != "line 3...\n" {
+		t.Errorf("expected the two newest rotated files, got %q", backups)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "api-*.log")); len(matches) != 0 {
+		t.Errorf("expected rotated files to be compressed, found %v", matches)
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.log")
+	f, err := logging.OpenRotatingFile(path, logging.Rotation{MaxAge: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("old\n"))
+	time.Sleep(60 * time.Millisecond)
+	f.Write([]byte("new\n"))
+
+	if backups := readBackups(t, dir); len(backups) != 1 || backups[0] != "old\n" {
+		t.Errorf("expected the old line to be rotated, got %q", backups)
+	}
+}
+
+// * Test: the age of an existing file is kept when it is opened again
+func TestRotateByAgeAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.log")
+	started := time.Now().Add(-2 * time.Hour).Format(time.RFC3339Nano)
+	if err := os.WriteFile(path, []byte("time="+started+" level=INFO msg=old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := logging.OpenRotatingFile(path, logging.Rotation{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write([]byte("new\n"))
+
+	if backups := readBackups(t, dir); len(backups) != 1 || !strings.HasSuffix(backups[0], "msg=old\n") {
+		t.Errorf("expected the file started two hours ago to be rotated, got %q", backups)
+	}
+	if current, _ := os.ReadFile(path); string(current) != "new\n" {
+		t.Errorf("expected a new file, got %q", current)
+	}
+
+	// * Files of other formats are as old as their last change
+	other := filepath.Join(dir, "other.log")
+	if err := os.WriteFile(other, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(other, time.Now(), time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	g, err := logging.OpenRotatingFile(other, logging.Rotation{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	g.Write([]byte("new\n"))
+	if current, _ := os.ReadFile(other); string(current) != "new\n" {
+		t.Errorf("expected the old file to be rotated, got %q", current)
+	}
+}
+
+// * Test: after an external tool moved the file, Reopen starts a new one at the same path
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.log")
+	f, err := logging.OpenRotatingFile(path, logging.Rotation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("before\n"))
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("after\n"))
+
+	moved, _ := os.ReadFile(path + ".1")
+	current, _ := os.ReadFile(path)
+	if string(moved) != "before\n" || string(current) != "after\n" {
+		t.Errorf("unexpected files: moved %q, current %q", moved, current)
+	}
+}
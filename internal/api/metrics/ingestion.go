@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/units"
+	"strings"
+	"sync"
+	"time"
+)
+
+// * Thresholds are read again at most this often, a changed threshold is counted against after this delay
+const thresholdCacheTTL = 10 * time.Second
+
+// * Every tenant gets a device_id series for the first devices it sends readings from since the start, *
+// * the readings of further devices are counted as otherDevices, so that the number of series stays bounded *
+const maxDeviceSeries = 100
+
+const otherDevices = "other"
+
+// * IngestionObserver counts stored readings and the thresholds they breach, it is fed by the data service *
+type IngestionObserver struct {
+	m          *Metrics
+	thresholds models.ThresholdRepository
+
+	// * Thresholds are read with the tenant of the caller, so they are cached per tenant
+	mu     sync.Mutex
+	cached map[string]cachedThresholds
+	// * The devices of every tenant that have a series of their own
+	devices map[string]map[string]bool
+}
+
+type cachedThresholds struct {
+	thresholds []*models.Threshold
+	at         time.Time
+}
+
+func NewIngestionObserver(m *Metrics, thresholds models.ThresholdRepository) *IngestionObserver {
+	return &IngestionObserver{m: m, thresholds: thresholds, cached: map[string]cachedThresholds{}, devices: map[string]map[string]bool{}}
+}
+
+// * Observe is called by the data service after a reading has been stored *
+func (o *IngestionObserver) Observe(data *models.Data, ctx context.Context) {
+	if o.m == nil {
+		return
+	}
+	tenant := auth.Tenant(ctx)
+	o.m.ingested.WithLabelValues(tenant, o.deviceLabel(tenant, data.DeviceID), data.Type).Inc()
+
+	// * A threshold applies to the reading value named by its sensor type, e.g. Temperature *
+	for _, threshold := range deviceThresholds(o.currentThresholds(ctx), data.DeviceID) {
+		value, ok := data.Metric(strings.ToLower(threshold.SensorType))
+		if !ok {
+			continue
+		}
+		low, high := canonicalBounds(threshold)
+		if value < low {
+			o.m.thresholdBreaches.WithLabelValues(threshold.SensorType, "min").Inc()
+		} else if value > high {
+			o.m.thresholdBreaches.WithLabelValues(threshold.SensorType, "max").Inc()
+		}
+	}
+}
+
+// * deviceLabel returns the device_id of a device that has a series, otherDevices once the tenant has maxDeviceSeries of them *
+func (o *IngestionObserver) deviceLabel(tenant string, deviceID string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	devices := o.devices[tenant]
+	if devices == nil {
+		devices = map[string]bool{}
+		o.devices[tenant] = devices
+	}
+	if !devices[deviceID] {
+		if len(devices) >= maxDeviceSeries {
+			return otherDevices
+		}
+		devices[deviceID] = true
+	}
+	return deviceID
+}
+
+// * deviceThresholds picks per sensor type the threshold of the device, or else the one of every device, like ThresholdByType *
+func deviceThresholds(thresholds []*models.Threshold, deviceID string) map[string]*models.Threshold {
+	applicable := map[string]*models.Threshold{}
+	for _, threshold := range thresholds {
+		sensorType := strings.ToLower(threshold.SensorType)
+		if threshold.DeviceID == deviceID || threshold.DeviceID == "" && applicable[sensorType] == nil {
+			applicable[sensorType] = threshold
+		}
+	}
+	return applicable
+}
+
+// * canonicalBounds converts the bounds of a threshold to the canonical unit readings are stored in *
+func canonicalBounds(threshold *models.Threshold) (float64, float64) {
+	quantity, ok := units.QuantityOf(threshold.SensorType)
+	if !ok {
+		return threshold.MinValue, threshold.MaxValue
+	}
+	u, err := units.Lookup(quantity, threshold.Unit)
+	if err != nil {
+		return threshold.MinValue, threshold.MaxValue
+	}
+	return u.ToCanonical(threshold.MinValue), u.ToCanonical(threshold.MaxValue)
+}
+
+// * currentThresholds returns the cached thresholds of the caller's tenant, on a read error the previous ones are used *
+func (o *IngestionObserver) currentThresholds(ctx context.Context) []*models.Threshold {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	tenant := auth.Tenant(ctx)
+	cached, ok := o.cached[tenant]
+	if ok && time.Since(cached.at) < thresholdCacheTTL {
+		return cached.thresholds
+	}
+
+	const rowsPerPage = 100
+	thresholds := []*models.Threshold{}
+	for page := 1; ; page++ {
+		rows, err := o.thresholds.ReadMany(page, rowsPerPage, ctx)
+		if err != nil {
+			return cached.thresholds
+		}
+		thresholds = append(thresholds, rows...)
+		if len(rows) < rowsPerPage {
+			break
+		}
+	}
+	o.cached[tenant] = cachedThresholds{thresholds: thresholds, at: time.Now()}
+	return thresholds
+}
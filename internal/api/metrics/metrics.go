@@ -0,0 +1,126 @@
+// Package metrics collects the Prometheus metrics of the API: HTTP requests,
+// ingested readings, threshold breaches, repository query latencies and the
+// database connection pool. A nil *Metrics records nothing, so that tests and
+// tools can create services without a registry.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const namespace = "goapi"
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests      *prometheus.CounterVec
+	httpDuration      *prometheus.HistogramVec
+	ingested          *prometheus.CounterVec
+	thresholdBreaches *prometheus.CounterVec
+	queryDuration     *prometheus.HistogramVec
+}
+
+// * New creates the metrics in their own registry together with the Go runtime and process metrics *
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests, by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		ingested: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "readings_ingested_total",
+			Help:      "Readings stored, by tenant, device and reading type. Devices beyond the first 100 of a tenant are counted as device_id=\"other\".",
+		}, []string{"tenant", "device_id", "type"}),
+		thresholdBreaches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "threshold_breaches_total",
+			Help:      "Stored readings outside of a threshold, by sensor type and the bound that was crossed.",
+		}, []string{"sensor_type", "bound"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Time taken by repository calls, by repository and operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repository", "operation"}),
+	}
+
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpDuration,
+		m.ingested,
+		m.thresholdBreaches,
+		m.queryDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// * RegisterDB exports the connection pool statistics of db, as reported by db.Stats() *
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	if m == nil {
+		return nil
+	}
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// * Handler serves the metrics in the Prometheus text format *
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// * Registry is used by tests to gather the current values *
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// * ObserveRequest records a served request, route is the pattern of the matched route *
+func (m *Metrics) ObserveRequest(route string, method string, status string, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.WithLabelValues(route, method, status).Inc()
+	m.httpDuration.WithLabelValues(route, method, status).Observe(elapsed.Seconds())
+}
+
+var tracer = otel.Tracer("goapi/internal/api/metrics")
+
+// * startQuery starts the span of a repository call, the returned function ends it and records the latency *
+func (m *Metrics) startQuery(ctx context.Context, repository string, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, repository+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation", operation), attribute.String("repository", repository)),
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		if m != nil {
+			m.queryDuration.WithLabelValues(repository, operation).Observe(time.Since(start).Seconds())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package metrics_test
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/metrics"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	return rr.Body.String()
+}
+
+func TestIngestionAndBreaches(t *testing.T) {
+	m := metrics.New()
+	ctx := context.Background()
+	thresholds := metrics.InstrumentThresholdRepository(Memory.NewThresholdRepository(), m)
+	if err := thresholds.Create(&models.Threshold{SensorType: "Temperature", MinValue: 0, MaxValue: 30}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 50-86 °F is 10-30 °C, readings are stored in °C
+	if err := thresholds.Create(&models.Threshold{SensorType: "temp_value", MinValue: 50, MaxValue: 86, Unit: "F"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The threshold of device3 replaces the one of every device, the one of device4 doesn't apply to other devices
+	if err := thresholds.Create(&models.Threshold{SensorType: "temperature", DeviceID: "device3", MinValue: -10, MaxValue: 40}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := thresholds.Create(&models.Threshold{SensorType: "humidity", DeviceID: "device4", MinValue: 0, MaxValue: 10}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	observer := metrics.NewIngestionObserver(m, thresholds)
+	for _, reading := range []models.Data{
+		{DeviceID: "device1", Type: "type1", TemperatureValue: 20, HumidityValue: 50},
+		{DeviceID: "device1", Type: "type1", TemperatureValue: 5},
+		{DeviceID: "device1", Type: "type1", TemperatureValue: 35},
+		{DeviceID: "device2", Type: "type1", TemperatureValue: -5},
+		{DeviceID: "device3", Type: "type1", TemperatureValue: 35},
+	} {
+		observer.Observe(&reading, ctx)
+	}
+	m.ObserveRequest("/data/{id}", "GET", "404", 5*time.Millisecond)
+
+	out := scrape(t, m)
+	for _, expected := range []string{
+		`goapi_readings_ingested_total{device_id="device1",tenant="default",type="type1"} 3`,
+		`goapi_readings_ingested_total{device_id="device2",tenant="default",type="type1"} 1`,
+		`goapi_threshold_breaches_total{bound="max",sensor_type="Temperature"} 1`,
+		`goapi_threshold_breaches_total{bound="min",sensor_type="Temperature"} 1`,
+		`goapi_threshold_breaches_total{bound="max",sensor_type="temp_value"} 2`,
+		`goapi_threshold_breaches_total{bound="min",sensor_type="temp_value"} 2`,
+		`goapi_db_query_duration_seconds_count{operation="create",repository="threshold"} 4`,
+		`goapi_http_requests_total{method="GET",route="/data/{id}",status="404"} 1`,
+		`goapi_http_request_duration_seconds_count{method="GET",route="/data/{id}",status="404"} 1`,
+	} {
+		if !strings.Contains(out, expected+"\n") {
+			t.Errorf("Expected %s in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, `sensor_type="temperature"`) || strings.Contains(out, `sensor_type="humidity"`) {
+		t.Errorf("Expected the thresholds of other devices to be ignored in:\n%s", out)
+	}
+
+	// The thresholds are cached, only the first reading read them
+	if !strings.Contains(out, `goapi_db_query_duration_seconds_count{operation="read_many",repository="threshold"} 1`+"\n") {
+		t.Errorf("Expected the thresholds to be read once")
+	}
+}
+
+// * Readings are counted against the thresholds of their own tenant, each tenant has its own cache
+func TestBreachesByTenant(t *testing.T) {
+	m := metrics.New()
+	acme := auth.WithPrincipal(context.Background(), auth.Principal{Username: "acme-user", Tenant: "acme"})
+	globex := auth.WithPrincipal(context.Background(), auth.Principal{Username: "globex-user", Tenant: "globex"})
+	thresholds := Memory.NewThresholdRepository()
+	if err := thresholds.Create(&models.Threshold{SensorType: "Temperature", MinValue: 0, MaxValue: 30}, acme); err != nil {
+		t.Fatal(err)
+	}
+
+	observer := metrics.NewIngestionObserver(m, thresholds)
+	observer.Observe(&models.Data{DeviceID: "device1", Type: "type1", TemperatureValue: 35}, globex)
+	if out := scrape(t, m); strings.Contains(out, "goapi_threshold_breaches_total{") {
+		t.Errorf("Expected no breach without a threshold of the tenant in:\n%s", out)
+	}
+	observer.Observe(&models.Data{DeviceID: "device1", Type: "type1", TemperatureValue: 35}, acme)
+	if out := scrape(t, m); !strings.Contains(out, `goapi_threshold_breaches_total{bound="max",sensor_type="Temperature"} 1`+"\n") {
+		t.Errorf("Expected the breach of the threshold of acme in:\n%s", out)
+	}
+}
+
+// * Every tenant has a series for each of its first 100 devices, the readings of further devices are counted together
+func TestIngestedDevicesAreBounded(t *testing.T) {
+	m := metrics.New()
+	acme := auth.WithPrincipal(context.Background(), auth.Principal{Username: "acme-user", Tenant: "acme"})
+	observer := metrics.NewIngestionObserver(m, Memory.NewThresholdRepository())
+	for i := range 102 {
+		observer.Observe(&models.Data{DeviceID: fmt.Sprintf("device%d", i), Type: "type1"}, acme)
+	}
+	observer.Observe(&models.Data{DeviceID: "device0", Type: "type1"}, acme)
+	observer.Observe(&models.Data{DeviceID: "device0", Type: "type1"}, context.Background())
+
+	out := scrape(t, m)
+	for _, expected := range []string{
+		`goapi_readings_ingested_total{device_id="device0",tenant="acme",type="type1"} 2`,
+		`goapi_readings_ingested_total{device_id="device99",tenant="acme",type="type1"} 1`,
+		`goapi_readings_ingested_total{device_id="other",tenant="acme",type="type1"} 2`,
+		`goapi_readings_ingested_total{device_id="device0",tenant="default",type="type1"} 1`,
+	} {
+		if !strings.Contains(out, expected+"\n") {
+			t.Errorf("Expected %s in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, `device_id="device100"`) {
+		t.Errorf("Expected no series beyond the first 100 devices in:\n%s", out)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *metrics.Metrics
+	repo := metrics.InstrumentDataRepository(Memory.NewDataRepository(), m)
+	data := &models.Data{DeviceID: "device1"}
+	if err := repo.Create(data, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.ReadOne(data.ID, context.Background()); err != nil || got == nil {
+		t.Errorf("Expected the created reading, got %v %v", got, err)
+	}
+	metrics.NewIngestionObserver(m, Memory.NewThresholdRepository()).Observe(&models.Data{DeviceID: "device1"}, context.Background())
+	m.ObserveRequest("/data", "GET", "200", time.Millisecond)
+}
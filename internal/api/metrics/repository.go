@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// * The Instrument functions wrap a repository so that every call is traced and its latency recorded in m, which may be nil *
+func InstrumentDataRepository(repo models.DataRepository, m *Metrics) models.DataRepository {
+	return &dataRepository{next: repo, m: m}
+}
+
+type dataRepository struct {
+	next models.DataRepository
+	m    *Metrics
+}
+
+func (r *dataRepository) Create(data *models.Data, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "create")
+	defer func() { end(err) }()
+	return r.next.Create(data, ctx)
+}
+
+func (r *dataRepository) ReadOne(id int, ctx context.Context) (_ *models.Data, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "read_one")
+	defer func() { end(err) }()
+	return r.next.ReadOne(id, ctx)
+}
+
+func (r *dataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) (_ []*models.Data, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "read_many")
+	defer func() { end(err) }()
+	return r.next.ReadMany(page, rowsPerPage, ctx)
+}
+
+func (r *dataRepository) ReadRange(from time.Time, to time.Time, ctx context.Context) (_ []*models.Data, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "read_range")
+	defer func() { end(err) }()
+	return r.next.ReadRange(from, to, ctx)
+}
+
+func (r *dataRepository) Update(data *models.Data, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "update")
+	defer func() { end(err) }()
+	return r.next.Update(data, ctx)
+}
+
+func (r *dataRepository) Modify(id int, change func(data *models.Data) error, ctx context.Context) (_ *models.Data, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "modify")
+	defer func() { end(err) }()
+	return r.next.Modify(id, change, ctx)
+}
+
+func (r *dataRepository) Delete(data *models.Data, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "delete")
+	defer func() { end(err) }()
+	return r.next.Delete(data, ctx)
+}
+
+func (r *dataRepository) Undelete(id int, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "undelete")
+	defer func() { end(err) }()
+	return r.next.Undelete(id, ctx)
+}
+
+func (r *dataRepository) Purge(before string, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "data", "purge")
+	defer func() { end(err) }()
+	return r.next.Purge(before, ctx)
+}
+
+func InstrumentThresholdRepository(repo models.ThresholdRepository, m *Metrics) models.ThresholdRepository {
+	return &thresholdRepository{next: repo, m: m}
+}
+
+type thresholdRepository struct {
+	next models.ThresholdRepository
+	m    *Metrics
+}
+
+func (r *thresholdRepository) Create(threshold *models.Threshold, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "create")
+	defer func() { end(err) }()
+	return r.next.Create(threshold, ctx)
+}
+
+func (r *thresholdRepository) ReadOne(id int, ctx context.Context) (_ *models.Threshold, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "read_one")
+	defer func() { end(err) }()
+	return r.next.ReadOne(id, ctx)
+}
+
+func (r *thresholdRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) (_ []*models.Threshold, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "read_many")
+	defer func() { end(err) }()
+	return r.next.ReadMany(page, rowsPerPage, ctx)
+}
+
+func (r *thresholdRepository) ReadByType(sensorType string, deviceID string, ctx context.Context) (_ *models.Threshold, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "read_by_type")
+	defer func() { end(err) }()
+	return r.next.ReadByType(sensorType, deviceID, ctx)
+}
+
+func (r *thresholdRepository) ReadForDevice(deviceID string, ctx context.Context) (_ []*models.Threshold, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "read_for_device")
+	defer func() { end(err) }()
+	return r.next.ReadForDevice(deviceID, ctx)
+}
+
+func (r *thresholdRepository) Update(threshold *models.Threshold, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "update")
+	defer func() { end(err) }()
+	return r.next.Update(threshold, ctx)
+}
+
+func (r *thresholdRepository) Modify(id int, change func(threshold *models.Threshold) error, ctx context.Context) (_ *models.Threshold, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "modify")
+	defer func() { end(err) }()
+	return r.next.Modify(id, change, ctx)
+}
+
+func (r *thresholdRepository) Delete(threshold *models.Threshold, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "delete")
+	defer func() { end(err) }()
+	return r.next.Delete(threshold, ctx)
+}
+
+func (r *thresholdRepository) Undelete(id int, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "undelete")
+	defer func() { end(err) }()
+	return r.next.Undelete(id, ctx)
+}
+
+func (r *thresholdRepository) Purge(before string, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "purge")
+	defer func() { end(err) }()
+	return r.next.Purge(before, ctx)
+}
+
+func (r *thresholdRepository) Import(plan func(current []*models.Threshold) ([]*models.ThresholdChange, error), ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "import")
+	defer func() { end(err) }()
+	return r.next.Import(plan, ctx)
+}
+
+func (r *thresholdRepository) ReadHistory(thresholdID int, ctx context.Context) (_ []*models.ThresholdHistory, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "read_history")
+	defer func() { end(err) }()
+	return r.next.ReadHistory(thresholdID, ctx)
+}
+
+func (r *thresholdRepository) ReadVersion(thresholdID int, version int, ctx context.Context) (_ *models.ThresholdHistory, err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "read_version")
+	defer func() { end(err) }()
+	return r.next.ReadVersion(thresholdID, version, ctx)
+}
+
+func (r *thresholdRepository) Restore(threshold *models.Threshold, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "threshold", "restore")
+	defer func() { end(err) }()
+	return r.next.Restore(threshold, ctx)
+}
+
+func InstrumentRuleRepository(repo models.RuleRepository, m *Metrics) models.RuleRepository {
+	return &ruleRepository{next: repo, m: m}
+}
+
+type ruleRepository struct {
+	next models.RuleRepository
+	m    *Metrics
+}
+
+func (r *ruleRepository) Create(rule *models.Rule, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "create")
+	defer func() { end(err) }()
+	return r.next.Create(rule, ctx)
+}
+
+func (r *ruleRepository) ReadOne(id int, ctx context.Context) (_ *models.Rule, err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "read_one")
+	defer func() { end(err) }()
+	return r.next.ReadOne(id, ctx)
+}
+
+func (r *ruleRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) (_ []*models.Rule, err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "read_many")
+	defer func() { end(err) }()
+	return r.next.ReadMany(page, rowsPerPage, ctx)
+}
+
+func (r *ruleRepository) ReadEnabled(ctx context.Context) (_ []*models.Rule, err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "read_enabled")
+	defer func() { end(err) }()
+	return r.next.ReadEnabled(ctx)
+}
+
+func (r *ruleRepository) Update(rule *models.Rule, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "update")
+	defer func() { end(err) }()
+	return r.next.Update(rule, ctx)
+}
+
+func (r *ruleRepository) Delete(rule *models.Rule, ctx context.Context) (_ int64, err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "delete")
+	defer func() { end(err) }()
+	return r.next.Delete(rule, ctx)
+}
+
+func (r *ruleRepository) ReadTags(deviceID string, ctx context.Context) (_ []string, err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "read_tags")
+	defer func() { end(err) }()
+	return r.next.ReadTags(deviceID, ctx)
+}
+
+func (r *ruleRepository) SetTags(deviceID string, tags []string, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "set_tags")
+	defer func() { end(err) }()
+	return r.next.SetTags(deviceID, tags, ctx)
+}
+
+func (r *ruleRepository) CreateEvent(event *models.RuleEvent, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "create_event")
+	defer func() { end(err) }()
+	return r.next.CreateEvent(event, ctx)
+}
+
+func (r *ruleRepository) ReadEvents(page int, rowsPerPage int, ctx context.Context) (_ []*models.RuleEvent, err error) {
+	ctx, end := r.m.startQuery(ctx, "rule", "read_events")
+	defer func() { end(err) }()
+	return r.next.ReadEvents(page, rowsPerPage, ctx)
+}
+
+func InstrumentAuditRepository(repo models.AuditRepository, m *Metrics) models.AuditRepository {
+	return &auditRepository{next: repo, m: m}
+}
+
+type auditRepository struct {
+	next models.AuditRepository
+	m    *Metrics
+}
+
+func (r *auditRepository) Create(entry *models.AuditEntry, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "audit", "create")
+	defer func() { end(err) }()
+	return r.next.Create(entry, ctx)
+}
+
+func (r *auditRepository) ReadMany(filter models.AuditFilter, page int, rowsPerPage int, ctx context.Context) (_ []*models.AuditEntry, err error) {
+	ctx, end := r.m.startQuery(ctx, "audit", "read_many")
+	defer func() { end(err) }()
+	return r.next.ReadMany(filter, page, rowsPerPage, ctx)
+}
+
+func (r *auditRepository) Walk(fn func(entry *models.AuditEntry) error, ctx context.Context) (err error) {
+	ctx, end := r.m.startQuery(ctx, "audit", "walk")
+	defer func() { end(err) }()
+	return r.next.Walk(fn, ctx)
+}
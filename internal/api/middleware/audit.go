@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/logging"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// * AuditRecorder persists audit entries, implemented by the audit service *
+type AuditRecorder interface {
+	Record(entry *models.AuditEntry, ctx context.Context) error
+}
+
+// * AuditMiddleware records every POST, PUT, PATCH and DELETE request with its principal and outcome *
+// * It runs before authentication so that rejected attempts are recorded too, callers that didn't authenticate are "anonymous" *
+func AuditMiddleware(recorder AuditRecorder, logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch && r.Method != http.MethodDelete {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// * The ID is normally assigned by the RequestID middleware
+			requestID := logging.RequestID(r.Context())
+			if requestID == "" {
+				requestID = r.Header.Get("X-Request-ID")
+				if !validRequestID(requestID) {
+					requestID = newRequestID()
+				}
+				w.Header().Set("X-Request-ID", requestID)
+			}
+
+			// * The principal is stored by the authentication middleware further down the chain
+			ctx, principal := auth.Track(r.Context())
+			rec := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			// * The entry goes to the audit chain of the caller's tenant, anonymous callers belong to the default tenant
+			recordCtx := r.Context()
+			if p, ok := principal(); ok {
+				recordCtx = auth.WithPrincipal(recordCtx, p)
+			}
+			username := auth.Username(recordCtx)
+			entry := &models.AuditEntry{
+				Principal:  username,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				ResourceID: resourceID(r, rec),
+				Status:     rec.status,
+				RequestID:  requestID,
+			}
+
+			// * The entry is written even if the client has already gone away
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(recordCtx), 2*time.Second)
+			defer cancel()
+			if err := recorder.Record(entry, ctx); err != nil {
+				logger.ErrorContext(r.Context(), "Error recording audit entry", "error", err, "entry", entry)
+			}
+		})
+	}
+}
+
+// * auditResponseWriter captures the status code and the beginning of the body for resource ID lookup *
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+const auditBodyLimit = 4096
+
+func (w *auditResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	if room := auditBodyLimit - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(room, len(b))])
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// * resourceID finds the ID of the affected resource: route parameter, ?id= query, numeric path segment or the "id" of a created resource *
+func resourceID(r *http.Request, rec *auditResponseWriter) string {
+	if id := r.PathValue("id"); id != "" {
+		return id
+	}
+	if id := r.URL.Query().Get("id"); id != "" {
+		return id
+	}
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(segments[i]); err == nil {
+			return segments[i]
+		}
+	}
+	if rec.status >= 200 && rec.status < 300 {
+		var body struct {
+			ID json.Number `json:"id"`
+		}
+		if json.Unmarshal(rec.body.Bytes(), &body) == nil {
+			return body.ID.String()
+		}
+	}
+	return ""
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recorderStub struct {
+	entries []*models.AuditEntry
+}
+
+func (rs *recorderStub) Record(entry *models.AuditEntry, ctx context.Context) error {
+	rs.entries = append(rs.entries, entry)
+	return nil
+}
+
+// * Test: Read-only requests are not audited
+func TestAuditSkipsGet(t *testing.T) {
+	recorder := &recorderStub{}
+	handler := AuditMiddleware(recorder, slog.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/data/1", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(recorder.entries) != 0 {
+		t.Fatalf("Expected no audit entries, got %d", len(recorder.entries))
+	}
+}
+
+// * Test: A DELETE is recorded with principal, resource ID, status and request ID
+func TestAuditRecordsDelete(t *testing.T) {
+	recorder := &recorderStub{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := AuditMiddleware(recorder, slog.Default())(mux)
+
+	req := httptest.NewRequest(http.MethodDelete, "/data/7", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Username: "saurav"}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(recorder.entries) != 1 {
+		t.Fatalf("Expected one audit entry, got %d", len(recorder.entries))
+	}
+	entry := recorder.entries[0]
+	if entry.Principal != "saurav" || entry.Method != http.MethodDelete || entry.ResourceID != "7" || entry.Status != http.StatusNoContent || entry.RequestID != "req-1" {
+		t.Errorf("Unexpected audit entry: %+v", entry)
+	}
+}
+
+// * Test: The ID of a created resource is taken from the response body
+func TestAuditRecordsCreatedID(t *testing.T) {
+	recorder := &recorderStub{}
+	handler := AuditMiddleware(recorder, slog.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":42,"device_id":"device1"}`))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/data", nil))
+
+	if len(recorder.entries) != 1 || recorder.entries[0].ResourceID != "42" {
+		t.Fatalf("Expected resource ID 42, got %+v", recorder.entries)
+	}
+	if recorder.entries[0].Principal != "anonymous" {
+		t.Errorf("Expected anonymous principal, got %s", recorder.entries[0].Principal)
+	}
+	if rr.Header().Get("X-Request-ID") == "" {
+		t.Errorf("Expected a generated X-Request-ID header")
+	}
+}
+
+// * Test: The principal stored by an authentication middleware further down the chain is recorded
+func TestAuditRecordsLaterPrincipal(t *testing.T) {
+	recorder := &recorderStub{}
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{Username: "saurav"})))
+		})
+	}
+	handler := AuditMiddleware(recorder, slog.Default())(authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/threshold/3", nil))
+
+	if len(recorder.entries) != 1 || recorder.entries[0].Principal != "saurav" || recorder.entries[0].Status != http.StatusForbidden {
+		t.Fatalf("Expected the forbidden write of saurav, got %+v", recorder.entries)
+	}
+}
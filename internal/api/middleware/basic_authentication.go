@@ -1,12 +1,31 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"encoding/base64"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
 	"net/http"
+	"slices"
 	"strings"
 )
 
+// * BasicAuthenticationMiddleware authenticates against the default users of the configuration *
 func BasicAuthenticationMiddleware(next http.Handler) http.Handler {
+	defaults := config.Default()
+	return BasicAuthentication(defaults.Auth, defaults.Tenants.Users)(next)
+}
+
+// * BasicAuthentication authenticates against the configured users, tenants maps user names to their tenant *
+func BasicAuthentication(settings config.Auth, tenants map[string]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return basicAuthentication(next, settings, tenants)
+	}
+}
+
+func basicAuthentication(next http.Handler, settings config.Auth, tenants map[string]string) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -16,53 +35,58 @@ func BasicAuthenticationMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// * Devices authenticated by their client certificate don't send credentials
+		if _, ok := auth.Device(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 
 		if authHeader == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"error": "Unauthorized: Missing credentials."}`))
+			problem.Write(w, r, service.DataError{Code: service.CodeUnauthorized, Message: "Unauthorized: Missing credentials."})
 			return
 		}
 
 		// * Split the Authorization header to get the 'Basic' part and the encoded credentials part
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Basic" {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Malformed or invalid Authorization header. [1]"}`))
+			problem.Write(w, r, service.DataError{Code: service.CodeInvalidRequest, Message: "Malformed or invalid Authorization header. [1]"})
 			return
 		}
 
 		// * Decode the credentials part of the header
 		decoded, err := base64.StdEncoding.DecodeString(headerParts[1])
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Malformed or invalid Authorization header. [2]"}`))
+			problem.Write(w, r, service.DataError{Code: service.CodeInvalidRequest, Message: "Malformed or invalid Authorization header. [2]"})
 			return
 		}
 
 		// * Split the decoded credentials to get the username and password
 		credentials := strings.SplitN(string(decoded), ":", 2)
 		if len(credentials) != 2 {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Malformed or invalid Authorization header. [3]"}`))
+			problem.Write(w, r, service.DataError{Code: service.CodeInvalidRequest, Message: "Malformed or invalid Authorization header. [3]"})
 			return
 		}
 
 		username, password := credentials[0], credentials[1]
 
-		if !validateUser(username, password) {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"error": "Unauthorized: Invalid credentials."}`))
+		if !validateUser(settings, username, password) {
+			problem.Write(w, r, service.DataError{Code: service.CodeUnauthorized, Message: "Unauthorized: Invalid credentials."})
 			return
 		}
 
+		// * Store the principal in the request context for auditing and to scope the repositories by its tenant
+		ctx := auth.WithPrincipal(r.Context(), auth.Principal{Username: username, Admin: slices.Contains(settings.Admins, username), Tenant: tenants[username]})
+
 		// Call the next handler in the chain
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func validateUser(username, password string) bool {
+func validateUser(settings config.Auth, username, password string) bool {
 
-	// ! This is a dummy implementation, replace this with real authentication logic
-	return username == "saurav" && password == "amatya"
+	// * Compare in constant time so that response times don't leak the password
+	expected, ok := settings.Users[username]
+	return ok && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
 }
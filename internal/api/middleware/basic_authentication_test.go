@@ -1,6 +1,10 @@
 package middleware
 
 import (
+	"goapi/internal/api/auth"
+	"goapi/internal/api/config"
+	"goapi/internal/api/problem/problemtest"
+	service "goapi/internal/api/service/data"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -25,10 +29,7 @@ func TestBasicAuthMissingAuthHeader(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Unauthorized: Missing credentials."}`
-	if rr.Body.String() != expected {
-		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeUnauthorized, "Unauthorized: Missing credentials.")
 }
 
 // * Test: Invalid Authorization header
@@ -51,10 +52,7 @@ func TestBasicAuthMalformedHeader(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Malformed or invalid Authorization header. [1]"}`
-	if rr.Body.String() != expected {
-		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "Malformed or invalid Authorization header. [1]")
 }
 
 func TestBasicAuthErrorOnBase64Decoding(t *testing.T) {
@@ -76,10 +74,7 @@ func TestBasicAuthErrorOnBase64Decoding(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Malformed or invalid Authorization header. [2]"}`
-	if rr.Body.String() != expected {
-		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "Malformed or invalid Authorization header. [2]")
 }
 
 func TestBasicAuthErrorOnSplitDecodedString(t *testing.T) {
@@ -101,10 +96,7 @@ func TestBasicAuthErrorOnSplitDecodedString(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Malformed or invalid Authorization header. [3]"}`
-	if rr.Body.String() != expected {
-		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeInvalidRequest, "Malformed or invalid Authorization header. [3]")
 }
 
 func TestBasicAuthInvalidCredentials(t *testing.T) {
@@ -126,9 +118,27 @@ func TestBasicAuthInvalidCredentials(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Unauthorized: Invalid credentials."}`
-	if rr.Body.String() != expected {
-		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeUnauthorized, "Unauthorized: Invalid credentials.")
+
+}
 
+// * Test: the principal carries the tenant of the user, users that aren't listed belong to the default tenant
+func TestBasicAuthTenant(t *testing.T) {
+	settings := config.Auth{Users: map[string]string{"alice": "secret", "bob": "secret"}}
+	tenants := map[string]string{"alice": "acme"}
+
+	for username, expected := range map[string]string{"alice": "acme", "bob": auth.DefaultTenant} {
+		var tenant string
+		handler := BasicAuthentication(settings, tenants)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant = auth.Tenant(r.Context())
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/data", nil)
+		req.SetBasicAuth(username, "secret")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || tenant != expected {
+			t.Errorf("Expected %s to belong to %s, got %q with status code %d", username, expected, tenant, rr.Code)
+		}
+	}
 }
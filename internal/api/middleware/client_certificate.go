@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/problem"
+	"net/http"
+	"slices"
+)
+
+// * deviceRoutes are the operations a device may call with its certificate, keyed by route pattern *
+var deviceRoutes = map[string][]string{
+	"/data":                            {http.MethodPost},
+	"/devices/{device_id}/thresholds":  {http.MethodGet, http.MethodHead},
+	"/threshold/by-type/{sensor_type}": {http.MethodGet, http.MethodHead},
+}
+
+// * ClientCertificateAuthentication authenticates devices by their verified TLS client certificate *
+// * devices maps a certificate subject common name or SAN to a device_id, other callers fall through to Basic authentication *
+// * tenants maps a device_id to its tenant, routes finds the route of a request, devices are refused every other route *
+func ClientCertificateAuthentication(devices map[string]string, tenants map[string]string, routes Router) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			// * Only certificates verified against the client CAs during the handshake are trusted
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			deviceID, ok := certificateDevice(r.TLS.PeerCertificates[0], devices)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// * A device certificate only reaches the device routes, preflight requests need no principal
+			if r.Method != http.MethodOptions && !deviceRoute(routes, r) {
+				problem.Forbidden(w, r, "Forbidden: client certificates may only send readings and read thresholds.")
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), auth.Principal{Username: "device:" + deviceID, Device: deviceID, Tenant: tenants[deviceID]})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func deviceRoute(routes Router, r *http.Request) bool {
+	_, pattern := routes.Handler(r)
+	return slices.Contains(deviceRoutes[pattern], r.Method)
+}
+
+// * certificateDevice looks up the common name first, then the DNS, URI and email SANs *
+func certificateDevice(cert *x509.Certificate, devices map[string]string) (string, bool) {
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+
+	for _, name := range names {
+		if deviceID, ok := devices[name]; ok && name != "" {
+			return deviceID, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"goapi/internal/api/auth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func deviceMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, pattern := range []string{"/data", "/data/{id}", "/devices/{device_id}/thresholds", "/threshold/by-type/{sensor_type}"} {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {})
+	}
+	return mux
+}
+
+func requestWithCertificate(cert *x509.Certificate, verified bool) *http.Request {
+	return deviceRequest(http.MethodPost, "/data", cert, verified)
+}
+
+func deviceRequest(method string, path string, cert *x509.Certificate, verified bool) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if verified {
+		req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return req
+}
+
+// * Test: the device is found by common name or SAN, its tenant by the device_id
+func TestClientCertificateDevice(t *testing.T) {
+	devices := map[string]string{
+		"sensor-1":                  "device1",
+		"sensor-2.example.com":      "device2",
+		"spiffe://example/sensor-3": "device3",
+	}
+	tenants := map[string]string{"device1": "acme"}
+	uri, _ := url.Parse("spiffe://example/sensor-3")
+
+	tests := []struct {
+		cert     *x509.Certificate
+		expected string
+		tenant   string
+	}{
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "sensor-1"}}, "device1", "acme"},
+		{&x509.Certificate{Subject: pkix.Name{CommonName: "other"}, DNSNames: []string{"sensor-2.example.com"}}, "device2", auth.DefaultTenant},
+		{&x509.Certificate{URIs: []*url.URL{uri}}, "device3", auth.DefaultTenant},
+	}
+
+	for _, test := range tests {
+		var device, tenant string
+		handler := ClientCertificateAuthentication(devices, tenants, deviceMux())(BasicAuthenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			device, _ = auth.Device(r.Context())
+			tenant = auth.Tenant(r.Context())
+		})))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, requestWithCertificate(test.cert, true))
+
+		if rr.Code != http.StatusOK || device != test.expected || tenant != test.tenant {
+			t.Errorf("Expected device %s of tenant %s, got %q of %q with status code %d", test.expected, test.tenant, device, tenant, rr.Code)
+		}
+	}
+}
+
+// * Test: unverified or unknown certificates need Basic credentials
+func TestClientCertificateFallsBackToBasicAuth(t *testing.T) {
+	devices := map[string]string{"sensor-1": "device1"}
+
+	for _, req := range []*http.Request{
+		requestWithCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "sensor-1"}}, false),
+		requestWithCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}}, true),
+	} {
+		handler := ClientCertificateAuthentication(devices, nil, deviceMux())(BasicAuthenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("Handler should not have been called")
+		})))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	}
+}
+
+// * Test: a device certificate only reaches the device routes
+func TestClientCertificateRoutes(t *testing.T) {
+	devices := map[string]string{"sensor-1": "device1"}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "sensor-1"}}
+
+	tests := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{http.MethodPost, "/data", http.StatusOK},
+		{http.MethodGet, "/devices/device1/thresholds", http.StatusOK},
+		{http.MethodGet, "/threshold/by-type/temperature", http.StatusOK},
+		{http.MethodOptions, "/data/1", http.StatusOK},
+		{http.MethodGet, "/data", http.StatusForbidden},
+		{http.MethodDelete, "/data/1", http.StatusForbidden},
+		{http.MethodPut, "/threshold/by-type/temperature", http.StatusForbidden},
+		{http.MethodGet, "/unknown", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		handler := ClientCertificateAuthentication(devices, nil, deviceMux())(BasicAuthenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, deviceRequest(test.method, test.path, cert, true))
+
+		if rr.Code != test.expected {
+			t.Errorf("%s %s: expected status code %d, got %d", test.method, test.path, test.expected, rr.Code)
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package middleware
 
 import (
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
 	"net/http"
 	"strings"
 )
 
 type Middleware func(http.Handler) http.Handler
 
+// * MergePatchContentType is the media type of a JSON merge patch (RFC 7396) *
+const MergePatchContentType = "application/merge-patch+json"
+
+// * CSVContentType is the media type of a CSV file, e.g. of thresholds to import *
+const CSVContentType = "text/csv"
+
 func ChainMiddleware(h http.Handler, middlewares ...Middleware) http.Handler {
 	for _, mw := range middlewares {
 		h = mw(h)
@@ -25,14 +33,16 @@ func CommonMiddleware(next http.Handler) http.Handler {
 		}
 
 		// * The request body should be JSON, and the Content-Type header must start with: application/json *
-		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
-			w.WriteHeader(http.StatusUnsupportedMediaType)
-			w.Write([]byte(`{"error": "Content-Type header should be set to: application/json."}`))
+		// * PATCH also takes a JSON merge patch, which has its own media type, and POST a CSV file to import *
+		contentType := r.Header.Get("Content-Type")
+		if !strings.HasPrefix(contentType, "application/json") && !(r.Method == http.MethodPatch && strings.HasPrefix(contentType, MergePatchContentType)) &&
+			!(r.Method == http.MethodPost && strings.HasPrefix(contentType, CSVContentType)) {
+			problem.Write(w, r, service.DataError{Code: service.CodeUnsupportedMediaType, Message: "Content-Type header should be set to: application/json."})
 			return
 		}
 
 		// * Set the Content-Type header of the response to application/json for all responses
-		// * On http.Error("..."), the Content-Type header will be set to text/plain; charset=utf-8
+		// * Errors are answered by the problem package as application/problem+json instead
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		next.ServeHTTP(w, r)
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"goapi/internal/api/problem/problemtest"
+	service "goapi/internal/api/service/data"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -24,10 +26,7 @@ func TestCommonInvalidContentType(t *testing.T) {
 		t.Fatalf("Expected status code 415, got: %d", rr.Code)
 	}
 
-	expected := `{"error": "Content-Type header should be set to: application/json."}`
-	if rr.Body.String() != expected {
-		t.Fatalf("Expected response body: %s, got: %s", expected, rr.Body.String())
-	}
+	problemtest.Expect(t, rr, service.CodeUnsupportedMediaType, "Content-Type header should be set to: application/json.")
 }
 
 func TestCommonCorrectContentType(t *testing.T) {
@@ -52,3 +51,22 @@ func TestCommonCorrectContentType(t *testing.T) {
 		t.Fatalf("Expected Access-Control-Allow-Origin: *, got: %s", rr.Header().Get("Access-Control-Allow-Origin"))
 	}
 }
+
+// * CSV files are only accepted on POST, where thresholds are imported *
+func TestCommonCSVContentType(t *testing.T) {
+	handler := CommonMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("POST", "/threshold/import", nil)
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected a CSV POST to pass, got: %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/threshold/1", nil)
+	req.Header.Set("Content-Type", "text/csv")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	problemtest.Expect(t, rr, service.CodeUnsupportedMediaType, "Content-Type header should be set to: application/json.")
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"goapi/internal/api/logging"
+	"goapi/internal/api/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// * Metrics counts requests and their latency per route and status code *
+// * It must run inside RequestID, the route pattern keeps the number of series bounded *
+func Metrics(m *metrics.Metrics) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			req, _ := logging.FromContext(r.Context())
+			route := req.Route
+			if route == "" {
+				route = "unmatched"
+			}
+			m.ObserveRequest(route, r.Method, strconv.Itoa(rec.status), time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"goapi/internal/api/logging"
+	"goapi/internal/api/openapi"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// * Request bodies larger than this are rejected before they are validated
+const maxBodySize = 1 << 20
+
+// * Validation rejects requests whose query parameters or JSON body don't match the operation in doc *
+// * With validateResponses, responses that don't match are logged, they are still sent as they are *
+// * It must run inside RequestID, which finds the route of the request; undocumented routes pass unchecked *
+func Validation(doc *openapi.Document, validateResponses bool, logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, _ := logging.FromContext(r.Context())
+			op := doc.Operation(req.Route, r.Method)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if op.RequestBody != nil {
+				var err error
+				body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+				if err != nil {
+					problem.Write(w, r, service.DataError{Code: service.CodeTooLarge, Message: "Request body is too large."})
+					return
+				}
+				// The handler decodes the body again
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+			if problems := doc.ValidateRequest(op, r.URL.Query(), r.Header.Get("Content-Type"), body); len(problems) > 0 {
+				logger.WarnContext(r.Context(), "Request does not match the specification", "problems", problems)
+				fields := make([]service.FieldError, len(problems))
+				for i, p := range problems {
+					fields[i] = service.FieldError{Field: p.Field, Message: p.Message}
+				}
+				problem.Write(w, r, service.DataError{Code: service.CodeInvalidRequest, Message: "Request does not match the API specification.", Fields: fields})
+				return
+			}
+
+			if !validateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rec := &responseBuffer{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// Server errors are undocumented and already logged by the handler
+			if rec.status < http.StatusInternalServerError {
+				if problems := doc.ValidateResponse(op, rec.status, w.Header().Get("Content-Type"), rec.body.Bytes()); len(problems) > 0 {
+					logger.ErrorContext(r.Context(), "Response does not match the specification", "status", rec.status, "problems", problems)
+				}
+			}
+			rec.flush()
+		})
+	}
+}
+
+// * responseBuffer holds back the response until it has been validated *
+type responseBuffer struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseBuffer) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *responseBuffer) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *responseBuffer) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+}
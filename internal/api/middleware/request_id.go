@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"goapi/internal/api/logging"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// * Router finds the route pattern of a request, implemented by http.ServeMux *
+type Router interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// * RequestID propagates the caller's X-Request-ID, or assigns a new one, into the request context and the response *
+// * routes names the matched route in the log lines of the request *
+func RequestID(routes Router) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			requestID := r.Header.Get("X-Request-ID")
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+			w.Header().Set("X-Request-ID", requestID)
+
+			_, route := routes.Handler(r)
+			ctx := logging.WithRequest(r.Context(), logging.Request{ID: requestID, Route: route, Start: time.Now()})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// * A caller supplied ID ends up in log lines and the audit log, only short IDs of safe characters are kept *
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// * RequestLogging logs every request with its status once it has been served *
+// * It must run inside RequestID so that the line carries the request ID, route and latency *
+func RequestLogging(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			level := slog.LevelInfo
+			if rec.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.Log(r.Context(), level, "Request served", "method", r.Method, "path", r.URL.Path, "status", rec.status, "bytes", rec.bytes)
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
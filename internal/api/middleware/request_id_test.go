@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"goapi/internal/api/logging"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// * Test: a valid caller ID is propagated, a missing or unsafe one is replaced
+func TestRequestID(t *testing.T) {
+	mux := http.NewServeMux()
+	var got logging.Request
+	mux.HandleFunc("/data/{id}", func(w http.ResponseWriter, r *http.Request) {
+		got, _ = logging.FromContext(r.Context())
+	})
+	handler := RequestID(mux)(mux)
+
+	tests := []struct {
+		header    string
+		propagate bool
+	}{
+		{"req-1.a_B", true},
+		{"", false},
+		{"bad id\nwith newline", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/data/1", nil)
+		req.Header.Set("X-Request-ID", test.header)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if got.ID == "" || rr.Header().Get("X-Request-ID") != got.ID {
+			t.Errorf("Expected the context and response to carry the same ID, got %q and %q", got.ID, rr.Header().Get("X-Request-ID"))
+		}
+		if (got.ID == test.header) != test.propagate {
+			t.Errorf("Header %q: expected propagated %v, got ID %q", test.header, test.propagate, got.ID)
+		}
+		if got.Route != "/data/{id}" {
+			t.Errorf("Expected route /data/{id}, got %q", got.Route)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"goapi/internal/api/config"
+	"net/http"
+)
+
+// * RequestSettingsMiddleware hands the configured timeout and page size to the handlers through the request context *
+func RequestSettingsMiddleware(settings config.Request) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(config.WithRequest(r.Context(), settings)))
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"goapi/internal/api/metrics"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// * Test: the spans of a POST /data continue the caller's trace down to the repository
+func TestTracingPropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ds := service.NewRepositoryDataService(metrics.InstrumentDataRepository(Memory.NewDataRepository(), nil), Memory.NewThresholdRepository())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
+		var data models.Data
+		json.NewDecoder(r.Body).Decode(&data)
+		if err := ds.Create(&data, r.Context()); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := ChainMiddleware(mux, Tracing(), RequestID(mux))
+
+	body, _ := json.Marshal(models.Data{DeviceID: "device1", Type: "type1", DateTime: models.NewTimestamp(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))})
+	req := httptest.NewRequest(http.MethodPost, "/data", bytes.NewReader(body))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+		if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Span %s is not part of the caller's trace", span.Name())
+		}
+	}
+
+	parents := map[string]string{
+		"POST /data":           "",
+		"DataService.Create":   "POST /data",
+		"DataService.Validate": "DataService.Create",
+		"data.create":          "DataService.Create",
+		"DataService.Observe":  "DataService.Create",
+	}
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s span, got %v", name, spans)
+			continue
+		}
+		expected := "00f067aa0ba902b7"
+		if parent != "" {
+			expected = spans[parent].SpanContext().SpanID().String()
+		}
+		if span.Parent().SpanID().String() != expected {
+			t.Errorf("Expected %s to be a child of %q", name, parent)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+// Package openapi builds the OpenAPI 3 document of the API from a table of
+// routes and validates requests and responses against it. Schemas are derived
+// from the Go types the handlers decode and encode, so a field added to a model
+// shows up in the document without further changes.
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+// * Route describes one method of a pattern registered on the ServeMux *
+type Route struct {
+	Method string
+	// Pattern is the ServeMux pattern, e.g. /data/{id}, path parameters are taken from it
+	Pattern     string
+	Tag         string
+	Summary     string
+	Description string
+	// Public routes need no authentication
+	Public    bool
+	Query     []Parameter
+	Body      *Body
+	Responses map[int]Response
+}
+
+// * Body is the JSON request body, Value is a value of the decoded type, e.g. models.Data{} *
+type Body struct {
+	Value any
+	// Required lists the properties the request must contain
+	Required []string
+	// ContentType defaults to application/json, only application/json bodies are validated
+	ContentType string
+	// Alternatives are other media types the body may be sent as, e.g. text/csv, they are documented without a schema
+	Alternatives []string
+}
+
+// * Response documents a status code, Value is a value of the encoded type or nil when there is no body *
+type Response struct {
+	Description string
+	Value       any
+	// ContentType defaults to application/json
+	ContentType string
+	// Alternatives are other media types of the body, they are documented without a schema
+	Alternatives []string
+}
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []SecurityRequirement            `json:"security,omitempty"`
+
+	formats map[reflect.Type]*Schema
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	Tags        []string                   `json:"tags,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	OperationID string                     `json:"operationId"`
+	Parameters  []Parameter                `json:"parameters,omitempty"`
+	RequestBody *RequestBody               `json:"requestBody,omitempty"`
+	Responses   map[string]*ResponseObject `json:"responses"`
+	Security    *[]SecurityRequirement     `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type ResponseObject struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type SecurityRequirement map[string][]string
+
+// * Schema is the subset of JSON Schema used by the document and understood by the validator *
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	// AdditionalProperties is false for structs, so that misspelt fields are rejected, or the schema of map values
+	AdditionalProperties any       `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema `json:"allOf,omitempty"`
+	OneOf                []*Schema `json:"oneOf,omitempty"`
+	Enum                 []string  `json:"enum,omitempty"`
+	Nullable             bool      `json:"nullable,omitempty"`
+}
+
+// * Format describes a type with its own JSON encoding, e.g. a timestamp, which the reflection of its fields would get wrong *
+type Format struct {
+	Value  any
+	Schema *Schema
+}
+
+// * Build creates the document of routes, every route is protected by HTTP Basic authentication unless it is Public *
+func Build(info Info, routes []Route, formats ...Format) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				"basicAuth": {Type: "http", Scheme: "basic"},
+			},
+		},
+		Security: []SecurityRequirement{{"basicAuth": {}}},
+		formats:  map[reflect.Type]*Schema{},
+	}
+	for _, format := range formats {
+		doc.formats[reflect.TypeOf(format.Value)] = format.Schema
+	}
+
+	for _, route := range routes {
+		op := &Operation{
+			Summary:     route.Summary,
+			Description: route.Description,
+			OperationID: operationID(route.Method, route.Pattern),
+			Parameters:  append(pathParameters(route.Pattern), route.Query...),
+			Responses:   map[string]*ResponseObject{},
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		if route.Public {
+			op.Security = &[]SecurityRequirement{}
+		}
+		if route.Body != nil {
+			schema := doc.schemaOf(reflect.TypeOf(route.Body.Value))
+			if len(route.Body.Required) > 0 {
+				schema = &Schema{AllOf: []*Schema{schema, {Required: route.Body.Required}}}
+			}
+			contentType := route.Body.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{contentType: {Schema: schema}}}
+			for _, alternative := range route.Body.Alternatives {
+				op.RequestBody.Content[alternative] = MediaType{}
+			}
+		}
+		for status, response := range route.Responses {
+			object := &ResponseObject{Description: response.Description}
+			if response.Value != nil || response.ContentType != "" {
+				contentType := response.ContentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				media := MediaType{}
+				if response.Value != nil {
+					media.Schema = doc.schemaOf(reflect.TypeOf(response.Value))
+				}
+				object.Content = map[string]MediaType{contentType: media}
+				for _, alternative := range response.Alternatives {
+					object.Content[alternative] = MediaType{}
+				}
+			}
+			op.Responses[strconv.Itoa(status)] = object
+		}
+
+		if doc.Paths[route.Pattern] == nil {
+			doc.Paths[route.Pattern] = map[string]*Operation{}
+		}
+		doc.Paths[route.Pattern][strings.ToLower(route.Method)] = op
+	}
+	return doc
+}
+
+// * Operation returns the operation of a ServeMux pattern and method, or nil if it is not documented *
+func (d *Document) Operation(pattern string, method string) *Operation {
+	return d.Paths[pattern][strings.ToLower(method)]
+}
+
+// * Patterns returns the documented patterns in order *
+func (d *Document) Patterns() []string {
+	patterns := make([]string, 0, len(d.Paths))
+	for pattern := range d.Paths {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// * schemaOf describes t, structs become components referenced by name *
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if schema, ok := d.formats[t]; ok {
+		// A copy, so that a pointer making it nullable doesn't change the other uses
+		copied := *schema
+		return &copied
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := d.schemaOf(t.Elem())
+		if elem.Ref != "" {
+			// Siblings of $ref are ignored, nullable needs a wrapper
+			return &Schema{AllOf: []*Schema{elem}, Nullable: true}
+		}
+		elem.Nullable = true
+		return elem
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return d.structSchema(t)
+		}
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Registered before the fields are described, so that recursive types like Condition terminate
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case reflect.Slice:
+		// A nil slice is encoded as null
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem()), Nullable: true}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+	// Anything else, e.g. an interface, accepts any value
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = d.schemaOf(field.Type)
+	}
+	return schema
+}
+
+// * pathParameters returns the wildcards of pattern, {id} is an integer and any other wildcard a string *
+func pathParameters(pattern string) []Parameter {
+	var params []Parameter
+	for _, segment := range strings.Split(pattern, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+		schema := &Schema{Type: "string"}
+		if name == "id" {
+			schema = &Schema{Type: "integer"}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+// * operationID names an operation after its method and path, e.g. GET /data/{id} becomes getDataById *
+func operationID(method string, pattern string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, "{") {
+			segment = "by-" + strings.Trim(segment, "{}.")
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+// * Message is the body of responses that only confirm an action *
+type Message struct {
+	Message string `json:"message"`
+}
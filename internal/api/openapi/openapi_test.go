@@ -0,0 +1,162 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/openapi"
+	"goapi/internal/api/repository/models"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func build() *openapi.Document {
+	return openapi.Build(openapi.Info{Title: "test", Version: "1"}, []openapi.Route{
+		{Method: "POST", Pattern: "/rule/{id}", Summary: "Replace a rule",
+			Query: []openapi.Parameter{{Name: "page", In: "query", Schema: &openapi.Schema{Type: "integer"}}},
+			Body:  &openapi.Body{Value: models.Rule{}, Required: []string{"name"}},
+			Responses: map[int]openapi.Response{
+				200: {Description: "The rule.", Value: models.Rule{}},
+				204: {Description: "No body."},
+			}},
+		{Method: "GET", Pattern: "/threshold/{id}/history", Public: true,
+			Responses: map[int]openapi.Response{200: {Description: "History.", Value: []models.ThresholdHistory{}}}},
+	})
+}
+
+func TestBuild(t *testing.T) {
+	doc := build()
+
+	op := doc.Operation("/rule/{id}", "POST")
+	if op == nil {
+		t.Fatal("expected the operation to be documented")
+	}
+	if op.OperationID != "postRuleById" {
+		t.Errorf("unexpected operation ID %q", op.OperationID)
+	}
+	if len(op.Parameters) != 2 || op.Parameters[0].In != "path" || op.Parameters[0].Schema.Type != "integer" {
+		t.Errorf("expected the path parameter first, got %+v", op.Parameters)
+	}
+
+	// Structs are components, the recursive Condition refers to itself
+	condition := doc.Components.Schemas["Condition"]
+	if condition == nil || condition.Properties["conditions"].Items.Ref != "#/components/schemas/Condition" {
+		t.Fatalf("unexpected Condition schema %+v", condition)
+	}
+	if condition.AdditionalProperties != false {
+		t.Errorf("expected unknown properties to be rejected")
+	}
+
+	// Pointers to structs are nullable
+	history := doc.Components.Schemas["ThresholdHistory"]
+	if before := history.Properties["before"]; !before.Nullable || before.AllOf[0].Ref != "#/components/schemas/Threshold" {
+		t.Errorf("unexpected schema of before %+v", before)
+	}
+
+	if public := doc.Operation("/threshold/{id}/history", "GET"); public.Security == nil || len(*public.Security) != 0 {
+		t.Errorf("expected the public route to need no authentication")
+	}
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc := build()
+	op := doc.Operation("/rule/{id}", "POST")
+
+	tests := []struct {
+		name     string
+		query    string
+		body     string
+		problems []string
+	}{
+		{"valid", "page=2", `{"name": "hot", "condition": {"op": "and", "conditions": [{"op": ">", "metric": "temperature", "value": 30}]}}`, nil},
+		{"missing body", "", ``, []string{"body is required"}},
+		{"invalid JSON", "", `{"name":`, []string{"body is not valid JSON"}},
+		{"required", "", `{"enabled": true}`, []string{"body.name is required"}},
+		{"types", "page=x", `{"name": 1, "min_devices": 1.5, "enabled": "yes"}`, []string{
+			"query.page must be an integer",
+			"body.enabled must be a boolean",
+			"body.min_devices must be an integer",
+			"body.name must be a string",
+		}},
+		{"nested", "", `{"name": "hot", "condition": {"op": ">", "conditions": [{"value": "30", "unit": "C"}]}}`, []string{
+			"body.condition.conditions[0].unit is not a known property",
+			"body.condition.conditions[0].value must be a number",
+		}},
+		{"not an object", "", `[]`, []string{"body must be an object"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			problems := messages(doc.ValidateRequest(op, query, "application/json", []byte(tt.body)))
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("expected %q, got %q", tt.problems, problems)
+			}
+		})
+	}
+
+	// * Bodies of other media types are left to the handler *
+	if problems := doc.ValidateRequest(op, nil, "text/csv", []byte("name,enabled\nhot,yes\n")); problems != nil {
+		t.Errorf("expected a CSV body not to be validated, got %q", messages(problems))
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc := build()
+	op := doc.Operation("/threshold/{id}/history", "GET")
+
+	body := `[{"id": 1, "threshold_id": 1, "version": 1, "action": "create", "changed_by": "saurav", "changed_at": "2024-01-01T00:00:00Z", "before": null,
+		"after": {"id": 1, "sensor_type": "temperature", "min_value": 10, "max_value": 30, "updated_at": "2024-01-01T00:00:00Z"}}]`
+	if problems := doc.ValidateResponse(op, 200, "application/json", []byte(body)); problems != nil {
+		t.Errorf("expected the history to match, got %q", messages(problems))
+	}
+	if problems := messages(doc.ValidateResponse(op, 200, "application/json; charset=utf-8", []byte(`[{"id": "1"}]`))); !reflect.DeepEqual(problems, []string{"response[0].id must be an integer"}) {
+		t.Errorf("unexpected problems %q", problems)
+	}
+	if problems := messages(doc.ValidateResponse(op, 200, "text/plain", []byte(`[]`))); !reflect.DeepEqual(problems, []string{`content type "text/plain" is not documented for status 200`}) {
+		t.Errorf("unexpected problems %q", problems)
+	}
+	if problems := messages(doc.ValidateResponse(op, 404, "", nil)); !reflect.DeepEqual(problems, []string{"status 404 is not documented"}) {
+		t.Errorf("unexpected problems %q", problems)
+	}
+	if problems := doc.ValidateResponse(doc.Operation("/rule/{id}", "POST"), 204, "", nil); problems != nil {
+		t.Errorf("expected an empty response to match, got %q", messages(problems))
+	}
+}
+
+// * Types with their own encoding are described by a Format instead of their fields *
+func TestFormat(t *testing.T) {
+	timestamp := &openapi.Schema{OneOf: []*openapi.Schema{{Type: "string", Format: "date-time"}, {Type: "number"}}, Nullable: true}
+	doc := openapi.Build(openapi.Info{Title: "test", Version: "1"}, []openapi.Route{
+		{Method: "POST", Pattern: "/data", Body: &openapi.Body{Value: models.Data{}}, Responses: map[int]openapi.Response{204: {Description: "Stored."}}},
+	}, openapi.Format{Value: models.Timestamp{}, Schema: timestamp})
+	op := doc.Operation("/data", "POST")
+
+	tests := []struct {
+		body     string
+		problems []string
+	}{
+		{`{"date_time": "2024-01-01T12:00:00.5+02:00"}`, nil},
+		{`{"date_time": 1704110400}`, nil},
+		{`{"date_time": null}`, nil},
+		{`{"date_time": "2024-01-01 12:00:00"}`, []string{"body.date_time must be one of string (date-time), number"}},
+		{`{"date_time": {"seconds": 1}}`, []string{"body.date_time must be one of string (date-time), number"}},
+	}
+	for _, tt := range tests {
+		if problems := messages(doc.ValidateRequest(op, nil, "application/json", []byte(tt.body))); !reflect.DeepEqual(problems, tt.problems) {
+			t.Errorf("%s: expected %q, got %q", tt.body, tt.problems, problems)
+		}
+	}
+	if _, ok := doc.Components.Schemas["Timestamp"]; ok {
+		t.Error("expected the timestamp not to be described by its fields")
+	}
+}
+
+func messages(violations []openapi.Violation) []string {
+	var out []string
+	for _, v := range violations {
+		out = append(out, v.String())
+	}
+	return out
+}
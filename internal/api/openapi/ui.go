@@ -0,0 +1,57 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"goapi/internal/api/problem"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// * Handler serves the document as JSON, a document that can't be encoded is logged and answered with 500 *
+func (d *Document) Handler(logger *slog.Logger) http.Handler {
+	body, err := json.MarshalIndent(d, "", "  ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			problem.Respond(w, r, logger, err, "Error encoding the OpenAPI document")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// * The bundled initializer points at the petstore, ours loads specURL and sends the JSON Content-Type the API requires
+const initializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: %q,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout",
+    requestInterceptor: function(request) {
+      request.headers['Content-Type'] = 'application/json';
+      return request;
+    }
+  });
+};
+`
+
+// * UIHandler serves the bundled Swagger UI under prefix, e.g. /docs/, showing the document at specURL *
+func UIHandler(prefix string, specURL string) http.Handler {
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+	script := []byte(fmt.Sprintf(initializer, specURL))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, prefix) == "swagger-initializer.js" {
+			w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			w.Write(script)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,258 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// * Violation is one way in which a request or response breaks the specification *
+// * Field is the path of the offending value, e.g. body.condition.value or query.page *
+type Violation struct {
+	Field   string
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Field + " " + v.Message
+}
+
+// * ValidateRequest checks the query parameters and JSON body of a request to op, it returns one violation per problem *
+func (d *Document) ValidateRequest(op *Operation, query url.Values, contentType string, body []byte) []Violation {
+	var problems []Violation
+	for _, param := range op.Parameters {
+		if param.In != "query" {
+			continue
+		}
+		value, present := query[param.Name]
+		if !present {
+			if param.Required {
+				problems = append(problems, Violation{"query." + param.Name, "is required"})
+			}
+			continue
+		}
+		if problem := checkParameter(param.Schema, value[0]); problem != "" {
+			problems = append(problems, Violation{"query." + param.Name, problem})
+		}
+	}
+
+	if op.RequestBody == nil {
+		return problems
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			problems = append(problems, Violation{"body", "is required"})
+		}
+		return problems
+	}
+	// Other media types, e.g. CSV files, are read and checked by the handler
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+		return problems
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	if !ok || media.Schema == nil {
+		return problems
+	}
+	return append(problems, d.validateJSON(media.Schema, body, "body")...)
+}
+
+// * ValidateResponse checks that status and contentType are documented for op and that the body matches its schema *
+func (d *Document) ValidateResponse(op *Operation, status int, contentType string, body []byte) []Violation {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return []Violation{{"status", fmt.Sprintf("%d is not documented", status)}}
+	}
+	if len(response.Content) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return []Violation{{"content type", fmt.Sprintf("%q is not documented for status %d", contentType, status)}}
+	}
+	if media.Schema == nil {
+		return nil
+	}
+	return d.validateJSON(media.Schema, body, "response")
+}
+
+func (d *Document) validateJSON(schema *Schema, body []byte, path string) []Violation {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return []Violation{{path, "is not valid JSON"}}
+	}
+	return d.Validate(schema, value, path)
+}
+
+// * Validate checks a decoded JSON value against schema, path names the value in the messages *
+func (d *Document) Validate(schema *Schema, value any, path string) []Violation {
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return []Violation{{path, "refers to the unknown schema " + schema.Ref}}
+		}
+		return d.Validate(resolved, value, path)
+	}
+	if value == nil {
+		if schema.Nullable || schema.Type == "" && len(schema.AllOf) == 0 {
+			return nil
+		}
+		return []Violation{{path, "must not be null"}}
+	}
+
+	var problems []Violation
+	for _, part := range schema.AllOf {
+		problems = append(problems, d.Validate(part, value, path)...)
+	}
+	if len(schema.OneOf) > 0 {
+		problems = append(problems, d.validateOneOf(schema.OneOf, value, path)...)
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return append(problems, Violation{path, "must be an object"})
+		}
+		problems = append(problems, d.validateObject(schema, object, path)...)
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return append(problems, Violation{path, "must be an array"})
+		}
+		if schema.Items != nil {
+			for i, item := range array {
+				problems = append(problems, d.Validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return append(problems, Violation{path, "must be a string"})
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			problems = append(problems, Violation{path, "must be one of " + strings.Join(schema.Enum, ", ")})
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				problems = append(problems, Violation{path, "must be an RFC 3339 date-time"})
+			}
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return append(problems, Violation{path, "must be a number"})
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return append(problems, Violation{path, "must be an integer"})
+		}
+		if f, err := n.Float64(); err != nil || f != math.Trunc(f) {
+			problems = append(problems, Violation{path, "must be an integer"})
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return append(problems, Violation{path, "must be a boolean"})
+		}
+	default:
+		// Only required properties without a type, as in the allOf of a request body
+		if object, ok := value.(map[string]any); ok {
+			problems = append(problems, d.validateObject(schema, object, path)...)
+		}
+	}
+	return problems
+}
+
+// * validateOneOf reports a single violation naming the alternatives when value matches none or several of them *
+func (d *Document) validateOneOf(alternatives []*Schema, value any, path string) []Violation {
+	matches := 0
+	names := make([]string, len(alternatives))
+	for i, alternative := range alternatives {
+		if len(d.Validate(alternative, value, path)) == 0 {
+			matches++
+		}
+		names[i] = alternative.Type
+		if alternative.Format != "" {
+			names[i] += " (" + alternative.Format + ")"
+		}
+	}
+	switch matches {
+	case 1:
+		return nil
+	case 0:
+		return []Violation{{path, "must be one of " + strings.Join(names, ", ")}}
+	default:
+		return []Violation{{path, "must match only one of " + strings.Join(names, ", ")}}
+	}
+}
+
+func (d *Document) validateObject(schema *Schema, object map[string]any, path string) []Violation {
+	var problems []Violation
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			problems = append(problems, Violation{path + "." + name, "is required"})
+		}
+	}
+
+	// Properties are checked in name order so that the messages are stable
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := schema.Properties[name]; ok {
+			problems = append(problems, d.Validate(property, object[name], path+"."+name)...)
+			continue
+		}
+		switch additional := schema.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				problems = append(problems, Violation{path + "." + name, "is not a known property"})
+			}
+		case *Schema:
+			problems = append(problems, d.Validate(additional, object[name], path+"."+name)...)
+		}
+	}
+	return problems
+}
+
+// * checkParameter checks a query parameter, which is always a string on the wire *
+func checkParameter(schema *Schema, value string) string {
+	switch schema.Type {
+	case "integer":
+		if _, err := strconv.Atoi(value); err != nil {
+			return "must be an integer"
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number"
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be true or false"
+		}
+	}
+	if len(schema.Enum) > 0 && !contains(schema.Enum, value) {
+		return "must be one of " + strings.Join(schema.Enum, ", ")
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+// Package problem writes error responses as RFC 7807 problem details
+// (application/problem+json). Every handler answers its errors through Write
+// or Respond, so that clients parse a single error format:
+//
+//	{"type": "urn:goapi:problem:not_found", "title": "Not found", "status": 404,
+//	 "detail": "Resource not found.", "instance": "/data/7", "code": "not_found",
+//	 "request_id": "6f1c..."}
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/logging"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+)
+
+const ContentType = "application/problem+json"
+
+// * Problem is the body of an error response, Code and Errors extend the members defined by RFC 7807 *
+type Problem struct {
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Status    int                  `json:"status"`
+	Detail    string               `json:"detail,omitempty"`
+	Instance  string               `json:"instance,omitempty"`
+	Code      service.ErrorCode    `json:"code"`
+	Errors    []service.FieldError `json:"errors,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
+}
+
+// * New describes err as a problem that occurred while serving r *
+func New(r *http.Request, err service.DataError) Problem {
+	code := err.ErrorCode()
+	return Problem{
+		Type:      "urn:goapi:problem:" + string(code),
+		Title:     err.Title(),
+		Status:    err.Status(),
+		Detail:    err.Message,
+		Instance:  r.URL.Path,
+		Code:      code,
+		Errors:    err.Fields,
+		RequestID: logging.RequestID(r.Context()),
+	}
+}
+
+// * Write answers r with err, with the status of its code *
+func Write(w http.ResponseWriter, r *http.Request, err service.DataError) {
+	p := New(r, err)
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// * Respond answers r with err: a DataError is the client's fault and logged as a warning, *
+// * anything else is logged as msg with args and answered with 500 without revealing the cause *
+func Respond(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error, msg string, args ...any) {
+	var dataErr service.DataError
+	if errors.As(err, &dataErr) {
+		logger.WarnContext(r.Context(), "Request rejected", "error", err)
+		Write(w, r, dataErr)
+		return
+	}
+	logger.ErrorContext(r.Context(), msg, append([]any{"error", err}, args...)...)
+	Write(w, r, service.DataError{Code: service.CodeInternal, Message: "Internal server error."})
+}
+
+// * BadRequest answers a malformed request, e.g. a body that is not JSON *
+func BadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, service.DataError{Code: service.CodeInvalidRequest, Message: message})
+}
+
+// * NotFound answers a request for a resource that does not exist *
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, service.DataError{Code: service.CodeNotFound, Message: "Resource not found."})
+}
+
+// * Forbidden answers a caller that may not do what it asked for *
+func Forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	Write(w, r, service.DataError{Code: service.CodeForbidden, Message: message})
+}
+
+// * MethodNotAllowed answers a request for a route that doesn't support its method *
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, service.DataError{Code: service.CodeMethodNotAllowed, Message: "Method " + r.Method + " is not allowed."})
+}
@@ -0,0 +1,84 @@
+package problem_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapi/internal/api/logging"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func respond(err error) (*httptest.ResponseRecorder, problem.Problem, string) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	r := httptest.NewRequest(http.MethodPost, "/threshold", nil)
+	r = r.WithContext(logging.WithRequest(r.Context(), logging.Request{ID: "abc", Route: "/threshold"}))
+	rr := httptest.NewRecorder()
+	problem.Respond(rr, r, logger, err, "Error creating threshold", "sensor_type", "temperature")
+
+	var p problem.Problem
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	return rr, p, logs.String()
+}
+
+func TestRespondDataError(t *testing.T) {
+	fields := []service.FieldError{{Field: "min_value", Message: `must be less than "max_value"`}}
+	rr, p, logs := respond(fmt.Errorf("creating: %w", service.DataError{Message: `MinValue "10" is too high.`, Fields: fields}))
+
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != problem.ContentType {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	expected := problem.Problem{
+		Type:      "urn:goapi:problem:invalid_data",
+		Title:     "Invalid data",
+		Status:    http.StatusBadRequest,
+		Detail:    `MinValue "10" is too high.`,
+		Instance:  "/threshold",
+		Code:      service.CodeInvalidData,
+		Errors:    fields,
+		RequestID: "abc",
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("expected %+v, got %+v", expected, p)
+	}
+	if !strings.Contains(logs, "level=WARN") {
+		t.Errorf("expected a warning, got %s", logs)
+	}
+}
+
+func TestRespondCodes(t *testing.T) {
+	for code, status := range map[service.ErrorCode]int{
+		service.CodeNotFound:         http.StatusNotFound,
+		service.CodeConflict:         http.StatusConflict,
+		service.CodeForbidden:        http.StatusForbidden,
+		service.CodeMethodNotAllowed: http.StatusMethodNotAllowed,
+		"unknown":                    http.StatusBadRequest,
+	} {
+		if rr, _, _ := respond(service.DataError{Code: code}); rr.Code != status {
+			t.Errorf("expected %s to be answered with %d, got %d", code, status, rr.Code)
+		}
+	}
+}
+
+func TestRespondInternalError(t *testing.T) {
+	rr, p, logs := respond(errors.New("database is locked"))
+
+	if rr.Code != http.StatusInternalServerError || p.Code != service.CodeInternal || p.Status != http.StatusInternalServerError {
+		t.Fatalf("unexpected response %d %+v", rr.Code, p)
+	}
+	if strings.Contains(rr.Body.String(), "database is locked") {
+		t.Errorf("expected the cause to be hidden from the client, got %s", rr.Body.String())
+	}
+	if !strings.Contains(logs, "level=ERROR") || !strings.Contains(logs, `error="database is locked"`) || !strings.Contains(logs, "sensor_type=temperature") {
+		t.Errorf("expected the cause to be logged, got %s", logs)
+	}
+}
@@ -0,0 +1,26 @@
+// Package problemtest holds the assertions the handler tests share for
+// problem+json responses.
+package problemtest
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"net/http/httptest"
+	"testing"
+)
+
+// * Expect checks that rr holds a problem+json response with code and detail *
+func Expect(t testing.TB, rr *httptest.ResponseRecorder, code service.ErrorCode, detail string) {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Errorf("handler returned content type %q, want %q", ct, problem.ContentType)
+	}
+	var p problem.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatalf("handler returned a body that is not a problem: %v: %s", err, rr.Body.String())
+	}
+	if p.Code != code || p.Detail != detail || p.Status != rr.Code {
+		t.Errorf("handler returned unexpected problem: got %+v want code %s and detail %q", p, code, detail)
+	}
+}
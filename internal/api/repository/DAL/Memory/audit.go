@@ -0,0 +1,81 @@
+package Memory
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"sync"
+)
+
+// * tenants holds the tenant of every entry, entries[i] belongs to tenants[i] *
+type AuditRepository struct {
+	mu      sync.RWMutex
+	entries []models.AuditEntry
+	tenants []string
+}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+// * Create links the entry to the previous one and stores it, PrevHash and Hash are set on the entry *
+func (r *AuditRepository) Create(entry *models.AuditEntry, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tenant := auth.Tenant(ctx)
+	entry.PrevHash = ""
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		if r.tenants[i] == tenant {
+			entry.PrevHash = r.entries[i].Hash
+			break
+		}
+	}
+	entry.Hash = entry.ComputeHash()
+	entry.ID = len(r.entries) + 1
+	r.entries = append(r.entries, *entry)
+	r.tenants = append(r.tenants, tenant)
+	return nil
+}
+
+// * ReadMany returns the newest entries first *
+func (r *AuditRepository) ReadMany(filter models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := auth.Tenant(ctx)
+	var entries []*models.AuditEntry
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		entry := r.entries[i]
+		if r.tenants[i] != tenant ||
+			(filter.Principal != "" && entry.Principal != filter.Principal) ||
+			(filter.Method != "" && entry.Method != filter.Method) ||
+			(filter.ResourceID != "" && entry.ResourceID != filter.ResourceID) ||
+			(filter.From != "" && entry.Time < filter.From) ||
+			(filter.To != "" && entry.Time > filter.To) {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return pageOf(entries, max(page, 1), rowsPerPage), nil
+}
+
+// * Walk calls fn for every entry in insertion order and stops at the first error *
+func (r *AuditRepository) Walk(fn func(entry *models.AuditEntry) error, ctx context.Context) error {
+	tenant := auth.Tenant(ctx)
+	r.mu.RLock()
+	var entries []models.AuditEntry
+	for i, entry := range r.entries {
+		if r.tenants[i] == tenant {
+			entries = append(entries, entry)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, entry := range entries {
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package Memory_test
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/DAL/contract"
+	"goapi/internal/api/repository/models"
+	"testing"
+)
+
+func TestDataRepositoryContract(t *testing.T) {
+	contract.RunDataRepository(t, func(t *testing.T, ctx context.Context) models.DataRepository {
+		return Memory.NewDataRepository()
+	})
+}
+
+func TestThresholdRepositoryContract(t *testing.T) {
+	contract.RunThresholdRepository(t, func(t *testing.T, ctx context.Context) models.ThresholdRepository {
+		return Memory.NewThresholdRepository()
+	})
+}
+
+func TestRuleRepositoryContract(t *testing.T) {
+	contract.RunRuleRepository(t, func(t *testing.T, ctx context.Context) models.RuleRepository {
+		return Memory.NewRuleRepository()
+	})
+}
+
+func TestAuditRepositoryContract(t *testing.T) {
+	contract.RunAuditRepository(t, func(t *testing.T, ctx context.Context) models.AuditRepository {
+		return Memory.NewAuditRepository()
+	})
+}
@@ -0,0 +1,179 @@
+package Memory
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"slices"
+	"sync"
+	"time"
+)
+
+type DataRepository struct {
+	mu   sync.RWMutex
+	rows map[int]models.Data
+	// tenants holds the tenant of every row, rows of other tenants are treated as missing
+	tenants map[int]string
+	nextID  int
+}
+
+func NewDataRepository() *DataRepository {
+	return &DataRepository{
+		rows:    map[int]models.Data{},
+		tenants: map[int]string{},
+		nextID:  1,
+	}
+}
+
+func (r *DataRepository) Create(data *models.Data, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// IDs are never reused, like SQLite AUTOINCREMENT
+	data.ID = r.nextID
+	data.Version = 1
+	r.nextID++
+	stored := *data
+	stored.DeletedAt = ""
+	r.rows[data.ID] = stored
+	r.tenants[data.ID] = auth.Tenant(ctx)
+	return nil
+}
+
+func (r *DataRepository) ReadOne(id int, ctx context.Context) (*models.Data, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	data, ok := r.owned(id, ctx)
+	if !ok || (data.DeletedAt != "" && !models.IncludeDeleted(ctx)) {
+		return nil, nil
+	}
+	return &data, nil
+}
+
+func (r *DataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
+	rows := r.visible(ctx, func(a, b *models.Data) int { return a.ID - b.ID })
+	if page < 1 {
+		return rows, nil
+	}
+	return pageOf(rows, page, rowsPerPage), nil
+}
+
+// * Zero bounds are treated as open ended, rows are returned in chronological order *
+func (r *DataRepository) ReadRange(from time.Time, to time.Time, ctx context.Context) ([]*models.Data, error) {
+	rows := r.visible(ctx, func(a, b *models.Data) int {
+		if c := a.DateTime.Compare(b.DateTime.Time); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+	return slices.DeleteFunc(rows, func(d *models.Data) bool {
+		return (!from.IsZero() && d.DateTime.Before(from)) || (!to.IsZero() && d.DateTime.After(to))
+	}), nil
+}
+
+func (r *DataRepository) Update(data *models.Data, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.owned(data.ID, ctx)
+	if !ok || current.DeletedAt != "" || !matches(current.Version, ctx) {
+		return 0, nil
+	}
+	// The time the reading was received doesn't change
+	data.ReceivedAt = current.ReceivedAt
+	data.Version = current.Version + 1
+	stored := *data
+	stored.DeletedAt = ""
+	r.rows[data.ID] = stored
+	return 1, nil
+}
+
+func (r *DataRepository) Modify(id int, change func(data *models.Data) error, ctx context.Context) (*models.Data, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.owned(id, ctx)
+	if !ok || current.DeletedAt != "" {
+		return nil, nil
+	}
+	data := current
+	if err := change(&data); err != nil {
+		return nil, err
+	}
+	data.ID = id
+	data.ReceivedAt = current.ReceivedAt
+	data.DeletedAt = ""
+	data.Version = current.Version + 1
+	r.rows[id] = data
+	return &data, nil
+}
+
+// * Delete only marks the row as deleted, it is removed for good by Purge *
+func (r *DataRepository) Delete(data *models.Data, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.owned(data.ID, ctx)
+	if !ok || current.DeletedAt != "" || !matches(current.Version, ctx) {
+		return 0, nil
+	}
+	current.DeletedAt = now()
+	current.Version++
+	r.rows[data.ID] = current
+	return 1, nil
+}
+
+func (r *DataRepository) Undelete(id int, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.owned(id, ctx)
+	if !ok || current.DeletedAt == "" {
+		return 0, nil
+	}
+	current.DeletedAt = ""
+	current.Version++
+	r.rows[id] = current
+	return 1, nil
+}
+
+// * Purge is run by the server for every tenant *
+func (r *DataRepository) Purge(before string, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, data := range r.rows {
+		if data.DeletedAt != "" && data.DeletedAt < before {
+			delete(r.rows, id)
+			delete(r.tenants, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// * visible returns copies of the rows the context may see, sorted with cmp *
+func (r *DataRepository) visible(ctx context.Context, cmp func(a, b *models.Data) int) []*models.Data {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := auth.Tenant(ctx)
+	var rows []*models.Data
+	for id, data := range r.rows {
+		if r.tenants[id] != tenant || (data.DeletedAt != "" && !models.IncludeDeleted(ctx)) {
+			continue
+		}
+		d := data
+		rows = append(rows, &d)
+	}
+	slices.SortFunc(rows, cmp)
+	return rows
+}
+
+// * owned returns the row with the ID if it belongs to the tenant of the caller, the lock must be held *
+func (r *DataRepository) owned(id int, ctx context.Context) (models.Data, bool) {
+	data, ok := r.rows[id]
+	return data, ok && r.tenants[id] == auth.Tenant(ctx)
+}
@@ -0,0 +1,54 @@
+// Package Memory keeps every repository in process memory. It behaves like the
+// SQLite backend (ID assignment, pagination, soft deletion, not-found results)
+// and is meant for tests and demos, nothing is persisted.
+package Memory
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// * Store holds one repository of each kind, services created from the same store share the data *
+type Store struct {
+	Data       *DataRepository
+	Thresholds *ThresholdRepository
+	Rules      *RuleRepository
+	Audit      *AuditRepository
+}
+
+func NewStore() *Store {
+	return &Store{
+		Data:       NewDataRepository(),
+		Thresholds: NewThresholdRepository(),
+		Rules:      NewRuleRepository(),
+		Audit:      NewAuditRepository(),
+	}
+}
+
+// * pageOf returns the part of items a LIMIT/OFFSET query would return *
+func pageOf[T any](items []T, page int, rowsPerPage int) []T {
+	offset := rowsPerPage * (page - 1)
+	if offset >= len(items) || rowsPerPage <= 0 {
+		return nil
+	}
+	return items[offset:min(offset+rowsPerPage, len(items))]
+}
+
+// * matches reports whether a row with the version may be written, see models.WithExpectedVersion *
+func matches(version int, ctx context.Context) bool {
+	expected := models.ExpectedVersion(ctx)
+	return expected == 0 || expected == version
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func copyThreshold(threshold *models.Threshold) *models.Threshold {
+	if threshold == nil {
+		return nil
+	}
+	c := *threshold
+	return &c
+}
@@ -0,0 +1,158 @@
+package Memory
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"slices"
+	"sync"
+)
+
+// * Rules carry their tenant, tags and events are stored with the tenant of the caller that wrote them *
+type RuleRepository struct {
+	mu     sync.RWMutex
+	rows   map[int]models.Rule
+	tags   map[deviceKey][]string
+	events []tenantEvent
+	nextID int
+}
+
+type deviceKey struct {
+	tenant   string
+	deviceID string
+}
+
+type tenantEvent struct {
+	tenant string
+	event  models.RuleEvent
+}
+
+func NewRuleRepository() *RuleRepository {
+	return &RuleRepository{
+		rows:   map[int]models.Rule{},
+		tags:   map[deviceKey][]string{},
+		nextID: 1,
+	}
+}
+
+func (r *RuleRepository) Create(rule *models.Rule, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule.ID = r.nextID
+	rule.Tenant = auth.Tenant(ctx)
+	r.nextID++
+	r.rows[rule.ID] = *rule
+	return nil
+}
+
+func (r *RuleRepository) ReadOne(id int, ctx context.Context) (*models.Rule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rule, ok := r.rows[id]
+	if !ok || rule.Tenant != auth.Tenant(ctx) {
+		return nil, nil
+	}
+	return &rule, nil
+}
+
+func (r *RuleRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error) {
+	tenant := auth.Tenant(ctx)
+	return pageOf(r.sorted(func(rule *models.Rule) bool { return rule.Tenant == tenant }), max(page, 1), rowsPerPage), nil
+}
+
+func (r *RuleRepository) ReadEnabled(ctx context.Context) ([]*models.Rule, error) {
+	return r.sorted(func(rule *models.Rule) bool { return rule.Enabled }), nil
+}
+
+func (r *RuleRepository) Update(rule *models.Rule, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.rows[rule.ID]
+	if !ok || stored.Tenant != auth.Tenant(ctx) {
+		return 0, nil
+	}
+	updated := *rule
+	updated.Tenant = stored.Tenant
+	r.rows[rule.ID] = updated
+	return 1, nil
+}
+
+func (r *RuleRepository) Delete(rule *models.Rule, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.rows[rule.ID]; !ok || stored.Tenant != auth.Tenant(ctx) {
+		return 0, nil
+	}
+	delete(r.rows, rule.ID)
+	return 1, nil
+}
+
+func (r *RuleRepository) ReadTags(deviceID string, ctx context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string{}, r.tags[deviceKey{auth.Tenant(ctx), deviceID}]...), nil
+}
+
+// * SetTags replaces all tags of a device, duplicates are dropped *
+func (r *RuleRepository) SetTags(deviceID string, tags []string, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sorted := append([]string{}, tags...)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	key := deviceKey{auth.Tenant(ctx), deviceID}
+	if len(sorted) == 0 {
+		delete(r.tags, key)
+		return nil
+	}
+	r.tags[key] = sorted
+	return nil
+}
+
+func (r *RuleRepository) CreateEvent(event *models.RuleEvent, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *event
+	stored.DeviceIDs = append([]string{}, event.DeviceIDs...)
+	r.events = append(r.events, tenantEvent{auth.Tenant(ctx), stored})
+	return nil
+}
+
+// * ReadEvents returns a page of the stored events, newest first *
+func (r *RuleRepository) ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tenant := auth.Tenant(ctx)
+	events := []*models.RuleEvent{}
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if r.events[i].tenant != tenant {
+			continue
+		}
+		event := r.events[i].event
+		events = append(events, &event)
+	}
+	return pageOf(events, max(page, 1), rowsPerPage), nil
+}
+
+func (r *RuleRepository) sorted(keep func(rule *models.Rule) bool) []*models.Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var rules []*models.Rule
+	for _, rule := range r.rows {
+		if !keep(&rule) {
+			continue
+		}
+		rules = append(rules, &rule)
+	}
+	slices.SortFunc(rules, func(a, b *models.Rule) int { return a.ID - b.ID })
+	return rules
+}
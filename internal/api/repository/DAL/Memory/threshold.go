@@ -0,0 +1,361 @@
+package Memory
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type ThresholdRepository struct {
+	mu      sync.RWMutex
+	rows    map[int]models.Threshold
+	history []models.ThresholdHistory
+	// tenants holds the tenant of every threshold, purged ones included so that their history stays with the tenant
+	tenants map[int]string
+	nextID  int
+}
+
+func NewThresholdRepository() *ThresholdRepository {
+	return &ThresholdRepository{
+		rows:    map[int]models.Threshold{},
+		tenants: map[int]string{},
+		nextID:  1,
+	}
+}
+
+func (r *ThresholdRepository) Create(threshold *models.Threshold, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.taken(r.nextID, threshold, ctx) {
+		return models.ErrDuplicateThreshold
+	}
+	threshold.ID = r.nextID
+	threshold.Version = 1
+	r.nextID++
+	stored := *threshold
+	stored.DeletedAt = ""
+	r.rows[threshold.ID] = stored
+	r.tenants[threshold.ID] = auth.Tenant(ctx)
+	r.recordHistory(threshold.ID, models.ThresholdCreated, nil, threshold, ctx)
+	return nil
+}
+
+func (r *ThresholdRepository) ReadOne(id int, ctx context.Context) (*models.Threshold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	threshold, ok := r.owned(id, ctx)
+	if !ok || (threshold.DeletedAt != "" && !models.IncludeDeleted(ctx)) {
+		return nil, nil
+	}
+	return &threshold, nil
+}
+
+func (r *ThresholdRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var thresholds []*models.Threshold
+	for id, threshold := range r.rows {
+		if r.tenants[id] != auth.Tenant(ctx) || (threshold.DeletedAt != "" && !models.IncludeDeleted(ctx)) {
+			continue
+		}
+		thresholds = append(thresholds, copyThreshold(&threshold))
+	}
+	slices.SortFunc(thresholds, func(a, b *models.Threshold) int { return a.ID - b.ID })
+	return pageOf(thresholds, max(page, 1), rowsPerPage), nil
+}
+
+func (r *ThresholdRepository) ReadByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scope := &models.Threshold{SensorType: sensorType, DeviceID: deviceID}
+	for id, threshold := range r.rows {
+		if r.tenants[id] == auth.Tenant(ctx) && threshold.DeletedAt == "" && models.SameScope(&threshold, scope) {
+			return copyThreshold(&threshold), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *ThresholdRepository) ReadForDevice(deviceID string, ctx context.Context) ([]*models.Threshold, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var thresholds []*models.Threshold
+	for id, threshold := range r.rows {
+		if r.tenants[id] == auth.Tenant(ctx) && threshold.DeletedAt == "" && (threshold.DeviceID == "" || threshold.DeviceID == deviceID) {
+			thresholds = append(thresholds, copyThreshold(&threshold))
+		}
+	}
+	slices.SortFunc(thresholds, func(a, b *models.Threshold) int {
+		if c := strings.Compare(strings.ToLower(a.SensorType), strings.ToLower(b.SensorType)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.DeviceID, b.DeviceID)
+	})
+	return thresholds, nil
+}
+
+func (r *ThresholdRepository) Update(threshold *models.Threshold, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.owned(threshold.ID, ctx)
+	if !ok || before.DeletedAt != "" || !matches(before.Version, ctx) {
+		return 0, nil
+	}
+	if r.taken(threshold.ID, threshold, ctx) {
+		return 0, models.ErrDuplicateThreshold
+	}
+	threshold.Version = before.Version + 1
+	stored := *threshold
+	stored.DeletedAt = ""
+	r.rows[threshold.ID] = stored
+	r.recordHistory(threshold.ID, models.ThresholdUpdated, &before, threshold, ctx)
+	return 1, nil
+}
+
+func (r *ThresholdRepository) Modify(id int, change func(threshold *models.Threshold) error, ctx context.Context) (*models.Threshold, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.owned(id, ctx)
+	if !ok || before.DeletedAt != "" {
+		return nil, nil
+	}
+	threshold := before
+	if err := change(&threshold); err != nil {
+		return nil, err
+	}
+	threshold.ID = id
+	if r.taken(id, &threshold, ctx) {
+		return nil, models.ErrDuplicateThreshold
+	}
+	threshold.DeletedAt = ""
+	threshold.Version = before.Version + 1
+	r.rows[id] = threshold
+	r.recordHistory(id, models.ThresholdUpdated, &before, &threshold, ctx)
+	return copyThreshold(&threshold), nil
+}
+
+func (r *ThresholdRepository) Delete(threshold *models.Threshold, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.owned(threshold.ID, ctx)
+	if !ok || before.DeletedAt != "" || !matches(before.Version, ctx) {
+		return 0, nil
+	}
+	deleted := before
+	deleted.DeletedAt = now()
+	deleted.Version++
+	r.rows[threshold.ID] = deleted
+	r.recordHistory(threshold.ID, models.ThresholdDeleted, &before, nil, ctx)
+	return 1, nil
+}
+
+// * Undelete clears the deletion mark and records the restored values in the history *
+func (r *ThresholdRepository) Undelete(id int, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	threshold, ok := r.owned(id, ctx)
+	if !ok || threshold.DeletedAt == "" {
+		return 0, nil
+	}
+	if r.taken(id, &threshold, ctx) {
+		return 0, models.ErrDuplicateThreshold
+	}
+	threshold.DeletedAt = ""
+	threshold.Version++
+	r.rows[id] = threshold
+	r.recordHistory(id, models.ThresholdRestored, nil, &threshold, ctx)
+	return 1, nil
+}
+
+// * Purge is run by the server for every tenant *
+func (r *ThresholdRepository) Purge(before string, ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, threshold := range r.rows {
+		if threshold.DeletedAt != "" && threshold.DeletedAt < before {
+			delete(r.rows, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// * Import holds the lock while planning and applying, the changes are checked before the first one is applied *
+func (r *ThresholdRepository) Import(plan func(current []*models.Threshold) ([]*models.ThresholdChange, error), ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var current []*models.Threshold
+	for id, threshold := range r.rows {
+		if r.tenants[id] == auth.Tenant(ctx) && threshold.DeletedAt == "" {
+			current = append(current, copyThreshold(&threshold))
+		}
+	}
+	slices.SortFunc(current, func(a, b *models.Threshold) int { return a.ID - b.ID })
+
+	changes, err := plan(current)
+	if err != nil {
+		return err
+	}
+	live := map[int]*models.Threshold{}
+	for _, threshold := range current {
+		live[threshold.ID] = threshold
+	}
+	for i, change := range changes {
+		switch change.Action {
+		case models.ThresholdCreated:
+			live[-1-i] = change.After
+		case models.ThresholdUpdated, models.ThresholdDeleted:
+			if stored, ok := r.owned(change.Before.ID, ctx); !ok || stored.DeletedAt != "" {
+				return fmt.Errorf("threshold %d does not exist", change.Before.ID)
+			}
+			live[change.Before.ID] = change.After
+			if change.After == nil {
+				delete(live, change.Before.ID)
+			}
+		default:
+			return fmt.Errorf("unknown threshold change %q", change.Action)
+		}
+	}
+	// The thresholds left after the import must not share a sensor type and device either
+	scopes := map[string]bool{}
+	for _, threshold := range live {
+		scope := strings.ToLower(threshold.SensorType) + "\x00" + threshold.DeviceID
+		if scopes[scope] {
+			return models.ErrDuplicateThreshold
+		}
+		scopes[scope] = true
+	}
+
+	for _, change := range changes {
+		switch change.Action {
+		case models.ThresholdCreated:
+			change.After.ID = r.nextID
+			change.After.Version = 1
+			r.nextID++
+			r.rows[change.After.ID] = *change.After
+			r.tenants[change.After.ID] = auth.Tenant(ctx)
+			r.recordHistory(change.After.ID, change.Action, nil, change.After, ctx)
+		case models.ThresholdUpdated:
+			change.After.ID = change.Before.ID
+			change.After.Version = change.Before.Version + 1
+			r.rows[change.After.ID] = *change.After
+			r.recordHistory(change.After.ID, change.Action, change.Before, change.After, ctx)
+		case models.ThresholdDeleted:
+			deleted := r.rows[change.Before.ID]
+			deleted.DeletedAt = now()
+			deleted.Version++
+			r.rows[change.Before.ID] = deleted
+			r.recordHistory(change.Before.ID, change.Action, change.Before, nil, ctx)
+		}
+	}
+	return nil
+}
+
+func (r *ThresholdRepository) ReadHistory(thresholdID int, ctx context.Context) ([]*models.ThresholdHistory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var history []*models.ThresholdHistory
+	for _, entry := range r.history {
+		if entry.ThresholdID == thresholdID && r.tenants[thresholdID] == auth.Tenant(ctx) {
+			history = append(history, copyHistory(entry))
+		}
+	}
+	return history, nil
+}
+
+func (r *ThresholdRepository) ReadVersion(thresholdID int, version int, ctx context.Context) (*models.ThresholdHistory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.history {
+		if entry.ThresholdID == thresholdID && entry.Version == version && r.tenants[thresholdID] == auth.Tenant(ctx) {
+			return copyHistory(entry), nil
+		}
+	}
+	return nil, nil
+}
+
+// * Restore writes the given values back under the same ID, re-creating the row if it was deleted or purged *
+func (r *ThresholdRepository) Restore(threshold *models.Threshold, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if tenant, ok := r.tenants[threshold.ID]; ok && tenant != auth.Tenant(ctx) {
+		return fmt.Errorf("threshold %d belongs to another tenant", threshold.ID)
+	}
+	if r.taken(threshold.ID, threshold, ctx) {
+		return models.ErrDuplicateThreshold
+	}
+	var before *models.Threshold
+	threshold.Version = 1
+	if current, ok := r.rows[threshold.ID]; ok {
+		before = &current
+		threshold.Version = current.Version + 1
+	}
+	stored := *threshold
+	stored.DeletedAt = ""
+	r.rows[threshold.ID] = stored
+	r.tenants[threshold.ID] = auth.Tenant(ctx)
+	r.nextID = max(r.nextID, threshold.ID+1)
+	r.recordHistory(threshold.ID, models.ThresholdRolledBack, before, threshold, ctx)
+	return nil
+}
+
+// * taken reports whether a live threshold of the tenant other than the one with the ID applies to the same sensor type and device, the caller holds the lock *
+func (r *ThresholdRepository) taken(id int, threshold *models.Threshold, ctx context.Context) bool {
+	for storedID, stored := range r.rows {
+		if storedID != id && r.tenants[storedID] == auth.Tenant(ctx) && stored.DeletedAt == "" && models.SameScope(&stored, threshold) {
+			return true
+		}
+	}
+	return false
+}
+
+// * owned returns the threshold with the ID if it belongs to the tenant of the caller, the caller holds the lock *
+func (r *ThresholdRepository) owned(id int, ctx context.Context) (models.Threshold, bool) {
+	threshold, ok := r.rows[id]
+	return threshold, ok && r.tenants[id] == auth.Tenant(ctx)
+}
+
+// * recordHistory appends a history entry, the caller holds the lock *
+func (r *ThresholdRepository) recordHistory(thresholdID int, action string, before, after *models.Threshold, ctx context.Context) {
+	version := 1
+	for _, entry := range r.history {
+		if entry.ThresholdID == thresholdID {
+			version = max(version, entry.Version+1)
+		}
+	}
+	r.history = append(r.history, models.ThresholdHistory{
+		ID:          len(r.history) + 1,
+		ThresholdID: thresholdID,
+		Version:     version,
+		Action:      action,
+		ChangedBy:   auth.Username(ctx),
+		ChangedAt:   now(),
+		Before:      copyThreshold(before),
+		After:       copyThreshold(after),
+	})
+}
+
+func copyHistory(entry models.ThresholdHistory) *models.ThresholdHistory {
+	entry.Before = copyThreshold(entry.Before)
+	entry.After = copyThreshold(entry.After)
+	return &entry
+}
@@ -0,0 +1,153 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+type AuditRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	lastHashStmt,
+	readManyStmt,
+	walkStmt *sql.Stmt
+	ctx context.Context
+}
+
+func NewAuditRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.AuditRepository, error) {
+	repo := &AuditRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Prepare SQL statements, every tenant reads and extends its own chain
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO audit_log (time, principal, method, path, resource_id, status, request_id, prev_hash, hash, tenant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	lastHashStmt, err := repo.sqlDB.Prepare(`SELECT hash FROM audit_log WHERE tenant_id = $1 ORDER BY id DESC LIMIT 1`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.lastHashStmt = lastHashStmt
+
+	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, time, principal, method, path, resource_id, status, request_id, prev_hash, hash FROM audit_log
+		WHERE tenant_id = $1 AND ($2 = '' OR principal = $2) AND ($3 = '' OR method = $3) AND ($4 = '' OR resource_id = $4) AND ($5 = '' OR time >= $5) AND ($6 = '' OR time <= $6)
+		ORDER BY id DESC LIMIT $7 OFFSET $8`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	walkStmt, err := repo.sqlDB.Prepare(`SELECT id, time, principal, method, path, resource_id, status, request_id, prev_hash, hash FROM audit_log WHERE tenant_id = $1 ORDER BY id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.walkStmt = walkStmt
+
+	go CloseAudit(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseAudit(ctx context.Context, r *AuditRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.lastHashStmt.Close()
+	r.readManyStmt.Close()
+	r.walkStmt.Close()
+	r.sqlDB.Close()
+}
+
+// * Create links the entry to the previous one and stores it, PrevHash and Hash are set on the entry *
+// * The table lock serializes writers across API instances sharing the database *
+func (r *AuditRepository) Create(entry *models.AuditEntry, ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE audit_log IN EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+
+	tenant := auth.Tenant(ctx)
+	var prevHash string
+	if err := tx.StmtContext(ctx, r.lastHashStmt).QueryRowContext(ctx, tenant).Scan(&prevHash); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	entry.PrevHash = prevHash
+	entry.Hash = entry.ComputeHash()
+
+	var id int
+	if err := tx.StmtContext(ctx, r.createStmt).QueryRowContext(ctx, entry.Time, entry.Principal, entry.Method, entry.Path,
+		entry.ResourceID, entry.Status, entry.RequestID, entry.PrevHash, entry.Hash, tenant).Scan(&id); err != nil {
+		return err
+	}
+	entry.ID = id
+	return tx.Commit()
+}
+
+// * ReadMany returns the newest entries first *
+func (r *AuditRepository) ReadMany(filter models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, auth.Tenant(ctx), filter.Principal, filter.Method, filter.ResourceID, filter.From, filter.To, rowsPerPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// * Walk calls fn for every entry in insertion order and stops at the first error *
+func (r *AuditRepository) Walk(fn func(entry *models.AuditEntry) error, ctx context.Context) error {
+	rows, err := r.walkStmt.QueryContext(ctx, auth.Tenant(ctx))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var resourceID, requestID sql.NullString
+	if err := row.Scan(&entry.ID, &entry.Time, &entry.Principal, &entry.Method, &entry.Path, &resourceID, &entry.Status, &requestID, &entry.PrevHash, &entry.Hash); err != nil {
+		return nil, err
+	}
+	entry.ResourceID = resourceID.String
+	entry.RequestID = requestID.String
+	return &entry, nil
+}
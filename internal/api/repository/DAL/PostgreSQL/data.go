@@ -0,0 +1,258 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+type DataRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readStmt,
+	readManyStmt,
+	readAllStmt,
+	readRangeStmt,
+	updateStmt,
+	deleteStmt,
+	undeleteStmt,
+	purgeStmt *sql.Stmt
+	ctx context.Context
+}
+
+func NewDataRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.DataRepository, error) {
+
+	repo := &DataRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// * The tables are created by the migrations in migrations.go
+	// * Soft-deleted rows are only read when the include deleted parameter is true
+	// * Every statement but purge is scoped by the tenant of the caller, rows of other tenants can't be read or written
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO data (device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, tenant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readStmt, err := repo.sqlDB.Prepare(`SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, deleted_at, version FROM data WHERE id = $1 AND (deleted_at IS NULL OR $2) AND tenant_id = $3`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readStmt = readStmt
+
+	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, deleted_at, version FROM data WHERE (deleted_at IS NULL OR $1) AND tenant_id = $4 ORDER BY id LIMIT $2 OFFSET $3`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	readAllStmt, err := repo.sqlDB.Prepare(`SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, deleted_at, version FROM data WHERE (deleted_at IS NULL OR $1) AND tenant_id = $2 ORDER BY id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readAllStmt = readAllStmt
+
+	// * NULL bounds are treated as open ended, rows are returned in chronological order
+	readRangeStmt, err := repo.sqlDB.Prepare(`SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, deleted_at, version FROM data
+		WHERE ($1::timestamptz IS NULL OR date_time >= $1) AND ($2::timestamptz IS NULL OR date_time <= $2) AND (deleted_at IS NULL OR $3) AND tenant_id = $4 ORDER BY date_time, id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readRangeStmt = readRangeStmt
+
+	updateStmt, err := repo.sqlDB.Prepare(`UPDATE data SET device_id = $1, device_name = $2, temp_value = $3, humi_value = $4, data_type = $5, date_time = $6, version = version + 1 WHERE id = $7 AND deleted_at IS NULL AND ($8 = 0 OR version = $8) AND tenant_id = $9`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.updateStmt = updateStmt
+
+	deleteStmt, err := repo.sqlDB.Prepare(`UPDATE data SET deleted_at = $1, version = version + 1 WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3) AND tenant_id = $4`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteStmt = deleteStmt
+
+	undeleteStmt, err := repo.sqlDB.Prepare(`UPDATE data SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL AND tenant_id = $2`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.undeleteStmt = undeleteStmt
+
+	// * Purge is run by the server for every tenant
+	purgeStmt, err := repo.sqlDB.Prepare(`DELETE FROM data WHERE deleted_at IS NOT NULL AND deleted_at < $1`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.purgeStmt = purgeStmt
+
+	go Close(ctx, repo)
+
+	return repo, nil
+}
+
+func Close(ctx context.Context, r *DataRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readStmt.Close()
+	r.readManyStmt.Close()
+	r.readAllStmt.Close()
+	r.readRangeStmt.Close()
+	r.updateStmt.Close()
+	r.deleteStmt.Close()
+	r.undeleteStmt.Close()
+	r.purgeStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *DataRepository) Create(data *models.Data, ctx context.Context) error {
+	var id int
+	err := r.createStmt.QueryRowContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue, data.HumidityValue, data.Type, data.DateTime, data.ReceivedAt, auth.Tenant(ctx)).Scan(&id)
+	if err != nil {
+		return err
+	}
+	data.ID = id
+	data.Version = 1
+	return nil
+}
+
+func (r *DataRepository) ReadOne(id int, ctx context.Context) (*models.Data, error) {
+	data, err := scanData(r.readStmt.QueryRowContext(ctx, id, models.IncludeDeleted(ctx), auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (r *DataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
+	if page < 1 {
+		rows, err := r.readAllStmt.QueryContext(ctx, models.IncludeDeleted(ctx), auth.Tenant(ctx))
+		if err != nil {
+			return nil, err
+		}
+		return scanDataRows(rows)
+	}
+
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, models.IncludeDeleted(ctx), rowsPerPage, offset, auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return scanDataRows(rows)
+}
+
+func (r *DataRepository) ReadRange(from time.Time, to time.Time, ctx context.Context) ([]*models.Data, error) {
+	// A zero Timestamp is stored as NULL
+	rows, err := r.readRangeStmt.QueryContext(ctx, models.NewTimestamp(from), models.NewTimestamp(to), models.IncludeDeleted(ctx), auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return scanDataRows(rows)
+}
+
+func (r *DataRepository) Update(data *models.Data, ctx context.Context) (int64, error) {
+	res, err := r.updateStmt.ExecContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue, data.HumidityValue, data.Type, data.DateTime, data.ID, models.ExpectedVersion(ctx), auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// * Modify locks the row before reading it, so that concurrent changes are applied one after the other *
+func (r *DataRepository) Modify(id int, change func(data *models.Data) error, ctx context.Context) (*models.Data, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM data WHERE id = $1 AND deleted_at IS NULL AND tenant_id = $2 FOR UPDATE", id, auth.Tenant(ctx)).Scan(&locked); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := scanData(tx.StmtContext(ctx, r.readStmt).QueryRowContext(ctx, id, false, auth.Tenant(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := change(data); err != nil {
+		return nil, err
+	}
+	data.ID = id
+	if _, err := tx.StmtContext(ctx, r.updateStmt).ExecContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue, data.HumidityValue, data.Type, data.DateTime, id, 0, auth.Tenant(ctx)); err != nil {
+		return nil, err
+	}
+	data.Version++
+	return data, tx.Commit()
+}
+
+// * Delete only marks the row as deleted, it is removed for good by Purge *
+func (r *DataRepository) Delete(data *models.Data, ctx context.Context) (int64, error) {
+	res, err := r.deleteStmt.ExecContext(ctx, time.Now().UTC(), data.ID, models.ExpectedVersion(ctx), auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *DataRepository) Undelete(id int, ctx context.Context) (int64, error) {
+	res, err := r.undeleteStmt.ExecContext(ctx, id, auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *DataRepository) Purge(before string, ctx context.Context) (int64, error) {
+	res, err := r.purgeStmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanData(row rowScanner) (*models.Data, error) {
+	var d models.Data
+	var deviceName, dataType sql.NullString
+	var deletedAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.DeviceID, &deviceName, &d.TemperatureValue, &d.HumidityValue, &dataType, &d.DateTime, &d.ReceivedAt, &deletedAt, &d.Version); err != nil {
+		return nil, err
+	}
+	d.DeviceName = deviceName.String
+	d.Type = dataType.String
+	d.DeletedAt = formatTime(deletedAt)
+	return &d, nil
+}
+
+func scanDataRows(rows *sql.Rows) ([]*models.Data, error) {
+	defer rows.Close()
+
+	var data []*models.Data
+	for rows.Next() {
+		d, err := scanData(rows)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+	return data, rows.Err()
+}
@@ -0,0 +1,252 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/DAL/migrate"
+	"strconv"
+	"strings"
+)
+
+// * The schema, one migration per change and numbered like the SQLite ones. Applied migrations must never be edited *
+var migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create_data_and_thresholds",
+		// * The time column is part of the primary key so that the table can become a TimescaleDB hypertable,
+		// * plain PostgreSQL keeps a regular table
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS data (
+			id SERIAL,
+			device_id VARCHAR(50) NOT NULL,
+			device_name VARCHAR(50),
+			temp_value DOUBLE PRECISION,
+			humi_value DOUBLE PRECISION,
+			data_type VARCHAR(20),
+			date_time TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (id, date_time)
+		);
+		CREATE INDEX IF NOT EXISTS data_date_time ON data (date_time);
+		DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb') THEN
+				PERFORM create_hypertable('data', 'date_time', if_not_exists => TRUE, migrate_data => TRUE);
+			END IF;
+		EXCEPTION WHEN OTHERS THEN
+			RAISE NOTICE 'data stays a regular table: %', SQLERRM;
+		END $$;
+		CREATE TABLE IF NOT EXISTS thresholds (
+			id SERIAL PRIMARY KEY,
+			sensor_type VARCHAR(50) NOT NULL,
+			min_value DOUBLE PRECISION NOT NULL,
+			max_value DOUBLE PRECISION NOT NULL,
+			updated_at TIMESTAMPTZ
+		);`),
+		Down: migrate.SQL(`DROP TABLE thresholds; DROP TABLE data;`),
+	},
+	{
+		Version: 2,
+		Name:    "create_threshold_history",
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS threshold_history (
+			id SERIAL PRIMARY KEY,
+			threshold_id INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			changed_by VARCHAR(50) NOT NULL,
+			changed_at TIMESTAMPTZ NOT NULL,
+			before_value JSONB,
+			after_value JSONB,
+			UNIQUE (threshold_id, version)
+		);
+		` + rejectModificationFunction + `
+		DROP TRIGGER IF EXISTS threshold_history_append_only ON threshold_history;
+		CREATE TRIGGER threshold_history_append_only BEFORE UPDATE OR DELETE ON threshold_history
+			FOR EACH ROW EXECUTE FUNCTION reject_modification();`),
+		Down: migrate.SQL(`DROP TABLE threshold_history;`),
+	},
+	{
+		Version: 3,
+		Name:    "create_rules_and_device_tags",
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS rules (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(50) NOT NULL,
+			condition JSONB NOT NULL,
+			for_duration VARCHAR(20),
+			group_tag VARCHAR(50),
+			min_devices INTEGER NOT NULL DEFAULT 1,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			updated_at TIMESTAMPTZ
+		);
+		CREATE TABLE IF NOT EXISTS device_tags (
+			device_id VARCHAR(50) NOT NULL,
+			tag VARCHAR(50) NOT NULL,
+			PRIMARY KEY (device_id, tag)
+		);`),
+		Down: migrate.SQL(`DROP TABLE device_tags; DROP TABLE rules;`),
+	},
+	{
+		Version: 4,
+		Name:    "create_audit_log",
+		// * time is kept as the text that was hashed so that the chain can be verified byte for byte
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS audit_log (
+			id SERIAL PRIMARY KEY,
+			time VARCHAR(40) NOT NULL,
+			principal VARCHAR(50) NOT NULL,
+			method VARCHAR(10) NOT NULL,
+			path VARCHAR(255) NOT NULL,
+			resource_id VARCHAR(50),
+			status INTEGER NOT NULL,
+			request_id VARCHAR(64),
+			prev_hash CHAR(64) NOT NULL,
+			hash CHAR(64) NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS audit_log_resource ON audit_log (resource_id);
+		` + rejectModificationFunction + `
+		DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+		CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+			FOR EACH ROW EXECUTE FUNCTION reject_modification();`),
+		Down: migrate.SQL(`DROP TABLE audit_log;`),
+	},
+	{
+		Version: 5,
+		Name:    "add_deleted_at",
+		Up: migrate.SQL(`ALTER TABLE data ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		ALTER TABLE thresholds ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;`),
+		Down: migrate.SQL(`ALTER TABLE thresholds DROP COLUMN deleted_at; ALTER TABLE data DROP COLUMN deleted_at;`),
+	},
+	{
+		Version: 6,
+		Name:    "add_received_at",
+		Up:      migrate.SQL(`ALTER TABLE data ADD COLUMN IF NOT EXISTS received_at TIMESTAMPTZ;`),
+		Down:    migrate.SQL(`ALTER TABLE data DROP COLUMN received_at;`),
+	},
+	{
+		Version: 7,
+		Name:    "add_threshold_unit",
+		Up:      migrate.SQL(`ALTER TABLE thresholds ADD COLUMN IF NOT EXISTS unit VARCHAR(10) NOT NULL DEFAULT '';`),
+		Down:    migrate.SQL(`ALTER TABLE thresholds DROP COLUMN unit;`),
+	},
+	{
+		Version: 8,
+		Name:    "add_row_versions",
+		Up: migrate.SQL(`ALTER TABLE data ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+			ALTER TABLE thresholds ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;`),
+		Down: migrate.SQL(`ALTER TABLE data DROP COLUMN version;
+			ALTER TABLE thresholds DROP COLUMN version;`),
+	},
+	{
+		Version: 9,
+		Name:    "add_threshold_device_scope",
+		Up:      migrate.SQL(`ALTER TABLE thresholds ADD COLUMN IF NOT EXISTS device_id VARCHAR(50) NOT NULL DEFAULT '';`),
+		Down:    migrate.SQL(`ALTER TABLE thresholds DROP COLUMN device_id;`),
+	},
+	{
+		Version: 10,
+		Name:    "unique_threshold_scope",
+		// * Only the oldest live threshold of a sensor type and device is kept, the others are soft-deleted so they can still be read and purged
+		Up: migrate.SQL(`UPDATE thresholds SET deleted_at = now(), version = version + 1
+			WHERE deleted_at IS NULL AND EXISTS (
+				SELECT 1 FROM thresholds AS older
+				WHERE older.deleted_at IS NULL AND lower(older.sensor_type) = lower(thresholds.sensor_type)
+					AND older.device_id = thresholds.device_id AND older.id < thresholds.id
+			);
+			CREATE UNIQUE INDEX IF NOT EXISTS thresholds_scope ON thresholds (lower(sensor_type), device_id) WHERE deleted_at IS NULL;`),
+		Down: migrate.SQL(`DROP INDEX thresholds_scope;`),
+	},
+	{
+		Version: 11,
+		Name:    "add_tenants",
+		// * Rows written before tenants existed belong to the default tenant, each tenant has its own threshold per sensor type and device
+		Up: migrate.SQL(`ALTER TABLE data ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(50) NOT NULL DEFAULT 'default';
+			ALTER TABLE thresholds ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(50) NOT NULL DEFAULT 'default';
+			ALTER TABLE threshold_history ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(50) NOT NULL DEFAULT 'default';
+			DROP INDEX IF EXISTS thresholds_scope;
+			CREATE UNIQUE INDEX thresholds_scope ON thresholds (tenant_id, lower(sensor_type), device_id) WHERE deleted_at IS NULL;
+			CREATE INDEX IF NOT EXISTS data_tenant ON data (tenant_id, id);`),
+		Down: migrate.SQL(`DROP INDEX data_tenant; DROP INDEX thresholds_scope;
+			CREATE UNIQUE INDEX thresholds_scope ON thresholds (lower(sensor_type), device_id) WHERE deleted_at IS NULL;
+			ALTER TABLE threshold_history DROP COLUMN tenant_id;
+			ALTER TABLE thresholds DROP COLUMN tenant_id;
+			ALTER TABLE data DROP COLUMN tenant_id;`),
+	},
+	{
+		Version: 12,
+		Name:    "create_rule_events",
+		Up: migrate.SQL(`CREATE TABLE rule_events (
+			id SERIAL PRIMARY KEY,
+			rule_id INTEGER NOT NULL,
+			rule_name VARCHAR(50) NOT NULL,
+			device_ids JSONB NOT NULL,
+			date_time TIMESTAMPTZ NOT NULL
+		);`),
+		Down: migrate.SQL(`DROP TABLE rule_events;`),
+	},
+	{
+		Version: 13,
+		Name:    "add_tenants_to_rules_and_audit",
+		// * Existing rules, tags, events and audit entries belong to the default tenant, every tenant has its own audit chain
+		Up: migrate.SQL(`ALTER TABLE rules ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(50) NOT NULL DEFAULT 'default';
+			ALTER TABLE rule_events ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(50) NOT NULL DEFAULT 'default';
+			ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(50) NOT NULL DEFAULT 'default';
+			ALTER TABLE device_tags ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(50) NOT NULL DEFAULT 'default';
+			ALTER TABLE device_tags DROP CONSTRAINT device_tags_pkey, ADD PRIMARY KEY (tenant_id, device_id, tag);
+			CREATE INDEX IF NOT EXISTS audit_log_tenant ON audit_log (tenant_id, id);`),
+		// * Tags that several tenants gave the same device are kept once
+		Down: migrate.SQL(`DROP INDEX audit_log_tenant;
+			DELETE FROM device_tags AS other USING device_tags AS kept
+				WHERE other.device_id = kept.device_id AND other.tag = kept.tag AND other.tenant_id > kept.tenant_id;
+			ALTER TABLE device_tags DROP COLUMN tenant_id;
+			ALTER TABLE device_tags ADD PRIMARY KEY (device_id, tag);
+			ALTER TABLE audit_log DROP COLUMN tenant_id;
+			ALTER TABLE rule_events DROP COLUMN tenant_id;
+			ALTER TABLE rules DROP COLUMN tenant_id;`),
+	},
+}
+
+// * rejectModificationFunction backs the triggers that make history tables append-only *
+const rejectModificationFunction = `CREATE OR REPLACE FUNCTION reject_modification() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+	END;
+	$$ LANGUAGE plpgsql;`
+
+// * NewMigrator returns the migrator for the PostgreSQL schema *
+func NewMigrator(sqlDB DAL.SQLDatabase) *migrate.Migrator {
+	return migrate.NewMigrator(sqlDB.Connection(), dialect{}, migrations)
+}
+
+// * Arbitrary key of the advisory lock held while migrating, shared by every instance of the API *
+const migrationLockKey = 7203495317
+
+type dialect struct{}
+
+func (dialect) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// * The advisory lock belongs to the session, so one connection is held until the lock is released *
+func (dialect) Lock(ctx context.Context, db *sql.DB) (func() error, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+		return err
+	}, nil
+}
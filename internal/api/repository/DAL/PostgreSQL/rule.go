@@ -0,0 +1,283 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+type RuleRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readStmt,
+	readManyStmt,
+	readEnabledStmt,
+	updateStmt,
+	deleteStmt,
+	readTagsStmt,
+	createEventStmt,
+	readEventsStmt *sql.Stmt
+	ctx context.Context
+}
+
+func NewRuleRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.RuleRepository, error) {
+	repo := &RuleRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Prepare SQL statements, every statement but readEnabled is scoped by the tenant of the caller
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO rules (name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readStmt, err := repo.sqlDB.Prepare(`SELECT id, name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id FROM rules WHERE id = $1 AND tenant_id = $2`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readStmt = readStmt
+
+	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id FROM rules WHERE tenant_id = $1 ORDER BY id LIMIT $2 OFFSET $3`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	readEnabledStmt, err := repo.sqlDB.Prepare(`SELECT id, name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id FROM rules WHERE enabled ORDER BY id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readEnabledStmt = readEnabledStmt
+
+	updateStmt, err := repo.sqlDB.Prepare(`UPDATE rules SET name = $1, condition = $2, for_duration = $3, group_tag = $4, min_devices = $5, enabled = $6, updated_at = $7 WHERE id = $8 AND tenant_id = $9`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.updateStmt = updateStmt
+
+	deleteStmt, err := repo.sqlDB.Prepare(`DELETE FROM rules WHERE id = $1 AND tenant_id = $2`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteStmt = deleteStmt
+
+	readTagsStmt, err := repo.sqlDB.Prepare(`SELECT tag FROM device_tags WHERE tenant_id = $1 AND device_id = $2 ORDER BY tag`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readTagsStmt = readTagsStmt
+
+	createEventStmt, err := repo.sqlDB.Prepare(`INSERT INTO rule_events (rule_id, rule_name, device_ids, date_time, tenant_id) VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createEventStmt = createEventStmt
+
+	readEventsStmt, err := repo.sqlDB.Prepare(`SELECT rule_id, rule_name, device_ids, date_time FROM rule_events WHERE tenant_id = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readEventsStmt = readEventsStmt
+
+	go CloseRule(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseRule(ctx context.Context, r *RuleRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readStmt.Close()
+	r.readManyStmt.Close()
+	r.readEnabledStmt.Close()
+	r.updateStmt.Close()
+	r.deleteStmt.Close()
+	r.readTagsStmt.Close()
+	r.createEventStmt.Close()
+	r.readEventsStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *RuleRepository) Create(rule *models.Rule, ctx context.Context) error {
+	condition, err := json.Marshal(rule.Condition)
+	if err != nil {
+		return err
+	}
+	var id int
+	tenant := auth.Tenant(ctx)
+	if err := r.createStmt.QueryRowContext(ctx, rule.Name, string(condition), rule.For, rule.GroupTag, rule.MinDevices, rule.Enabled, nullTime(rule.UpdatedAt), tenant).Scan(&id); err != nil {
+		return err
+	}
+	rule.ID, rule.Tenant = id, tenant
+	return nil
+}
+
+func (r *RuleRepository) ReadOne(id int, ctx context.Context) (*models.Rule, error) {
+	rule, err := scanRule(r.readStmt.QueryRowContext(ctx, id, auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *RuleRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, auth.Tenant(ctx), rowsPerPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanRules(rows)
+}
+
+func (r *RuleRepository) ReadEnabled(ctx context.Context) ([]*models.Rule, error) {
+	rows, err := r.readEnabledStmt.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return scanRules(rows)
+}
+
+func (r *RuleRepository) Update(rule *models.Rule, ctx context.Context) (int64, error) {
+	condition, err := json.Marshal(rule.Condition)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.updateStmt.ExecContext(ctx, rule.Name, string(condition), rule.For, rule.GroupTag, rule.MinDevices, rule.Enabled, nullTime(rule.UpdatedAt), rule.ID, auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *RuleRepository) Delete(rule *models.Rule, ctx context.Context) (int64, error) {
+	res, err := r.deleteStmt.ExecContext(ctx, rule.ID, auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *RuleRepository) ReadTags(deviceID string, ctx context.Context) ([]string, error) {
+	rows, err := r.readTagsStmt.QueryContext(ctx, auth.Tenant(ctx), deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// * SetTags replaces all tags of a device in a single transaction *
+func (r *RuleRepository) SetTags(deviceID string, tags []string, ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tenant := auth.Tenant(ctx)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM device_tags WHERE tenant_id = $1 AND device_id = $2`, tenant, deviceID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO device_tags (tenant_id, device_id, tag) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, tenant, deviceID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *RuleRepository) CreateEvent(event *models.RuleEvent, ctx context.Context) error {
+	deviceIDs, err := json.Marshal(event.DeviceIDs)
+	if err != nil {
+		return err
+	}
+	_, err = r.createEventStmt.ExecContext(ctx, event.RuleID, event.RuleName, string(deviceIDs), nullTime(event.DateTime), auth.Tenant(ctx))
+	return err
+}
+
+func (r *RuleRepository) ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error) {
+	if page < 1 {
+		page = 1
+	}
+	rows, err := r.readEventsStmt.QueryContext(ctx, auth.Tenant(ctx), rowsPerPage, rowsPerPage*(page-1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.RuleEvent
+	for rows.Next() {
+		var event models.RuleEvent
+		var deviceIDs []byte
+		var dateTime sql.NullTime
+		if err := rows.Scan(&event.RuleID, &event.RuleName, &deviceIDs, &dateTime); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(deviceIDs, &event.DeviceIDs); err != nil {
+			return nil, err
+		}
+		event.DateTime = formatTime(dateTime)
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func scanRule(row rowScanner) (*models.Rule, error) {
+	var rule models.Rule
+	var condition []byte
+	var forDuration, groupTag sql.NullString
+	var updatedAt sql.NullTime
+	if err := row.Scan(&rule.ID, &rule.Name, &condition, &forDuration, &groupTag, &rule.MinDevices, &rule.Enabled, &updatedAt, &rule.Tenant); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(condition, &rule.Condition); err != nil {
+		return nil, err
+	}
+	rule.For = forDuration.String
+	rule.GroupTag = groupTag.String
+	rule.UpdatedAt = formatTime(updatedAt)
+	return &rule, nil
+}
+
+func scanRules(rows *sql.Rows) ([]*models.Rule, error) {
+	defer rows.Close()
+
+	var rules []*models.Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
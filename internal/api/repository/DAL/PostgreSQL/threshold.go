@@ -0,0 +1,578 @@
+package PostgreSQL
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ThresholdRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	insertStmt,
+	readStmt,
+	readManyStmt,
+	readByTypeStmt,
+	readForDeviceStmt,
+	updateStmt,
+	deleteStmt,
+	undeleteStmt,
+	restoreStmt,
+	purgeStmt,
+	historyStmt,
+	readHistoryStmt,
+	readVersionStmt *sql.Stmt
+	ctx context.Context
+}
+
+func NewThresholdRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.ThresholdRepository, error) {
+	repo := &ThresholdRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Prepare SQL statements, soft-deleted rows are only read when the include deleted parameter is true
+	// Every statement but purge is scoped by the tenant of the caller, thresholds of other tenants can't be read or written
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO thresholds (sensor_type, device_id, min_value, max_value, unit, updated_at, tenant_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	// Used by rollbacks to re-create a purged threshold under its old ID
+	insertStmt, err := repo.sqlDB.Prepare(`INSERT INTO thresholds (id, sensor_type, device_id, min_value, max_value, unit, updated_at, tenant_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.insertStmt = insertStmt
+
+	readStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE id = $1 AND (deleted_at IS NULL OR $2) AND tenant_id = $3`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readStmt = readStmt
+
+	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE (deleted_at IS NULL OR $1) AND tenant_id = $4 ORDER BY id LIMIT $2 OFFSET $3`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	// Both lookups are served by the thresholds_scope index
+	readByTypeStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE tenant_id = $3 AND lower(sensor_type) = lower($1) AND device_id = $2 AND deleted_at IS NULL`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByTypeStmt = readByTypeStmt
+
+	readForDeviceStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE tenant_id = $2 AND device_id IN ('', $1) AND deleted_at IS NULL ORDER BY lower(sensor_type), device_id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readForDeviceStmt = readForDeviceStmt
+
+	updateStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET sensor_type = $1, device_id = $2, min_value = $3, max_value = $4, unit = $5, updated_at = $6, version = version + 1 WHERE id = $7 AND deleted_at IS NULL AND ($8 = 0 OR version = $8) AND tenant_id = $9`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.updateStmt = updateStmt
+
+	deleteStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET deleted_at = $1, version = version + 1 WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3) AND tenant_id = $4`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteStmt = deleteStmt
+
+	undeleteStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET deleted_at = NULL, version = version + 1 WHERE id = $1 AND deleted_at IS NOT NULL AND tenant_id = $2`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.undeleteStmt = undeleteStmt
+
+	// Rollbacks may target a soft-deleted threshold, which is restored at the same time
+	restoreStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET sensor_type = $1, device_id = $2, min_value = $3, max_value = $4, unit = $5, updated_at = $6, deleted_at = NULL, version = version + 1 WHERE id = $7 AND ($8 = 0 OR version = $8) AND tenant_id = $9`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.restoreStmt = restoreStmt
+
+	// Purge is run by the server for every tenant
+	purgeStmt, err := repo.sqlDB.Prepare(`DELETE FROM thresholds WHERE deleted_at IS NOT NULL AND deleted_at < $1`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.purgeStmt = purgeStmt
+
+	// * The version is derived from the previous entries of the same threshold inside the writing transaction
+	historyStmt, err := repo.sqlDB.Prepare(`INSERT INTO threshold_history (threshold_id, version, action, changed_by, changed_at, before_value, after_value, tenant_id)
+		SELECT $1::integer, COALESCE(MAX(version), 0) + 1, $2::varchar, $3::varchar, $4::timestamptz, $5::jsonb, $6::jsonb, $7::varchar FROM threshold_history WHERE threshold_id = $1`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.historyStmt = historyStmt
+
+	readHistoryStmt, err := repo.sqlDB.Prepare(`SELECT id, threshold_id, version, action, changed_by, changed_at, before_value, after_value FROM threshold_history WHERE threshold_id = $1 AND tenant_id = $2 ORDER BY version`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readHistoryStmt = readHistoryStmt
+
+	readVersionStmt, err := repo.sqlDB.Prepare(`SELECT id, threshold_id, version, action, changed_by, changed_at, before_value, after_value FROM threshold_history WHERE threshold_id = $1 AND version = $2 AND tenant_id = $3`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readVersionStmt = readVersionStmt
+
+	go CloseThreshold(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseThreshold(ctx context.Context, r *ThresholdRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.insertStmt.Close()
+	r.readStmt.Close()
+	r.readManyStmt.Close()
+	r.readByTypeStmt.Close()
+	r.readForDeviceStmt.Close()
+	r.updateStmt.Close()
+	r.deleteStmt.Close()
+	r.undeleteStmt.Close()
+	r.restoreStmt.Close()
+	r.purgeStmt.Close()
+	r.historyStmt.Close()
+	r.readHistoryStmt.Close()
+	r.readVersionStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *ThresholdRepository) Create(threshold *models.Threshold, ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.StmtContext(ctx, r.createStmt).QueryRowContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, nullTime(threshold.UpdatedAt), auth.Tenant(ctx)).Scan(&id); err != nil {
+		return scopeConflict(err)
+	}
+	threshold.ID = id
+	threshold.Version = 1
+
+	if err := r.recordHistory(tx, threshold.ID, models.ThresholdCreated, nil, threshold, ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *ThresholdRepository) ReadOne(id int, ctx context.Context) (*models.Threshold, error) {
+	threshold, err := scanThreshold(r.readStmt.QueryRowContext(ctx, id, models.IncludeDeleted(ctx), auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return threshold, nil
+}
+
+func (r *ThresholdRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, models.IncludeDeleted(ctx), rowsPerPage, offset, auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var thresholds []*models.Threshold
+	for rows.Next() {
+		threshold, err := scanThreshold(rows)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, rows.Err()
+}
+
+func (r *ThresholdRepository) ReadByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error) {
+	threshold, err := scanThreshold(r.readByTypeStmt.QueryRowContext(ctx, sensorType, deviceID, auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return threshold, nil
+}
+
+func (r *ThresholdRepository) ReadForDevice(deviceID string, ctx context.Context) ([]*models.Threshold, error) {
+	rows, err := r.readForDeviceStmt.QueryContext(ctx, deviceID, auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var thresholds []*models.Threshold
+	for rows.Next() {
+		threshold, err := scanThreshold(rows)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, rows.Err()
+}
+
+func (r *ThresholdRepository) Update(threshold *models.Threshold, ctx context.Context) (int64, error) {
+	return r.update(threshold, r.updateStmt, models.ThresholdUpdated, ctx)
+}
+
+// * Modify reads the row with readInTx, which locks it, so that concurrent changes are applied one after the other *
+func (r *ThresholdRepository) Modify(id int, change func(threshold *models.Threshold) error, ctx context.Context) (*models.Threshold, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.readInTx(tx, id, false, ctx)
+	if err != nil || before == nil {
+		return nil, err
+	}
+
+	threshold := *before
+	if err := change(&threshold); err != nil {
+		return nil, err
+	}
+	threshold.ID = id
+	threshold.Version = before.Version + 1
+	if _, err := tx.StmtContext(ctx, r.updateStmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, nullTime(threshold.UpdatedAt), id, 0, auth.Tenant(ctx)); err != nil {
+		return nil, scopeConflict(err)
+	}
+	if err := r.recordHistory(tx, id, models.ThresholdUpdated, before, &threshold, ctx); err != nil {
+		return nil, err
+	}
+	return &threshold, tx.Commit()
+}
+
+func (r *ThresholdRepository) Delete(threshold *models.Threshold, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.readInTx(tx, threshold.ID, false, ctx)
+	if err != nil || before == nil {
+		return 0, err
+	}
+
+	res, err := tx.StmtContext(ctx, r.deleteStmt).ExecContext(ctx, time.Now().UTC(), threshold.ID, models.ExpectedVersion(ctx), auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil || aff == 0 {
+		return 0, err
+	}
+
+	if err := r.recordHistory(tx, threshold.ID, models.ThresholdDeleted, before, nil, ctx); err != nil {
+		return 0, err
+	}
+	return aff, tx.Commit()
+}
+
+// * Undelete clears the deletion mark and records the restored values in the history *
+func (r *ThresholdRepository) Undelete(id int, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.StmtContext(ctx, r.undeleteStmt).ExecContext(ctx, id, auth.Tenant(ctx))
+	if err != nil {
+		return 0, scopeConflict(err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil || aff == 0 {
+		return 0, err
+	}
+
+	after, err := r.readInTx(tx, id, false, ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.recordHistory(tx, id, models.ThresholdRestored, nil, after, ctx); err != nil {
+		return 0, err
+	}
+	return aff, tx.Commit()
+}
+
+func (r *ThresholdRepository) Purge(before string, ctx context.Context) (int64, error) {
+	res, err := r.purgeStmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// * Import locks the table against other writers before reading, so that the plan is made from the thresholds it replaces *
+func (r *ThresholdRepository) Import(plan func(current []*models.Threshold) ([]*models.ThresholdChange, error), ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE thresholds IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE deleted_at IS NULL AND tenant_id = $1 ORDER BY id`, auth.Tenant(ctx))
+	if err != nil {
+		return err
+	}
+	var current []*models.Threshold
+	for rows.Next() {
+		threshold, err := scanThreshold(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, threshold)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	changes, err := plan(current)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := r.apply(tx, change, ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// * apply writes one change of an import and records it in the history *
+func (r *ThresholdRepository) apply(tx *sql.Tx, change *models.ThresholdChange, ctx context.Context) error {
+	switch change.Action {
+	case models.ThresholdCreated:
+		threshold := change.After
+		if err := tx.StmtContext(ctx, r.createStmt).QueryRowContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, nullTime(threshold.UpdatedAt), auth.Tenant(ctx)).Scan(&threshold.ID); err != nil {
+			return scopeConflict(err)
+		}
+		threshold.Version = 1
+	case models.ThresholdUpdated:
+		threshold := change.After
+		threshold.ID = change.Before.ID
+		if _, err := tx.StmtContext(ctx, r.updateStmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, nullTime(threshold.UpdatedAt), threshold.ID, 0, auth.Tenant(ctx)); err != nil {
+			return scopeConflict(err)
+		}
+		threshold.Version = change.Before.Version + 1
+	case models.ThresholdDeleted:
+		if _, err := tx.StmtContext(ctx, r.deleteStmt).ExecContext(ctx, time.Now().UTC(), change.Before.ID, 0, auth.Tenant(ctx)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown threshold change %q", change.Action)
+	}
+
+	id := change.After
+	if id == nil {
+		id = change.Before
+	}
+	return r.recordHistory(tx, id.ID, change.Action, change.Before, change.After, ctx)
+}
+
+func (r *ThresholdRepository) ReadHistory(thresholdID int, ctx context.Context) ([]*models.ThresholdHistory, error) {
+	rows, err := r.readHistoryStmt.QueryContext(ctx, thresholdID, auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*models.ThresholdHistory
+	for rows.Next() {
+		entry, err := scanThresholdHistory(rows)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+func (r *ThresholdRepository) ReadVersion(thresholdID int, version int, ctx context.Context) (*models.ThresholdHistory, error) {
+	entry, err := scanThresholdHistory(r.readVersionStmt.QueryRowContext(ctx, thresholdID, version, auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// * Restore writes the given values back under the same ID, re-creating the row if it was deleted or purged *
+func (r *ThresholdRepository) Restore(threshold *models.Threshold, ctx context.Context) error {
+	aff, err := r.update(threshold, r.restoreStmt, models.ThresholdRolledBack, models.WithDeleted(ctx))
+	if err != nil || aff > 0 {
+		return err
+	}
+
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.StmtContext(ctx, r.insertStmt).ExecContext(ctx, threshold.ID, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, nullTime(threshold.UpdatedAt), auth.Tenant(ctx)); err != nil {
+		return scopeConflict(err)
+	}
+	threshold.Version = 1
+	if err := r.recordHistory(tx, threshold.ID, models.ThresholdRolledBack, nil, threshold, ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// * update changes the row and records the before and after values in one transaction *
+func (r *ThresholdRepository) update(threshold *models.Threshold, stmt *sql.Stmt, action string, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.readInTx(tx, threshold.ID, models.IncludeDeleted(ctx), ctx)
+	if err != nil || before == nil {
+		return 0, err
+	}
+
+	expected := models.ExpectedVersion(ctx)
+	res, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, nullTime(threshold.UpdatedAt), threshold.ID, expected, auth.Tenant(ctx))
+	if err != nil {
+		return 0, scopeConflict(err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil || aff == 0 {
+		return 0, err
+	}
+	threshold.Version = before.Version + 1
+
+	if err := r.recordHistory(tx, threshold.ID, action, before, threshold, ctx); err != nil {
+		return 0, err
+	}
+	return aff, tx.Commit()
+}
+
+// * readInTx locks the row so that concurrent changes of the same threshold get consecutive versions *
+func (r *ThresholdRepository) readInTx(tx *sql.Tx, id int, includeDeleted bool, ctx context.Context) (*models.Threshold, error) {
+	row := tx.QueryRowContext(ctx, `SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE id = $1 AND (deleted_at IS NULL OR $2) AND tenant_id = $3 FOR UPDATE`, id, includeDeleted, auth.Tenant(ctx))
+	threshold, err := scanThreshold(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return threshold, nil
+}
+
+// * recordHistory appends a history entry, the user is taken from the authenticated principal and the time is set by the server *
+func (r *ThresholdRepository) recordHistory(tx *sql.Tx, thresholdID int, action string, before, after *models.Threshold, ctx context.Context) error {
+	var beforeValue, afterValue any
+	if before != nil {
+		b, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		beforeValue = string(b)
+	}
+	if after != nil {
+		a, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		afterValue = string(a)
+	}
+
+	_, err := tx.StmtContext(ctx, r.historyStmt).ExecContext(ctx, thresholdID, action, auth.Username(ctx), time.Now().UTC(), beforeValue, afterValue, auth.Tenant(ctx))
+	return err
+}
+
+// * scopeConflict replaces the violation of the thresholds_scope index by models.ErrDuplicateThreshold *
+func scopeConflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "thresholds_scope" {
+		return models.ErrDuplicateThreshold
+	}
+	return err
+}
+
+func scanThreshold(row rowScanner) (*models.Threshold, error) {
+	var threshold models.Threshold
+	var updatedAt, deletedAt sql.NullTime
+	if err := row.Scan(&threshold.ID, &threshold.SensorType, &threshold.DeviceID, &threshold.MinValue, &threshold.MaxValue, &threshold.Unit, &updatedAt, &deletedAt, &threshold.Version); err != nil {
+		return nil, err
+	}
+	threshold.UpdatedAt = formatTime(updatedAt)
+	threshold.DeletedAt = formatTime(deletedAt)
+	return &threshold, nil
+}
+
+func scanThresholdHistory(row rowScanner) (*models.ThresholdHistory, error) {
+	var entry models.ThresholdHistory
+	var changedAt sql.NullTime
+	var beforeValue, afterValue []byte
+	if err := row.Scan(&entry.ID, &entry.ThresholdID, &entry.Version, &entry.Action, &entry.ChangedBy, &changedAt, &beforeValue, &afterValue); err != nil {
+		return nil, err
+	}
+	entry.ChangedAt = formatTime(changedAt)
+	if beforeValue != nil {
+		entry.Before = &models.Threshold{}
+		if err := json.Unmarshal(beforeValue, entry.Before); err != nil {
+			return nil, err
+		}
+	}
+	if afterValue != nil {
+		entry.After = &models.Threshold{}
+		if err := json.Unmarshal(afterValue, entry.After); err != nil {
+			return nil, err
+		}
+	}
+	return &entry, nil
+}
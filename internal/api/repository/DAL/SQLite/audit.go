@@ -0,0 +1,166 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"sync"
+)
+
+type AuditRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	lastHashStmt,
+	readManyStmt,
+	walkStmt *sql.Stmt
+	ctx context.Context
+
+	// * Serializes writers so that every entry is chained to the latest one
+	mu sync.Mutex
+}
+
+func NewAuditRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.AuditRepository, error) {
+	repo := &AuditRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Prepare SQL statements, every tenant reads and extends its own chain
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO audit_log (time, principal, method, path, resource_id, status, request_id, prev_hash, hash, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	lastHashStmt, err := repo.sqlDB.Prepare(`SELECT hash FROM audit_log WHERE tenant_id = ? ORDER BY id DESC LIMIT 1`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.lastHashStmt = lastHashStmt
+
+	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, time, principal, method, path, resource_id, status, request_id, prev_hash, hash FROM audit_log
+		WHERE tenant_id = ? AND (? = '' OR principal = ?) AND (? = '' OR method = ?) AND (? = '' OR resource_id = ?) AND (? = '' OR time >= ?) AND (? = '' OR time <= ?)
+		ORDER BY id DESC LIMIT ? OFFSET ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	walkStmt, err := repo.sqlDB.Prepare(`SELECT id, time, principal, method, path, resource_id, status, request_id, prev_hash, hash FROM audit_log WHERE tenant_id = ? ORDER BY id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.walkStmt = walkStmt
+
+	go CloseAudit(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseAudit(ctx context.Context, r *AuditRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.lastHashStmt.Close()
+	r.readManyStmt.Close()
+	r.walkStmt.Close()
+	r.sqlDB.Close()
+}
+
+// * Create links the entry to the previous one and stores it, PrevHash and Hash are set on the entry *
+func (r *AuditRepository) Create(entry *models.AuditEntry, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tenant := auth.Tenant(ctx)
+	var prevHash string
+	if err := tx.StmtContext(ctx, r.lastHashStmt).QueryRowContext(ctx, tenant).Scan(&prevHash); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	entry.PrevHash = prevHash
+	entry.Hash = entry.ComputeHash()
+
+	res, err := tx.StmtContext(ctx, r.createStmt).ExecContext(ctx, entry.Time, entry.Principal, entry.Method, entry.Path,
+		entry.ResourceID, entry.Status, entry.RequestID, entry.PrevHash, entry.Hash, tenant)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+	return tx.Commit()
+}
+
+// * ReadMany returns the newest entries first *
+func (r *AuditRepository) ReadMany(filter models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx,
+		auth.Tenant(ctx),
+		filter.Principal, filter.Principal,
+		filter.Method, filter.Method,
+		filter.ResourceID, filter.ResourceID,
+		filter.From, filter.From,
+		filter.To, filter.To,
+		rowsPerPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// * Walk calls fn for every entry in insertion order and stops at the first error *
+func (r *AuditRepository) Walk(fn func(entry *models.AuditEntry) error, ctx context.Context) error {
+	rows, err := r.walkStmt.QueryContext(ctx, auth.Tenant(ctx))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var resourceID, requestID sql.NullString
+	if err := row.Scan(&entry.ID, &entry.Time, &entry.Principal, &entry.Method, &entry.Path, &resourceID, &entry.Status, &requestID, &entry.PrevHash, &entry.Hash); err != nil {
+		return nil, err
+	}
+	entry.ResourceID = resourceID.String
+	entry.RequestID = requestID.String
+	return &entry, nil
+}
@@ -0,0 +1,63 @@
+package SQLite_test
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/DAL/contract"
+	"goapi/internal/api/repository/models"
+	"path/filepath"
+	"testing"
+)
+
+// * Every test gets its own migrated database file, the repository closes it when ctx is done *
+func openDatabase(t *testing.T) DAL.SQLDatabase {
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SQLite.NewMigrator(db).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDataRepositoryContract(t *testing.T) {
+	contract.RunDataRepository(t, func(t *testing.T, ctx context.Context) models.DataRepository {
+		repo, err := SQLite.NewDataRepository(openDatabase(t), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func TestThresholdRepositoryContract(t *testing.T) {
+	contract.RunThresholdRepository(t, func(t *testing.T, ctx context.Context) models.ThresholdRepository {
+		repo, err := SQLite.NewThresholdRepository(openDatabase(t), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func TestRuleRepositoryContract(t *testing.T) {
+	contract.RunRuleRepository(t, func(t *testing.T, ctx context.Context) models.RuleRepository {
+		repo, err := SQLite.NewRuleRepository(openDatabase(t), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
+
+func TestAuditRepositoryContract(t *testing.T) {
+	contract.RunAuditRepository(t, func(t *testing.T, ctx context.Context) models.AuditRepository {
+		repo, err := SQLite.NewAuditRepository(openDatabase(t), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
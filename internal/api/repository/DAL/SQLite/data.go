@@ -3,8 +3,10 @@ package SQLite
 import (
 	"context"
 	"database/sql"
+	"goapi/internal/api/auth"
 	"goapi/internal/api/repository/DAL"
 	"goapi/internal/api/repository/models"
+	"time"
 )
 
 type DataRepository struct {
@@ -12,8 +14,11 @@ type DataRepository struct {
 	createStmt,
 	readStmt,
 	readManyStmt,
+	readRangeStmt,
 	updateStmt,
-	deleteStmt *sql.Stmt
+	deleteStmt,
+	undeleteStmt,
+	purgeStmt *sql.Stmt
 	ctx context.Context
 }
 
@@ -24,56 +29,70 @@ func NewDataRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.DataR
 		ctx:   ctx,
 	}
 
-	// Create the data table if it doesn't exist
-	if _, err := repo.sqlDB.Exec(`CREATE TABLE  IF NOT EXISTS data (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		device_id VARCHAR(50) NOT NULL,
-		device_name VARCHAR(50),
-		temp_value FLOAT,
-		humi_value FLOAT,
-		data_type VARCHAR(20),
-		date_time TIMESTAMP
-	);`); err != nil {
-		repo.sqlDB.Close()
-		return nil, err
-	}
-
+	// * The tables are created by the migrations in migrations.go
 	// * Create needed Prepared SQL statements, this is more efficient than running each query individually
-	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO data (device_id, device_name, temp_value, humi_value, data_type, date_time) VALUES (?, ?, ?, ?, ?, ?)`)
+	// * Soft-deleted rows are only read when the include deleted parameter is true
+	// * Every statement but purge is scoped by the tenant of the caller, rows of other tenants can't be read or written
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO data (device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		repo.sqlDB.Close() // Close the database connection if statement preparation fails
 		return nil, err
 	}
 	repo.createStmt = createStmt
 
-	readStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time FROM data WHERE id = ?")
+	readStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, deleted_at, version FROM data WHERE id = ? AND (deleted_at IS NULL OR ?) AND tenant_id = ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readStmt = readStmt
 
-	readManyStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time FROM data LIMIT ? OFFSET ?")
+	readManyStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, deleted_at, version FROM data WHERE (deleted_at IS NULL OR ?) AND tenant_id = ? ORDER BY id LIMIT ? OFFSET ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readManyStmt = readManyStmt
 
-	updateStmt, err := repo.sqlDB.Prepare("UPDATE data SET device_id = ?, device_name = ?, temp_value = ?, humi_value = ?, data_type = ?, date_time = ? WHERE id = ?")
+	// * Empty bounds are treated as open ended, rows are returned in chronological order
+	// * Timestamps are stored in models.StorageLayout, so comparing the text compares the instants
+	readRangeStmt, err := repo.sqlDB.Prepare(`SELECT id, device_id, device_name, temp_value, humi_value, data_type, date_time, received_at, deleted_at, version FROM data
+		WHERE (? = '' OR date_time >= ?) AND (? = '' OR date_time <= ?) AND (deleted_at IS NULL OR ?) AND tenant_id = ? ORDER BY date_time, id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readRangeStmt = readRangeStmt
+
+	updateStmt, err := repo.sqlDB.Prepare("UPDATE data SET device_id = ?, device_name = ?, temp_value = ?, humi_value = ?, data_type = ?, date_time = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?) AND tenant_id = ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.updateStmt = updateStmt
 
-	deleteStmt, err := repo.sqlDB.Prepare("DELETE FROM data WHERE id = ?")
+	deleteStmt, err := repo.sqlDB.Prepare("UPDATE data SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?) AND tenant_id = ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.deleteStmt = deleteStmt
 
+	undeleteStmt, err := repo.sqlDB.Prepare("UPDATE data SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL AND tenant_id = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.undeleteStmt = undeleteStmt
+
+	// * Purge is run by the server for every tenant
+	purgeStmt, err := repo.sqlDB.Prepare("DELETE FROM data WHERE deleted_at IS NOT NULL AND deleted_at < ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.purgeStmt = purgeStmt
+
 	go Close(ctx, repo)
 
 	return repo, nil
@@ -86,13 +105,16 @@ func Close(ctx context.Context, r *DataRepository) {
 	r.readStmt.Close()
 	r.updateStmt.Close()
 	r.deleteStmt.Close()
+	r.undeleteStmt.Close()
+	r.purgeStmt.Close()
 	r.readManyStmt.Close()
+	r.readRangeStmt.Close()
 	r.sqlDB.Close()
 }
 
 func (r *DataRepository) Create(data *models.Data, ctx context.Context) error {
 
-	res, err := r.createStmt.ExecContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue,data.HumidityValue, data.Type, data.DateTime)
+	res, err := r.createStmt.ExecContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue,data.HumidityValue, data.Type, data.DateTime, data.ReceivedAt, auth.Tenant(ctx))
 	if err != nil {
 		return err
 	}
@@ -101,68 +123,64 @@ func (r *DataRepository) Create(data *models.Data, ctx context.Context) error {
 		return err
 	}
 	data.ID = int(id)
+	data.Version = 1
 	return nil
 }
 
 func (r *DataRepository) ReadOne(id int, ctx context.Context) (*models.Data, error) {
-	row := r.readStmt.QueryRowContext(ctx, id)
-	var data models.Data
-	err := row.Scan(&data.ID, &data.DeviceID, &data.DeviceName, &data.TemperatureValue,&data.HumidityValue, &data.Type, &data.DateTime)
+	row := r.readStmt.QueryRowContext(ctx, id, models.IncludeDeleted(ctx), auth.Tenant(ctx))
+	data, err := scanData(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &data, nil
+	return data, nil
 }
 
 func (r *DataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
 
 	if page < 1 {
-		return r.ReadAll()
+		return r.ReadAll(ctx)
 	}
 
 	offset := rowsPerPage * (page - 1)
-	rows, err := r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
+	rows, err := r.readManyStmt.QueryContext(ctx, models.IncludeDeleted(ctx), auth.Tenant(ctx), rowsPerPage, offset)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var data []*models.Data
-	for rows.Next() {
-		var d models.Data
-		err := rows.Scan(&d.ID, &d.DeviceID, &d.DeviceName, &d.TemperatureValue, &d.HumidityValue, &d.DateTime)
-		if err != nil {
-			return nil, err
-		}
-		data = append(data, &d)
-	}
-	return data, nil
+	return scanDataRows(rows)
 }
 
-func (r *DataRepository) ReadAll() ([]*models.Data, error) {
-	rows, err := r.sqlDB.QueryContext(context.Background(), "SELECT id, device_id, device_name, temp_value,humi_value, data_type, date_time FROM data")
+func (r *DataRepository) ReadRange(from time.Time, to time.Time, ctx context.Context) ([]*models.Data, error) {
+	lower, upper := rangeBound(from), rangeBound(to)
+	rows, err := r.readRangeStmt.QueryContext(ctx, lower, lower, upper, upper, models.IncludeDeleted(ctx), auth.Tenant(ctx))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanDataRows(rows)
+}
 
-	var data []*models.Data
-	for rows.Next() {
-		var d models.Data
-		err := rows.Scan(&d.ID, &d.DeviceID, &d.DeviceName, &d.TemperatureValue,&d.HumidityValue, &d.Type, &d.DateTime)
-		if err != nil {
-			return nil, err
-		}
-		data = append(data, &d)
+// * rangeBound formats a bound of ReadRange like the stored timestamps, "" for an open end *
+func rangeBound(t time.Time) string {
+	if t.IsZero() {
+		return ""
 	}
-	return data, nil
+	return t.UTC().Format(models.StorageLayout)
+}
+
+func (r *DataRepository) ReadAll(ctx context.Context) ([]*models.Data, error) {
+	rows, err := r.sqlDB.QueryContext(ctx, "SELECT id, device_id, device_name, temp_value,humi_value, data_type, date_time, received_at, deleted_at, version FROM data WHERE (deleted_at IS NULL OR ?) AND tenant_id = ? ORDER BY id", models.IncludeDeleted(ctx), auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return scanDataRows(rows)
 }
 
 func (r *DataRepository) Update(data *models.Data, ctx context.Context) (int64, error) {
-	res, err := r.updateStmt.ExecContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue, data.HumidityValue, data.Type, data.DateTime, data.ID)
+	expected := models.ExpectedVersion(ctx)
+	res, err := r.updateStmt.ExecContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue, data.HumidityValue, data.Type, data.DateTime, data.ID, expected, expected, auth.Tenant(ctx))
 	if err != nil {
 		return 0, err
 	}
@@ -173,8 +191,41 @@ func (r *DataRepository) Update(data *models.Data, ctx context.Context) (int64,
 	return rowsAffected, nil
 }
 
+// * Modify claims the write lock before reading the row, so that concurrent changes are applied one after the other *
+func (r *DataRepository) Modify(id int, change func(data *models.Data) error, ctx context.Context) (*models.Data, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "UPDATE data SET id = id WHERE id = ? AND deleted_at IS NULL AND tenant_id = ?", id, auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if aff, err := res.RowsAffected(); err != nil || aff == 0 {
+		return nil, err
+	}
+	data, err := scanData(tx.StmtContext(ctx, r.readStmt).QueryRowContext(ctx, id, false, auth.Tenant(ctx)))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := change(data); err != nil {
+		return nil, err
+	}
+	data.ID = id
+	if _, err := tx.StmtContext(ctx, r.updateStmt).ExecContext(ctx, data.DeviceID, data.DeviceName, data.TemperatureValue, data.HumidityValue, data.Type, data.DateTime, id, 0, 0, auth.Tenant(ctx)); err != nil {
+		return nil, err
+	}
+	data.Version++
+	return data, tx.Commit()
+}
+
+// * Delete only marks the row as deleted, it is removed for good by Purge *
 func (r *DataRepository) Delete(data *models.Data, ctx context.Context) (int64, error) {
-	res, err := r.deleteStmt.ExecContext(ctx, data.ID)
+	expected := models.ExpectedVersion(ctx)
+	res, err := r.deleteStmt.ExecContext(ctx, time.Now().UTC().Format(time.RFC3339), data.ID, expected, expected, auth.Tenant(ctx))
 	if err != nil {
 		return 0, err
 	}
@@ -184,3 +235,43 @@ func (r *DataRepository) Delete(data *models.Data, ctx context.Context) (int64,
 	}
 	return rowsAffected, nil
 }
+
+func (r *DataRepository) Undelete(id int, ctx context.Context) (int64, error) {
+	res, err := r.undeleteStmt.ExecContext(ctx, id, auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *DataRepository) Purge(before string, ctx context.Context) (int64, error) {
+	res, err := r.purgeStmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanData(row rowScanner) (*models.Data, error) {
+	var d models.Data
+	var deletedAt sql.NullString
+	if err := row.Scan(&d.ID, &d.DeviceID, &d.DeviceName, &d.TemperatureValue, &d.HumidityValue, &d.Type, &d.DateTime, &d.ReceivedAt, &deletedAt, &d.Version); err != nil {
+		return nil, err
+	}
+	d.DeletedAt = deletedAt.String
+	return &d, nil
+}
+
+func scanDataRows(rows *sql.Rows) ([]*models.Data, error) {
+	defer rows.Close()
+
+	var data []*models.Data
+	for rows.Next() {
+		d, err := scanData(rows)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+	return data, rows.Err()
+}
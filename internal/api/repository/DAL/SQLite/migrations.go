@@ -0,0 +1,331 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/DAL/migrate"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// * The schema, one migration per change. Applied migrations must never be edited, add a new one instead *
+// * The first migrations use IF NOT EXISTS so that databases created before migrations existed are adopted *
+var migrations = []migrate.Migration{
+	{
+		Version: 1,
+		Name:    "create_data_and_thresholds",
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS data (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_id VARCHAR(50) NOT NULL,
+			device_name VARCHAR(50),
+			temp_value FLOAT,
+			humi_value FLOAT,
+			data_type VARCHAR(20),
+			date_time TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS thresholds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			sensor_type VARCHAR(50) NOT NULL,
+			min_value FLOAT NOT NULL,
+			max_value FLOAT NOT NULL,
+			updated_at TIMESTAMP
+		);`),
+		Down: migrate.SQL(`DROP TABLE thresholds; DROP TABLE data;`),
+	},
+	{
+		Version: 2,
+		Name:    "create_threshold_history",
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS threshold_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			threshold_id INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			changed_by VARCHAR(50) NOT NULL,
+			changed_at TIMESTAMP NOT NULL,
+			before_value TEXT,
+			after_value TEXT,
+			UNIQUE (threshold_id, version)
+		);
+		CREATE TRIGGER IF NOT EXISTS threshold_history_no_update BEFORE UPDATE ON threshold_history
+		BEGIN SELECT RAISE(ABORT, 'threshold_history is append-only'); END;
+		CREATE TRIGGER IF NOT EXISTS threshold_history_no_delete BEFORE DELETE ON threshold_history
+		BEGIN SELECT RAISE(ABORT, 'threshold_history is append-only'); END;`),
+		Down: migrate.SQL(`DROP TABLE threshold_history;`),
+	},
+	{
+		Version: 3,
+		Name:    "create_rules_and_device_tags",
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(50) NOT NULL,
+			condition TEXT NOT NULL,
+			for_duration VARCHAR(20),
+			group_tag VARCHAR(50),
+			min_devices INTEGER NOT NULL DEFAULT 1,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			updated_at TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS device_tags (
+			device_id VARCHAR(50) NOT NULL,
+			tag VARCHAR(50) NOT NULL,
+			PRIMARY KEY (device_id, tag)
+		);`),
+		Down: migrate.SQL(`DROP TABLE device_tags; DROP TABLE rules;`),
+	},
+	{
+		Version: 4,
+		Name:    "create_audit_log",
+		Up: migrate.SQL(`CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			time TIMESTAMP NOT NULL,
+			principal VARCHAR(50) NOT NULL,
+			method VARCHAR(10) NOT NULL,
+			path VARCHAR(255) NOT NULL,
+			resource_id VARCHAR(50),
+			status INTEGER NOT NULL,
+			request_id VARCHAR(64),
+			prev_hash CHAR(64) NOT NULL,
+			hash CHAR(64) NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS audit_log_resource ON audit_log (resource_id);
+		CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;
+		CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;`),
+		Down: migrate.SQL(`DROP TABLE audit_log;`),
+	},
+	{
+		Version: 5,
+		Name:    "add_deleted_at",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			if err := addColumnIfMissing(ctx, tx, "data", "deleted_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(ctx, tx, "thresholds", "deleted_at", "TIMESTAMP")
+		},
+		Down: migrate.SQL(`ALTER TABLE thresholds DROP COLUMN deleted_at; ALTER TABLE data DROP COLUMN deleted_at;`),
+	},
+	{
+		Version: 6,
+		Name:    "add_received_at",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			if err := addColumnIfMissing(ctx, tx, "data", "received_at", "TIMESTAMP"); err != nil {
+				return err
+			}
+			return normaliseDateTimes(ctx, tx)
+		},
+		Down: migrate.SQL(`ALTER TABLE data DROP COLUMN received_at;`),
+	},
+	{
+		Version: 7,
+		Name:    "add_threshold_unit",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			return addColumnIfMissing(ctx, tx, "thresholds", "unit", "VARCHAR(10) NOT NULL DEFAULT ''")
+		},
+		Down: migrate.SQL(`ALTER TABLE thresholds DROP COLUMN unit;`),
+	},
+	{
+		Version: 8,
+		Name:    "add_row_versions",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			if err := addColumnIfMissing(ctx, tx, "data", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+				return err
+			}
+			return addColumnIfMissing(ctx, tx, "thresholds", "version", "INTEGER NOT NULL DEFAULT 1")
+		},
+		Down: migrate.SQL(`ALTER TABLE data DROP COLUMN version;
+			ALTER TABLE thresholds DROP COLUMN version;`),
+	},
+	{
+		Version: 9,
+		Name:    "add_threshold_device_scope",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			return addColumnIfMissing(ctx, tx, "thresholds", "device_id", "VARCHAR(50) NOT NULL DEFAULT ''")
+		},
+		Down: migrate.SQL(`ALTER TABLE thresholds DROP COLUMN device_id;`),
+	},
+	{
+		Version: 10,
+		Name:    "unique_threshold_scope",
+		// * Only the oldest live threshold of a sensor type and device is kept, the others are soft-deleted so they can still be read and purged
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, dedupeThresholds, time.Now().UTC().Format(time.RFC3339)); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS thresholds_scope ON thresholds (lower(sensor_type), device_id) WHERE deleted_at IS NULL`)
+			return err
+		},
+		Down: migrate.SQL(`DROP INDEX thresholds_scope;`),
+	},
+	{
+		Version: 11,
+		Name:    "add_tenants",
+		// * Rows written before tenants existed belong to the default tenant, each tenant has its own threshold per sensor type and device
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			for _, table := range []string{"data", "thresholds", "threshold_history"} {
+				if err := addColumnIfMissing(ctx, tx, table, "tenant_id", "VARCHAR(50) NOT NULL DEFAULT 'default'"); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, `DROP INDEX IF EXISTS thresholds_scope;
+				CREATE UNIQUE INDEX thresholds_scope ON thresholds (tenant_id, lower(sensor_type), device_id) WHERE deleted_at IS NULL;
+				CREATE INDEX IF NOT EXISTS data_tenant ON data (tenant_id, id);`)
+			return err
+		},
+		Down: migrate.SQL(`DROP INDEX data_tenant; DROP INDEX thresholds_scope;
+			CREATE UNIQUE INDEX thresholds_scope ON thresholds (lower(sensor_type), device_id) WHERE deleted_at IS NULL;
+			ALTER TABLE threshold_history DROP COLUMN tenant_id;
+			ALTER TABLE thresholds DROP COLUMN tenant_id;
+			ALTER TABLE data DROP COLUMN tenant_id;`),
+	},
+	{
+		Version: 12,
+		Name:    "create_rule_events",
+		Up: migrate.SQL(`CREATE TABLE rule_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_id INTEGER NOT NULL,
+			rule_name VARCHAR(50) NOT NULL,
+			device_ids TEXT NOT NULL,
+			date_time TIMESTAMP NOT NULL
+		);`),
+		Down: migrate.SQL(`DROP TABLE rule_events;`),
+	},
+	{
+		Version: 13,
+		Name:    "add_tenants_to_rules_and_audit",
+		// * Existing rules, tags, events and audit entries belong to the default tenant, every tenant has its own audit chain
+		// * SQLite can't change a primary key, device_tags is copied into a table keyed by tenant
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			for _, table := range []string{"rules", "rule_events", "audit_log"} {
+				if err := addColumnIfMissing(ctx, tx, table, "tenant_id", "VARCHAR(50) NOT NULL DEFAULT 'default'"); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, `CREATE TABLE device_tags_by_tenant (
+					tenant_id VARCHAR(50) NOT NULL DEFAULT 'default',
+					device_id VARCHAR(50) NOT NULL,
+					tag VARCHAR(50) NOT NULL,
+					PRIMARY KEY (tenant_id, device_id, tag)
+				);
+				INSERT INTO device_tags_by_tenant (device_id, tag) SELECT device_id, tag FROM device_tags;
+				DROP TABLE device_tags;
+				ALTER TABLE device_tags_by_tenant RENAME TO device_tags;
+				CREATE INDEX IF NOT EXISTS audit_log_tenant ON audit_log (tenant_id, id);`)
+			return err
+		},
+		Down: migrate.SQL(`DROP INDEX audit_log_tenant;
+			CREATE TABLE device_tags_shared (
+				device_id VARCHAR(50) NOT NULL,
+				tag VARCHAR(50) NOT NULL,
+				PRIMARY KEY (device_id, tag)
+			);
+			INSERT OR IGNORE INTO device_tags_shared (device_id, tag) SELECT device_id, tag FROM device_tags;
+			DROP TABLE device_tags;
+			ALTER TABLE device_tags_shared RENAME TO device_tags;
+			ALTER TABLE audit_log DROP COLUMN tenant_id;
+			ALTER TABLE rule_events DROP COLUMN tenant_id;
+			ALTER TABLE rules DROP COLUMN tenant_id;`),
+	},
+}
+
+// * dedupeThresholds soft-deletes every live threshold that has an older live one for the same sensor type and device *
+const dedupeThresholds = `UPDATE thresholds SET deleted_at = ?, version = version + 1
+	WHERE deleted_at IS NULL AND EXISTS (
+		SELECT 1 FROM thresholds AS older
+		WHERE older.deleted_at IS NULL AND lower(older.sensor_type) = lower(thresholds.sensor_type)
+			AND older.device_id = thresholds.device_id AND older.id < thresholds.id
+	)`
+
+// * normaliseDateTimes rewrites date_time in models.StorageLayout so that range queries can compare the text *
+// * Rows whose date_time cannot be parsed are left as they are *
+func normaliseDateTimes(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, date_time FROM data WHERE date_time IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	normalised := map[int]models.Timestamp{}
+	for rows.Next() {
+		var id int
+		var raw any
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		var ts models.Timestamp
+		if err := ts.Scan(raw); err == nil && !ts.IsZero() {
+			normalised[id] = ts
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, ts := range normalised {
+		if _, err := tx.ExecContext(ctx, `UPDATE data SET date_time = ? WHERE id = ?`, ts, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * NewMigrator returns the migrator for the SQLite schema *
+func NewMigrator(sqlDB DAL.SQLDatabase) *migrate.Migrator {
+	return migrate.NewMigrator(sqlDB.Connection(), dialect{}, migrations)
+}
+
+// * A lock older than this is left over from a crashed process and is taken over *
+const staleLockAge = 10 * time.Minute
+
+type dialect struct{}
+
+func (dialect) Rebind(query string) string {
+	return query
+}
+
+// * SQLite has no advisory locks, a single row in schema_migrations_lock marks the migrating process *
+func (dialect) Lock(ctx context.Context, db *sql.DB) (func() error, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		locked_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		return nil, err
+	}
+
+	for {
+		// Only read while another process holds the lock, a write here would make its migration fail to take the write lock
+		now := time.Now().UTC()
+		stale := now.Add(-staleLockAge).Format(time.RFC3339)
+		var held int
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations_lock WHERE locked_at >= ?`, stale).Scan(&held); err != nil {
+			return nil, err
+		}
+		if held == 0 {
+			if _, err := db.ExecContext(ctx, `DELETE FROM schema_migrations_lock WHERE locked_at < ?`, stale); err != nil {
+				return nil, err
+			}
+			res, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)`, now.Format(time.RFC3339))
+			if err != nil {
+				return nil, err
+			}
+			if aff, err := res.RowsAffected(); err != nil {
+				return nil, err
+			} else if aff == 1 {
+				break
+			}
+		}
+
+		// Another process holds the lock, wait for it to finish
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	return func() error {
+		_, err := db.Exec(`DELETE FROM schema_migrations_lock`)
+		return err
+	}, nil
+}
@@ -0,0 +1,242 @@
+package SQLite_test
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMigrationsUpDown(t *testing.T) {
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	migrator := SQLite.NewMigrator(db)
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) == 0 {
+		t.Fatal("expected migrations to be applied to an empty database")
+	}
+	if again, err := migrator.Up(ctx); err != nil || len(again) != 0 {
+		t.Fatalf("expected nothing to apply the second time, got %v, %v", again, err)
+	}
+
+	// Revert the latest migration and check that it is pending again
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != applied[len(applied)-1].Version {
+		t.Fatalf("expected the latest migration to be reverted, got %v, %v", reverted, err)
+	}
+	if pending, err := migrator.Pending(ctx); err != nil || pending != 1 {
+		t.Fatalf("expected 1 pending migration, got %d, %v", pending, err)
+	}
+
+	// Revert everything, the schema must be gone
+	if _, err := migrator.Down(ctx, len(applied)); err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	if err := db.Connection().QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('data', 'thresholds')`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Fatalf("expected the tables to be dropped, %d left", tables)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Fatalf("expected every migration to be pending, got %+v", status)
+		}
+	}
+}
+
+// * Databases created before migrations existed must be adopted without losing rows *
+func TestMigrationsAdoptExistingDatabase(t *testing.T) {
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Connection().Exec(`CREATE TABLE data (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		device_id VARCHAR(50) NOT NULL,
+		device_name VARCHAR(50),
+		temp_value FLOAT,
+		humi_value FLOAT,
+		data_type VARCHAR(20),
+		date_time TIMESTAMP
+	);
+	INSERT INTO data (device_id, temp_value, date_time) VALUES ('dev1', 21.5, '2024-01-01T10:00:00Z');`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SQLite.NewMigrator(db).Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := db.Connection().QueryRow(`SELECT COUNT(*) FROM data WHERE deleted_at IS NULL`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected the existing row to be kept, got %d rows", count)
+	}
+}
+
+// * Thresholds that share a sensor type and device before the unique index exists are soft-deleted, except the oldest one *
+func TestMigrationsDedupeThresholds(t *testing.T) {
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	migrator := SQLite.NewMigrator(db)
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// * The later migrations are reverted too, until the unique index is dropped
+	for {
+		reverted, err := migrator.Down(ctx, 1)
+		if err != nil || len(reverted) != 1 || reverted[0].Version < 10 {
+			t.Fatalf("expected the unique index to be dropped, got %v, %v", reverted, err)
+		}
+		if reverted[0].Name == "unique_threshold_scope" {
+			break
+		}
+	}
+	if _, err := db.Connection().Exec(`INSERT INTO thresholds (sensor_type, device_id, min_value, max_value) VALUES
+		('temperature', '', 10, 20), ('Temperature', '', 15, 25), ('temperature', 'dev1', 10, 20), ('humidity', '', 30, 60)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Connection().Query(`SELECT id FROM thresholds WHERE deleted_at IS NULL ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var live []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		live = append(live, id)
+	}
+	if len(live) != 3 || live[0] != 1 || live[1] != 3 || live[2] != 4 {
+		t.Fatalf("expected thresholds 1, 3 and 4 to be kept, got %v", live)
+	}
+	if _, err := db.Connection().Exec(`INSERT INTO thresholds (sensor_type, min_value, max_value) VALUES ('HUMIDITY', 30, 60)`); err == nil {
+		t.Fatal("expected the unique index to reject a second humidity threshold")
+	}
+
+	// * The existing thresholds belong to the default tenant, other tenants have their own
+	var tenants int
+	if err := db.Connection().QueryRow(`SELECT COUNT(*) FROM thresholds WHERE tenant_id = 'default'`).Scan(&tenants); err != nil || tenants != 4 {
+		t.Fatalf("expected the thresholds to belong to the default tenant, got %d, %v", tenants, err)
+	}
+	if _, err := db.Connection().Exec(`INSERT INTO thresholds (sensor_type, min_value, max_value, tenant_id) VALUES ('HUMIDITY', 30, 60, 'acme')`); err != nil {
+		t.Fatalf("expected another tenant to have its own humidity threshold, got %v", err)
+	}
+}
+
+// * Rules, tags and audit entries written before they had a tenant belong to the default tenant *
+func TestMigrationsTenantsOfRulesAndAudit(t *testing.T) {
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	migrator := SQLite.NewMigrator(db)
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reverted, err := migrator.Down(ctx, 1); err != nil || len(reverted) != 1 || reverted[0].Name != "add_tenants_to_rules_and_audit" {
+		t.Fatalf("expected the tenants of rules and audit to be reverted, got %v, %v", reverted, err)
+	}
+	if _, err := db.Connection().Exec(`INSERT INTO rules (name, condition) VALUES ('hot', '{}');
+		INSERT INTO device_tags (device_id, tag) VALUES ('dev1', 'zone-a');
+		INSERT INTO audit_log (time, principal, method, path, status, prev_hash, hash) VALUES ('2024-01-01T10:00:00Z', 'saurav', 'POST', '/rule', 201, '', 'hash')`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"rules", "device_tags", "audit_log"} {
+		var count int
+		if err := db.Connection().QueryRow(`SELECT COUNT(*) FROM ` + table + ` WHERE tenant_id = 'default'`).Scan(&count); err != nil || count != 1 {
+			t.Fatalf("expected the row of %s to belong to the default tenant, got %d, %v", table, count, err)
+		}
+	}
+	if _, err := db.Connection().Exec(`INSERT INTO device_tags (tenant_id, device_id, tag) VALUES ('acme', 'dev1', 'zone-a')`); err != nil {
+		t.Fatalf("expected another tenant to tag its own dev1, got %v", err)
+	}
+
+	// * Reverting keeps the tags of a device once
+	if _, err := migrator.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	var tags int
+	if err := db.Connection().QueryRow(`SELECT COUNT(*) FROM device_tags`).Scan(&tags); err != nil || tags != 1 {
+		t.Fatalf("expected a single tag after reverting, got %d, %v", tags, err)
+	}
+}
+
+func TestMigrationsConcurrentUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	ctx := context.Background()
+
+	// Two instances starting at the same time must apply every migration exactly once
+	var wg sync.WaitGroup
+	results := make([]int, 2)
+	errs := make([]error, 2)
+	for i := range results {
+		db, err := SQLite.NewSqlite(path + "?_busy_timeout=5000")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied, err := SQLite.NewMigrator(db).Up(ctx)
+			results[i], errs[i] = len(applied), err
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := SQLite.NewSqlite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	statuses, err := SQLite.NewMigrator(db).Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0]+results[1] != len(statuses) {
+		t.Fatalf("expected %d migrations to be applied once, got %v", len(statuses), results)
+	}
+}
@@ -0,0 +1,288 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+)
+
+type RuleRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readStmt,
+	readManyStmt,
+	readEnabledStmt,
+	updateStmt,
+	deleteStmt,
+	readTagsStmt,
+	createEventStmt,
+	readEventsStmt *sql.Stmt
+	ctx context.Context
+}
+
+func NewRuleRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.RuleRepository, error) {
+	repo := &RuleRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Prepare SQL statements, every statement but readEnabled is scoped by the tenant of the caller
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO rules (name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readStmt, err := repo.sqlDB.Prepare(`SELECT id, name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id FROM rules WHERE id = ? AND tenant_id = ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readStmt = readStmt
+
+	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id FROM rules WHERE tenant_id = ? ORDER BY id LIMIT ? OFFSET ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	readEnabledStmt, err := repo.sqlDB.Prepare(`SELECT id, name, condition, for_duration, group_tag, min_devices, enabled, updated_at, tenant_id FROM rules WHERE enabled = 1 ORDER BY id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readEnabledStmt = readEnabledStmt
+
+	updateStmt, err := repo.sqlDB.Prepare(`UPDATE rules SET name = ?, condition = ?, for_duration = ?, group_tag = ?, min_devices = ?, enabled = ?, updated_at = ? WHERE id = ? AND tenant_id = ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.updateStmt = updateStmt
+
+	deleteStmt, err := repo.sqlDB.Prepare(`DELETE FROM rules WHERE id = ? AND tenant_id = ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteStmt = deleteStmt
+
+	readTagsStmt, err := repo.sqlDB.Prepare(`SELECT tag FROM device_tags WHERE tenant_id = ? AND device_id = ? ORDER BY tag`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readTagsStmt = readTagsStmt
+
+	createEventStmt, err := repo.sqlDB.Prepare(`INSERT INTO rule_events (rule_id, rule_name, device_ids, date_time, tenant_id) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createEventStmt = createEventStmt
+
+	readEventsStmt, err := repo.sqlDB.Prepare(`SELECT rule_id, rule_name, device_ids, date_time FROM rule_events WHERE tenant_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readEventsStmt = readEventsStmt
+
+	go CloseRule(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseRule(ctx context.Context, r *RuleRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readStmt.Close()
+	r.readManyStmt.Close()
+	r.readEnabledStmt.Close()
+	r.updateStmt.Close()
+	r.deleteStmt.Close()
+	r.readTagsStmt.Close()
+	r.createEventStmt.Close()
+	r.readEventsStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *RuleRepository) Create(rule *models.Rule, ctx context.Context) error {
+	condition, err := json.Marshal(rule.Condition)
+	if err != nil {
+		return err
+	}
+	tenant := auth.Tenant(ctx)
+	res, err := r.createStmt.ExecContext(ctx, rule.Name, string(condition), rule.For, rule.GroupTag, rule.MinDevices, rule.Enabled, rule.UpdatedAt, tenant)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rule.ID, rule.Tenant = int(id), tenant
+	return nil
+}
+
+func (r *RuleRepository) ReadOne(id int, ctx context.Context) (*models.Rule, error) {
+	rule, err := scanRule(r.readStmt.QueryRowContext(ctx, id, auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *RuleRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, auth.Tenant(ctx), rowsPerPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanRules(rows)
+}
+
+func (r *RuleRepository) ReadEnabled(ctx context.Context) ([]*models.Rule, error) {
+	rows, err := r.readEnabledStmt.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return scanRules(rows)
+}
+
+func (r *RuleRepository) Update(rule *models.Rule, ctx context.Context) (int64, error) {
+	condition, err := json.Marshal(rule.Condition)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.updateStmt.ExecContext(ctx, rule.Name, string(condition), rule.For, rule.GroupTag, rule.MinDevices, rule.Enabled, rule.UpdatedAt, rule.ID, auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *RuleRepository) Delete(rule *models.Rule, ctx context.Context) (int64, error) {
+	res, err := r.deleteStmt.ExecContext(ctx, rule.ID, auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *RuleRepository) ReadTags(deviceID string, ctx context.Context) ([]string, error) {
+	rows, err := r.readTagsStmt.QueryContext(ctx, auth.Tenant(ctx), deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// * SetTags replaces all tags of a device in a single transaction *
+func (r *RuleRepository) SetTags(deviceID string, tags []string, ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	tenant := auth.Tenant(ctx)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM device_tags WHERE tenant_id = ? AND device_id = ?`, tenant, deviceID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO device_tags (tenant_id, device_id, tag) VALUES (?, ?, ?)`, tenant, deviceID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *RuleRepository) CreateEvent(event *models.RuleEvent, ctx context.Context) error {
+	deviceIDs, err := json.Marshal(event.DeviceIDs)
+	if err != nil {
+		return err
+	}
+	_, err = r.createEventStmt.ExecContext(ctx, event.RuleID, event.RuleName, string(deviceIDs), event.DateTime, auth.Tenant(ctx))
+	return err
+}
+
+func (r *RuleRepository) ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error) {
+	if page < 1 {
+		page = 1
+	}
+	rows, err := r.readEventsStmt.QueryContext(ctx, auth.Tenant(ctx), rowsPerPage, rowsPerPage*(page-1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.RuleEvent
+	for rows.Next() {
+		var event models.RuleEvent
+		var deviceIDs string
+		if err := rows.Scan(&event.RuleID, &event.RuleName, &deviceIDs, &event.DateTime); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(deviceIDs), &event.DeviceIDs); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRule(row rowScanner) (*models.Rule, error) {
+	var rule models.Rule
+	var condition string
+	var forDuration, groupTag, updatedAt sql.NullString
+	if err := row.Scan(&rule.ID, &rule.Name, &condition, &forDuration, &groupTag, &rule.MinDevices, &rule.Enabled, &updatedAt, &rule.Tenant); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(condition), &rule.Condition); err != nil {
+		return nil, err
+	}
+	rule.For = forDuration.String
+	rule.GroupTag = groupTag.String
+	rule.UpdatedAt = updatedAt.String
+	return &rule, nil
+}
+
+func scanRules(rows *sql.Rows) ([]*models.Rule, error) {
+	defer rows.Close()
+
+	var rules []*models.Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
@@ -1,8 +1,10 @@
 package SQLite
 
 import (
+	"context"
 	"database/sql"
 	"goapi/internal/api/repository/DAL"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -15,7 +17,7 @@ type SQLite struct {
 
 func NewSqlite(dataSourceName string) (DAL.SQLDatabase, error) {
 
-	sqlDB, err := sql.Open("sqlite3", dataSourceName)
+	sqlDB, err := sql.Open("sqlite3", immediateTransactions(dataSourceName))
 	if err != nil {
 		return nil, err
 	}
@@ -33,10 +35,52 @@ func NewSqlite(dataSourceName string) (DAL.SQLDatabase, error) {
 	return Sqlite, nil
 }
 
+// * immediateTransactions makes every transaction take the write lock when it begins *
+// * A deferred transaction reads on a shared lock, so two writers could plan against the same rows and one fail with SQLITE_BUSY *
+// * Transactions waiting for the lock wait up to the busy timeout of the driver, 5 seconds unless the DSN sets _busy_timeout *
+func immediateTransactions(dataSourceName string) string {
+	if strings.Contains(dataSourceName, "_txlock=") {
+		return dataSourceName
+	}
+	if strings.Contains(dataSourceName, "?") {
+		return dataSourceName + "&_txlock=immediate"
+	}
+	return dataSourceName + "?_txlock=immediate"
+}
+
 func (s *SQLite) Connection() *sql.DB {
 	return s.sqlDB
 }
 
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.sqlDB.PingContext(ctx)
+}
+
 func (s *SQLite) Close() error {
 	return s.sqlDB.Close()
 }
+
+// * addColumnIfMissing adds a column to a table created by an earlier version of the schema *
+func addColumnIfMissing(ctx context.Context, tx *sql.Tx, table string, column string, definition string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
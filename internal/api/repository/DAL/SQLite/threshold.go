@@ -3,8 +3,16 @@ package SQLite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapi/internal/api/auth"
 	"goapi/internal/api/repository/DAL"
 	"goapi/internal/api/repository/models"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 type ThresholdRepository struct {
@@ -12,8 +20,16 @@ type ThresholdRepository struct {
 	createStmt,
 	readStmt,
 	readManyStmt,
+	readByTypeStmt,
+	readForDeviceStmt,
 	updateStmt,
-	deleteStmt *sql.Stmt
+	deleteStmt,
+	undeleteStmt,
+	restoreStmt,
+	purgeStmt,
+	historyStmt,
+	readHistoryStmt,
+	readVersionStmt *sql.Stmt
 	ctx context.Context
 }
 
@@ -23,54 +39,104 @@ func NewThresholdRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.
 		ctx:   ctx,
 	}
 
-	// Create the thresholds table if it doesn't exist
-	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS thresholds (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		sensor_type VARCHAR(50) NOT NULL,
-		min_value FLOAT NOT NULL,
-		max_value FLOAT NOT NULL,
-		updated_at TIMESTAMP
-	);`); err != nil {
+	// Prepare SQL statements, soft-deleted rows are only read when the include deleted parameter is true
+	// Every statement but purge is scoped by the tenant of the caller, thresholds of other tenants can't be read or written
+	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO thresholds (sensor_type, device_id, min_value, max_value, unit, updated_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
+	repo.createStmt = createStmt
 
-	// Prepare SQL statements
-	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO thresholds (sensor_type, min_value, max_value, updated_at) VALUES (?, ?, ?, ?)`)
+	readStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE id = ? AND (deleted_at IS NULL OR ?) AND tenant_id = ?`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
-	repo.createStmt = createStmt
+	repo.readStmt = readStmt
 
-	readStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, min_value, max_value, updated_at FROM thresholds WHERE id = ?`)
+	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE (deleted_at IS NULL OR ?) AND tenant_id = ? ORDER BY id LIMIT ? OFFSET ?`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
-	repo.readStmt = readStmt
+	repo.readManyStmt = readManyStmt
 
-	readManyStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, min_value, max_value, updated_at FROM thresholds LIMIT ? OFFSET ?`)
+	// Both lookups are served by the thresholds_scope index
+	readByTypeStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE tenant_id = ? AND lower(sensor_type) = lower(?) AND device_id = ? AND deleted_at IS NULL`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
-	repo.readManyStmt = readManyStmt
+	repo.readByTypeStmt = readByTypeStmt
 
-	updateStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET sensor_type = ?, min_value = ?, max_value = ?, updated_at = ? WHERE id = ?`)
+	readForDeviceStmt, err := repo.sqlDB.Prepare(`SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE tenant_id = ? AND device_id IN ('', ?) AND deleted_at IS NULL ORDER BY lower(sensor_type), device_id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readForDeviceStmt = readForDeviceStmt
+
+	updateStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET sensor_type = ?, device_id = ?, min_value = ?, max_value = ?, unit = ?, updated_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?) AND tenant_id = ?`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.updateStmt = updateStmt
 
-	deleteStmt, err := repo.sqlDB.Prepare(`DELETE FROM thresholds WHERE id = ?`)
+	deleteStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?) AND tenant_id = ?`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.deleteStmt = deleteStmt
 
+	undeleteStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL AND tenant_id = ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.undeleteStmt = undeleteStmt
+
+	// Rollbacks may target a soft-deleted threshold, which is restored at the same time
+	restoreStmt, err := repo.sqlDB.Prepare(`UPDATE thresholds SET sensor_type = ?, device_id = ?, min_value = ?, max_value = ?, unit = ?, updated_at = ?, deleted_at = NULL, version = version + 1 WHERE id = ? AND (? = 0 OR version = ?) AND tenant_id = ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.restoreStmt = restoreStmt
+
+	// Purge is run by the server for every tenant
+	purgeStmt, err := repo.sqlDB.Prepare(`DELETE FROM thresholds WHERE deleted_at IS NOT NULL AND deleted_at < ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.purgeStmt = purgeStmt
+
+	// * The version is derived from the previous entries of the same threshold inside the writing transaction
+	historyStmt, err := repo.sqlDB.Prepare(`INSERT INTO threshold_history (threshold_id, version, action, changed_by, changed_at, before_value, after_value, tenant_id)
+		SELECT ?, COALESCE(MAX(version), 0) + 1, ?, ?, ?, ?, ?, ? FROM threshold_history WHERE threshold_id = ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.historyStmt = historyStmt
+
+	readHistoryStmt, err := repo.sqlDB.Prepare(`SELECT id, threshold_id, version, action, changed_by, changed_at, before_value, after_value FROM threshold_history WHERE threshold_id = ? AND tenant_id = ? ORDER BY version`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readHistoryStmt = readHistoryStmt
+
+	readVersionStmt, err := repo.sqlDB.Prepare(`SELECT id, threshold_id, version, action, changed_by, changed_at, before_value, after_value FROM threshold_history WHERE threshold_id = ? AND version = ? AND tenant_id = ?`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readVersionStmt = readVersionStmt
+
 	go CloseThreshold(ctx, repo)
 
 	return repo, nil
@@ -81,40 +147,60 @@ func CloseThreshold(ctx context.Context, r *ThresholdRepository) {
 	r.createStmt.Close()
 	r.readStmt.Close()
 	r.readManyStmt.Close()
+	r.readByTypeStmt.Close()
+	r.readForDeviceStmt.Close()
 	r.updateStmt.Close()
 	r.deleteStmt.Close()
+	r.undeleteStmt.Close()
+	r.restoreStmt.Close()
+	r.purgeStmt.Close()
+	r.historyStmt.Close()
+	r.readHistoryStmt.Close()
+	r.readVersionStmt.Close()
 	r.sqlDB.Close()
 }
 
 func (r *ThresholdRepository) Create(threshold *models.Threshold, ctx context.Context) error {
-	res, err := r.createStmt.ExecContext(ctx, threshold.SensorType, threshold.MinValue, threshold.MaxValue, threshold.UpdatedAt)
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	res, err := tx.StmtContext(ctx, r.createStmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, threshold.UpdatedAt, auth.Tenant(ctx))
+	if err != nil {
+		return scopeConflict(err)
+	}
 	id, err := res.LastInsertId()
 	if err != nil {
 		return err
 	}
 	threshold.ID = int(id)
-	return nil
+	threshold.Version = 1
+
+	if err := r.recordHistory(tx, threshold.ID, models.ThresholdCreated, nil, threshold, ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *ThresholdRepository) ReadOne(id int, ctx context.Context) (*models.Threshold, error) {
-	row := r.readStmt.QueryRowContext(ctx, id)
-	var threshold models.Threshold
-	err := row.Scan(&threshold.ID, &threshold.SensorType, &threshold.MinValue, &threshold.MaxValue, &threshold.UpdatedAt)
+	threshold, err := scanThreshold(r.readStmt.QueryRowContext(ctx, id, models.IncludeDeleted(ctx), auth.Tenant(ctx)))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &threshold, nil
+	return threshold, nil
 }
 
 func (r *ThresholdRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
+	if page < 1 {
+		page = 1
+	}
 	offset := rowsPerPage * (page - 1)
-	rows, err := r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
+	rows, err := r.readManyStmt.QueryContext(ctx, models.IncludeDeleted(ctx), auth.Tenant(ctx), rowsPerPage, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -122,29 +208,371 @@ func (r *ThresholdRepository) ReadMany(page int, rowsPerPage int, ctx context.Co
 
 	var thresholds []*models.Threshold
 	for rows.Next() {
-		var threshold models.Threshold
-		err := rows.Scan(&threshold.ID, &threshold.SensorType, &threshold.MinValue, &threshold.MaxValue, &threshold.UpdatedAt)
+		threshold, err := scanThreshold(rows)
 		if err != nil {
 			return nil, err
 		}
-		thresholds = append(thresholds, &threshold)
+		thresholds = append(thresholds, threshold)
 	}
 	return thresholds, nil
 }
 
+func (r *ThresholdRepository) ReadByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error) {
+	threshold, err := scanThreshold(r.readByTypeStmt.QueryRowContext(ctx, auth.Tenant(ctx), sensorType, deviceID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return threshold, nil
+}
+
+func (r *ThresholdRepository) ReadForDevice(deviceID string, ctx context.Context) ([]*models.Threshold, error) {
+	rows, err := r.readForDeviceStmt.QueryContext(ctx, auth.Tenant(ctx), deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var thresholds []*models.Threshold
+	for rows.Next() {
+		threshold, err := scanThreshold(rows)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, rows.Err()
+}
+
 func (r *ThresholdRepository) Update(threshold *models.Threshold, ctx context.Context) (int64, error) {
-	res, err := r.updateStmt.ExecContext(ctx, threshold.SensorType, threshold.MinValue, threshold.MaxValue, threshold.UpdatedAt, threshold.ID)
+	return r.update(threshold, r.updateStmt, models.ThresholdUpdated, ctx)
+}
+
+// * Modify claims the write lock before reading the row, so that concurrent changes are applied one after the other *
+func (r *ThresholdRepository) Modify(id int, change func(threshold *models.Threshold) error, ctx context.Context) (*models.Threshold, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE thresholds SET id = id WHERE id = ? AND deleted_at IS NULL AND tenant_id = ?`, id, auth.Tenant(ctx)); err != nil {
+		return nil, err
+	}
+	before, err := r.readInTx(tx, id, false, ctx)
+	if err != nil || before == nil {
+		return nil, err
+	}
+
+	threshold := *before
+	if err := change(&threshold); err != nil {
+		return nil, err
+	}
+	threshold.ID = id
+	threshold.Version = before.Version + 1
+	if _, err := tx.StmtContext(ctx, r.updateStmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, threshold.UpdatedAt, id, 0, 0, auth.Tenant(ctx)); err != nil {
+		return nil, scopeConflict(err)
+	}
+	if err := r.recordHistory(tx, id, models.ThresholdUpdated, before, &threshold, ctx); err != nil {
+		return nil, err
+	}
+	return &threshold, tx.Commit()
+}
+
+func (r *ThresholdRepository) Delete(threshold *models.Threshold, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.readInTx(tx, threshold.ID, false, ctx)
+	if err != nil || before == nil {
+		return 0, err
+	}
+
+	expected := models.ExpectedVersion(ctx)
+	res, err := tx.StmtContext(ctx, r.deleteStmt).ExecContext(ctx, time.Now().UTC().Format(time.RFC3339), threshold.ID, expected, expected, auth.Tenant(ctx))
+	if err != nil {
+		return 0, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil || aff == 0 {
+		return 0, err
+	}
+
+	if err := r.recordHistory(tx, threshold.ID, models.ThresholdDeleted, before, nil, ctx); err != nil {
+		return 0, err
+	}
+	return aff, tx.Commit()
+}
+
+// * Undelete clears the deletion mark and records the restored values in the history *
+func (r *ThresholdRepository) Undelete(id int, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.StmtContext(ctx, r.undeleteStmt).ExecContext(ctx, id, auth.Tenant(ctx))
+	if err != nil {
+		return 0, scopeConflict(err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil || aff == 0 {
+		return 0, err
+	}
+
+	after, err := r.readInTx(tx, id, false, ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.recordHistory(tx, id, models.ThresholdRestored, nil, after, ctx); err != nil {
+		return 0, err
+	}
+	return aff, tx.Commit()
+}
+
+func (r *ThresholdRepository) Purge(before string, ctx context.Context) (int64, error) {
+	res, err := r.purgeStmt.ExecContext(ctx, before)
 	if err != nil {
 		return 0, err
 	}
 	return res.RowsAffected()
 }
 
-func (r *ThresholdRepository) Delete(threshold *models.Threshold, ctx context.Context) (int64, error) {
-    res, err := r.deleteStmt.ExecContext(ctx, threshold.ID)
-    if err != nil {
-        return 0, err
-    }
-    return res.RowsAffected()
+// * Import plans and applies the changes in one transaction, which holds the write lock from BEGIN IMMEDIATE on *
+// * Concurrent imports are serialized, each plan is made from the thresholds it replaces *
+func (r *ThresholdRepository) Import(plan func(current []*models.Threshold) ([]*models.ThresholdChange, error), ctx context.Context) error {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, sensor_type, device_id, min_value, max_value, unit, updated_at, deleted_at, version FROM thresholds WHERE deleted_at IS NULL AND tenant_id = ? ORDER BY id`, auth.Tenant(ctx))
+	if err != nil {
+		return err
+	}
+	var current []*models.Threshold
+	for rows.Next() {
+		threshold, err := scanThreshold(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, threshold)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	changes, err := plan(current)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := r.apply(tx, change, ctx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// * apply writes one change of an import and records it in the history *
+func (r *ThresholdRepository) apply(tx *sql.Tx, change *models.ThresholdChange, ctx context.Context) error {
+	switch change.Action {
+	case models.ThresholdCreated:
+		threshold := change.After
+		res, err := tx.StmtContext(ctx, r.createStmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, threshold.UpdatedAt, auth.Tenant(ctx))
+		if err != nil {
+			return scopeConflict(err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		threshold.ID = int(id)
+		threshold.Version = 1
+	case models.ThresholdUpdated:
+		threshold := change.After
+		threshold.ID = change.Before.ID
+		if _, err := tx.StmtContext(ctx, r.updateStmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, threshold.UpdatedAt, threshold.ID, 0, 0, auth.Tenant(ctx)); err != nil {
+			return scopeConflict(err)
+		}
+		threshold.Version = change.Before.Version + 1
+	case models.ThresholdDeleted:
+		if _, err := tx.StmtContext(ctx, r.deleteStmt).ExecContext(ctx, time.Now().UTC().Format(time.RFC3339), change.Before.ID, 0, 0, auth.Tenant(ctx)); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown threshold change %q", change.Action)
+	}
+
+	id := change.After
+	if id == nil {
+		id = change.Before
+	}
+	return r.recordHistory(tx, id.ID, change.Action, change.Before, change.After, ctx)
+}
+
+func (r *ThresholdRepository) ReadHistory(thresholdID int, ctx context.Context) ([]*models.ThresholdHistory, error) {
+	rows, err := r.readHistoryStmt.QueryContext(ctx, thresholdID, auth.Tenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*models.ThresholdHistory
+	for rows.Next() {
+		entry, err := scanThresholdHistory(rows)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
 }
 
+func (r *ThresholdRepository) ReadVersion(thresholdID int, version int, ctx context.Context) (*models.ThresholdHistory, error) {
+	entry, err := scanThresholdHistory(r.readVersionStmt.QueryRowContext(ctx, thresholdID, version, auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// * Restore writes the given values back under the same ID, re-creating the row if it was deleted or purged *
+func (r *ThresholdRepository) Restore(threshold *models.Threshold, ctx context.Context) error {
+	aff, err := r.update(threshold, r.restoreStmt, models.ThresholdRolledBack, models.WithDeleted(ctx))
+	if err != nil || aff > 0 {
+		return err
+	}
+
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO thresholds (id, sensor_type, device_id, min_value, max_value, unit, updated_at, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		threshold.ID, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, threshold.UpdatedAt, auth.Tenant(ctx)); err != nil {
+		return scopeConflict(err)
+	}
+	threshold.Version = 1
+	if err := r.recordHistory(tx, threshold.ID, models.ThresholdRolledBack, nil, threshold, ctx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// * update changes the row and records the before and after values in one transaction *
+func (r *ThresholdRepository) update(threshold *models.Threshold, stmt *sql.Stmt, action string, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := r.readInTx(tx, threshold.ID, models.IncludeDeleted(ctx), ctx)
+	if err != nil || before == nil {
+		return 0, err
+	}
+
+	expected := models.ExpectedVersion(ctx)
+	res, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, threshold.SensorType, threshold.DeviceID, threshold.MinValue, threshold.MaxValue, threshold.Unit, threshold.UpdatedAt, threshold.ID, expected, expected, auth.Tenant(ctx))
+	if err != nil {
+		return 0, scopeConflict(err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil || aff == 0 {
+		return 0, err
+	}
+	threshold.Version = before.Version + 1
+
+	if err := r.recordHistory(tx, threshold.ID, action, before, threshold, ctx); err != nil {
+		return 0, err
+	}
+	return aff, tx.Commit()
+}
+
+func (r *ThresholdRepository) readInTx(tx *sql.Tx, id int, includeDeleted bool, ctx context.Context) (*models.Threshold, error) {
+	threshold, err := scanThreshold(tx.StmtContext(ctx, r.readStmt).QueryRowContext(ctx, id, includeDeleted, auth.Tenant(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return threshold, nil
+}
+
+// * scopeConflict replaces the violation of the thresholds_scope index by models.ErrDuplicateThreshold *
+func scopeConflict(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique && strings.Contains(sqliteErr.Error(), "thresholds_scope") {
+		return models.ErrDuplicateThreshold
+	}
+	return err
+}
+
+func scanThreshold(row rowScanner) (*models.Threshold, error) {
+	var threshold models.Threshold
+	var deletedAt sql.NullString
+	if err := row.Scan(&threshold.ID, &threshold.SensorType, &threshold.DeviceID, &threshold.MinValue, &threshold.MaxValue, &threshold.Unit, &threshold.UpdatedAt, &deletedAt, &threshold.Version); err != nil {
+		return nil, err
+	}
+	threshold.DeletedAt = deletedAt.String
+	return &threshold, nil
+}
+
+// * recordHistory appends a history entry, the user is taken from the authenticated principal and the time is set by the server *
+func (r *ThresholdRepository) recordHistory(tx *sql.Tx, thresholdID int, action string, before, after *models.Threshold, ctx context.Context) error {
+	var beforeValue, afterValue sql.NullString
+	if before != nil {
+		b, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		beforeValue = sql.NullString{String: string(b), Valid: true}
+	}
+	if after != nil {
+		a, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		afterValue = sql.NullString{String: string(a), Valid: true}
+	}
+
+	_, err := tx.StmtContext(ctx, r.historyStmt).ExecContext(ctx, thresholdID, action, auth.Username(ctx),
+		time.Now().UTC().Format(time.RFC3339), beforeValue, afterValue, auth.Tenant(ctx), thresholdID)
+	return err
+}
+
+func scanThresholdHistory(row rowScanner) (*models.ThresholdHistory, error) {
+	var entry models.ThresholdHistory
+	var beforeValue, afterValue sql.NullString
+	if err := row.Scan(&entry.ID, &entry.ThresholdID, &entry.Version, &entry.Action, &entry.ChangedBy, &entry.ChangedAt, &beforeValue, &afterValue); err != nil {
+		return nil, err
+	}
+	if beforeValue.Valid {
+		entry.Before = &models.Threshold{}
+		if err := json.Unmarshal([]byte(beforeValue.String), entry.Before); err != nil {
+			return nil, err
+		}
+	}
+	if afterValue.Valid {
+		entry.After = &models.Threshold{}
+		if err := json.Unmarshal([]byte(afterValue.String), entry.After); err != nil {
+			return nil, err
+		}
+	}
+	return &entry, nil
+}
@@ -1,10 +1,13 @@
 package DAL
 
 import (
+	"context"
 	"database/sql"
 )
 
 type SQLDatabase interface {
 	Connection() *sql.DB
+	// * Ping checks that the database can be reached, used by the readiness check *
+	Ping(ctx context.Context) error
 	Close() error
 }
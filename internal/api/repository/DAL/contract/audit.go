@@ -0,0 +1,84 @@
+package contract
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"testing"
+)
+
+// * NewAuditRepository returns a repository on an empty database that lives until ctx is done *
+type NewAuditRepository func(t *testing.T, ctx context.Context) models.AuditRepository
+
+// * RunAuditRepository checks an audit repository against the shared contract *
+func RunAuditRepository(t *testing.T, newRepo NewAuditRepository) {
+	t.Run("Chain", func(t *testing.T) {
+		ctx, repo := openAudit(t, newRepo)
+		first := createAuditEntry(t, repo, ctx, "saurav", "2024-01-01T10:00:00Z")
+		second := createAuditEntry(t, repo, ctx, "other", "2024-01-01T11:00:00Z")
+		if first.PrevHash != "" || second.PrevHash != first.Hash || second.Hash != second.ComputeHash() {
+			t.Fatalf("expected the entries to be chained, got %+v and %+v", first, second)
+		}
+
+		entries, err := repo.ReadMany(models.AuditFilter{}, 1, 10, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].ID != second.ID || entries[1].Hash != first.Hash {
+			t.Fatalf("expected the newest entry first, got %+v", entries)
+		}
+		if entries, err := repo.ReadMany(models.AuditFilter{Principal: "saurav"}, 1, 10, ctx); err != nil || len(entries) != 1 || entries[0].ID != first.ID {
+			t.Fatalf("expected the entry of the principal, got %+v, %v", entries, err)
+		}
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		ctx, repo := openAudit(t, newRepo)
+		acme, globex := tenant(ctx, "acme"), tenant(ctx, "globex")
+		first := createAuditEntry(t, repo, acme, "acme-user", "2024-01-01T10:00:00Z")
+		theirs := createAuditEntry(t, repo, globex, "globex-user", "2024-01-01T11:00:00Z")
+		second := createAuditEntry(t, repo, acme, "acme-user", "2024-01-01T12:00:00Z")
+
+		// * Every tenant has its own chain, entries of other tenants don't break it
+		if theirs.PrevHash != "" || second.PrevHash != first.Hash {
+			t.Fatalf("expected a chain per tenant, got %+v, %+v and %+v", first, theirs, second)
+		}
+		var walked []int
+		err := repo.Walk(func(entry *models.AuditEntry) error {
+			walked = append(walked, entry.ID)
+			return nil
+		}, acme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(walked) != 2 || walked[0] != first.ID || walked[1] != second.ID {
+			t.Fatalf("expected the entries of the tenant in order, got %v", walked)
+		}
+		if entries, err := repo.ReadMany(models.AuditFilter{}, 1, 10, globex); err != nil || len(entries) != 1 || entries[0].ID != theirs.ID {
+			t.Fatalf("expected only the entry of the tenant, got %+v, %v", entries, err)
+		}
+		if entries, err := repo.ReadMany(models.AuditFilter{}, 1, 10, ctx); err != nil || len(entries) != 0 {
+			t.Fatalf("expected no entries of the default tenant, got %+v, %v", entries, err)
+		}
+	})
+}
+
+func openAudit(t *testing.T, newRepo NewAuditRepository) (context.Context, models.AuditRepository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return ctx, newRepo(t, ctx)
+}
+
+func createAuditEntry(t *testing.T, repo models.AuditRepository, ctx context.Context, principal string, at string) *models.AuditEntry {
+	entry := &models.AuditEntry{
+		Time:      at,
+		Principal: principal,
+		Method:    "POST",
+		Path:      "/data",
+		Status:    201,
+		RequestID: "request-" + at,
+	}
+	if err := repo.Create(entry, ctx); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
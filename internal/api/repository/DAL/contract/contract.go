@@ -0,0 +1,821 @@
+// Package contract holds the behaviour every repository backend must share.
+// Each backend runs the suites from its own tests with a factory that returns
+// a repository on an empty database.
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// * NewDataRepository returns a repository on an empty database that lives until ctx is done *
+type NewDataRepository func(t *testing.T, ctx context.Context) models.DataRepository
+
+// * NewThresholdRepository returns a repository on an empty database that lives until ctx is done *
+type NewThresholdRepository func(t *testing.T, ctx context.Context) models.ThresholdRepository
+
+// * RunDataRepository checks a data repository against the shared contract *
+func RunDataRepository(t *testing.T, newRepo NewDataRepository) {
+	t.Run("CreateAssignsIDs", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		first := createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00Z")
+		second := createData(t, repo, ctx, "dev1", "2024-01-01T11:00:00Z")
+		if first.ID == 0 || second.ID <= first.ID {
+			t.Fatalf("expected increasing ids, got %d and %d", first.ID, second.ID)
+		}
+
+		got, err := repo.ReadOne(second.ID, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || *got != *second {
+			t.Fatalf("expected %+v, got %+v", second, got)
+		}
+	})
+
+	t.Run("ReadOneMissing", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		got, err := repo.ReadOne(42, ctx)
+		if err != nil || got != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("ReadManyPages", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		for i := 0; i < 5; i++ {
+			createData(t, repo, ctx, "dev1", time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC).Format(time.RFC3339))
+		}
+
+		page, err := repo.ReadMany(2, 2, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) != 2 || page[0].ID >= page[1].ID {
+			t.Fatalf("expected the 3rd and 4th rows in order, got %+v", page)
+		}
+
+		all, err := repo.ReadMany(0, 2, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 5 {
+			t.Fatalf("expected page 0 to return all 5 rows, got %d", len(all))
+		}
+	})
+
+	t.Run("ReadRange", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		createData(t, repo, ctx, "dev1", "2024-01-01T12:00:00Z")
+		createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00Z")
+		createData(t, repo, ctx, "dev1", "2024-01-01T11:00:00Z")
+
+		got, err := repo.ReadRange(instant(t, "2024-01-01T10:30:00Z"), instant(t, "2024-01-01T12:00:00Z"), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[0].DateTime.String() != "2024-01-01T11:00:00Z" || got[1].DateTime.String() != "2024-01-01T12:00:00Z" {
+			t.Fatalf("expected 11:00 and 12:00 in order, got %+v", got)
+		}
+
+		got, err = repo.ReadRange(time.Time{}, time.Time{}, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 3 || got[0].DateTime.String() != "2024-01-01T10:00:00Z" {
+			t.Fatalf("expected all rows in chronological order, got %+v", got)
+		}
+	})
+
+	t.Run("Timestamps", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		// 09:30 UTC sent with an offset sorts before 10:00 UTC, nanoseconds survive the round trip
+		createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00.123456789Z")
+		early := createData(t, repo, ctx, "dev1", "2024-01-01T11:30:00+02:00")
+		received := models.NewTimestamp(instant(t, "2024-01-01T10:00:05Z"))
+		early.ReceivedAt = received
+		if _, err := repo.Update(early, ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repo.ReadRange(time.Time{}, instant(t, "2024-01-01T10:00:00Z"), ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].DateTime.String() != "2024-01-01T09:30:00Z" {
+			t.Fatalf("expected only the reading taken at 09:30 UTC, got %+v", got)
+		}
+		if !got[0].ReceivedAt.IsZero() {
+			t.Errorf("expected update to leave received_at alone, got %s", got[0].ReceivedAt)
+		}
+
+		got, err = repo.ReadRange(time.Time{}, time.Time{}, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 || got[1].DateTime.String() != "2024-01-01T10:00:00.123456789Z" {
+			t.Fatalf("expected the nanoseconds to be kept, got %+v", got)
+		}
+
+		data := &models.Data{DeviceID: "dev2", DateTime: received, ReceivedAt: received}
+		if err := repo.Create(data, ctx); err != nil {
+			t.Fatal(err)
+		}
+		read, err := repo.ReadOne(data.ID, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !read.ReceivedAt.Equal(received.Time) {
+			t.Errorf("expected received_at %s, got %s", received, read.ReceivedAt)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		data := createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00Z")
+		data.TemperatureValue = 30.5
+
+		if aff, err := repo.Update(data, ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row updated, got %d, %v", aff, err)
+		}
+		got, err := repo.ReadOne(data.ID, ctx)
+		if err != nil || got == nil || got.TemperatureValue != 30.5 {
+			t.Fatalf("expected the updated row, got %+v, %v", got, err)
+		}
+
+		missing := *data
+		missing.ID = data.ID + 100
+		if aff, err := repo.Update(&missing, ctx); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows updated, got %d, %v", aff, err)
+		}
+	})
+
+	t.Run("Modify", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		data := createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00Z")
+
+		got, err := repo.Modify(data.ID, func(d *models.Data) error {
+			if d.TemperatureValue != data.TemperatureValue {
+				return fmt.Errorf("expected the stored reading, got %+v", d)
+			}
+			d.TemperatureValue = 30.5
+			return nil
+		}, ctx)
+		if err != nil || got == nil || got.TemperatureValue != 30.5 || got.ID != data.ID {
+			t.Fatalf("expected the modified row, got %+v, %v", got, err)
+		}
+		if got, err := repo.ReadOne(data.ID, ctx); err != nil || got == nil || got.TemperatureValue != 30.5 || !got.ReceivedAt.Equal(data.ReceivedAt.Time) {
+			t.Fatalf("expected the modified row to be stored, got %+v, %v", got, err)
+		}
+
+		rejected := errors.New("rejected")
+		if _, err := repo.Modify(data.ID, func(d *models.Data) error { d.TemperatureValue = 99; return rejected }, ctx); err != rejected {
+			t.Fatalf("expected the error of change, got %v", err)
+		}
+		if got, err := repo.ReadOne(data.ID, ctx); err != nil || got == nil || got.TemperatureValue != 30.5 {
+			t.Fatalf("expected nothing to be written, got %+v, %v", got, err)
+		}
+
+		called := false
+		if got, err := repo.Modify(data.ID+100, func(d *models.Data) error { called = true; return nil }, ctx); err != nil || got != nil || called {
+			t.Fatalf("expected nil, nil without calling change, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		data := createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00Z")
+		if data.Version != 1 {
+			t.Fatalf("expected version 1 on create, got %d", data.Version)
+		}
+
+		if aff, err := repo.Update(data, ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row updated, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Update(data, models.WithExpectedVersion(ctx, 1)); err != nil || aff != 0 {
+			t.Fatalf("expected an update of a stale version to affect 0 rows, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Update(data, models.WithExpectedVersion(ctx, 2)); err != nil || aff != 1 {
+			t.Fatalf("expected an update of the current version, got %d, %v", aff, err)
+		}
+		got, err := repo.Modify(data.ID, func(d *models.Data) error { return nil }, ctx)
+		if err != nil || got == nil || got.Version != 4 {
+			t.Fatalf("expected version 4 after modify, got %+v, %v", got, err)
+		}
+
+		if aff, err := repo.Delete(data, models.WithExpectedVersion(ctx, 3)); err != nil || aff != 0 {
+			t.Fatalf("expected a delete of a stale version to affect 0 rows, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Delete(data, models.WithExpectedVersion(ctx, 4)); err != nil || aff != 1 {
+			t.Fatalf("expected a delete of the current version, got %d, %v", aff, err)
+		}
+		if _, err := repo.Undelete(data.ID, ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := repo.ReadOne(data.ID, ctx); err != nil || got == nil || got.Version != 6 {
+			t.Fatalf("expected version 6 after delete and restore, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("SoftDelete", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		data := createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00Z")
+
+		if aff, err := repo.Delete(data, ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row deleted, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Delete(data, ctx); err != nil || aff != 0 {
+			t.Fatalf("expected a second delete to affect 0 rows, got %d, %v", aff, err)
+		}
+		if got, err := repo.ReadOne(data.ID, ctx); err != nil || got != nil {
+			t.Fatalf("expected the deleted row to be hidden, got %+v, %v", got, err)
+		}
+		if aff, err := repo.Update(data, ctx); err != nil || aff != 0 {
+			t.Fatalf("expected deleted rows not to be updated, got %d, %v", aff, err)
+		}
+
+		got, err := repo.ReadOne(data.ID, models.WithDeleted(ctx))
+		if err != nil || got == nil || got.DeletedAt == "" {
+			t.Fatalf("expected the deleted row with its deletion time, got %+v, %v", got, err)
+		}
+		all, err := repo.ReadMany(0, 10, models.WithDeleted(ctx))
+		if err != nil || len(all) != 1 {
+			t.Fatalf("expected the deleted row to be listed, got %+v, %v", all, err)
+		}
+
+		if aff, err := repo.Undelete(data.ID, ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row restored, got %d, %v", aff, err)
+		}
+		if got, err := repo.ReadOne(data.ID, ctx); err != nil || got == nil || got.DeletedAt != "" {
+			t.Fatalf("expected the restored row, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		deleted := createData(t, repo, ctx, "dev1", "2024-01-01T10:00:00Z")
+		kept := createData(t, repo, ctx, "dev1", "2024-01-01T11:00:00Z")
+		if _, err := repo.Delete(deleted, ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if aff, err := repo.Purge(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), ctx); err != nil || aff != 0 {
+			t.Fatalf("expected rows inside the grace period to be kept, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Purge(time.Now().Add(time.Hour).UTC().Format(time.RFC3339), ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row purged, got %d, %v", aff, err)
+		}
+		if got, err := repo.ReadOne(deleted.ID, models.WithDeleted(ctx)); err != nil || got != nil {
+			t.Fatalf("expected the purged row to be gone, got %+v, %v", got, err)
+		}
+		if got, err := repo.ReadOne(kept.ID, ctx); err != nil || got == nil {
+			t.Fatalf("expected the other row to be kept, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		ctx, repo := openData(t, newRepo)
+		acme, globex := tenant(ctx, "acme"), tenant(ctx, "globex")
+		theirs := createData(t, repo, acme, "dev1", "2024-01-01T10:00:00Z")
+		ours := createData(t, repo, globex, "dev1", "2024-01-01T11:00:00Z")
+
+		// * Rows of another tenant can't be read, not even with deleted rows or by the default tenant
+		for _, ctx := range []context.Context{globex, models.WithDeleted(globex), ctx} {
+			if got, err := repo.ReadOne(theirs.ID, ctx); err != nil || got != nil {
+				t.Fatalf("expected the row of another tenant to be hidden, got %+v, %v", got, err)
+			}
+		}
+		if all, err := repo.ReadMany(0, 10, globex); err != nil || len(all) != 1 || all[0].ID != ours.ID {
+			t.Fatalf("expected only the row of the tenant, got %+v, %v", all, err)
+		}
+		if page, err := repo.ReadMany(1, 10, globex); err != nil || len(page) != 1 || page[0].ID != ours.ID {
+			t.Fatalf("expected only the row of the tenant, got %+v, %v", page, err)
+		}
+		if all, err := repo.ReadRange(time.Time{}, time.Time{}, globex); err != nil || len(all) != 1 || all[0].ID != ours.ID {
+			t.Fatalf("expected only the row of the tenant, got %+v, %v", all, err)
+		}
+
+		// * ... nor written
+		changed := *theirs
+		changed.TemperatureValue = -10
+		if aff, err := repo.Update(&changed, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows updated, got %d, %v", aff, err)
+		}
+		if got, err := repo.Modify(theirs.ID, func(data *models.Data) error { data.TemperatureValue = -10; return nil }, globex); err != nil || got != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", got, err)
+		}
+		if aff, err := repo.Delete(theirs, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows deleted, got %d, %v", aff, err)
+		}
+		if _, err := repo.Delete(theirs, acme); err != nil {
+			t.Fatal(err)
+		}
+		if aff, err := repo.Undelete(theirs.ID, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows restored, got %d, %v", aff, err)
+		}
+		got, err := repo.ReadOne(theirs.ID, models.WithDeleted(acme))
+		if err != nil || got == nil || got.TemperatureValue != theirs.TemperatureValue || got.DeletedAt == "" || got.Version != 2 {
+			t.Fatalf("expected the row to be changed by its tenant only, got %+v, %v", got, err)
+		}
+
+		// * The purge job runs for every tenant
+		if aff, err := repo.Purge(time.Now().Add(time.Hour).UTC().Format(time.RFC3339), ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row purged, got %d, %v", aff, err)
+		}
+	})
+}
+
+// * RunThresholdRepository checks a threshold repository against the shared contract *
+func RunThresholdRepository(t *testing.T, newRepo NewThresholdRepository) {
+	t.Run("CRUD", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		threshold := createThreshold(t, repo, ctx, "temperature")
+		if threshold.ID == 0 {
+			t.Fatal("expected an id to be assigned")
+		}
+
+		got, err := repo.ReadOne(threshold.ID, ctx)
+		if err != nil || got == nil || *got != *threshold {
+			t.Fatalf("expected %+v, got %+v, %v", threshold, got, err)
+		}
+		if got, err := repo.ReadOne(threshold.ID+100, ctx); err != nil || got != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", got, err)
+		}
+
+		threshold.MaxValue = 40
+		threshold.Unit = "F"
+		threshold.DeviceID = "dev1"
+		if aff, err := repo.Update(threshold, ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row updated, got %d, %v", aff, err)
+		}
+		if got, err := repo.ReadOne(threshold.ID, ctx); err != nil || got == nil || *got != *threshold {
+			t.Fatalf("expected the update to be stored, got %+v, %v", got, err)
+		}
+		missing := *threshold
+		missing.ID = threshold.ID + 100
+		if aff, err := repo.Update(&missing, ctx); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows updated, got %d, %v", aff, err)
+		}
+	})
+
+	t.Run("ReadManyPages", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		for _, sensorType := range []string{"a", "b", "c"} {
+			createThreshold(t, repo, ctx, sensorType)
+		}
+
+		page, err := repo.ReadMany(2, 2, ctx)
+		if err != nil || len(page) != 1 || page[0].SensorType != "c" {
+			t.Fatalf("expected the last threshold on page 2, got %+v, %v", page, err)
+		}
+		page, err = repo.ReadMany(0, 2, ctx)
+		if err != nil || len(page) != 2 || page[0].SensorType != "a" {
+			t.Fatalf("expected page 0 to be the first page, got %+v, %v", page, err)
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		threshold := createThreshold(t, repo, ctx, "temperature")
+		threshold.MaxValue = 40
+		if _, err := repo.Update(threshold, ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Delete(threshold, ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		history, err := repo.ReadHistory(threshold.ID, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions := []string{models.ThresholdCreated, models.ThresholdUpdated, models.ThresholdDeleted}
+		if len(history) != len(actions) {
+			t.Fatalf("expected %d history entries, got %d", len(actions), len(history))
+		}
+		for i, entry := range history {
+			if entry.Version != i+1 || entry.Action != actions[i] {
+				t.Fatalf("expected version %d %s, got %+v", i+1, actions[i], entry)
+			}
+		}
+		if history[1].Before == nil || history[1].Before.MaxValue != 30 || history[1].After == nil || history[1].After.MaxValue != 40 {
+			t.Fatalf("expected the update to record before and after values, got %+v", history[1])
+		}
+		if history[2].After != nil {
+			t.Fatalf("expected the delete to have no after value, got %+v", history[2].After)
+		}
+
+		version, err := repo.ReadVersion(threshold.ID, 2, ctx)
+		if err != nil || version == nil || version.Action != models.ThresholdUpdated {
+			t.Fatalf("expected version 2, got %+v, %v", version, err)
+		}
+		if version, err := repo.ReadVersion(threshold.ID, 9, ctx); err != nil || version != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", version, err)
+		}
+	})
+
+	t.Run("Modify", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		threshold := createThreshold(t, repo, ctx, "temperature")
+
+		got, err := repo.Modify(threshold.ID, func(th *models.Threshold) error { th.MaxValue = 40; return nil }, ctx)
+		if err != nil || got == nil || got.MaxValue != 40 || got.MinValue != threshold.MinValue {
+			t.Fatalf("expected the modified threshold, got %+v, %v", got, err)
+		}
+		rejected := errors.New("rejected")
+		if _, err := repo.Modify(threshold.ID, func(th *models.Threshold) error { th.MaxValue = 50; return rejected }, ctx); err != rejected {
+			t.Fatalf("expected the error of change, got %v", err)
+		}
+		if got, err := repo.ReadOne(threshold.ID, ctx); err != nil || got == nil || got.MaxValue != 40 {
+			t.Fatalf("expected the first change only, got %+v, %v", got, err)
+		}
+
+		history, err := repo.ReadHistory(threshold.ID, ctx)
+		if err != nil || len(history) != 2 || history[1].Action != models.ThresholdUpdated || history[1].Before.MaxValue != 30 || history[1].After.MaxValue != 40 {
+			t.Fatalf("expected the creation and one update, got %+v, %v", history, err)
+		}
+
+		if _, err := repo.Delete(threshold, ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := repo.Modify(threshold.ID, func(th *models.Threshold) error { return nil }, ctx); err != nil || got != nil {
+			t.Fatalf("expected deleted thresholds not to be modified, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		threshold := createThreshold(t, repo, ctx, "temperature")
+		if threshold.Version != 1 {
+			t.Fatalf("expected version 1 on create, got %d", threshold.Version)
+		}
+
+		threshold.MaxValue = 40
+		if aff, err := repo.Update(threshold, models.WithExpectedVersion(ctx, 2)); err != nil || aff != 0 {
+			t.Fatalf("expected an update of another version to affect 0 rows, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Update(threshold, models.WithExpectedVersion(ctx, 1)); err != nil || aff != 1 || threshold.Version != 2 {
+			t.Fatalf("expected version 2 after update, got %d, %v, %d", aff, err, threshold.Version)
+		}
+		got, err := repo.Modify(threshold.ID, func(th *models.Threshold) error { return nil }, ctx)
+		if err != nil || got == nil || got.Version != 3 {
+			t.Fatalf("expected version 3 after modify, got %+v, %v", got, err)
+		}
+		if got, err := repo.ReadOne(threshold.ID, ctx); err != nil || got == nil || got.Version != 3 {
+			t.Fatalf("expected version 3 to be stored, got %+v, %v", got, err)
+		}
+
+		if aff, err := repo.Delete(threshold, models.WithExpectedVersion(ctx, 2)); err != nil || aff != 0 {
+			t.Fatalf("expected a delete of a stale version to affect 0 rows, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Delete(threshold, models.WithExpectedVersion(ctx, 3)); err != nil || aff != 1 {
+			t.Fatalf("expected a delete of the current version, got %d, %v", aff, err)
+		}
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		kept := createThreshold(t, repo, ctx, "temperature")
+		removed := createThreshold(t, repo, ctx, "humidity")
+
+		// * An error of the plan is returned as is and nothing is written
+		errPlan := errors.New("plan failed")
+		err := repo.Import(func(current []*models.Threshold) ([]*models.ThresholdChange, error) {
+			return []*models.ThresholdChange{{Action: models.ThresholdCreated, After: &models.Threshold{SensorType: "pressure", MaxValue: 1}}}, errPlan
+		}, ctx)
+		if err != errPlan {
+			t.Fatalf("expected the error of the plan, got %v", err)
+		}
+
+		updated := *kept
+		updated.MaxValue = 50
+		created := &models.Threshold{SensorType: "pressure", DeviceID: "dev1", MinValue: 900, MaxValue: 1100, UpdatedAt: "2024-01-01T10:00:00Z"}
+		var seen []*models.Threshold
+		err = repo.Import(func(current []*models.Threshold) ([]*models.ThresholdChange, error) {
+			seen = current
+			return []*models.ThresholdChange{
+				{Action: models.ThresholdUpdated, Before: current[0], After: &updated},
+				{Action: models.ThresholdDeleted, Before: current[1]},
+				{Action: models.ThresholdCreated, After: created},
+			}, nil
+		}, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(seen) != 2 || seen[0].ID != kept.ID || seen[1].ID != removed.ID {
+			t.Fatalf("expected the plan to see the live thresholds in ID order, got %+v", seen)
+		}
+
+		if got, err := repo.ReadOne(kept.ID, ctx); err != nil || got == nil || got.MaxValue != 50 || got.Version != 2 || updated.Version != 2 {
+			t.Fatalf("expected the update to be stored as version 2, got %+v, %v", got, err)
+		}
+		if got, err := repo.ReadOne(removed.ID, ctx); err != nil || got != nil {
+			t.Fatalf("expected the threshold to be deleted, got %+v, %v", got, err)
+		}
+		if created.ID == 0 || created.Version != 1 {
+			t.Fatalf("expected an ID to be assigned to the created threshold, got %+v", created)
+		}
+		if got, err := repo.ReadOne(created.ID, ctx); err != nil || got == nil || got.DeviceID != "dev1" {
+			t.Fatalf("expected the created threshold, got %+v, %v", got, err)
+		}
+		history, err := repo.ReadHistory(created.ID, ctx)
+		if err != nil || len(history) != 1 || history[0].Action != models.ThresholdCreated {
+			t.Fatalf("expected the creation to be recorded, got %+v, %v", history, err)
+		}
+		history, err = repo.ReadHistory(removed.ID, ctx)
+		if err != nil || len(history) != 2 || history[1].Action != models.ThresholdDeleted {
+			t.Fatalf("expected the deletion to be recorded, got %+v, %v", history, err)
+		}
+	})
+
+	t.Run("ConcurrentImports", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+
+		// * Every import raises the limit the previous one stored, or creates the threshold when there is none
+		raise := func(current []*models.Threshold) ([]*models.ThresholdChange, error) {
+			time.Sleep(10 * time.Millisecond)
+			for _, stored := range current {
+				if stored.SensorType == "pressure" {
+					raised := *stored
+					raised.MaxValue++
+					return []*models.ThresholdChange{{Action: models.ThresholdUpdated, Before: stored, After: &raised}}, nil
+				}
+			}
+			return []*models.ThresholdChange{{Action: models.ThresholdCreated, After: &models.Threshold{SensorType: "pressure", MaxValue: 1, UpdatedAt: "2024-01-01T10:00:00Z"}}}, nil
+		}
+		const imports = 8
+		errs := make(chan error, imports)
+		var wg sync.WaitGroup
+		for range imports {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- repo.Import(raise, ctx)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("expected concurrent imports to be serialized, got %v", err)
+			}
+		}
+
+		thresholds, err := repo.ReadMany(0, 10, ctx)
+		if err != nil || len(thresholds) != 1 || thresholds[0].MaxValue != imports || thresholds[0].Version != imports {
+			t.Fatalf("expected every import to build on the previous one, got %+v, %v", thresholds, err)
+		}
+	})
+
+	t.Run("UniqueScope", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		threshold := createThreshold(t, repo, ctx, "temperature")
+		other := createThreshold(t, repo, ctx, "humidity")
+
+		// * Sensor types are compared without case, a device scope is a different threshold
+		if err := repo.Create(&models.Threshold{SensorType: "Temperature", MaxValue: 1}, ctx); !errors.Is(err, models.ErrDuplicateThreshold) {
+			t.Fatalf("expected ErrDuplicateThreshold, got %v", err)
+		}
+		scoped := &models.Threshold{SensorType: "temperature", DeviceID: "dev1", MaxValue: 1}
+		if err := repo.Create(scoped, ctx); err != nil {
+			t.Fatal(err)
+		}
+		moved := *other
+		moved.SensorType = "TEMPERATURE"
+		if _, err := repo.Update(&moved, ctx); !errors.Is(err, models.ErrDuplicateThreshold) {
+			t.Fatalf("expected ErrDuplicateThreshold, got %v", err)
+		}
+		if _, err := repo.Modify(scoped.ID, func(t *models.Threshold) error { t.DeviceID = ""; return nil }, ctx); !errors.Is(err, models.ErrDuplicateThreshold) {
+			t.Fatalf("expected ErrDuplicateThreshold, got %v", err)
+		}
+
+		// * A deleted threshold frees its scope and cannot be restored while another one holds it
+		if _, err := repo.Delete(threshold, ctx); err != nil {
+			t.Fatal(err)
+		}
+		replacement := createThreshold(t, repo, ctx, "temperature")
+		if _, err := repo.Undelete(threshold.ID, ctx); !errors.Is(err, models.ErrDuplicateThreshold) {
+			t.Fatalf("expected ErrDuplicateThreshold, got %v", err)
+		}
+		if err := repo.Restore(threshold, ctx); !errors.Is(err, models.ErrDuplicateThreshold) {
+			t.Fatalf("expected ErrDuplicateThreshold, got %v", err)
+		}
+		if got, err := repo.ReadOne(replacement.ID, ctx); err != nil || got == nil || got.Version != 1 {
+			t.Fatalf("expected the replacement to be unchanged, got %+v, %v", got, err)
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		temperature := createThreshold(t, repo, ctx, "Temperature")
+		humidity := createThreshold(t, repo, ctx, "humidity")
+		scoped := &models.Threshold{SensorType: "temperature", DeviceID: "dev1", MinValue: 5, MaxValue: 25}
+		other := &models.Threshold{SensorType: "pressure", DeviceID: "dev2", MinValue: 900, MaxValue: 1100}
+		for _, threshold := range []*models.Threshold{scoped, other} {
+			if err := repo.Create(threshold, ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got, err := repo.ReadByType("temperature", "", ctx); err != nil || got == nil || got.ID != temperature.ID {
+			t.Fatalf("expected threshold %d, got %+v, %v", temperature.ID, got, err)
+		}
+		if got, err := repo.ReadByType("TEMPERATURE", "dev1", ctx); err != nil || got == nil || got.ID != scoped.ID {
+			t.Fatalf("expected threshold %d, got %+v, %v", scoped.ID, got, err)
+		}
+		if got, err := repo.ReadByType("pressure", "", ctx); err != nil || got != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", got, err)
+		}
+
+		// * The thresholds of every device come with those of the device, not with those of other devices
+		thresholds, err := repo.ReadForDevice("dev1", ctx)
+		if err != nil || len(thresholds) != 3 || thresholds[0].ID != humidity.ID || thresholds[1].ID != temperature.ID || thresholds[2].ID != scoped.ID {
+			t.Fatalf("expected humidity and both temperature thresholds, got %+v, %v", thresholds, err)
+		}
+		if _, err := repo.Delete(humidity, ctx); err != nil {
+			t.Fatal(err)
+		}
+		if thresholds, err := repo.ReadForDevice("dev3", ctx); err != nil || len(thresholds) != 1 || thresholds[0].ID != temperature.ID {
+			t.Fatalf("expected the temperature threshold of every device, got %+v, %v", thresholds, err)
+		}
+	})
+
+	t.Run("SoftDeleteAndRestore", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		threshold := createThreshold(t, repo, ctx, "temperature")
+
+		if aff, err := repo.Delete(threshold, ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row deleted, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Delete(threshold, ctx); err != nil || aff != 0 {
+			t.Fatalf("expected a second delete to affect 0 rows, got %d, %v", aff, err)
+		}
+		if got, err := repo.ReadOne(threshold.ID, ctx); err != nil || got != nil {
+			t.Fatalf("expected the deleted threshold to be hidden, got %+v, %v", got, err)
+		}
+		if got, err := repo.ReadOne(threshold.ID, models.WithDeleted(ctx)); err != nil || got == nil || got.DeletedAt == "" {
+			t.Fatalf("expected the deleted threshold, got %+v, %v", got, err)
+		}
+
+		if aff, err := repo.Undelete(threshold.ID, ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row restored, got %d, %v", aff, err)
+		}
+		history, err := repo.ReadHistory(threshold.ID, ctx)
+		if err != nil || len(history) != 3 || history[2].Action != models.ThresholdRestored {
+			t.Fatalf("expected the restore to be recorded, got %+v, %v", history, err)
+		}
+	})
+
+	t.Run("RestorePurged", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		threshold := createThreshold(t, repo, ctx, "temperature")
+		if _, err := repo.Delete(threshold, ctx); err != nil {
+			t.Fatal(err)
+		}
+		if aff, err := repo.Purge(time.Now().Add(time.Hour).UTC().Format(time.RFC3339), ctx); err != nil || aff != 1 {
+			t.Fatalf("expected 1 row purged, got %d, %v", aff, err)
+		}
+
+		// * A rollback re-creates a purged threshold under its old id
+		if err := repo.Restore(threshold, ctx); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.ReadOne(threshold.ID, ctx)
+		if err != nil || got == nil || *got != *threshold {
+			t.Fatalf("expected %+v, got %+v, %v", threshold, got, err)
+		}
+		history, err := repo.ReadHistory(threshold.ID, ctx)
+		if err != nil || len(history) != 3 || history[2].Action != models.ThresholdRolledBack {
+			t.Fatalf("expected the rollback to be recorded, got %+v, %v", history, err)
+		}
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		ctx, repo := openThreshold(t, newRepo)
+		acme, globex := tenant(ctx, "acme"), tenant(ctx, "globex")
+		theirs := createThreshold(t, repo, acme, "temperature")
+
+		// * Each tenant has its own threshold per sensor type and device
+		ours := createThreshold(t, repo, globex, "Temperature")
+
+		// * Thresholds of another tenant can't be read
+		for _, ctx := range []context.Context{globex, models.WithDeleted(globex), ctx} {
+			if got, err := repo.ReadOne(theirs.ID, ctx); err != nil || got != nil {
+				t.Fatalf("expected the threshold of another tenant to be hidden, got %+v, %v", got, err)
+			}
+		}
+		if page, err := repo.ReadMany(1, 10, globex); err != nil || len(page) != 1 || page[0].ID != ours.ID {
+			t.Fatalf("expected only the threshold of the tenant, got %+v, %v", page, err)
+		}
+		if got, err := repo.ReadByType("temperature", "", globex); err != nil || got == nil || got.ID != ours.ID {
+			t.Fatalf("expected threshold %d, got %+v, %v", ours.ID, got, err)
+		}
+		if got, err := repo.ReadByType("temperature", "", ctx); err != nil || got != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", got, err)
+		}
+		if thresholds, err := repo.ReadForDevice("dev1", globex); err != nil || len(thresholds) != 1 || thresholds[0].ID != ours.ID {
+			t.Fatalf("expected only the threshold of the tenant, got %+v, %v", thresholds, err)
+		}
+		if history, err := repo.ReadHistory(theirs.ID, globex); err != nil || len(history) != 0 {
+			t.Fatalf("expected no history, got %+v, %v", history, err)
+		}
+		if entry, err := repo.ReadVersion(theirs.ID, 1, globex); err != nil || entry != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", entry, err)
+		}
+
+		// * ... nor written
+		changed := *theirs
+		changed.MaxValue = 99
+		if aff, err := repo.Update(&changed, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows updated, got %d, %v", aff, err)
+		}
+		if got, err := repo.Modify(theirs.ID, func(t *models.Threshold) error { t.MaxValue = 99; return nil }, globex); err != nil || got != nil {
+			t.Fatalf("expected nil, nil; got %+v, %v", got, err)
+		}
+		if aff, err := repo.Delete(theirs, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows deleted, got %d, %v", aff, err)
+		}
+		if _, err := repo.Delete(theirs, acme); err != nil {
+			t.Fatal(err)
+		}
+		if aff, err := repo.Undelete(theirs.ID, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows restored, got %d, %v", aff, err)
+		}
+		err := repo.Import(func(current []*models.Threshold) ([]*models.ThresholdChange, error) {
+			if len(current) != 1 || current[0].ID != ours.ID {
+				t.Errorf("expected the import to plan with the thresholds of the tenant, got %+v", current)
+			}
+			return nil, nil
+		}, globex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := repo.ReadOne(theirs.ID, models.WithDeleted(acme))
+		if err != nil || got == nil || got.MaxValue != theirs.MaxValue || got.DeletedAt == "" || got.Version != 2 {
+			t.Fatalf("expected the threshold to be changed by its tenant only, got %+v, %v", got, err)
+		}
+		if history, err := repo.ReadHistory(theirs.ID, acme); err != nil || len(history) != 2 {
+			t.Fatalf("expected the history of the tenant, got %+v, %v", history, err)
+		}
+	})
+}
+
+func openData(t *testing.T, newRepo NewDataRepository) (context.Context, models.DataRepository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return ctx, newRepo(t, ctx)
+}
+
+func openThreshold(t *testing.T, newRepo NewThresholdRepository) (context.Context, models.ThresholdRepository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return ctx, newRepo(t, ctx)
+}
+
+// * tenant returns ctx with a principal of the tenant, contexts without one belong to auth.DefaultTenant *
+func tenant(ctx context.Context, name string) context.Context {
+	return auth.WithPrincipal(ctx, auth.Principal{Username: name + "-user", Tenant: name})
+}
+
+func instant(t *testing.T, s string) time.Time {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
+
+func createData(t *testing.T, repo models.DataRepository, ctx context.Context, deviceID string, dateTime string) *models.Data {
+	data := &models.Data{
+		DeviceID:         deviceID,
+		DeviceName:       "Device " + deviceID,
+		TemperatureValue: 21.5,
+		HumidityValue:    40,
+		Type:             "sensor",
+		DateTime:         models.NewTimestamp(instant(t, dateTime)),
+	}
+	if err := repo.Create(data, ctx); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func createThreshold(t *testing.T, repo models.ThresholdRepository, ctx context.Context, sensorType string) *models.Threshold {
+	threshold := &models.Threshold{
+		SensorType: sensorType,
+		MinValue:   10,
+		MaxValue:   30,
+		UpdatedAt:  "2024-01-01T10:00:00Z",
+	}
+	if err := repo.Create(threshold, ctx); err != nil {
+		t.Fatal(err)
+	}
+	return threshold
+}
@@ -0,0 +1,149 @@
+package contract
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"reflect"
+	"testing"
+)
+
+// * NewRuleRepository returns a repository on an empty database that lives until ctx is done *
+type NewRuleRepository func(t *testing.T, ctx context.Context) models.RuleRepository
+
+// * RunRuleRepository checks a rule repository against the shared contract *
+func RunRuleRepository(t *testing.T, newRepo NewRuleRepository) {
+	t.Run("CreateAndRead", func(t *testing.T) {
+		ctx, repo := openRule(t, newRepo)
+		rule := createRule(t, repo, ctx, "hot")
+
+		got, err := repo.ReadOne(rule.ID, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || !reflect.DeepEqual(got, rule) {
+			t.Fatalf("expected %+v, got %+v", rule, got)
+		}
+		if missing, err := repo.ReadOne(rule.ID+100, ctx); err != nil || missing != nil {
+			t.Fatalf("expected no rule, got %+v, %v", missing, err)
+		}
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		ctx, repo := openRule(t, newRepo)
+		if err := repo.SetTags("dev1", []string{"zone-b", "zone-a", "zone-a"}, ctx); err != nil {
+			t.Fatal(err)
+		}
+		tags, err := repo.ReadTags("dev1", ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tags, []string{"zone-a", "zone-b"}) {
+			t.Fatalf("expected sorted unique tags, got %v", tags)
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		ctx, repo := openRule(t, newRepo)
+		for _, event := range []*models.RuleEvent{
+			{RuleID: 1, RuleName: "hot", DeviceIDs: []string{"dev1"}, DateTime: "2024-01-01T10:00:00Z"},
+			{RuleID: 2, RuleName: "zone a", DeviceIDs: []string{"dev1", "dev2"}, DateTime: "2024-01-01T11:00:00Z"},
+			{RuleID: 1, RuleName: "hot", DeviceIDs: []string{"dev2"}, DateTime: "2024-01-01T12:00:00Z"},
+		} {
+			if err := repo.CreateEvent(event, ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		events, err := repo.ReadEvents(1, 2, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].DateTime != "2024-01-01T12:00:00Z" || !reflect.DeepEqual(events[1].DeviceIDs, []string{"dev1", "dev2"}) {
+			t.Fatalf("expected the newest events first, got %+v", events)
+		}
+		if events, err := repo.ReadEvents(2, 2, ctx); err != nil || len(events) != 1 || events[0].RuleName != "hot" {
+			t.Fatalf("expected the oldest event on the second page, got %+v, %v", events, err)
+		}
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		ctx, repo := openRule(t, newRepo)
+		acme, globex := tenant(ctx, "acme"), tenant(ctx, "globex")
+		theirs := createRule(t, repo, acme, "hot")
+		ours := createRule(t, repo, globex, "hot")
+		if theirs.Tenant != "acme" || ours.Tenant != "globex" {
+			t.Fatalf("expected the rules to belong to their tenants, got %q and %q", theirs.Tenant, ours.Tenant)
+		}
+
+		// * Rules of another tenant can be neither read nor written
+		if got, err := repo.ReadOne(theirs.ID, globex); err != nil || got != nil {
+			t.Fatalf("expected the rule of another tenant to be hidden, got %+v, %v", got, err)
+		}
+		if page, err := repo.ReadMany(1, 10, globex); err != nil || len(page) != 1 || page[0].ID != ours.ID {
+			t.Fatalf("expected only the rule of the tenant, got %+v, %v", page, err)
+		}
+		changed := *theirs
+		changed.Name = "changed"
+		if aff, err := repo.Update(&changed, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows updated, got %d, %v", aff, err)
+		}
+		if aff, err := repo.Delete(theirs, globex); err != nil || aff != 0 {
+			t.Fatalf("expected 0 rows deleted, got %d, %v", aff, err)
+		}
+		if got, err := repo.ReadOne(theirs.ID, acme); err != nil || got == nil || got.Name != "hot" {
+			t.Fatalf("expected the rule to be unchanged, got %+v, %v", got, err)
+		}
+
+		// * The engine evaluates the rules of every tenant
+		enabled, err := repo.ReadEnabled(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enabled) != 2 || enabled[0].Tenant != "acme" || enabled[1].Tenant != "globex" {
+			t.Fatalf("expected the rules of both tenants, got %+v", enabled)
+		}
+
+		// * Every tenant tags its own devices and reads its own events
+		if err := repo.SetTags("dev1", []string{"zone-a"}, acme); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.SetTags("dev1", []string{"zone-b"}, globex); err != nil {
+			t.Fatal(err)
+		}
+		for ctx, expected := range map[context.Context][]string{acme: {"zone-a"}, globex: {"zone-b"}, ctx: {}} {
+			if tags, err := repo.ReadTags("dev1", ctx); err != nil || !reflect.DeepEqual(tags, expected) {
+				t.Fatalf("expected tags %v, got %v, %v", expected, tags, err)
+			}
+		}
+		if err := repo.CreateEvent(&models.RuleEvent{RuleID: theirs.ID, RuleName: "hot", DeviceIDs: []string{"dev1"}, DateTime: "2024-01-01T10:00:00Z"}, acme); err != nil {
+			t.Fatal(err)
+		}
+		if events, err := repo.ReadEvents(1, 10, globex); err != nil || len(events) != 0 {
+			t.Fatalf("expected no events of another tenant, got %+v, %v", events, err)
+		}
+		if events, err := repo.ReadEvents(1, 10, acme); err != nil || len(events) != 1 {
+			t.Fatalf("expected the event of the tenant, got %+v, %v", events, err)
+		}
+	})
+}
+
+func openRule(t *testing.T, newRepo NewRuleRepository) (context.Context, models.RuleRepository) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return ctx, newRepo(t, ctx)
+}
+
+func createRule(t *testing.T, repo models.RuleRepository, ctx context.Context, name string) *models.Rule {
+	rule := &models.Rule{
+		Name:       name,
+		Condition:  models.Condition{Op: ">", Metric: "temperature", Value: 30},
+		For:        "15m",
+		MinDevices: 1,
+		Enabled:    true,
+		UpdatedAt:  "2024-01-01T10:00:00Z",
+	}
+	if err := repo.Create(rule, ctx); err != nil {
+		t.Fatal(err)
+	}
+	return rule
+}
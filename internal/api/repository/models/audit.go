@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// * AuditEntry records a single mutating API call *
+// * Entries form a hash chain: Hash covers the entry fields and the Hash of the previous entry *
+type AuditEntry struct {
+	ID         int    `json:"id"`
+	Time       string `json:"time"`
+	Principal  string `json:"principal"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	ResourceID string `json:"resource_id"`
+	Status     int    `json:"status"`
+	RequestID  string `json:"request_id"`
+	PrevHash   string `json:"prev_hash"`
+	Hash       string `json:"hash"`
+}
+
+// * AuditFilter narrows down audit queries, empty fields match everything *
+// * From and To are in StorageLayout like the time of the entries, they are compared as text *
+type AuditFilter struct {
+	Principal  string
+	Method     string
+	ResourceID string
+	From       string
+	To         string
+}
+
+// * Entries belong to the tenant of the caller, every tenant has its own hash chain and only reads its own entries *
+type AuditRepository interface {
+	Create(entry *AuditEntry, ctx context.Context) error
+	ReadMany(filter AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*AuditEntry, error)
+	Walk(fn func(entry *AuditEntry) error, ctx context.Context) error
+}
+
+// * ComputeHash returns the chained hash of the entry, ID and Hash itself are not covered *
+func (e *AuditEntry) ComputeHash() string {
+	fields := []string{e.PrevHash, e.Time, e.Principal, e.Method, e.Path, e.ResourceID, strconv.Itoa(e.Status), e.RequestID}
+	sum := sha256.Sum256([]byte(strings.Join(fields, "\x1f")))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,6 +1,9 @@
 package models
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Data struct {
 	ID          int     `json:"id"`
@@ -8,14 +11,45 @@ type Data struct {
 	DeviceName  string  `json:"device_name"`
 	TemperatureValue       float64 `json:"temp_value"`
 	HumidityValue       float64 `json:"humi_value"`
+	// The units of the values, stored values are always in the canonical units °C and %
+	TemperatureUnit string `json:"temp_unit,omitempty"`
+	HumidityUnit    string `json:"humi_unit,omitempty"`
 	Type        string  `json:"type"`
-	DateTime    string  `json:"date_time"`
+	// DateTime is when the device took the reading, by the device's clock
+	DateTime    Timestamp `json:"date_time"`
+	// ReceivedAt is when the server stored the reading, by the server's clock
+	ReceivedAt  Timestamp `json:"received_at"`
+	DeletedAt   string  `json:"deleted_at,omitempty"`
+	// Version counts the changes of the stored reading, it starts at 1 and is the ETag of the reading
+	Version     int     `json:"version,omitempty"`
 }
 
+// * DeviceDrift summarises how far the clock of a device is behind the server's *
+// * Drift is ReceivedAt minus DateTime in seconds, it includes the transmission delay and is negative for a clock that runs ahead *
+type DeviceDrift struct {
+	DeviceID string    `json:"device_id"`
+	Readings int       `json:"readings"`
+	Mean     float64   `json:"mean_seconds"`
+	Min      float64   `json:"min_seconds"`
+	Max      float64   `json:"max_seconds"`
+	Last     float64   `json:"last_seconds"`
+	LastAt   Timestamp `json:"last_received_at"`
+}
+
+// * Every method but Purge only sees the readings of the tenant of the caller, see auth.Tenant *
 type DataRepository interface {
 	Create(Data *Data, ctx context.Context) error
 	ReadOne(id int, ctx context.Context) (*Data, error)
 	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*Data, error)
+	// ReadRange returns the readings taken between from and to in chronological order, a zero bound is open ended
+	ReadRange(from time.Time, to time.Time, ctx context.Context) ([]*Data, error)
 	Update(data *Data, ctx context.Context) (int64, error)
+	// Modify passes the stored reading to change and writes the changed reading back in one transaction, it returns nil if there is no reading with the ID
+	// Nothing is written when change returns an error, the error is returned as is
+	Modify(id int, change func(data *Data) error, ctx context.Context) (*Data, error)
 	Delete(data *Data, ctx context.Context) (int64, error)
+
+	// Soft deletion: Delete only marks rows, Undelete clears the mark and Purge removes the rows of every tenant marked before the given time
+	Undelete(id int, ctx context.Context) (int64, error)
+	Purge(before string, ctx context.Context) (int64, error)
 }
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// * Condition is a node of a rule's boolean expression tree *
+// * Leaf nodes compare a metric of a reading against a value: {"metric": "humidity", "op": ">", "value": 80} *
+// * Branch nodes combine other conditions: {"op": "and", "conditions": [...]}, "or" and "not" work the same way *
+type Condition struct {
+	Op         string      `json:"op"`
+	Metric     string      `json:"metric,omitempty"`
+	Value      float64     `json:"value,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// * Rule is a composite condition with an optional duration qualifier and optional cross-device grouping *
+// * For: the condition must hold continuously for this long (e.g. "15m") before the rule fires *
+// * GroupTag / MinDevices: only devices tagged with GroupTag are evaluated and the rule fires when at least MinDevices of them match *
+type Rule struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Condition  Condition `json:"condition"`
+	For        string    `json:"for"`
+	GroupTag   string    `json:"group_tag"`
+	MinDevices int       `json:"min_devices"`
+	Enabled    bool      `json:"enabled"`
+	UpdatedAt  string    `json:"updated_at"`
+	// Tenant owns the rule, it is set by the repository from the caller and only evaluated against readings of that tenant
+	Tenant string `json:"-"`
+}
+
+// * RuleEvent is emitted when a rule transitions into the firing state *
+type RuleEvent struct {
+	RuleID    int      `json:"rule_id"`
+	RuleName  string   `json:"rule_name"`
+	DeviceIDs []string `json:"device_ids"`
+	DateTime  string   `json:"date_time"`
+}
+
+// * Every method but ReadEnabled only sees the rules, tags and events of the tenant of the caller, see auth.Tenant *
+type RuleRepository interface {
+	Create(rule *Rule, ctx context.Context) error
+	ReadOne(id int, ctx context.Context) (*Rule, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*Rule, error)
+	// ReadEnabled returns the enabled rules of every tenant for the engine
+	ReadEnabled(ctx context.Context) ([]*Rule, error)
+	Update(rule *Rule, ctx context.Context) (int64, error)
+	Delete(rule *Rule, ctx context.Context) (int64, error)
+
+	// Device tags used for cross-device grouping
+	ReadTags(deviceID string, ctx context.Context) ([]string, error)
+	SetTags(deviceID string, tags []string, ctx context.Context) error
+
+	// Events of rules that started firing, read newest first
+	CreateEvent(event *RuleEvent, ctx context.Context) error
+	ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*RuleEvent, error)
+}
+
+// * Metric returns the value of the named metric of a reading *
+func (d *Data) Metric(name string) (float64, bool) {
+	switch name {
+	case "temperature", "temp_value":
+		return d.TemperatureValue, true
+	case "humidity", "humi_value":
+		return d.HumidityValue, true
+	}
+	return 0, false
+}
+
+// * Validate checks that the condition tree is well formed *
+func (c *Condition) Validate() error {
+	switch c.Op {
+	case "and", "or":
+		if len(c.Conditions) == 0 {
+			return fmt.Errorf("'%s' needs at least one condition", c.Op)
+		}
+	case "not":
+		if len(c.Conditions) != 1 {
+			return errors.New("'not' needs exactly one condition")
+		}
+	case ">", ">=", "<", "<=", "==", "!=":
+		if _, ok := (&Data{}).Metric(c.Metric); !ok {
+			return fmt.Errorf("unknown metric '%s'", c.Metric)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown operator '%s'", c.Op)
+	}
+	for i := range c.Conditions {
+		if err := c.Conditions[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// * Eval evaluates the condition tree against a single reading *
+func (c *Condition) Eval(d *Data) bool {
+	switch c.Op {
+	case "and":
+		for i := range c.Conditions {
+			if !c.Conditions[i].Eval(d) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for i := range c.Conditions {
+			if c.Conditions[i].Eval(d) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		return len(c.Conditions) == 1 && !c.Conditions[0].Eval(d)
+	}
+
+	v, ok := d.Metric(c.Metric)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case ">":
+		return v > c.Value
+	case ">=":
+		return v >= c.Value
+	case "<":
+		return v < c.Value
+	case "<=":
+		return v <= c.Value
+	case "==":
+		return v == c.Value
+	case "!=":
+		return v != c.Value
+	}
+	return false
+}
+
+// * Duration returns the parsed duration qualifier, zero if none is set *
+func (r *Rule) Duration() (time.Duration, error) {
+	if r.For == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(r.For)
+}
@@ -0,0 +1,19 @@
+package models
+
+import "context"
+
+type includeDeletedKey struct{}
+
+// * WithDeleted returns a copy of ctx that makes repository reads return soft-deleted rows as well *
+func WithDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey{}, true)
+}
+
+// * IncludeDeleted reports whether soft-deleted rows were requested with WithDeleted *
+func IncludeDeleted(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	include, _ := ctx.Value(includeDeletedKey{}).(bool)
+	return include
+}
@@ -1,19 +1,112 @@
 package models
 
-import "context"
+import (
+    "context"
+    "errors"
+    "strings"
+)
 
 type Threshold struct {
     ID         int     `json:"id"`
     SensorType string  `json:"sensor_type"`
+    // DeviceID scopes the threshold to one device, "" applies it to every device
+    DeviceID   string  `json:"device_id,omitempty"`
     MinValue   float64 `json:"min_value"`
     MaxValue   float64 `json:"max_value"`
+    // Unit of MinValue and MaxValue, "" is the canonical unit of the sensor type
+    Unit       string  `json:"unit,omitempty"`
     UpdatedAt  string  `json:"updated_at"`
+    DeletedAt  string  `json:"deleted_at,omitempty"`
+    // Version counts the changes of the stored threshold, it starts at 1 and is the ETag of the threshold
+    Version    int     `json:"version,omitempty"`
 }
 
+// * DeviceThresholds is the compact form of the thresholds that apply to a device, devices fetch and cache it *
+// * Thresholds is keyed by lower-case sensor type, a threshold of the device replaces the one of every device *
+type DeviceThresholds struct {
+    DeviceID   string            `json:"device_id"`
+    Thresholds map[string]Limits `json:"thresholds"`
+}
+
+// * Limits are the bounds of a threshold, in its unit *
+type Limits struct {
+    Min  float64 `json:"min"`
+    Max  float64 `json:"max"`
+    Unit string  `json:"unit,omitempty"`
+}
+
+// * ErrDuplicateThreshold is returned by writes that would give a sensor type and device a second live threshold *
+var ErrDuplicateThreshold = errors.New("a threshold for the sensor type and device exists")
+
+// * SameScope reports whether two thresholds apply to the same sensor type and devices, sensor types are compared without case *
+func SameScope(a, b *Threshold) bool {
+    return strings.EqualFold(a.SensorType, b.SensorType) && a.DeviceID == b.DeviceID
+}
+
+// * ThresholdHistory is an append-only record of a single change to a threshold *
+// * Before is nil for creations and After is nil for deletions *
+type ThresholdHistory struct {
+    ID          int        `json:"id"`
+    ThresholdID int        `json:"threshold_id"`
+    Version     int        `json:"version"`
+    Action      string     `json:"action"`
+    ChangedBy   string     `json:"changed_by"`
+    ChangedAt   string     `json:"changed_at"`
+    Before      *Threshold `json:"before"`
+    After       *Threshold `json:"after"`
+}
+
+// * Actions recorded in the threshold history *
+const (
+    ThresholdCreated    = "create"
+    ThresholdUpdated    = "update"
+    ThresholdDeleted    = "delete"
+    ThresholdRolledBack = "rollback"
+    ThresholdRestored   = "restore"
+)
+
+// * ThresholdChange is one write of a bulk import, Before is nil for creations and After is nil for deletions *
+type ThresholdChange struct {
+    Action string     `json:"action"`
+    Before *Threshold `json:"before,omitempty"`
+    After  *Threshold `json:"after,omitempty"`
+}
+
+// * ThresholdImportReport tells what an import changed, or would have changed when it is a dry run *
+type ThresholdImportReport struct {
+    Mode      string             `json:"mode"`
+    DryRun    bool               `json:"dry_run"`
+    Created   int                `json:"created"`
+    Updated   int                `json:"updated"`
+    Deleted   int                `json:"deleted"`
+    Unchanged int                `json:"unchanged"`
+    Changes   []*ThresholdChange `json:"changes"`
+}
+
+// * Every method but Purge only sees the thresholds of the tenant of the caller, see auth.Tenant *
 type ThresholdRepository interface {
+    // Writes that would give a sensor type and device a second live threshold in the tenant return ErrDuplicateThreshold
     Create(threshold *Threshold, ctx context.Context) error
     ReadOne(id int, ctx context.Context) (*Threshold, error)
     ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*Threshold, error)
+    // ReadByType returns the live threshold of a sensor type and device, nil if there is none. Sensor types are compared without case
+    ReadByType(sensorType string, deviceID string, ctx context.Context) (*Threshold, error)
+    // ReadForDevice returns the live thresholds of every device and those of the device, ordered by sensor type and then device
+    ReadForDevice(deviceID string, ctx context.Context) ([]*Threshold, error)
     Update(threshold *Threshold, ctx context.Context) (int64, error)
+    // Modify passes the stored threshold to change and writes the changed threshold back in one transaction, it returns nil if there is no threshold with the ID
+    // Nothing is written when change returns an error, the error is returned as is
+    Modify(id int, change func(threshold *Threshold) error, ctx context.Context) (*Threshold, error)
     Delete(threshold *Threshold, ctx context.Context) (int64, error)
+    Undelete(id int, ctx context.Context) (int64, error)
+    // Purge removes the thresholds of every tenant that were soft-deleted before the given time
+    Purge(before string, ctx context.Context) (int64, error)
+    // Import passes every live threshold to plan and applies the changes it returns in one transaction, other writes wait until it is done
+    // Creations get their ID and every change is recorded in the history, nothing is written when plan or a change fails
+    Import(plan func(current []*Threshold) ([]*ThresholdChange, error), ctx context.Context) error
+
+    // History of changes, written by Create, Update, Delete, Undelete and Restore
+    ReadHistory(thresholdID int, ctx context.Context) ([]*ThresholdHistory, error)
+    ReadVersion(thresholdID int, version int, ctx context.Context) (*ThresholdHistory, error)
+    Restore(threshold *Threshold, ctx context.Context) error
 }
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// * StorageLayout is how text columns store a Timestamp: UTC with a fixed number of digits, so that it sorts like the instant *
+const StorageLayout = "2006-01-02T15:04:05.000000000Z"
+
+// * Readings sent as Unix time with a larger value are in milliseconds, 1e11 seconds is the year 5138 *
+const epochMillisFrom = 1e11
+
+// * Timestamp is an instant, always in UTC *
+// * It is read from RFC 3339 strings with any offset and up to nanoseconds, or from Unix time in seconds or milliseconds, *
+// * and written as RFC 3339 in UTC, the zero Timestamp is written as null *
+type Timestamp struct {
+	time.Time
+}
+
+// * NewTimestamp returns t in UTC *
+func NewTimestamp(t time.Time) Timestamp {
+	if t.IsZero() {
+		return Timestamp{}
+	}
+	return Timestamp{t.UTC()}
+}
+
+// * ParseTimestamp reads an RFC 3339 string or Unix time in seconds or milliseconds, "" is the zero Timestamp *
+func ParseTimestamp(s string) (Timestamp, error) {
+	if s == "" {
+		return Timestamp{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return NewTimestamp(t), nil
+	}
+	// Written by SQLite's CURRENT_TIMESTAMP and by earlier versions of the API
+	if t, err := time.Parse("2006-01-02 15:04:05", s); err == nil {
+		return NewTimestamp(t), nil
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return fromEpoch(n), nil
+	}
+	return Timestamp{}, fmt.Errorf("%q is neither an RFC 3339 timestamp nor Unix time", s)
+}
+
+func fromEpoch(n float64) Timestamp {
+	if math.Abs(n) >= epochMillisFrom {
+		n /= 1000
+	}
+	sec, frac := math.Modf(n)
+	return NewTimestamp(time.Unix(int64(sec), int64(math.Round(frac*1e9))))
+}
+
+func (t Timestamp) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*t = Timestamp{}
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fmt.Errorf("%q is not an RFC 3339 timestamp", s)
+		}
+		*t = NewTimestamp(parsed)
+		return nil
+	}
+	n, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("%s is not Unix time", b)
+	}
+	*t = fromEpoch(n)
+	return nil
+}
+
+// * Value stores the Timestamp as sortable text, NULL when zero *
+func (t Timestamp) Value() (driver.Value, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return t.UTC().Format(StorageLayout), nil
+}
+
+// * Scan reads a TIMESTAMP column, both as time.Time and as text in any layout ParseTimestamp accepts *
+func (t *Timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = Timestamp{}
+	case time.Time:
+		*t = NewTimestamp(v)
+	case string:
+		parsed, err := ParseTimestamp(v)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+	case []byte:
+		return t.Scan(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into a Timestamp", src)
+	}
+	return nil
+}
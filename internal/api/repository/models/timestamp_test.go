@@ -0,0 +1,58 @@
+package models_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	"testing"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"2024-01-01T12:00:00Z", "2024-01-01T12:00:00Z"},
+		{"2024-01-01T14:00:00.123456789+02:00", "2024-01-01T12:00:00.123456789Z"},
+		{"2024-01-01 12:00:00", "2024-01-01T12:00:00Z"},
+		{"1704110400", "2024-01-01T12:00:00Z"},
+		{"1704110400.5", "2024-01-01T12:00:00.5Z"},
+		{"1704110400250", "2024-01-01T12:00:00.25Z"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		ts, err := models.ParseTimestamp(tt.in)
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+			continue
+		}
+		if ts.String() != tt.out {
+			t.Errorf("%q: expected %s, got %s", tt.in, tt.out, ts)
+		}
+	}
+	if _, err := models.ParseTimestamp("yesterday"); err == nil {
+		t.Error("expected an error for a timestamp that cannot be parsed")
+	}
+}
+
+func TestTimestampJSON(t *testing.T) {
+	var data struct {
+		Offset models.Timestamp `json:"offset"`
+		Millis models.Timestamp `json:"millis"`
+		Null   models.Timestamp `json:"null"`
+	}
+	if err := json.Unmarshal([]byte(`{"offset": "2024-01-01T07:00:00-05:00", "millis": 1704110400000, "null": null}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"offset":"2024-01-01T12:00:00Z","millis":"2024-01-01T12:00:00Z","null":null}`; string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+
+	// Strings must be RFC 3339, the layout without a zone is ambiguous
+	var ts models.Timestamp
+	if err := json.Unmarshal([]byte(`"2024-01-01 12:00:00"`), &ts); err == nil {
+		t.Error("expected a timestamp without a zone to be rejected")
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "context"
+
+type expectedVersionKey struct{}
+
+// * WithExpectedVersion returns a copy of ctx that makes repository updates and deletes apply only to a row that still has the given version *
+// * A negative version matches no row *
+func WithExpectedVersion(ctx context.Context, version int) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// * ExpectedVersion returns the version set with WithExpectedVersion, 0 if any version will do *
+func ExpectedVersion(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	version, _ := ctx.Value(expectedVersionKey{}).(int)
+	return version
+}
@@ -0,0 +1,415 @@
+package server
+
+import (
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/handlers/rules"
+	"goapi/internal/api/health"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/openapi"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	auditservice "goapi/internal/api/service/audit"
+	ruleservice "goapi/internal/api/service/rules"
+	"net/http"
+)
+
+// * Specification returns the OpenAPI document of every route registered by NewServer *
+// * TestSpecificationCoversRoutes fails when a route is registered without being described here *
+func Specification() *openapi.Document {
+	routes := append(append(append(append(dataRoutes(), thresholdRoutes()...), ruleRoutes()...), auditRoutes()...), operationalRoutes()...)
+
+	// Every route behind the middleware chain can be rejected before it reaches its handler
+	for i := range routes {
+		if routes[i].Public || routes[i].Pattern == "/metrics" {
+			continue
+		}
+		routes[i].Responses[http.StatusUnauthorized] = failure("Missing or invalid credentials.")
+		if routes[i].Method != http.MethodOptions {
+			routes[i].Responses[http.StatusUnsupportedMediaType] = failure("The Content-Type header is not application/json.")
+		}
+	}
+
+	return openapi.Build(openapi.Info{
+		Title:   "goapi",
+		Version: "1.0.0",
+		Description: "Readings of intelligent devices, thresholds, rules and the audit log. " +
+			"Every request except the public ones needs HTTP Basic authentication and a Content-Type of application/json, " +
+			"devices may authenticate with a client certificate instead.",
+	}, routes, openapi.Format{Value: models.Timestamp{}, Schema: timestampSchema})
+}
+
+// * Thresholds fetched by devices may be cached, the max-age is server.threshold_max_age *
+const thresholdCacheDescription = "The answer has a Cache-Control max-age of server.threshold_max_age and an ETag to revalidate it with If-None-Match."
+
+// * Merge patches are validated by the service once they have been applied, not against the schema *
+const mergePatchDescription = "A JSON merge patch (RFC 7396) with a Content-Type of application/merge-patch+json or application/json: " +
+	"members replace the stored fields, null clears a field and fields that are not sent are kept. The merged result is validated as a whole."
+
+func ok(description string, value any) openapi.Response {
+	return openapi.Response{Description: description, Value: value}
+}
+
+func failure(description string) openapi.Response {
+	return openapi.Response{Description: description, Value: problem.Problem{}, ContentType: problem.ContentType}
+}
+
+// * timeParam is a query parameter parsed by models.ParseTimestamp *
+func timeParam(name string, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description + " RFC 3339 or Unix time in seconds or milliseconds.", Schema: &openapi.Schema{Type: "string"}}
+}
+
+var (
+	badRequest   = failure("The request is invalid.")
+	notFound     = failure("The resource does not exist.")
+	forbidden    = failure("include_deleted=true is only available to administrators.")
+	noContent    = openapi.Response{Description: "Done."}
+	messageReply = ok("Done.", openapi.Message{})
+
+	// Readings and thresholds are tagged with their version, lists with a hash of their content
+	notModified          = openapi.Response{Description: "The resource still has the ETag sent in If-None-Match."}
+	preconditionFailed   = failure("The resource has been changed since the ETag sent in If-Match was read.")
+	preconditionRequired = failure("The If-Match header is missing but required by server.require_if_match.")
+	duplicateThreshold   = failure("Another threshold applies to the sensor type and device already.")
+
+	pageParam            = openapi.Parameter{Name: "page", In: "query", Description: "Page to return.", Schema: &openapi.Schema{Type: "integer"}}
+	rowsPerPageParam     = openapi.Parameter{Name: "rowsPerPage", In: "query", Description: "Rows per page, defaults to the configured page size.", Schema: &openapi.Schema{Type: "integer"}}
+	includeDeletedParam  = openapi.Parameter{Name: "include_deleted", In: "query", Description: "Include soft-deleted rows, administrators only.", Schema: &openapi.Schema{Type: "boolean"}}
+	unitsParam           = openapi.Parameter{Name: "units", In: "query", Description: "Units to express readings in, e.g. F or K,%. Readings are stored in C and %.", Schema: &openapi.Schema{Type: "string"}}
+	unitsHeader          = openapi.Parameter{Name: data.UnitsHeader, In: "header", Description: "Same as the units query parameter, which takes precedence.", Schema: &openapi.Schema{Type: "string"}}
+	ifMatchHeader        = openapi.Parameter{Name: "If-Match", In: "header", Description: "The ETag of the version to change, the change is refused with 412 if the resource has another one. * matches any version.", Schema: &openapi.Schema{Type: "string"}}
+	formatParam          = openapi.Parameter{Name: "format", In: "query", Description: "json, the default, or csv.", Schema: &openapi.Schema{Type: "string", Enum: []string{"json", "csv"}}}
+	importModeParam      = openapi.Parameter{Name: "mode", In: "query", Description: "create-only, the default, fails if a threshold exists already; upsert updates existing thresholds; replace-all also deletes the thresholds that are not in the file.", Schema: &openapi.Schema{Type: "string", Enum: []string{"create-only", "upsert", "replace-all"}}}
+	dryRunParam          = openapi.Parameter{Name: "dry_run", In: "query", Description: "Report the changes without writing them.", Schema: &openapi.Schema{Type: "boolean"}}
+	thresholdDeviceParam = openapi.Parameter{Name: "device_id", In: "query", Description: "The device the threshold applies to, every device if it is not set.", Schema: &openapi.Schema{Type: "string"}}
+	appliesToDeviceParam = openapi.Parameter{Name: "device_id", In: "query", Description: "The device to find the threshold for, its own threshold is preferred to the one of every device.", Schema: &openapi.Schema{Type: "string"}}
+	ifNoneMatchHeader    = openapi.Parameter{Name: "If-None-Match", In: "header", Description: "ETags the client has already, answered with 304 if one is current.", Schema: &openapi.Schema{Type: "string"}}
+
+	// Timestamps are written in UTC, devices may send any offset or Unix time
+	timestampSchema = &openapi.Schema{
+		Description: "An RFC 3339 date-time, or Unix time in seconds or milliseconds. Written as RFC 3339 in UTC.",
+		OneOf:       []*openapi.Schema{{Type: "string", Format: "date-time"}, {Type: "number"}},
+		Nullable:    true,
+	}
+)
+
+func dataRoutes() []openapi.Route {
+	return []openapi.Route{
+		{Method: http.MethodOptions, Pattern: "/data", Tag: "data", Summary: "CORS pre-flight check", Public: true,
+			Responses: map[int]openapi.Response{http.StatusOK: {Description: "The allowed methods and headers."}}},
+		{Method: http.MethodPost, Pattern: "/data", Tag: "data", Summary: "Store a reading",
+			Description: "A device authenticated by its client certificate may only post readings of its own device_id.",
+			Query:       []openapi.Parameter{unitsParam, unitsHeader},
+			Body:        &openapi.Body{Value: models.Data{}, Required: []string{"device_id", "date_time"}},
+			Responses: map[int]openapi.Response{
+				http.StatusCreated:    ok("The stored reading.", models.Data{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusForbidden:  failure("The client certificate is not registered for this device_id."),
+			}},
+		{Method: http.MethodPut, Pattern: "/data", Tag: "data", Summary: "Replace a reading",
+			Query: []openapi.Parameter{unitsParam, unitsHeader, ifMatchHeader},
+			Body:  &openapi.Body{Value: models.Data{}, Required: []string{"id", "device_id", "date_time"}},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                   ok("The updated reading.", models.Data{}),
+				http.StatusBadRequest:           badRequest,
+				http.StatusNotFound:             notFound,
+				http.StatusPreconditionFailed:   preconditionFailed,
+				http.StatusPreconditionRequired: preconditionRequired,
+			}},
+		{Method: http.MethodGet, Pattern: "/data", Tag: "data", Summary: "List readings",
+			Query: []openapi.Parameter{pageParam, includeDeletedParam, unitsParam, unitsHeader, ifNoneMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          ok("A page of readings.", []models.Data{}),
+				http.StatusNotModified: notModified,
+				http.StatusBadRequest:  badRequest,
+				http.StatusForbidden:   forbidden,
+				http.StatusNotFound:    notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/data/{id}", Tag: "data", Summary: "Read a reading",
+			Query: []openapi.Parameter{includeDeletedParam, unitsParam, unitsHeader, ifNoneMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          ok("The reading.", models.Data{}),
+				http.StatusNotModified: notModified,
+				http.StatusBadRequest:  badRequest,
+				http.StatusForbidden:   forbidden,
+				http.StatusNotFound:    notFound,
+			}},
+		{Method: http.MethodPatch, Pattern: "/data/{id}", Tag: "data", Summary: "Change some fields of a reading",
+			Description: mergePatchDescription,
+			Query:       []openapi.Parameter{unitsParam, unitsHeader, ifMatchHeader},
+			Body:        &openapi.Body{Value: models.Data{}, ContentType: middleware.MergePatchContentType},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                   ok("The updated reading.", models.Data{}),
+				http.StatusBadRequest:           badRequest,
+				http.StatusNotFound:             notFound,
+				http.StatusPreconditionFailed:   preconditionFailed,
+				http.StatusPreconditionRequired: preconditionRequired,
+			}},
+		{Method: http.MethodDelete, Pattern: "/data/{id}", Tag: "data", Summary: "Soft-delete a reading",
+			Query: []openapi.Parameter{ifMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusNoContent:            noContent,
+				http.StatusBadRequest:           badRequest,
+				http.StatusNotFound:             notFound,
+				http.StatusPreconditionFailed:   preconditionFailed,
+				http.StatusPreconditionRequired: preconditionRequired,
+			}},
+		{Method: http.MethodPost, Pattern: "/data/{id}/restore", Tag: "data", Summary: "Restore a soft-deleted reading",
+			Responses: map[int]openapi.Response{
+				http.StatusNoContent:  noContent,
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/devices/drift", Tag: "data", Summary: "Report the clock drift of devices",
+			Description: "Drift is received_at minus date_time in seconds, over the readings taken between from and to. " +
+				"It includes the transmission delay and is negative for a clock that runs ahead.",
+			Query: []openapi.Parameter{timeParam("from", "Start of the range, defaults to a day ago."), timeParam("to", "End of the range, open ended by default.")},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("The drift of every device that sent readings in the range.", []models.DeviceDrift{}),
+				http.StatusBadRequest: badRequest,
+			}},
+	}
+}
+
+func thresholdRoutes() []openapi.Route {
+	threshold := &openapi.Body{Value: models.Threshold{}, Required: []string{"sensor_type", "min_value", "max_value"}}
+	return []openapi.Route{
+		{Method: http.MethodPost, Pattern: "/threshold", Tag: "threshold", Summary: "Create a threshold", Body: threshold,
+			Description: "A sensor type has at most one threshold per device_id, sensor types are compared without case.",
+			Responses: map[int]openapi.Response{
+				http.StatusCreated:    messageReply,
+				http.StatusBadRequest: badRequest,
+				http.StatusConflict:   duplicateThreshold,
+			}},
+		{Method: http.MethodGet, Pattern: "/threshold", Tag: "threshold", Summary: "List thresholds",
+			Query: []openapi.Parameter{pageParam, rowsPerPageParam, includeDeletedParam, ifNoneMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          ok("A page of thresholds.", []models.Threshold{}),
+				http.StatusNotModified: notModified,
+				http.StatusBadRequest:  badRequest,
+				http.StatusForbidden:   forbidden,
+				http.StatusNotFound:    notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/threshold/export", Tag: "threshold", Summary: "Export every threshold as JSON or CSV",
+			Description: "The file can be imported again with POST /threshold/import. The format query parameter takes precedence over an Accept header of text/csv.",
+			Query:       []openapi.Parameter{formatParam},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         {Description: "Every threshold that is not deleted.", Value: []models.Threshold{}, Alternatives: []string{middleware.CSVContentType}},
+				http.StatusBadRequest: badRequest,
+			}},
+		{Method: http.MethodPost, Pattern: "/threshold/import", Tag: "threshold", Summary: "Import thresholds from JSON or CSV",
+			Description: "Thresholds are matched to the stored ones by sensor_type and device_id and written in one transaction, nothing is written when one of them is invalid. " +
+				"A CSV file has a header line naming its columns: sensor_type, min_value and max_value are required, device_id and unit are optional and other columns, e.g. those of an export, are ignored.",
+			Query: []openapi.Parameter{importModeParam, dryRunParam},
+			Body:  &openapi.Body{Value: []models.Threshold{}, Alternatives: []string{middleware.CSVContentType}},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("What the import changed, or would change with dry_run=true.", models.ThresholdImportReport{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusConflict:   failure("With mode create-only, some of the thresholds exist already."),
+			}},
+		{Method: http.MethodGet, Pattern: "/threshold/by-type/{sensor_type}", Tag: "threshold", Summary: "Read the threshold of a sensor type",
+			Description: thresholdCacheDescription,
+			Query:       []openapi.Parameter{appliesToDeviceParam, ifNoneMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          ok("The threshold that applies.", models.Threshold{}),
+				http.StatusNotModified: notModified,
+				http.StatusForbidden:   failure("The client certificate is not registered for this device_id."),
+				http.StatusNotFound:    notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/devices/{device_id}/thresholds", Tag: "threshold", Summary: "Read the thresholds of a device in compact form",
+			Description: "Every threshold that applies to the device keyed by lower-case sensor type, the device's own threshold replaces the one of every device. " + thresholdCacheDescription,
+			Query:       []openapi.Parameter{ifNoneMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          ok("The thresholds of the device.", models.DeviceThresholds{}),
+				http.StatusNotModified: notModified,
+				http.StatusForbidden:   failure("The client certificate is not registered for this device_id."),
+			}},
+		{Method: http.MethodPut, Pattern: "/threshold/by-type/{sensor_type}", Tag: "threshold", Summary: "Create or replace the threshold of a sensor type",
+			Description: "Declarative alternative to POST /threshold and PUT /threshold/{id}: the threshold of the sensor type and device_id gets the limits of the body. " +
+				"The body may leave out sensor_type and device_id, if it sends them they must match the URL.",
+			Query: []openapi.Parameter{thresholdDeviceParam, ifMatchHeader},
+			Body:  &openapi.Body{Value: models.Threshold{}, Required: []string{"min_value", "max_value"}},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                   ok("The threshold existed, it has the limits of the body now.", models.Threshold{}),
+				http.StatusCreated:              ok("The threshold was created.", models.Threshold{}),
+				http.StatusBadRequest:           badRequest,
+				http.StatusPreconditionFailed:   preconditionFailed,
+				http.StatusPreconditionRequired: preconditionRequired,
+			}},
+		{Method: http.MethodGet, Pattern: "/threshold/{id}", Tag: "threshold", Summary: "Read a threshold",
+			Query: []openapi.Parameter{includeDeletedParam, ifNoneMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          ok("The threshold.", models.Threshold{}),
+				http.StatusNotModified: notModified,
+				http.StatusBadRequest:  badRequest,
+				http.StatusForbidden:   forbidden,
+				http.StatusNotFound:    notFound,
+			}},
+		{Method: http.MethodPut, Pattern: "/threshold/{id}", Tag: "threshold", Summary: "Replace a threshold", Body: threshold,
+			Query: []openapi.Parameter{ifMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                   messageReply,
+				http.StatusBadRequest:           badRequest,
+				http.StatusConflict:             duplicateThreshold,
+				http.StatusPreconditionFailed:   preconditionFailed,
+				http.StatusPreconditionRequired: preconditionRequired,
+			}},
+		{Method: http.MethodPatch, Pattern: "/threshold/{id}", Tag: "threshold", Summary: "Change some fields of a threshold",
+			Description: mergePatchDescription,
+			Query:       []openapi.Parameter{ifMatchHeader},
+			Body:        &openapi.Body{Value: models.Threshold{}, ContentType: middleware.MergePatchContentType},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                   ok("The updated threshold.", models.Threshold{}),
+				http.StatusBadRequest:           badRequest,
+				http.StatusConflict:             duplicateThreshold,
+				http.StatusNotFound:             notFound,
+				http.StatusPreconditionFailed:   preconditionFailed,
+				http.StatusPreconditionRequired: preconditionRequired,
+			}},
+		{Method: http.MethodDelete, Pattern: "/threshold/{id}", Tag: "threshold", Summary: "Soft-delete a threshold",
+			Query: []openapi.Parameter{ifMatchHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                   messageReply,
+				http.StatusBadRequest:           badRequest,
+				http.StatusPreconditionFailed:   preconditionFailed,
+				http.StatusPreconditionRequired: preconditionRequired,
+			}},
+		{Method: http.MethodGet, Pattern: "/threshold/{id}/history", Tag: "threshold", Summary: "List the changes of a threshold, oldest first",
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("Every recorded version.", []models.ThresholdHistory{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodPost, Pattern: "/threshold/{id}/restore", Tag: "threshold", Summary: "Restore a soft-deleted threshold",
+			Responses: map[int]openapi.Response{
+				http.StatusNoContent:  noContent,
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+				http.StatusConflict:   duplicateThreshold,
+			}},
+		{Method: http.MethodPost, Pattern: "/threshold/{id}/rollback", Tag: "threshold", Summary: "Roll a threshold back to a version",
+			Body: &openapi.Body{Value: data.RollbackRequest{}, Required: []string{"version"}},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("The threshold after the rollback.", models.Threshold{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+				http.StatusConflict:   duplicateThreshold,
+			}},
+	}
+}
+
+func ruleRoutes() []openapi.Route {
+	rule := &openapi.Body{Value: models.Rule{}, Required: []string{"name", "condition"}}
+	return []openapi.Route{
+		{Method: http.MethodPost, Pattern: "/rule", Tag: "rule", Summary: "Create a rule", Body: rule,
+			Responses: map[int]openapi.Response{
+				http.StatusCreated:    ok("The stored rule.", models.Rule{}),
+				http.StatusBadRequest: badRequest,
+			}},
+		{Method: http.MethodGet, Pattern: "/rule", Tag: "rule", Summary: "List rules",
+			Query: []openapi.Parameter{pageParam},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("A page of rules.", []models.Rule{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodPost, Pattern: "/rule/dry-run", Tag: "rule", Summary: "Replay historical readings through a rule",
+			Description: "Either rule_id selects a stored rule or rule carries an unsaved one. Nothing is stored. from and to are required and at most 31 days apart.",
+			Body:        &openapi.Body{Value: rules.DryRunRequest{}, Required: []string{"from", "to"}},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("The events the rule would have emitted.", ruleservice.DryRunResult{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/rule/events", Tag: "rule", Summary: "List the events of rules that started firing, newest first",
+			Query: []openapi.Parameter{pageParam},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("A page of rule events.", []models.RuleEvent{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/rule/{id}", Tag: "rule", Summary: "Read a rule",
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("The rule.", models.Rule{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodPut, Pattern: "/rule/{id}", Tag: "rule", Summary: "Replace a rule", Body: rule,
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("The updated rule.", models.Rule{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodDelete, Pattern: "/rule/{id}", Tag: "rule", Summary: "Delete a rule",
+			Responses: map[int]openapi.Response{
+				http.StatusNoContent:  noContent,
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/tags/{device_id}", Tag: "rule", Summary: "Read the tags of a device",
+			Responses: map[int]openapi.Response{
+				http.StatusOK: ok("The tags.", []string{}),
+			}},
+		{Method: http.MethodPut, Pattern: "/tags/{device_id}", Tag: "rule", Summary: "Replace the tags of a device",
+			Body: &openapi.Body{Value: []string{}},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("The tags.", []string{}),
+				http.StatusBadRequest: badRequest,
+			}},
+	}
+}
+
+func auditRoutes() []openapi.Route {
+	filter := func(name string, description string) openapi.Parameter {
+		return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+	}
+	return []openapi.Route{
+		{Method: http.MethodGet, Pattern: "/audit", Tag: "audit", Summary: "List audit entries, newest first",
+			Query: []openapi.Parameter{
+				pageParam,
+				filter("principal", "Only calls of this user or device."),
+				filter("method", "Only calls with this HTTP method."),
+				filter("resource_id", "Only calls on this resource."),
+				filter("from", "Only calls at or after this time, RFC 3339."),
+				filter("to", "Only calls before this time, RFC 3339."),
+			},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:         ok("A page of audit entries.", []models.AuditEntry{}),
+				http.StatusBadRequest: badRequest,
+				http.StatusNotFound:   notFound,
+			}},
+		{Method: http.MethodGet, Pattern: "/audit/verify", Tag: "audit", Summary: "Verify the hash chain of the audit log",
+			Responses: map[int]openapi.Response{
+				http.StatusOK:       ok("The chain is intact.", auditservice.Verification{}),
+				http.StatusConflict: ok("An entry was tampered with.", auditservice.Verification{}),
+			}},
+	}
+}
+
+// * Routes served outside the middleware chain *
+func operationalRoutes() []openapi.Route {
+	return []openapi.Route{
+		{Method: http.MethodGet, Pattern: "/metrics", Tag: "operations", Summary: "Prometheus metrics",
+			Description: "Needs Basic authentication but no Content-Type.",
+			Responses: map[int]openapi.Response{
+				http.StatusOK:           {Description: "The metrics in the Prometheus text format.", ContentType: "text/plain"},
+				http.StatusUnauthorized: failure("Missing or invalid credentials."),
+			}},
+		{Method: http.MethodGet, Pattern: "/healthz", Tag: "operations", Summary: "Liveness probe", Public: true,
+			Responses: map[int]openapi.Response{
+				http.StatusOK: ok("The process serves requests.", health.Report{}),
+			}},
+		{Method: http.MethodGet, Pattern: "/readyz", Tag: "operations", Summary: "Readiness probe", Public: true,
+			Responses: map[int]openapi.Response{
+				http.StatusOK:                 ok("Every check passed.", health.Report{}),
+				http.StatusServiceUnavailable: ok("A check failed or the server is shutting down.", health.Report{}),
+			}},
+		{Method: http.MethodGet, Pattern: "/openapi.json", Tag: "operations", Summary: "This document", Public: true,
+			Responses: map[int]openapi.Response{
+				http.StatusOK: {Description: "The OpenAPI document.", ContentType: "application/json"},
+			}},
+		{Method: http.MethodGet, Pattern: "/docs/", Tag: "operations", Summary: "Swagger UI", Public: true,
+			Responses: map[int]openapi.Response{
+				http.StatusOK: {Description: "The Swagger UI showing this document.", ContentType: "text/html"},
+			}},
+	}
+}
@@ -2,65 +2,203 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"goapi/internal/api/config"
+	"goapi/internal/api/handlers/audit"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/handlers/rules"
+	"goapi/internal/api/health"
+	"goapi/internal/api/metrics"
 	"goapi/internal/api/middleware"
+	"goapi/internal/api/openapi"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/service"
-	"log"
+	auditservice "goapi/internal/api/service/audit"
+	dataservice "goapi/internal/api/service/data"
+	ruleservice "goapi/internal/api/service/rules"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 type Server struct {
-	ctx        context.Context
 	HTTPServer *http.Server
-	logger     *log.Logger
+	logger     *slog.Logger
+	routes     []string
+	// * How long readiness fails before the listener is closed, and how long open requests may take then *
+	shutdownDelay   time.Duration
+	shutdownTimeout time.Duration
+
+	// * Health answers /healthz and /readyz, main adds the database checks and background workers *
+	Health *health.Checker
 }
 
-func NewServer(ctx context.Context, sf *service.ServiceFactory, logger *log.Logger) *Server {
-	mux := http.NewServeMux()
+// * services are shared by every route group, main passes the same ones to the background jobs *
+// * m is served on /metrics *
+func NewServer(ctx context.Context, services *service.Services, cfg *config.Config, m *metrics.Metrics, logger *slog.Logger) (*Server, error) {
+	mux := newRouter()
+
+	services.Data.SetValidation(cfg.Validation)
+	services.Rules.SetGroupWindow(cfg.Rules.GroupWindow.Std())
 
 	// Setup data-related handlers
-	err := setupDataHandlers(mux, sf, logger)
-	if err != nil {
-		logger.Fatalf("Error setting up data handlers: %v", err)
-	}
+	setupDataHandlers(mux, services.Data, logger)
 
 	// Setup threshold-related handlers
-	err = setupThresholdHandlers(mux, sf, logger)
-	if err != nil {
-		logger.Fatalf("Error setting up threshold handlers: %v", err)
-	}
+	setupThresholdHandlers(mux, services.Data, logger)
+
+	// Setup rule-related handlers
+	setupRuleHandlers(mux, services.Rules, logger)
 
+	// Setup audit log handlers, the same service records the mutating calls
+	as := services.Audit
+	setupAuditHandlers(mux, as, logger)
+
+	// * The last middleware is the outermost one, auditing runs before authentication and the checks of CommonMiddleware
+	// * so that rejected writes are recorded too
+	spec := Specification()
 	middlewares := []middleware.Middleware{
-		middleware.BasicAuthenticationMiddleware,
+		middleware.Validation(spec, cfg.Server.ValidateResponses, logger),
+		middleware.RequestSettingsMiddleware(config.Request{Timeout: cfg.Server.RequestTimeout.Std(), PageSize: cfg.Server.PageSize, RequireIfMatch: cfg.Server.RequireIfMatch, ThresholdMaxAge: cfg.Server.ThresholdMaxAge.Std()}),
+		middleware.BasicAuthentication(cfg.Auth, cfg.Tenants.Users),
+		middleware.ClientCertificateAuthentication(cfg.TLS.Devices, cfg.Tenants.Devices, mux),
 		middleware.CommonMiddleware,
+		middleware.AuditMiddleware(as, logger),
+		middleware.Metrics(m),
+		middleware.RequestLogging(logger),
+		middleware.Tracing(),
+		middleware.RequestID(mux),
+	}
+
+	// * Prometheus scrapes without a JSON Content-Type, /metrics only needs Basic authentication
+	root := newRouter()
+	root.Handle("/metrics", getOnly(middleware.BasicAuthentication(cfg.Auth, cfg.Tenants.Users)(m.Handler())))
+
+	// * Probes of load balancers and orchestrators need neither credentials nor a JSON Content-Type
+	checker := health.New()
+	root.Handle("/healthz", checker.LivenessHandler())
+	root.Handle("/readyz", checker.ReadinessHandler())
+
+	// * The specification and its Swagger UI are public, so that clients can be generated without credentials
+	root.Handle("/openapi.json", getOnly(spec.Handler(logger)))
+	root.Handle("/docs/", getOnly(openapi.UIHandler("/docs/", "/openapi.json")))
+	// The chain serves the routes recorded on mux, "/" itself is not a route
+	root.ServeMux.Handle("/", middleware.ChainMiddleware(mux, middlewares...))
+
+	httpServer := &http.Server{
+		Handler: root,
+		// Errors of the server itself, e.g. failed TLS handshakes, go to the same log
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	// * Serve HTTPS when a certificate is configured, replaced certificate files are picked up without a restart
+	if cfg.TLS.Enabled() {
+		certificates, err := NewCertificateReloader(cfg.TLS, logger)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		checker.Go("certificates", func() { certificates.Watch(ctx, certificateCheckInterval) })
+		httpServer.TLSConfig = certificates.TLSConfig()
 	}
 
 	return &Server{
-		ctx:    ctx,
-		logger: logger,
-		HTTPServer: &http.Server{
-			Handler: middleware.ChainMiddleware(mux, middlewares...),
-		},
+		logger:          logger,
+		HTTPServer:      httpServer,
+		Health:          checker,
+		routes:          append(root.patterns, mux.patterns...),
+		shutdownDelay:   cfg.Server.ShutdownDelay.Std(),
+		shutdownTimeout: cfg.Server.ShutdownTimeout.Std(),
+	}, nil
+}
+
+// * Routes returns every pattern registered on the server, used to check that the specification is complete *
+func (api *Server) Routes() []string {
+	return api.routes
+}
+
+// * router records the patterns registered on its ServeMux *
+type router struct {
+	*http.ServeMux
+	// * literal holds the routes whose literal segments overlap the wildcards of other routes, e.g. /threshold/by-type/{sensor_type}
+	// * and /threshold/{id}/history, which one ServeMux refuses to hold. It is consulted first
+	literal  *http.ServeMux
+	patterns []string
+}
+
+func newRouter() *router {
+	return &router{ServeMux: http.NewServeMux(), literal: http.NewServeMux()}
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.ServeMux.Handle(pattern, handler)
+}
+
+func (rt *router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// * HandleLiteralFunc registers a route that takes precedence over the wildcards of the other routes *
+func (rt *router) HandleLiteralFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.literal.HandleFunc(pattern, handler)
+}
+
+// * Handler returns the handler and pattern of the route a request is served by *
+func (rt *router) Handler(r *http.Request) (http.Handler, string) {
+	if h, pattern := rt.literal.Handler(r); pattern != "" {
+		return h, pattern
+	}
+	return rt.ServeMux.Handler(r)
+}
+
+// * ServeHTTP lets the ServeMux of the route serve the request, which sets the path values of its pattern *
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.literal.Handler(r); pattern != "" {
+		rt.literal.ServeHTTP(w, r)
+		return
 	}
+	rt.ServeMux.ServeHTTP(w, r)
 }
 
+// * getOnly answers anything but GET and HEAD with 405, for the routes outside the middleware chain *
+func getOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "HEAD" {
+			next.ServeHTTP(w, r)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+}
+
+// * How often the certificate files are checked for changes
+const certificateCheckInterval = 10 * time.Second
+
+// * Shutdown fails readiness, keeps serving for the shutdown delay and then waits for the open requests to finish *
+// * The background workers still run, the caller cancels their context once Shutdown returns *
 func (api *Server) Shutdown() error {
-	api.logger.Println("Gracefully shutting down server...")
-	return api.HTTPServer.Shutdown(api.ctx)
+	api.logger.Info("Gracefully shutting down server...", "delay", api.shutdownDelay)
+	// Load balancers stop sending traffic before the listener is closed
+	api.Health.Shutdown()
+	time.Sleep(api.shutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), api.shutdownTimeout)
+	defer cancel()
+	return api.HTTPServer.Shutdown(ctx)
 }
 
 func (api *Server) ListenAndServe(addr string) error {
 	api.HTTPServer.Addr = addr
+	if api.HTTPServer.TLSConfig != nil {
+		// The certificate comes from the TLS configuration, not from files given here
+		return api.HTTPServer.ListenAndServeTLS("", "")
+	}
 	return api.HTTPServer.ListenAndServe()
 }
 
 // * REST API handlers for Data *
-func setupDataHandlers(mux *http.ServeMux, sf *service.ServiceFactory, logger *log.Logger) error {
-	ds, err := sf.CreateDataService(service.SQLiteDataService)
-	if err != nil {
-		return err
-	}
-
+func setupDataHandlers(mux *router, ds *dataservice.RepositoryDataService, logger *slog.Logger) {
 	mux.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
 		// Handle the OPTIONS request to allow for CORS or pre-flight checks
 		if r.Method == "OPTIONS" {
@@ -72,53 +210,191 @@ func setupDataHandlers(mux *http.ServeMux, sf *service.ServiceFactory, logger *l
 		} else if r.Method == "GET" {
 			data.GetHandler(w, r, logger, ds)
 		} else {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			problem.MethodNotAllowed(w, r)
 		}
 	})
 
 	// Use a separate route for handling the ID-based actions
-	mux.HandleFunc("/data/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/data/{id}", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
 			data.GetByIDHandler(w, r, logger, ds)
+		} else if r.Method == "PATCH" {
+			data.PatchHandler(w, r, logger, ds)
 		} else if r.Method == "DELETE" {
 			data.DeleteHandler(w, r, logger, ds)
 		} else {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			problem.MethodNotAllowed(w, r)
 		}
 	})
 
-	return nil
+	mux.HandleFunc("/data/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			data.RestoreHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/devices/drift", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			data.DriftHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
 }
 
 // * REST API handlers for Threshold *
-func setupThresholdHandlers(mux *http.ServeMux, sf *service.ServiceFactory, logger *log.Logger) error {
-	ds, err := sf.CreateDataService(service.SQLiteDataService)
-	if err != nil {
-		return err
-	}
-
+func setupThresholdHandlers(mux *router, ds *dataservice.RepositoryDataService, logger *slog.Logger) {
 	mux.HandleFunc("/threshold", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "POST" {
 			data.PostThresholdHandler(w, r, logger, ds)
 		} else if r.Method == "GET" {
 			data.GetThresholdHandler(w, r, logger, ds)
 		} else {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/threshold/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			data.ExportThresholdsHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/threshold/import", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			data.ImportThresholdsHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleLiteralFunc("/threshold/by-type/{sensor_type}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			data.ThresholdByTypeHandler(w, r, logger, ds)
+		} else if r.Method == "PUT" {
+			data.UpsertThresholdHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
 		}
 	})
 
+	mux.HandleFunc("/devices/{device_id}/thresholds", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			data.DeviceThresholdsHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
 
-	mux.HandleFunc("/threshold/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/threshold/{id}", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "DELETE" {
 			data.DeleteThresholdHandler(w, r, logger, ds)
 		} else if r.Method == "GET" {
-			data.GetThresholdHandler(w, r, logger, ds)
-		}else if r.Method == "PUT" {
-			data.UpdateThresholdHandler(w, r, logger, ds) // Only handle PUT with ID
-		}else {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			data.GetThresholdByIDHandler(w, r, logger, ds)
+		} else if r.Method == "PUT" {
+			data.UpdateThresholdHandler(w, r, logger, ds)
+		} else if r.Method == "PATCH" {
+			data.PatchThresholdHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/threshold/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			data.ThresholdHistoryHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
 		}
 	})
 
-	return nil
+	mux.HandleFunc("/threshold/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			data.RestoreThresholdHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/threshold/{id}/rollback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			data.RollbackThresholdHandler(w, r, logger, ds)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+}
+
+// * REST API handlers for Rules *
+func setupRuleHandlers(mux *router, rs *ruleservice.RepositoryRuleService, logger *slog.Logger) {
+	mux.HandleFunc("/rule", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			rules.PostHandler(w, r, logger, rs)
+		} else if r.Method == "GET" {
+			rules.GetHandler(w, r, logger, rs)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/rule/dry-run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			rules.DryRunHandler(w, r, logger, rs)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/rule/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			rules.GetEventsHandler(w, r, logger, rs)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/rule/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			rules.GetByIDHandler(w, r, logger, rs)
+		} else if r.Method == "PUT" {
+			rules.PutHandler(w, r, logger, rs)
+		} else if r.Method == "DELETE" {
+			rules.DeleteHandler(w, r, logger, rs)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/tags/{device_id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			rules.GetTagsHandler(w, r, logger, rs)
+		} else if r.Method == "PUT" {
+			rules.PutTagsHandler(w, r, logger, rs)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+}
+
+// * REST API handlers for the Audit log *
+func setupAuditHandlers(mux *router, as *auditservice.RepositoryAuditService, logger *slog.Logger) {
+	mux.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			audit.GetHandler(w, r, logger, as)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
+
+	mux.HandleFunc("/audit/verify", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			audit.VerifyHandler(w, r, logger, as)
+		} else {
+			problem.MethodNotAllowed(w, r)
+		}
+	})
 }
@@ -0,0 +1,419 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/config"
+	"goapi/internal/api/metrics"
+	"goapi/internal/api/openapi"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/server"
+	"goapi/internal/api/service"
+	dataservice "goapi/internal/api/service/data"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthProbesBypassAuthentication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	services, err := service.NewServiceFactory(nil, slog.Default(), nil, ctx).CreateServices(service.MemoryDataService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := server.NewServer(ctx, services, config.Default(), metrics.New(), slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) int {
+		rr := httptest.NewRecorder()
+		api.HTTPServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+
+	// Neither credentials nor a JSON Content-Type are sent
+	if status := get("/healthz"); status != http.StatusOK {
+		t.Errorf("expected /healthz to answer 200, got %d", status)
+	}
+	if status := get("/readyz"); status != http.StatusOK {
+		t.Errorf("expected /readyz to answer 200, got %d", status)
+	}
+	if status := get("/data"); status == http.StatusOK {
+		t.Errorf("expected /data to still require authentication")
+	}
+
+	api.Health.Shutdown()
+	if status := get("/readyz"); status != http.StatusServiceUnavailable {
+		t.Errorf("expected /readyz to answer 503 during shutdown, got %d", status)
+	}
+	if status := get("/healthz"); status != http.StatusOK {
+		t.Errorf("expected /healthz to answer 200 during shutdown, got %d", status)
+	}
+}
+
+// * Shutdown keeps accepting connections for the delay, then lets the open requests finish *
+func TestShutdownDrainsBeforeClosing(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.ShutdownDelay = config.Duration(200 * time.Millisecond)
+	api := newTestServer(t, cfg, &bytes.Buffer{})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- api.HTTPServer.Serve(listener) }()
+
+	start := time.Now()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- api.Shutdown() }()
+
+	// * During the delay readiness fails, but the listener still answers
+	time.Sleep(50 * time.Millisecond)
+	res, err := http.Get("http://" + listener.Addr().String() + "/readyz")
+	if err != nil {
+		t.Fatalf("expected connections to be accepted during the delay: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected /readyz to answer 503 during the delay, got %d", res.StatusCode)
+	}
+
+	if err := <-shutdown; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected the listener to be kept for the delay, shut down after %v", elapsed)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected the server to be closed, got %v", err)
+	}
+}
+
+// * newTestServer creates a server on the in-memory database, log lines are written to logs *
+func newTestServer(t *testing.T, cfg *config.Config, logs *bytes.Buffer) *server.Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+	services, err := service.NewServiceFactory(nil, logger, nil, ctx).CreateServices(service.MemoryDataService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, err := server.NewServer(ctx, services, cfg, metrics.New(), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+// * send makes an authenticated JSON request as the default administrator *
+func send(api *server.Server, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.SetBasicAuth("saurav", "amatya")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	api.HTTPServer.Handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSpecificationCoversRoutes(t *testing.T) {
+	api := newTestServer(t, config.Default(), &bytes.Buffer{})
+	spec := server.Specification()
+
+	registered := map[string]bool{}
+	for _, pattern := range api.Routes() {
+		registered[pattern] = true
+		if _, ok := spec.Paths[pattern]; !ok {
+			t.Errorf("route %s is registered but missing from the specification", pattern)
+		}
+	}
+	for _, pattern := range spec.Patterns() {
+		if !registered[pattern] {
+			t.Errorf("route %s is in the specification but not registered", pattern)
+		}
+	}
+
+	// Every method a route answers other than with 405 must be documented
+	path := strings.NewReplacer("{id}", "1", "{device_id}", "device1", "{sensor_type}", "temperature?device_id=device1")
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+	for _, pattern := range api.Routes() {
+		for _, method := range methods {
+			rr := send(api, method, path.Replace(pattern), "{}")
+			if rr.Code != http.StatusMethodNotAllowed && spec.Operation(pattern, method) == nil {
+				t.Errorf("%s %s answers %d but is missing from the specification", method, pattern, rr.Code)
+			}
+		}
+	}
+}
+
+func TestResponsesMatchSpecification(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.ValidateResponses = true
+	var logs bytes.Buffer
+	api := newTestServer(t, cfg, &logs)
+
+	requests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "/data", `{"device_id": "device1", "device_name": "sensor", "temp_value": 21.5, "humi_value": 40, "type": "temperature", "date_time": "2024-01-01T00:00:00Z"}`, 201},
+		{"GET", "/data", ``, 200},
+		{"GET", "/data/1", ``, 200},
+		{"PUT", "/data", `{"id": 1, "device_id": "device1", "temp_value": 22, "type": "temperature", "date_time": "2024-01-01T00:00:00Z"}`, 200},
+		{"POST", "/data", `{"device_id": "device2", "temp_value": 20, "type": "temperature", "date_time": 1704067200000}`, 201},
+		{"GET", "/devices/drift?from=2023-12-31T00:00:00%2B02:00", ``, 200},
+		{"POST", "/threshold", `{"sensor_type": "temperature", "min_value": 10, "max_value": 30}`, 201},
+		{"GET", "/threshold", ``, 200},
+		{"GET", "/threshold/1", ``, 200},
+		{"PUT", "/threshold/1", `{"sensor_type": "temperature", "min_value": 15, "max_value": 30}`, 200},
+		{"GET", "/threshold/1/history", ``, 200},
+		{"POST", "/threshold/1/rollback", `{"version": 1}`, 200},
+		{"DELETE", "/threshold/1", ``, 200},
+		{"POST", "/threshold/1/restore", ``, 204},
+		{"POST", "/threshold", `{"sensor_type": "Temperature", "min_value": 10, "max_value": 30}`, 409},
+		{"PUT", "/threshold/by-type/humidity?device_id=device1", `{"min_value": 30, "max_value": 60}`, 201},
+		{"PUT", "/threshold/by-type/humidity?device_id=device1", `{"min_value": 35, "max_value": 60}`, 200},
+		{"GET", "/threshold/by-type/humidity?device_id=device1", ``, 200},
+		{"GET", "/threshold/by-type/pressure", ``, 404},
+		{"GET", "/devices/device1/thresholds", ``, 200},
+		{"POST", "/rule", `{"name": "hot", "enabled": true, "condition": {"op": ">", "metric": "temperature", "value": 30}}`, 201},
+		{"GET", "/rule", ``, 200},
+		{"GET", "/rule/1", ``, 200},
+		{"PUT", "/rule/1", `{"name": "hot", "enabled": false, "condition": {"op": ">", "metric": "temperature", "value": 35}}`, 200},
+		{"POST", "/rule/dry-run", `{"rule_id": 1, "from": "2023-12-31T00:00:00Z", "to": "2024-01-02T00:00:00Z"}`, 200},
+		{"POST", "/rule/dry-run", `{"rule_id": 1, "from": "2023-01-01T00:00:00Z", "to": "2025-01-01T00:00:00Z"}`, 400},
+		{"GET", "/rule/events", ``, 404},
+		{"PUT", "/tags/device1", `["zone-a"]`, 200},
+		{"GET", "/tags/device1", ``, 200},
+		{"DELETE", "/rule/1", ``, 204},
+		{"DELETE", "/data/1", ``, 204},
+		{"POST", "/data/1/restore", ``, 204},
+		{"GET", "/data/99", ``, 404},
+		{"GET", "/audit", ``, 200},
+		{"GET", "/audit/verify", ``, 200},
+	}
+	for _, req := range requests {
+		if rr := send(api, req.method, req.path, req.body); rr.Code != req.status {
+			t.Errorf("%s %s: expected %d, got %d: %s", req.method, req.path, req.status, rr.Code, rr.Body.String())
+		}
+	}
+	if strings.Contains(logs.String(), "does not match the specification") {
+		t.Errorf("responses don't match the specification:\n%s", logs.String())
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	api := newTestServer(t, config.Default(), &bytes.Buffer{})
+
+	// The fields of an outdated client, value and description are not part of a reading
+	rr := send(api, "POST", "/data", `{"device_id": "device1", "value": 1.0, "type": "type1", "date_time": "2021-01-01T00:00:00Z", "description": "description1"}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != problem.ContentType {
+		t.Errorf("expected a problem, got %s", ct)
+	}
+	var body problem.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	expected := []dataservice.FieldError{
+		{Field: "body.description", Message: "is not a known property"},
+		{Field: "body.value", Message: "is not a known property"},
+	}
+	if body.Code != dataservice.CodeInvalidRequest || !reflect.DeepEqual(body.Errors, expected) {
+		t.Errorf("expected errors %v, got %+v", expected, body)
+	}
+
+	if rr := send(api, "POST", "/threshold", `{"sensor_type": "temperature", "min_value": "10"}`); rr.Code != http.StatusBadRequest ||
+		!strings.Contains(rr.Body.String(), `{"field":"body.max_value","message":"is required"}`) || !strings.Contains(rr.Body.String(), `{"field":"body.min_value","message":"must be a number"}`) {
+		t.Errorf("expected the threshold to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := send(api, "POST", "/data", `{"device_id": "device1", "date_time": "2021-01-01 00:00"}`); rr.Code != http.StatusBadRequest ||
+		!strings.Contains(rr.Body.String(), `{"field":"body.date_time","message":"must be one of string (date-time), number"}`) {
+		t.Errorf("expected the timestamp to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := send(api, "GET", "/data?page=first", ""); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"field":"query.page"`) {
+		t.Errorf("expected the page to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestSpecificationIsServed(t *testing.T) {
+	api := newTestServer(t, config.Default(), &bytes.Buffer{})
+
+	for _, path := range []string{"/openapi.json", "/docs/", "/docs/swagger-initializer.js"} {
+		rr := httptest.NewRecorder()
+		api.HTTPServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected %s to be served without credentials, got %d", path, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	api.HTTPServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var doc openapi.Document
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != openapi.Version || doc.Operation("/data/{id}", "GET") == nil {
+		t.Errorf("unexpected document %s", rr.Body.String()[:min(200, rr.Body.Len())])
+	}
+}
+
+// * Writes rejected by authentication or the Content-Type check are audited too *
+func TestRejectedWritesAreAudited(t *testing.T) {
+	api := newTestServer(t, config.Default(), &bytes.Buffer{})
+
+	req := httptest.NewRequest(http.MethodDelete, "/data/5", nil)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	api.HTTPServer.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/data", strings.NewReader(`{}`))
+	req.SetBasicAuth("saurav", "amatya")
+	req.Header.Set("Content-Type", "text/plain")
+	rr = httptest.NewRecorder()
+	api.HTTPServer.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", rr.Code)
+	}
+
+	rr = send(api, "GET", "/audit", "")
+	var entries []models.AuditEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	// * The Content-Type is checked before the credentials, so both callers are anonymous
+	if len(entries) != 2 || entries[0].Principal != "anonymous" || entries[0].Status != http.StatusUnsupportedMediaType ||
+		entries[1].Principal != "anonymous" || entries[1].Status != http.StatusUnauthorized || entries[1].ResourceID != "5" {
+		t.Errorf("expected both rejected writes to be audited, got %+v", entries)
+	}
+}
+
+// * A caller of one tenant can neither see the rules, tags and audit entries of another tenant nor break its audit chain *
+func TestCrossTenantRulesAndAudit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.Users["wile"] = "coyote"
+	cfg.Auth.Admins = append(cfg.Auth.Admins, "wile")
+	cfg.Tenants.Users = map[string]string{"saurav": "acme", "wile": "globex"}
+	api := newTestServer(t, cfg, &bytes.Buffer{})
+
+	as := func(username string, password string, method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth(username, password)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		api.HTTPServer.Handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := as("saurav", "amatya", "POST", "/rule", `{"name": "hot", "enabled": true, "condition": {"metric": "temperature", "op": ">", "value": 30}}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected acme to create a rule, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := as("saurav", "amatya", "PUT", "/tags/device1", `["zone-a"]`); rr.Code != http.StatusOK {
+		t.Fatalf("expected acme to tag device1, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := as("wile", "coyote", "GET", "/rule/1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected globex not to read the rule of acme, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := as("wile", "coyote", "DELETE", "/rule/1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected globex not to delete the rule of acme, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var tags []string
+	rr := as("wile", "coyote", "GET", "/tags/device1", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &tags); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || len(tags) != 0 {
+		t.Errorf("expected globex to see no tags on device1, got %d %v", rr.Code, tags)
+	}
+
+	// * globex has only audited its own rejected delete, acme has the create, the tags and nothing of globex
+	var entries []models.AuditEntry
+	rr = as("wile", "coyote", "GET", "/audit", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Principal != "wile" || entries[0].Method != http.MethodDelete {
+		t.Errorf("expected globex to see its own delete only, got %+v", entries)
+	}
+	entries = nil
+	rr = as("saurav", "amatya", "GET", "/audit", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected acme to see its two writes only, got %+v", entries)
+	}
+	for _, username := range []string{"saurav", "wile"} {
+		password := cfg.Auth.Users[username]
+		if rr := as(username, password, "GET", "/audit/verify", ""); rr.Code != http.StatusOK {
+			t.Errorf("expected the audit chain of %s to verify, got %d: %s", username, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := as("saurav", "amatya", "GET", "/rule/1", ""); rr.Code != http.StatusOK {
+		t.Errorf("expected the rule of acme to be untouched, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// * A device certificate reaches the device operations only, every other route of the chain answers 403 *
+func TestDeviceCertificateRoutes(t *testing.T) {
+	cfg := config.Default()
+	cfg.TLS.Devices = map[string]string{"sensor-1": "device1"}
+	api := newTestServer(t, cfg, &bytes.Buffer{})
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "sensor-1"}}
+	allowed := map[string]bool{
+		"POST /data":                           true,
+		"GET /devices/{device_id}/thresholds":  true,
+		"GET /threshold/by-type/{sensor_type}": true,
+	}
+	// * These routes are served outside the chain and don't know device certificates
+	public := map[string]bool{"/metrics": true, "/healthz": true, "/readyz": true, "/openapi.json": true, "/docs/": true}
+	path := strings.NewReplacer("{id}", "1", "{device_id}", "device1", "{sensor_type}", "temperature?device_id=device1")
+
+	for _, pattern := range api.Routes() {
+		if public[pattern] {
+			continue
+		}
+		for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+			body := `{}`
+			if method+" "+pattern == "POST /data" {
+				body = `{"device_id": "device1", "temp_value": 21.5, "humi_value": 40, "type": "sensor", "date_time": "2024-01-01T10:00:00Z"}`
+			}
+			req := httptest.NewRequest(method, path.Replace(pattern), strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+			rr := httptest.NewRecorder()
+			api.HTTPServer.Handler.ServeHTTP(rr, req)
+
+			if allowed[method+" "+pattern] {
+				if rr.Code == http.StatusForbidden || rr.Code == http.StatusUnauthorized {
+					t.Errorf("%s %s: expected the device to be served, got %d: %s", method, pattern, rr.Code, rr.Body.String())
+				}
+			} else if rr.Code != http.StatusForbidden {
+				t.Errorf("%s %s: expected %d for a device, got %d", method, pattern, http.StatusForbidden, rr.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"goapi/internal/api/config"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// * CertificateReloader serves the configured certificate and client CAs and picks up changes to their files *
+type CertificateReloader struct {
+	settings config.TLS
+	logger   *slog.Logger
+
+	mu        sync.RWMutex
+	files     [][]byte
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// * NewCertificateReloader loads the files once, a missing or invalid file is an error at startup only *
+func NewCertificateReloader(settings config.TLS, logger *slog.Logger) (*CertificateReloader, error) {
+	c := &CertificateReloader{settings: settings, logger: logger}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// * Reload reads the files again and reports whether they changed, the previous certificate is kept on error *
+func (c *CertificateReloader) Reload() (bool, error) {
+	paths := []string{c.settings.CertFile, c.settings.KeyFile}
+	if c.settings.ClientCAFile != "" {
+		paths = append(paths, c.settings.ClientCAFile)
+	}
+
+	files := make([][]byte, len(paths))
+	for i, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		files[i] = content
+	}
+
+	c.mu.RLock()
+	unchanged := c.files != nil && equalFiles(c.files, files)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(files[0], files[1])
+	if err != nil {
+		return false, err
+	}
+	var clientCAs *x509.CertPool
+	if len(files) > 2 {
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(files[2]) {
+			return false, errors.New(c.settings.ClientCAFile + ": no PEM certificates found")
+		}
+	}
+
+	c.mu.Lock()
+	c.files, c.cert, c.clientCAs = files, &cert, clientCAs
+	c.mu.Unlock()
+	return true, nil
+}
+
+// * Watch checks the files every interval until ctx is done *
+func (c *CertificateReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Certificates are often replaced file by file, a half written pair is retried on the next tick
+			changed, err := c.Reload()
+			if err != nil {
+				c.logger.Error("Error reloading TLS certificate, keeping the current one", "error", err)
+			} else if changed {
+				c.logger.Info("Reloaded TLS certificate")
+			}
+		}
+	}
+}
+
+// * TLSConfig returns a configuration reading the current certificate and client CAs on every handshake *
+// * The configuration of a handshake replaces the base one, so it offers the same HTTP/2 and HTTP/1.1 protocols *
+func (c *CertificateReloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	config := base.Clone()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		handshake := base.Clone()
+		handshake.Certificates = []tls.Certificate{*c.cert}
+		handshake.ClientCAs = c.clientCAs
+		handshake.ClientAuth = clientAuthType(c.settings.ClientAuth)
+		return handshake, nil
+	}
+	return config
+}
+
+func clientAuthType(clientAuth string) tls.ClientAuthType {
+	switch clientAuth {
+	case "optional":
+		return tls.VerifyClientCertIfGiven
+	case "require":
+		return tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
+
+func equalFiles(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
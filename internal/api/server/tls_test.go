@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/config"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/server"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// * issue creates a certificate signed by parent, or a self-signed CA when parent is nil *
+func issue(t *testing.T, serial int64, commonName string, parent *testCertificate) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCertificate) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCertificate) keyPair(t *testing.T) tls.Certificate {
+	pair, err := tls.X509KeyPair(c.pem, c.keyPEM(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+func writeCertificate(t *testing.T, settings config.TLS, c *testCertificate) {
+	if err := os.WriteFile(settings.CertFile, c.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(settings.KeyFile, c.keyPEM(t), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// * Test: a device authenticates with its client certificate and the server certificate is reloaded when replaced
+func TestMutualTLSAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := issue(t, 1, "test CA", nil)
+	settings := config.TLS{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   "optional",
+		Devices:      map[string]string{"sensor-1": "device1"},
+	}
+	writeCertificate(t, settings, issue(t, 2, "localhost", ca))
+	if err := os.WriteFile(settings.ClientCAFile, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloader, err := server.NewCertificateReloader(settings, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/devices/{device_id}/thresholds", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.Username(r.Context())))
+	})
+	handler := middleware.ChainMiddleware(mux, middleware.BasicAuthentication(config.Default().Auth, nil), middleware.ClientCertificateAuthentication(settings.Devices, nil, mux))
+	ts := httptest.NewUnstartedServer(handler)
+	ts.EnableHTTP2 = true
+	ts.TLS = reloader.TLSConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := func(certificates ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}, ForceAttemptHTTP2: true}}
+	}
+	get := func(c *http.Client) (int, string, *x509.Certificate) {
+		res, err := c.Get(ts.URL + "/devices/device1/thresholds")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		// * The protocols of the base configuration are negotiated on every handshake
+		if res.ProtoMajor != 2 {
+			t.Errorf("Expected HTTP/2, got %s", res.Proto)
+		}
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body), res.TLS.PeerCertificates[0]
+	}
+
+	// The registered device needs no Basic credentials
+	status, body, serverCert := get(client(issue(t, 3, "sensor-1", ca).keyPair(t)))
+	if status != http.StatusOK || body != "device:device1" {
+		t.Errorf("Expected the device principal, got %d %s", status, body)
+	}
+	if serverCert.SerialNumber.Int64() != 2 {
+		t.Errorf("Expected server certificate 2, got %v", serverCert.SerialNumber)
+	}
+
+	// Without a client certificate Basic authentication applies
+	if status, _, _ := get(client()); status != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnauthorized, status)
+	}
+
+	// A certificate from another CA is rejected during the handshake
+	untrusted := issue(t, 4, "sensor-1", issue(t, 5, "other CA", nil)).keyPair(t)
+	forced := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) { return &untrusted, nil }}}}
+	if _, err := forced.Get(ts.URL); err == nil {
+		t.Error("Expected the handshake to fail for an untrusted client certificate")
+	}
+
+	// The replaced server certificate is served without a restart
+	writeCertificate(t, settings, issue(t, 6, "localhost", ca))
+	deadline := time.Now().Add(5 * time.Second)
+	for serverCert.SerialNumber.Int64() != 6 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		_, _, serverCert = get(client())
+	}
+	if serverCert.SerialNumber.Int64() != 6 {
+		t.Errorf("Expected the reloaded server certificate 6, got %v", serverCert.SerialNumber)
+	}
+}
@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+)
+
+type AuditService interface {
+	Record(entry *models.AuditEntry, ctx context.Context) error
+	ReadMany(filter models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error)
+	Verify(ctx context.Context) (*Verification, error)
+}
+
+// * Verification is the result of checking the hash chain of the audit log *
+// * FirstInvalidID is the first entry whose hash or link to the previous entry does not match *
+type Verification struct {
+	Valid          bool `json:"valid"`
+	Entries        int  `json:"entries"`
+	FirstInvalidID int  `json:"first_invalid_id,omitempty"`
+}
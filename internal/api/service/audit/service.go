@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// * Implementation of AuditService on the repository interfaces, it works with every backend *
+type RepositoryAuditService struct {
+	repo models.AuditRepository
+}
+
+func NewRepositoryAuditService(repo models.AuditRepository) *RepositoryAuditService {
+	return &RepositoryAuditService{
+		repo: repo,
+	}
+}
+
+func (as *RepositoryAuditService) Record(entry *models.AuditEntry, ctx context.Context) error {
+	// Fixed width, so that the from and to filters can compare the text
+	entry.Time = time.Now().UTC().Format(models.StorageLayout)
+	return as.repo.Create(entry, ctx)
+}
+
+func (as *RepositoryAuditService) ReadMany(filter models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	return as.repo.ReadMany(filter, page, rowsPerPage, ctx)
+}
+
+var errChainBroken = errors.New("audit chain broken")
+
+// * Verify recomputes the hash chain from the first entry and reports the first entry that was tampered with *
+func (as *RepositoryAuditService) Verify(ctx context.Context) (*Verification, error) {
+	result := &Verification{Valid: true}
+	prevHash := ""
+	err := as.repo.Walk(func(entry *models.AuditEntry) error {
+		result.Entries++
+		if entry.PrevHash != prevHash || entry.ComputeHash() != entry.Hash {
+			result.Valid = false
+			result.FirstInvalidID = entry.ID
+			return errChainBroken
+		}
+		prevHash = entry.Hash
+		return nil
+	}, ctx)
+	if err != nil && err != errChainBroken {
+		return nil, err
+	}
+	return result, nil
+}
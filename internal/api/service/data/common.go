@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"goapi/internal/api/repository/models"
+	"time"
 )
 
 type DataService interface {
@@ -10,22 +11,38 @@ type DataService interface {
 	ReadOne(id int, ctx context.Context) (*models.Data, error)
 	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error)
 	Update(data *models.Data, ctx context.Context) (int64, error)
+	// Patch applies a JSON merge patch to a reading and returns the result, nil if there is no reading with the ID
+	Patch(id int, patch []byte, ctx context.Context) (*models.Data, error)
 	Delete(data *models.Data, ctx context.Context) (int64, error)
+	Restore(id int, ctx context.Context) (int64, error)
 	ValidateData(data *models.Data) error
+	// ClockDrift reports per device how far the reported time of the readings taken between from and to differs from the time they were received
+	ClockDrift(from time.Time, to time.Time, ctx context.Context) ([]*models.DeviceDrift, error)
 
 	// Threshold methods
 	CreateThreshold(threshold *models.Threshold, ctx context.Context) error
 	ReadThreshold(id int, ctx context.Context) (*models.Threshold, error)
 	UpdateThreshold(threshold *models.Threshold, ctx context.Context) (int64, error)
+	PatchThreshold(id int, patch []byte, ctx context.Context) (*models.Threshold, error)
 	DeleteThreshold(id int, ctx context.Context) (int64, error)
+	RestoreThreshold(id int, ctx context.Context) (int64, error)
 	GetAllThresholds(page, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error)
+	ThresholdHistory(id int, ctx context.Context) ([]*models.ThresholdHistory, error)
+	RollbackThreshold(id int, version int, ctx context.Context) (*models.Threshold, error)
+	// ThresholdByType returns the threshold of a sensor type that applies to a device, its own or else the one of every device, nil if there is none
+	ThresholdByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error)
+	// DeviceThresholds returns the thresholds that apply to a device in compact form
+	DeviceThresholds(deviceID string, ctx context.Context) (*models.DeviceThresholds, error)
+	// UpsertThreshold creates or replaces the threshold of a sensor type and device, it reports whether the threshold was created
+	UpsertThreshold(threshold *models.Threshold, ctx context.Context) (bool, error)
+	// ExportThresholds returns every threshold, ImportThresholds writes the thresholds of a file in one transaction
+	ExportThresholds(ctx context.Context) ([]*models.Threshold, error)
+	ImportThresholds(thresholds []*models.Threshold, mode string, dryRun bool, ctx context.Context) (*models.ThresholdImportReport, error)
 
 }
 
-type DataError struct {
-	Message string
+// * Observer is notified of every reading that has been successfully stored *
+type Observer interface {
+	Observe(data *models.Data, ctx context.Context)
 }
 
-func (de DataError) Error() string {
-	return de.Message
-}
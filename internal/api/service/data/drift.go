@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"sort"
+	"time"
+)
+
+// * ClockDrift compares the reported and the received time of the readings taken between from and to, per device *
+// * Readings stored before received_at was recorded are skipped, devices are sorted by ID *
+func (ds *RepositoryDataService) ClockDrift(from time.Time, to time.Time, ctx context.Context) ([]*models.DeviceDrift, error) {
+	ctx, span := tracer.Start(ctx, "DataService.ClockDrift")
+	defer span.End()
+
+	readings, err := ds.repo.ReadRange(from, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return clockDrift(readings), nil
+}
+
+func clockDrift(readings []*models.Data) []*models.DeviceDrift {
+	devices := map[string]*models.DeviceDrift{}
+	for _, reading := range readings {
+		if reading.ReceivedAt.IsZero() || reading.DateTime.IsZero() {
+			continue
+		}
+		drift := reading.ReceivedAt.Sub(reading.DateTime.Time).Seconds()
+
+		d, ok := devices[reading.DeviceID]
+		if !ok {
+			d = &models.DeviceDrift{DeviceID: reading.DeviceID, Min: drift, Max: drift}
+			devices[reading.DeviceID] = d
+		}
+		// Mean is kept as a running mean, so the sums of long ranges don't lose precision
+		d.Readings++
+		d.Mean += (drift - d.Mean) / float64(d.Readings)
+		d.Min = min(d.Min, drift)
+		d.Max = max(d.Max, drift)
+		if !reading.ReceivedAt.Before(d.LastAt.Time) {
+			d.Last = drift
+			d.LastAt = reading.ReceivedAt
+		}
+	}
+
+	drifts := make([]*models.DeviceDrift, 0, len(devices))
+	for _, d := range devices {
+		drifts = append(drifts, d)
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].DeviceID < drifts[j].DeviceID })
+	return drifts
+}
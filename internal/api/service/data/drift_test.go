@@ -0,0 +1,70 @@
+package data_test
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClockDrift(t *testing.T) {
+	repo := Memory.NewDataRepository()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	reading := func(device string, taken time.Duration, received time.Duration) {
+		data := &models.Data{DeviceID: device, DateTime: models.NewTimestamp(base.Add(taken)), ReceivedAt: models.NewTimestamp(base.Add(received))}
+		if err := repo.Create(data, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// d2 runs two seconds behind, d1 runs ahead and then catches up, d3 was stored before received_at was recorded
+	reading("d2", 0, 2*time.Second)
+	reading("d1", time.Minute, time.Minute-4*time.Second)
+	reading("d1", 2*time.Minute, 2*time.Minute+time.Second)
+	reading("d2", 3*time.Minute, 3*time.Minute+2*time.Second)
+	if err := repo.Create(&models.Data{DeviceID: "d3", DateTime: models.NewTimestamp(base.Add(time.Minute))}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	reading("d1", time.Hour, time.Hour)
+
+	ds := service.NewRepositoryDataService(repo, nil)
+	drift, err := ds.ClockDrift(base, base.Add(10*time.Minute), ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*models.DeviceDrift{
+		{DeviceID: "d1", Readings: 2, Mean: -1.5, Min: -4, Max: 1, Last: 1, LastAt: models.NewTimestamp(base.Add(2*time.Minute + time.Second))},
+		{DeviceID: "d2", Readings: 2, Mean: 2, Min: 2, Max: 2, Last: 2, LastAt: models.NewTimestamp(base.Add(3*time.Minute + 2*time.Second))},
+	}
+	if !reflect.DeepEqual(drift, expected) {
+		t.Errorf("expected %+v, got %+v", expected, drift)
+	}
+}
+
+// * The server decides when a reading was received, a device can't claim a time *
+func TestCreateSetsReceivedAt(t *testing.T) {
+	repo := Memory.NewDataRepository()
+	ds := service.NewRepositoryDataService(repo, nil)
+	claimed := models.NewTimestamp(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	data := &models.Data{DeviceID: "d1", DeviceName: "hall", Type: "sensor", TemperatureValue: 20, HumidityValue: 40,
+		DateTime: models.NewTimestamp(time.Now().Add(-time.Minute)), ReceivedAt: claimed}
+
+	before := time.Now()
+	if err := ds.Create(data, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data.ReceivedAt.Before(before) || data.ReceivedAt.After(time.Now()) {
+		t.Errorf("expected received_at to be set by the server, got %s", data.ReceivedAt)
+	}
+
+	data.ReceivedAt = claimed
+	if _, err := ds.Update(data, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data.ReceivedAt.Equal(claimed.Time) {
+		t.Errorf("expected update to keep the stored received_at, got %s", data.ReceivedAt)
+	}
+}
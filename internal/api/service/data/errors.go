@@ -0,0 +1,77 @@
+package data
+
+import "net/http"
+
+// * ErrorCode classifies a DataError for clients, each code maps to one HTTP status *
+type ErrorCode string
+
+const (
+	// CodeInvalidData is the default, the values of a resource break a rule of the service
+	CodeInvalidData ErrorCode = "invalid_data"
+	// CodeInvalidRequest means the request itself is malformed, e.g. a body that isn't JSON or an ID that isn't a number
+	CodeInvalidRequest       ErrorCode = "invalid_request"
+	CodeUnauthorized         ErrorCode = "unauthorized"
+	CodeForbidden            ErrorCode = "forbidden"
+	CodeNotFound             ErrorCode = "not_found"
+	CodeMethodNotAllowed     ErrorCode = "method_not_allowed"
+	CodeConflict             ErrorCode = "conflict"
+	CodePreconditionFailed   ErrorCode = "precondition_failed"
+	CodePreconditionRequired ErrorCode = "precondition_required"
+	CodeTooLarge             ErrorCode = "too_large"
+	CodeUnsupportedMediaType ErrorCode = "unsupported_media_type"
+	CodeInternal             ErrorCode = "internal"
+)
+
+var errorCodes = map[ErrorCode]struct {
+	status int
+	title  string
+}{
+	CodeInvalidData:          {http.StatusBadRequest, "Invalid data"},
+	CodeInvalidRequest:       {http.StatusBadRequest, "Invalid request"},
+	CodeUnauthorized:         {http.StatusUnauthorized, "Unauthorized"},
+	CodeForbidden:            {http.StatusForbidden, "Forbidden"},
+	CodeNotFound:             {http.StatusNotFound, "Not found"},
+	CodeMethodNotAllowed:     {http.StatusMethodNotAllowed, "Method not allowed"},
+	CodeConflict:             {http.StatusConflict, "Conflict"},
+	CodePreconditionFailed:   {http.StatusPreconditionFailed, "Precondition failed"},
+	CodePreconditionRequired: {http.StatusPreconditionRequired, "Precondition required"},
+	CodeTooLarge:             {http.StatusRequestEntityTooLarge, "Request body too large"},
+	CodeUnsupportedMediaType: {http.StatusUnsupportedMediaType, "Unsupported media type"},
+	CodeInternal:             {http.StatusInternalServerError, "Internal server error"},
+}
+
+// * FieldError names the field of a request that is invalid and why *
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// * DataError is an error the client can correct, the zero Code is CodeInvalidData *
+type DataError struct {
+	Message string
+	Code    ErrorCode
+	// Fields lists the invalid fields when the error comes from validation
+	Fields []FieldError
+}
+
+func (de DataError) Error() string {
+	return de.Message
+}
+
+// * ErrorCode returns the code of the error, CodeInvalidData if none was set *
+func (de DataError) ErrorCode() ErrorCode {
+	if _, ok := errorCodes[de.Code]; !ok {
+		return CodeInvalidData
+	}
+	return de.Code
+}
+
+// * Status returns the HTTP status the error is answered with *
+func (de DataError) Status() int {
+	return errorCodes[de.ErrorCode()].status
+}
+
+// * Title returns a short summary of the kind of error, the same for every error with the code *
+func (de DataError) Title() string {
+	return errorCodes[de.ErrorCode()].title
+}
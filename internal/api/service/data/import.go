@@ -0,0 +1,177 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"strings"
+	"time"
+)
+
+// * Modes of ImportThresholds, they differ in what happens to thresholds that already exist for a sensor type and device *
+const (
+	// ImportCreateOnly creates the thresholds of the file and rejects the import if one of them exists already
+	ImportCreateOnly = "create-only"
+	// ImportUpsert creates the thresholds that don't exist and updates the others
+	ImportUpsert = "upsert"
+	// ImportReplaceAll is ImportUpsert that also deletes the thresholds that are not in the file
+	ImportReplaceAll = "replace-all"
+)
+
+// * exportPageSize is the number of thresholds ExportThresholds reads at a time *
+const exportPageSize = 500
+
+// * errDryRun rolls back the transaction of a dry run once its changes are planned *
+var errDryRun = errors.New("dry run")
+
+// * ExportThresholds returns every threshold that is not deleted, in ID order *
+func (ds *RepositoryDataService) ExportThresholds(ctx context.Context) ([]*models.Threshold, error) {
+	ctx, span := tracer.Start(ctx, "DataService.ExportThresholds")
+	defer span.End()
+
+	var thresholds []*models.Threshold
+	for page := 1; ; page++ {
+		batch, err := ds.thresholdRepo.ReadMany(page, exportPageSize, ctx)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, batch...)
+		if len(batch) < exportPageSize {
+			return thresholds, nil
+		}
+	}
+}
+
+// * ImportThresholds writes the thresholds of a file in one transaction, matching them to the stored ones by sensor type and device *
+// * A dry run plans the same changes and reports them without writing anything *
+func (ds *RepositoryDataService) ImportThresholds(thresholds []*models.Threshold, mode string, dryRun bool, ctx context.Context) (*models.ThresholdImportReport, error) {
+	ctx, span := tracer.Start(ctx, "DataService.ImportThresholds")
+	defer span.End()
+
+	if mode != ImportCreateOnly && mode != ImportUpsert && mode != ImportReplaceAll {
+		return nil, DataError{Code: CodeInvalidRequest, Message: "Invalid mode, use " + ImportCreateOnly + ", " + ImportUpsert + " or " + ImportReplaceAll + "."}
+	}
+	if err := ds.validateImport(thresholds); err != nil {
+		return nil, err
+	}
+
+	report := &models.ThresholdImportReport{Mode: mode, DryRun: dryRun, Changes: []*models.ThresholdChange{}}
+	err := ds.thresholdRepo.Import(func(current []*models.Threshold) ([]*models.ThresholdChange, error) {
+		changes, unchanged, err := planImport(current, thresholds, mode)
+		if err != nil {
+			return nil, err
+		}
+		report.Changes, report.Unchanged = changes, unchanged
+		if dryRun {
+			return nil, errDryRun
+		}
+		return changes, nil
+	}, ctx)
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, thresholdConflict(err)
+	}
+
+	for _, change := range report.Changes {
+		switch change.Action {
+		case models.ThresholdCreated:
+			report.Created++
+		case models.ThresholdUpdated:
+			report.Updated++
+		case models.ThresholdDeleted:
+			report.Deleted++
+		}
+	}
+	return report, nil
+}
+
+// * validateImport checks every threshold of a file, the fields of the DataError are named after their position, e.g. thresholds[2].unit *
+func (ds *RepositoryDataService) validateImport(thresholds []*models.Threshold) error {
+	var fields []FieldError
+	first := map[string]int{}
+	for i, threshold := range thresholds {
+		name := fmt.Sprintf("thresholds[%d]", i)
+		if threshold == nil {
+			fields = append(fields, FieldError{Field: name, Message: "must be an object"})
+			continue
+		}
+		if err := ds.validateThreshold(threshold); err != nil {
+			var dataErr DataError
+			if !errors.As(err, &dataErr) {
+				return err
+			}
+			if len(dataErr.Fields) == 0 {
+				fields = append(fields, FieldError{Field: name, Message: dataErr.Message})
+			}
+			for _, field := range dataErr.Fields {
+				fields = append(fields, FieldError{Field: name + "." + field.Field, Message: field.Message})
+			}
+			continue
+		}
+		key := thresholdKey(threshold)
+		if j, ok := first[key]; ok {
+			fields = append(fields, FieldError{Field: name + ".sensor_type", Message: fmt.Sprintf("duplicates thresholds[%d]", j)})
+			continue
+		}
+		first[key] = i
+	}
+	if len(fields) > 0 {
+		return DataError{Message: "Invalid thresholds.", Fields: fields}
+	}
+	return nil
+}
+
+// * planImport compares the thresholds of a file with the stored ones and returns the changes the mode asks for *
+func planImport(current []*models.Threshold, thresholds []*models.Threshold, mode string) ([]*models.ThresholdChange, int, error) {
+	// The oldest threshold of a sensor type and device is the one that is updated
+	stored := map[string]*models.Threshold{}
+	for _, threshold := range current {
+		if _, ok := stored[thresholdKey(threshold)]; !ok {
+			stored[thresholdKey(threshold)] = threshold
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	changes := []*models.ThresholdChange{}
+	matched := map[int]bool{}
+	unchanged := 0
+	var conflicts []FieldError
+	for i, threshold := range thresholds {
+		after := &models.Threshold{SensorType: threshold.SensorType, DeviceID: threshold.DeviceID, MinValue: threshold.MinValue, MaxValue: threshold.MaxValue, Unit: threshold.Unit, UpdatedAt: now}
+		before, ok := stored[thresholdKey(threshold)]
+		switch {
+		case !ok:
+			changes = append(changes, &models.ThresholdChange{Action: models.ThresholdCreated, After: after})
+		case mode == ImportCreateOnly:
+			conflicts = append(conflicts, FieldError{Field: fmt.Sprintf("thresholds[%d].sensor_type", i), Message: fmt.Sprintf("already exists as threshold %d", before.ID)})
+		case sameLimits(before, after):
+			matched[before.ID] = true
+			unchanged++
+		default:
+			matched[before.ID] = true
+			after.ID = before.ID
+			changes = append(changes, &models.ThresholdChange{Action: models.ThresholdUpdated, Before: before, After: after})
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, 0, DataError{Code: CodeConflict, Message: "Thresholds already exist, import them with mode " + ImportUpsert + " or " + ImportReplaceAll + ".", Fields: conflicts}
+	}
+
+	if mode == ImportReplaceAll {
+		for _, threshold := range current {
+			if !matched[threshold.ID] {
+				changes = append(changes, &models.ThresholdChange{Action: models.ThresholdDeleted, Before: threshold})
+			}
+		}
+	}
+	return changes, unchanged, nil
+}
+
+// * thresholdKey identifies the threshold of a sensor type and device, sensor types are compared without case *
+func thresholdKey(threshold *models.Threshold) string {
+	return strings.ToLower(threshold.SensorType) + "\x00" + threshold.DeviceID
+}
+
+func sameLimits(a, b *models.Threshold) bool {
+	return a.SensorType == b.SensorType && a.MinValue == b.MinValue && a.MaxValue == b.MaxValue && a.Unit == b.Unit
+}
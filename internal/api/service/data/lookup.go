@@ -0,0 +1,37 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"strings"
+)
+
+// * ThresholdByType looks for the threshold of the device first and falls back to the one of every device *
+func (ds *RepositoryDataService) ThresholdByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error) {
+	ctx, span := tracer.Start(ctx, "DataService.ThresholdByType")
+	defer span.End()
+
+	if deviceID != "" {
+		threshold, err := ds.thresholdRepo.ReadByType(sensorType, deviceID, ctx)
+		if err != nil || threshold != nil {
+			return threshold, err
+		}
+	}
+	return ds.thresholdRepo.ReadByType(sensorType, "", ctx)
+}
+
+// * DeviceThresholds relies on ReadForDevice returning the threshold of every device before the one of the device *
+func (ds *RepositoryDataService) DeviceThresholds(deviceID string, ctx context.Context) (*models.DeviceThresholds, error) {
+	ctx, span := tracer.Start(ctx, "DataService.DeviceThresholds")
+	defer span.End()
+
+	thresholds, err := ds.thresholdRepo.ReadForDevice(deviceID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	compact := &models.DeviceThresholds{DeviceID: deviceID, Thresholds: map[string]models.Limits{}}
+	for _, threshold := range thresholds {
+		compact.Thresholds[strings.ToLower(threshold.SensorType)] = models.Limits{Min: threshold.MinValue, Max: threshold.MaxValue, Unit: threshold.Unit}
+	}
+	return compact, nil
+}
@@ -2,7 +2,9 @@ package data
 
 import (
 	"context"
+	"errors"
 	"goapi/internal/api/repository/models"
+	"time"
 )
 
 // * Mock implementation of DataService for testing purposes, always returns a successful response and Data object(s) *
@@ -17,7 +19,7 @@ func (m *MockDataServiceSuccessful) ReadMany(page int, rowsPerPage int, ctx cont
 			TemperatureValue: 0.0,
 			HumidityValue: 0.0,
 			Type:        "type1",
-			DateTime:    "2021-01-01 00:00:00",
+			DateTime:    models.NewTimestamp(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
 		},
 		{
 			ID:          2,
@@ -26,7 +28,7 @@ func (m *MockDataServiceSuccessful) ReadMany(page int, rowsPerPage int, ctx cont
 			TemperatureValue: 0.0,
 			HumidityValue: 0.0,
 			Type:        "type2",
-			DateTime:    "2021-01-01 00:00:00",
+			DateTime:    models.NewTimestamp(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
 		},
 	}, nil
 }
@@ -39,7 +41,7 @@ func (m *MockDataServiceSuccessful) ReadOne(id int, ctx context.Context) (*model
 		TemperatureValue: 0.0,
 		HumidityValue: 0.0,
 		Type:        "type1",
-		DateTime:    "2021-01-01 00:00:00",
+		DateTime:    models.NewTimestamp(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)),
 
 	}, nil
 }
@@ -48,10 +50,18 @@ func (m *MockDataServiceSuccessful) Update(data *models.Data, ctx context.Contex
 	return 1, nil
 }
 
+func (m *MockDataServiceSuccessful) Patch(id int, patch []byte, ctx context.Context) (*models.Data, error) {
+	return m.ReadOne(id, ctx)
+}
+
 func (m *MockDataServiceSuccessful) Delete(data *models.Data, ctx context.Context) (int64, error) {
 	return 1, nil
 }
 
+func (m *MockDataServiceSuccessful) Restore(id int, ctx context.Context) (int64, error) {
+	return 1, nil
+}
+
 func (m *MockDataServiceSuccessful) Create(data *models.Data, ctx context.Context) error {
 	return nil
 }
@@ -76,11 +86,21 @@ func (m *MockDataServiceSuccessful) UpdateThreshold(threshold *models.Threshold,
 	return 1, nil
 }
 
+func (m *MockDataServiceSuccessful) PatchThreshold(id int, patch []byte, ctx context.Context) (*models.Threshold, error) {
+	// Return the sample threshold as if the patch had been applied
+	return m.ReadThreshold(id, ctx)
+}
+
 func (m *MockDataServiceSuccessful) DeleteThreshold(id int, ctx context.Context) (int64, error) {
 	// Return 1 to signify a successful delete of the threshold
 	return 1, nil
 }
 
+func (m *MockDataServiceSuccessful) RestoreThreshold(id int, ctx context.Context) (int64, error) {
+	// Return 1 to signify a successful restore of the threshold
+	return 1, nil
+}
+
 func (m *MockDataServiceSuccessful) GetAllThresholds(page, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
 	// Return a list of sample thresholds
 	return []*models.Threshold{
@@ -89,10 +109,64 @@ func (m *MockDataServiceSuccessful) GetAllThresholds(page, rowsPerPage int, ctx
 	}, nil
 }
 
+func (m *MockDataServiceSuccessful) ThresholdHistory(id int, ctx context.Context) ([]*models.ThresholdHistory, error) {
+	// Return a creation followed by an update
+	return []*models.ThresholdHistory{
+		{ID: 1, ThresholdID: id, Version: 1, Action: models.ThresholdCreated, ChangedBy: "user1", ChangedAt: "2021-01-01T00:00:00Z",
+			After: &models.Threshold{ID: id, MinValue: 10.0, MaxValue: 50.0, SensorType: "Temperature"}},
+		{ID: 2, ThresholdID: id, Version: 2, Action: models.ThresholdUpdated, ChangedBy: "user1", ChangedAt: "2021-01-02T00:00:00Z",
+			Before: &models.Threshold{ID: id, MinValue: 10.0, MaxValue: 50.0, SensorType: "Temperature"},
+			After:  &models.Threshold{ID: id, MinValue: 15.0, MaxValue: 45.0, SensorType: "Temperature"}},
+	}, nil
+}
+
+func (m *MockDataServiceSuccessful) RollbackThreshold(id int, version int, ctx context.Context) (*models.Threshold, error) {
+	// Return the threshold as it was after the first version
+	return &models.Threshold{ID: id, MinValue: 10.0, MaxValue: 50.0, SensorType: "Temperature"}, nil
+}
+
+func (m *MockDataServiceSuccessful) ExportThresholds(ctx context.Context) ([]*models.Threshold, error) {
+	// Return a single sample threshold
+	threshold, err := m.ReadThreshold(1, ctx)
+	return []*models.Threshold{threshold}, err
+}
+
+func (m *MockDataServiceSuccessful) ImportThresholds(thresholds []*models.Threshold, mode string, dryRun bool, ctx context.Context) (*models.ThresholdImportReport, error) {
+	// Report every threshold as created
+	report := &models.ThresholdImportReport{Mode: mode, DryRun: dryRun, Created: len(thresholds), Changes: []*models.ThresholdChange{}}
+	for _, threshold := range thresholds {
+		report.Changes = append(report.Changes, &models.ThresholdChange{Action: models.ThresholdCreated, After: threshold})
+	}
+	return report, nil
+}
+
+func (m *MockDataServiceSuccessful) ThresholdByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error) {
+	// Return the sample threshold for any sensor type
+	return m.ReadThreshold(1, ctx)
+}
+
+func (m *MockDataServiceSuccessful) DeviceThresholds(deviceID string, ctx context.Context) (*models.DeviceThresholds, error) {
+	// Return the sample threshold in compact form
+	return &models.DeviceThresholds{DeviceID: deviceID, Thresholds: map[string]models.Limits{"temperature": {Min: 10.0, Max: 50.0}}}, nil
+}
+
+func (m *MockDataServiceSuccessful) UpsertThreshold(threshold *models.Threshold, ctx context.Context) (bool, error) {
+	// Simulate an update of the stored threshold
+	threshold.ID = 1
+	threshold.Version = 2
+	return false, nil
+}
+
 func (m *MockDataServiceSuccessful) ValidateData(data *models.Data) error {
 	return nil
 }
 
+func (m *MockDataServiceSuccessful) ClockDrift(from time.Time, to time.Time, ctx context.Context) ([]*models.DeviceDrift, error) {
+	return []*models.DeviceDrift{
+		{DeviceID: "device1", Readings: 2, Mean: 1.5, Min: 1, Max: 2, Last: 2, LastAt: models.NewTimestamp(time.Date(2021, 1, 1, 0, 0, 2, 0, time.UTC))},
+	}, nil
+}
+
 // * Mock implementation of DataService for testing purposes, always returns empty data *
 
 type MockDataServiceNotFound struct{}
@@ -113,10 +187,18 @@ func (m *MockDataServiceNotFound) Update(data *models.Data, ctx context.Context)
 	return 0, nil
 }
 
+func (m *MockDataServiceNotFound) Patch(id int, patch []byte, ctx context.Context) (*models.Data, error) {
+	return nil, nil
+}
+
 func (m *MockDataServiceNotFound) Delete(data *models.Data, ctx context.Context) (int64, error) {
 	return 0, nil
 }
 
+func (m *MockDataServiceNotFound) Restore(id int, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
 func (m *MockDataServiceNotFound) CreateThreshold(threshold *models.Threshold, ctx context.Context) error {
 	// No action, implicitly returning nil for error
 	return nil
@@ -132,29 +214,81 @@ func (m *MockDataServiceNotFound) UpdateThreshold(threshold *models.Threshold, c
 	return 0, nil
 }
 
+func (m *MockDataServiceNotFound) PatchThreshold(id int, patch []byte, ctx context.Context) (*models.Threshold, error) {
+	// Simulate a threshold that does not exist
+	return nil, nil
+}
+
 func (m *MockDataServiceNotFound) DeleteThreshold(id int, ctx context.Context) (int64, error) {
 	// Simulate no records affected for delete attempt
 	return 0, nil
 }
 
+func (m *MockDataServiceNotFound) RestoreThreshold(id int, ctx context.Context) (int64, error) {
+	// Simulate no deleted threshold with the ID
+	return 0, nil
+}
+
 func (m *MockDataServiceNotFound) GetAllThresholds(page, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
 	// Return empty list for no thresholds found
 	return []*models.Threshold{}, nil
 }
 
+func (m *MockDataServiceNotFound) ThresholdHistory(id int, ctx context.Context) ([]*models.ThresholdHistory, error) {
+	// Return empty history for an unknown threshold
+	return []*models.ThresholdHistory{}, nil
+}
+
+func (m *MockDataServiceNotFound) RollbackThreshold(id int, version int, ctx context.Context) (*models.Threshold, error) {
+	// Simulate a version that does not exist
+	return nil, nil
+}
+
+func (m *MockDataServiceNotFound) ExportThresholds(ctx context.Context) ([]*models.Threshold, error) {
+	// Simulate an empty table
+	return nil, nil
+}
+
+func (m *MockDataServiceNotFound) ImportThresholds(thresholds []*models.Threshold, mode string, dryRun bool, ctx context.Context) (*models.ThresholdImportReport, error) {
+	// Nothing is stored, so every threshold is created
+	return &models.ThresholdImportReport{Mode: mode, DryRun: dryRun, Created: len(thresholds), Changes: []*models.ThresholdChange{}}, nil
+}
+
+func (m *MockDataServiceNotFound) ThresholdByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error) {
+	// Simulate a sensor type without threshold
+	return nil, nil
+}
+
+func (m *MockDataServiceNotFound) DeviceThresholds(deviceID string, ctx context.Context) (*models.DeviceThresholds, error) {
+	// No threshold applies to the device
+	return &models.DeviceThresholds{DeviceID: deviceID, Thresholds: map[string]models.Limits{}}, nil
+}
+
+func (m *MockDataServiceNotFound) UpsertThreshold(threshold *models.Threshold, ctx context.Context) (bool, error) {
+	// Nothing is stored, so the threshold is created
+	threshold.ID = 1
+	threshold.Version = 1
+	return true, nil
+}
+
 func (m *MockDataServiceNotFound) ValidateData(data *models.Data) error {
 	return nil
 }
 
+func (m *MockDataServiceNotFound) ClockDrift(from time.Time, to time.Time, ctx context.Context) ([]*models.DeviceDrift, error) {
+	return []*models.DeviceDrift{}, nil
+}
+
 // * Mock implementation of DataService for testing purposes, always returns an error *
+// * Reads and deletes fail like the database would, writes are rejected with a DataError *
 type MockDataServiceError struct{}
 
 func (m *MockDataServiceError) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
-	return nil, DataError{Message: "Error reading data."}
+	return nil, errors.New("Error reading data.")
 }
 
 func (m *MockDataServiceError) ReadOne(id int, ctx context.Context) (*models.Data, error) {
-	return nil, DataError{Message: "Error reading data."}
+	return nil, errors.New("Error reading data.")
 }
 
 func (m *MockDataServiceError) Create(data *models.Data, ctx context.Context) error {
@@ -165,9 +299,17 @@ func (m *MockDataServiceError) Update(data *models.Data, ctx context.Context) (i
 	return 0, DataError{Message: "Error updating data."}
 }
 
+func (m *MockDataServiceError) Patch(id int, patch []byte, ctx context.Context) (*models.Data, error) {
+	return nil, DataError{Message: "Error updating data."}
+}
+
 func (m *MockDataServiceError) Delete(data *models.Data, ctx context.Context) (int64, error) {
-	return 0, DataError{Message: "Error deleting data."}
+	return 0, errors.New("Error deleting data.")
+}
+func (m *MockDataServiceError) Restore(id int, ctx context.Context) (int64, error) {
+	return 0, errors.New("Error restoring data.")
 }
+
 // Mock for CreateThreshold - returning a DataError
 func (m *MockDataServiceError) CreateThreshold(threshold *models.Threshold, ctx context.Context) error {
 	return DataError{Message: "Error creating threshold."}
@@ -183,18 +325,66 @@ func (m *MockDataServiceError) UpdateThreshold(threshold *models.Threshold, ctx
 	return 0, DataError{Message: "Error updating threshold."}
 }
 
+// Mock for PatchThreshold - returning a DataError
+func (m *MockDataServiceError) PatchThreshold(id int, patch []byte, ctx context.Context) (*models.Threshold, error) {
+	return nil, DataError{Message: "Error updating threshold."}
+}
+
 // Mock for DeleteThreshold - returning a DataError
 func (m *MockDataServiceError) DeleteThreshold(id int, ctx context.Context) (int64, error) {
 	return 0, DataError{Message: "Error deleting threshold."}
 }
 
+// Mock for RestoreThreshold - returning a DataError
+func (m *MockDataServiceError) RestoreThreshold(id int, ctx context.Context) (int64, error) {
+	return 0, DataError{Message: "Error restoring threshold."}
+}
+
 // Mock for GetAllThresholds - returning a DataError
 func (m *MockDataServiceError) GetAllThresholds(page, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
 	return nil, DataError{Message: "Error retrieving thresholds."}
 }
 
 
+// Mock for ThresholdHistory - returning a DataError
+func (m *MockDataServiceError) ThresholdHistory(id int, ctx context.Context) ([]*models.ThresholdHistory, error) {
+	return nil, DataError{Message: "Error reading threshold history."}
+}
+
+// Mock for RollbackThreshold - returning a DataError
+func (m *MockDataServiceError) RollbackThreshold(id int, version int, ctx context.Context) (*models.Threshold, error) {
+	return nil, DataError{Message: "Error rolling back threshold."}
+}
+
+// Mock for ExportThresholds - returning a DataError
+func (m *MockDataServiceError) ExportThresholds(ctx context.Context) ([]*models.Threshold, error) {
+	return nil, DataError{Message: "Error exporting thresholds."}
+}
+
+// Mock for ImportThresholds - returning a DataError
+func (m *MockDataServiceError) ImportThresholds(thresholds []*models.Threshold, mode string, dryRun bool, ctx context.Context) (*models.ThresholdImportReport, error) {
+	return nil, DataError{Message: "Error importing thresholds."}
+}
+
+// Mock for ThresholdByType - returning an error
+func (m *MockDataServiceError) ThresholdByType(sensorType string, deviceID string, ctx context.Context) (*models.Threshold, error) {
+	return nil, errors.New("Error reading threshold.")
+}
+
+// Mock for DeviceThresholds - returning an error
+func (m *MockDataServiceError) DeviceThresholds(deviceID string, ctx context.Context) (*models.DeviceThresholds, error) {
+	return nil, errors.New("Error reading thresholds.")
+}
+
+// Mock for UpsertThreshold - returning a DataError
+func (m *MockDataServiceError) UpsertThreshold(threshold *models.Threshold, ctx context.Context) (bool, error) {
+	return false, DataError{Message: "Error upserting threshold."}
+}
 
 func (m *MockDataServiceError) ValidateData(data *models.Data) error {
 	return nil
 }
+
+func (m *MockDataServiceError) ClockDrift(from time.Time, to time.Time, ctx context.Context) ([]*models.DeviceDrift, error) {
+	return nil, errors.New("Error reading data.")
+}
@@ -0,0 +1,128 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// * Patch applies a JSON merge patch (RFC 7396) to a stored reading, returns nil if there is no reading with the ID *
+// * The patch is applied to the reading in the canonical units, the merged reading is validated as a whole before it is written *
+func (ds *RepositoryDataService) Patch(id int, patch []byte, ctx context.Context) (*models.Data, error) {
+	ctx, span := tracer.Start(ctx, "DataService.Patch")
+	defer span.End()
+
+	return ds.repo.Modify(id, func(data *models.Data) error {
+		if err := checkVersion(data.Version, ctx); err != nil {
+			return err
+		}
+		Express(data, nil)
+		receivedAt, version := data.ReceivedAt, data.Version
+		if err := mergePatch(data, patch); err != nil {
+			return err
+		}
+		if data.ID != id {
+			return DataError{Message: "Invalid patch.", Fields: []FieldError{{Field: "id", Message: "cannot be changed"}}}
+		}
+		// * received_at is kept from the original reading, like on PUT, and the version is counted by the repository
+		data.ReceivedAt, data.Version = receivedAt, version
+		if err := ds.ValidateData(data); err != nil {
+			return err
+		}
+		normalise(data)
+		return nil
+	}, ctx)
+}
+
+// * PatchThreshold applies a JSON merge patch (RFC 7396) to a threshold, returns nil if there is no threshold with the ID *
+func (ds *RepositoryDataService) PatchThreshold(id int, patch []byte, ctx context.Context) (*models.Threshold, error) {
+	threshold, err := ds.thresholdRepo.Modify(id, func(threshold *models.Threshold) error {
+		if err := checkVersion(threshold.Version, ctx); err != nil {
+			return err
+		}
+		version := threshold.Version
+		if err := mergePatch(threshold, patch); err != nil {
+			return err
+		}
+		if threshold.ID != id {
+			return DataError{Message: "Invalid patch.", Fields: []FieldError{{Field: "id", Message: "cannot be changed"}}}
+		}
+		threshold.Version = version
+		if err := ds.validateThreshold(threshold); err != nil {
+			return err
+		}
+		threshold.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		return nil
+	}, ctx)
+	return threshold, thresholdConflict(err)
+}
+
+// * mergePatch merges patch into the JSON form of target and decodes the result back into target *
+// * Members of the patch replace those of target and a null member removes one, which leaves the field at its zero value *
+func mergePatch[T any](target *T, patch []byte) error {
+	var changes map[string]any
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return DataError{Code: CodeInvalidRequest, Message: "The patch must be a JSON object."}
+	}
+
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var document map[string]any
+	if err := json.Unmarshal(current, &document); err != nil {
+		return err
+	}
+
+	var merged T
+	encoded, err := json.Marshal(merge(document, changes))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(encoded, &merged); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return DataError{Message: "Invalid patch.", Fields: []FieldError{{Field: typeErr.Field, Message: "must be a " + jsonType(typeErr.Type.Kind().String())}}}
+		}
+		return DataError{Message: "Invalid patch: " + err.Error() + "."}
+	}
+	*target = merged
+	return nil
+}
+
+// * merge is the MergePatch function of RFC 7396 on decoded JSON *
+func merge(target any, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	document, ok := target.(map[string]any)
+	if !ok {
+		document = map[string]any{}
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(document, name)
+		} else {
+			document[name] = merge(document[name], value)
+		}
+	}
+	return document
+}
+
+// * jsonType names a Go kind the way a client knows it *
+func jsonType(kind string) string {
+	switch kind {
+	case "float32", "float64", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		return "number"
+	case "struct", "map":
+		return "object"
+	case "slice", "array":
+		return "array"
+	case "bool":
+		return "boolean"
+	}
+	return kind
+}
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// * RunPurgeJob permanently removes soft-deleted rows once they are older than the grace period *
+// * The job runs every interval until ctx is cancelled *
+func RunPurgeJob(ctx context.Context, ds *RepositoryDataService, grace time.Duration, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeCtx, cancel := context.WithTimeout(ctx, time.Minute)
+		purged, err := ds.Purge(time.Now().Add(-grace), purgeCtx)
+		cancel()
+		if err != nil {
+			logger.Error("Error purging soft-deleted rows", "error", err)
+		} else if purged > 0 {
+			logger.Info("Purged soft-deleted rows", "rows", purged, "grace", grace)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/config"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/units"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/data")
+
+// * Implementation of DataService on the repository interfaces, it works with every backend *
+type RepositoryDataService struct {
+	repo models.DataRepository
+	thresholdRepo    models.ThresholdRepository 
+	observers []Observer
+	validator *Validator
+}
+
+func NewRepositoryDataService(repo models.DataRepository, thresholdRepo models.ThresholdRepository) *RepositoryDataService {
+	return &RepositoryDataService{
+		repo: repo,
+		thresholdRepo: thresholdRepo,
+		validator: NewValidator(config.Default().Validation),
+	}
+}
+
+// * SetValidation replaces the default rules readings are validated against *
+func (ds *RepositoryDataService) SetValidation(rules config.Validation) {
+	ds.validator = NewValidator(rules)
+}
+
+// * AddObserver registers an observer, e.g. the rule engine, that is fed every created reading *
+func (ds *RepositoryDataService) AddObserver(o Observer) {
+	ds.observers = append(ds.observers, o)
+}
+
+func (ds *RepositoryDataService) Create(data *models.Data, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "DataService.Create", trace.WithAttributes(
+		attribute.String("device_id", data.DeviceID),
+		attribute.String("type", data.Type),
+	))
+	defer span.End()
+
+	_, validateSpan := tracer.Start(ctx, "DataService.Validate")
+	err := ds.ValidateData(data)
+	validateSpan.End()
+	if err != nil {
+		span.SetStatus(codes.Error, "invalid data")
+		return err
+	}
+	normalise(data)
+	// * The device's clock may be off, the server records when the reading actually arrived
+	data.ReceivedAt = models.NewTimestamp(time.Now())
+	if err := ds.repo.Create(data, ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// * Observers look up thresholds and rules, their time is traced separately from the insert
+	observeCtx, observeSpan := tracer.Start(ctx, "DataService.Observe")
+	for _, o := range ds.observers {
+		o.Observe(data, observeCtx)
+	}
+	observeSpan.End()
+	return nil
+}
+
+func (ds *RepositoryDataService) ReadOne(id int, ctx context.Context) (*models.Data, error) {
+
+	data, err := ds.repo.ReadOne(id, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = data
+
+	// Tehdään datalle jotain, päätellään datasta jotain!!!
+	// Tämä ohjaa toimintaa älykkäästi, esim. jos data on tietynlaista, niin tehdään jotain
+
+	return data, nil
+}
+
+func (ds *RepositoryDataService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
+	return ds.repo.ReadMany(page, rowsPerPage, ctx)
+}
+
+func (ds *RepositoryDataService) Update(data *models.Data, ctx context.Context) (int64, error) {
+
+	if err := ds.ValidateData(data); err != nil {
+		return 0, err
+	}
+	normalise(data)
+	aff, err := ds.repo.Update(data, ctx)
+	if err != nil {
+		return aff, err
+	}
+	if aff == 0 {
+		return 0, ds.staleData(data.ID, ctx)
+	}
+
+	// * received_at is kept from the original reading, whatever the client sent, and the version is counted by the repository
+	stored, err := ds.repo.ReadOne(data.ID, ctx)
+	if err != nil {
+		return aff, err
+	}
+	if stored != nil {
+		data.ReceivedAt = stored.ReceivedAt
+		data.Version = stored.Version
+	}
+	return aff, nil
+}
+
+func (ds *RepositoryDataService) Delete(data *models.Data, ctx context.Context) (int64, error) {
+	aff, err := ds.repo.Delete(data, ctx)
+	if err == nil && aff == 0 {
+		err = ds.staleData(data.ID, ctx)
+	}
+	return aff, err
+}
+
+// * Restore brings back a soft-deleted reading, returns 0 if there is no deleted reading with the ID *
+func (ds *RepositoryDataService) Restore(id int, ctx context.Context) (int64, error) {
+	return ds.repo.Undelete(id, ctx)
+}
+
+// * Purge permanently removes readings and thresholds that were soft-deleted before the given time *
+func (ds *RepositoryDataService) Purge(before time.Time, ctx context.Context) (int64, error) {
+	cutoff := before.UTC().Format(time.RFC3339)
+	data, err := ds.repo.Purge(cutoff, ctx)
+	if err != nil {
+		return 0, err
+	}
+	thresholds, err := ds.thresholdRepo.Purge(cutoff, ctx)
+	if err != nil {
+		return data, err
+	}
+	return data + thresholds, nil
+}
+func (ds *RepositoryDataService) DeleteThreshold(id int, ctx context.Context) (int64, error) {
+    // Create a threshold object with just the ID
+    threshold := &models.Threshold{ID: id}
+    
+    // Call the repository's Delete method
+    result, err := ds.thresholdRepo.Delete(threshold, ctx)
+    if err != nil {
+        return 0, err
+    }
+    if result == 0 {
+        return 0, ds.staleThreshold(id, ctx)
+    }
+    return result, nil
+}
+
+
+// Restore a soft-deleted threshold
+func (ds *RepositoryDataService) RestoreThreshold(id int, ctx context.Context) (int64, error) {
+    aff, err := ds.thresholdRepo.Undelete(id, ctx)
+    return aff, thresholdConflict(err)
+}
+
+// * ValidateData checks a reading against the rules of its type, the DataError lists every invalid field *
+func (ds *RepositoryDataService) ValidateData(data *models.Data) error {
+	if fields := ds.validator.Validate(data); len(fields) > 0 {
+		return DataError{Message: "Invalid data.", Fields: fields}
+	}
+	return nil
+}
+func (ds *RepositoryDataService) CreateThreshold(threshold *models.Threshold, ctx context.Context) error {
+	// Example logic for creating a Threshold
+	if threshold.SensorType == "" {
+		return DataError{Message: "SensorType is required."}
+	}
+	if threshold.MinValue >= threshold.MaxValue {
+		return DataError{Message: "MinValue should be less than MaxValue."}
+	}
+	if err := resolveThresholdUnit(threshold); err != nil {
+		return err
+	}
+
+	// * The modification time is always set by the server
+	threshold.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return thresholdConflict(ds.thresholdRepo.Create(threshold, ctx))
+}
+func (ds *RepositoryDataService) GetAllThresholds(page, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
+    // Call the repository method to get all thresholds with pagination
+    thresholds, err := ds.thresholdRepo.ReadMany(page, rowsPerPage, ctx)
+    if err != nil {
+        return nil, err
+    }
+    return thresholds, nil
+}
+
+// Read a single threshold by ID
+func (ds *RepositoryDataService) ReadThreshold(id int, ctx context.Context) (*models.Threshold, error) {
+    threshold, err := ds.thresholdRepo.ReadOne(id, ctx)
+    if err != nil {
+        return nil, err
+    }
+    return threshold, nil
+}
+
+
+
+// Get all thresholds with pagination
+// func (ds *RepositoryDataService) GetAllThresholds(page, rowsPerPage int, ctx context.Context) ([]*models.Threshold, error) {
+//     thresholds, err := ds.thresholdRepo.ReadMany(page, rowsPerPage, ctx)
+//     if err != nil {
+//         return nil, err
+//     }
+//     return thresholds, nil
+// }
+
+// Create a new threshold
+// func (ds *RepositoryDataService) CreateThreshold(threshold *models.Threshold, ctx context.Context) error {
+//     if err := ds.validateThreshold(threshold); err != nil {
+//         return err
+//     }
+//     return ds.thresholdRepo.Create(threshold, ctx)
+// }
+
+// Update an existing threshold
+func (ds *RepositoryDataService) UpdateThreshold(threshold *models.Threshold, ctx context.Context) (int64, error) {
+    if err := ds.validateThreshold(threshold); err != nil {
+        return 0, err
+    }
+    threshold.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+    aff, err := ds.thresholdRepo.Update(threshold, ctx)
+    if err == nil && aff == 0 {
+        err = ds.staleThreshold(threshold.ID, ctx)
+    }
+    return aff, thresholdConflict(err)
+}
+
+// Get the change history of a threshold, oldest version first
+func (ds *RepositoryDataService) ThresholdHistory(id int, ctx context.Context) ([]*models.ThresholdHistory, error) {
+    return ds.thresholdRepo.ReadHistory(id, ctx)
+}
+
+// Roll a threshold back to the values it had after the given version, returns nil if the version does not exist
+func (ds *RepositoryDataService) RollbackThreshold(id int, version int, ctx context.Context) (*models.Threshold, error) {
+    entry, err := ds.thresholdRepo.ReadVersion(id, version, ctx)
+    if err != nil || entry == nil {
+        return nil, err
+    }
+    if entry.After == nil {
+        return nil, DataError{Message: "Cannot roll back to a version where the threshold was deleted."}
+    }
+
+    threshold := *entry.After
+    threshold.ID = id
+    threshold.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+    if err := ds.thresholdRepo.Restore(&threshold, ctx); err != nil {
+        return nil, thresholdConflict(err)
+    }
+    return &threshold, nil
+}
+
+// Helper function to validate threshold data
+func (ds *RepositoryDataService) validateThreshold(threshold *models.Threshold) error {
+    if threshold.SensorType == "" {
+        return DataError{Message: "SensorType is required"}
+    }
+    if threshold.MinValue >= threshold.MaxValue {
+        return DataError{Message: "MinValue must be less than MaxValue"}
+    }
+    return resolveThresholdUnit(threshold)
+}
+
+// * resolveThresholdUnit checks the unit of a threshold and replaces an alias or "" by the unit's symbol *
+// * Only temperature and humidity thresholds have units, the bounds are kept in the unit they were given in *
+func resolveThresholdUnit(threshold *models.Threshold) error {
+	quantity, ok := units.QuantityOf(threshold.SensorType)
+	if !ok {
+		if threshold.Unit != "" {
+			return DataError{Message: "Invalid unit.", Fields: []FieldError{{Field: "unit", Message: "is only supported for temperature and humidity thresholds"}}}
+		}
+		return nil
+	}
+	u, err := units.Lookup(quantity, threshold.Unit)
+	if err != nil {
+		return DataError{Message: "Invalid unit.", Fields: []FieldError{{Field: "unit", Message: "must be one of " + strings.Join(units.Symbols(quantity), ", ")}}}
+	}
+	threshold.Unit = u.Symbol
+	return nil
+}
@@ -0,0 +1,71 @@
+package data_test
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"goapi/internal/api/units"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// * Readings are stored in °C and % whatever unit they were sent in, and expressed in the preferred units on the way out *
+func TestReadingUnits(t *testing.T) {
+	repo := Memory.NewDataRepository()
+	ds := service.NewRepositoryDataService(repo, nil)
+	data := &models.Data{DeviceID: "d1", DeviceName: "hall", Type: "sensor", TemperatureValue: 70.7, TemperatureUnit: "F", HumidityValue: 40,
+		DateTime: models.NewTimestamp(time.Now().Add(-time.Minute))}
+	if err := ds.Create(data, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := repo.ReadOne(data.ID, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.TemperatureValue != 21.5 || stored.TemperatureUnit != "C" || stored.HumidityUnit != "%" {
+		t.Errorf("expected 21.5 C and %%, got %v %s and %s", stored.TemperatureValue, stored.TemperatureUnit, stored.HumidityUnit)
+	}
+
+	service.Express(stored, units.Preference{units.Temperature: units.Kelvin})
+	if stored.TemperatureValue != 294.65 || stored.TemperatureUnit != "K" || stored.HumidityValue != 40 {
+		t.Errorf("expected 294.65 K, got %v %s", stored.TemperatureValue, stored.TemperatureUnit)
+	}
+}
+
+// * Thresholds keep the unit they were given in, by its symbol *
+func TestThresholdUnits(t *testing.T) {
+	ds := service.NewRepositoryDataService(nil, Memory.NewThresholdRepository())
+	ctx := context.Background()
+
+	threshold := &models.Threshold{SensorType: "Temperature", MinValue: 50, MaxValue: 86, Unit: "fahrenheit"}
+	if err := ds.CreateThreshold(threshold, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if threshold.Unit != "F" || threshold.MinValue != 50 {
+		t.Errorf("expected the bounds to be kept in F, got %+v", threshold)
+	}
+	canonical := &models.Threshold{SensorType: "humidity", MinValue: 20, MaxValue: 60}
+	if err := ds.CreateThreshold(canonical, ctx); err != nil || canonical.Unit != "%" {
+		t.Errorf("expected the canonical unit, got %q, %v", canonical.Unit, err)
+	}
+
+	invalid := []struct {
+		threshold models.Threshold
+		message   string
+	}{
+		{models.Threshold{SensorType: "Temperature", MinValue: 0, MaxValue: 1, Unit: "%"}, "must be one of C, F, K"},
+		{models.Threshold{SensorType: "Pressure", MinValue: 0, MaxValue: 1, Unit: "hPa"}, "is only supported for temperature and humidity thresholds"},
+	}
+	for _, tt := range invalid {
+		expected := service.DataError{Message: "Invalid unit.", Fields: []service.FieldError{{Field: "unit", Message: tt.message}}}
+		if err := ds.CreateThreshold(&tt.threshold, ctx); !reflect.DeepEqual(err, expected) {
+			t.Errorf("expected %v, got %v", expected, err)
+		}
+		if _, err := ds.UpdateThreshold(&tt.threshold, ctx); !reflect.DeepEqual(err, expected) {
+			t.Errorf("expected update to fail with %v, got %v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// * ErrDuplicateThreshold is answered with 409 when a write would give a sensor type and device a second threshold *
+var ErrDuplicateThreshold = DataError{Code: CodeConflict, Message: "A threshold for the sensor type and device exists already.", Fields: []FieldError{{Field: "sensor_type", Message: "already has a threshold for the device"}}}
+
+// * thresholdConflict replaces models.ErrDuplicateThreshold of the repository by ErrDuplicateThreshold *
+func thresholdConflict(err error) error {
+	if errors.Is(err, models.ErrDuplicateThreshold) {
+		return ErrDuplicateThreshold
+	}
+	return err
+}
+
+// * UpsertThreshold creates the threshold of a sensor type and device or replaces its limits, it reports whether the threshold was created *
+// * Writing the limits a threshold already has changes nothing, the stored threshold is copied into threshold in every case *
+// * The plan runs under the write lock of Import, so parallel upserts of a new sensor type create it once and update it afterwards *
+func (ds *RepositoryDataService) UpsertThreshold(threshold *models.Threshold, ctx context.Context) (bool, error) {
+	ctx, span := tracer.Start(ctx, "DataService.UpsertThreshold")
+	defer span.End()
+
+	if err := ds.validateThreshold(threshold); err != nil {
+		return false, err
+	}
+	threshold.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	created := false
+	err := ds.thresholdRepo.Import(func(current []*models.Threshold) ([]*models.ThresholdChange, error) {
+		for _, stored := range current {
+			if !models.SameScope(stored, threshold) {
+				continue
+			}
+			if err := checkVersion(stored.Version, ctx); err != nil {
+				return nil, err
+			}
+			if sameLimits(stored, threshold) {
+				*threshold = *stored
+				return nil, nil
+			}
+			threshold.ID = stored.ID
+			return []*models.ThresholdChange{{Action: models.ThresholdUpdated, Before: stored, After: threshold}}, nil
+		}
+
+		// An If-Match naming a version cannot match a threshold that does not exist
+		if models.ExpectedVersion(ctx) != 0 {
+			return nil, ErrVersionMismatch
+		}
+		created = true
+		return []*models.ThresholdChange{{Action: models.ThresholdCreated, After: threshold}}, nil
+	}, ctx)
+	if err != nil {
+		return false, thresholdConflict(err)
+	}
+	return created, nil
+}
@@ -0,0 +1,171 @@
+package data
+
+import (
+	"fmt"
+	"goapi/internal/api/config"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/units"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// * values points to the numeric fields of a reading that can be required or ranged, by their JSON name *
+var values = map[string]func(*models.Data) *float64{
+	"temp_value": func(d *models.Data) *float64 { return &d.TemperatureValue },
+	"humi_value": func(d *models.Data) *float64 { return &d.HumidityValue },
+}
+
+// * unitsOf points to the unit of each numeric field, and unitNames names it in field errors *
+var (
+	unitsOf = map[string]func(*models.Data) *string{
+		"temp_value": func(d *models.Data) *string { return &d.TemperatureUnit },
+		"humi_value": func(d *models.Data) *string { return &d.HumidityUnit },
+	}
+	unitNames = map[string]string{"temp_value": "temp_unit", "humi_value": "humi_unit"}
+)
+
+// * Validator checks readings against the declarative rules of their sensor type *
+type Validator struct {
+	rules config.Validation
+	now   func() time.Time
+}
+
+func NewValidator(rules config.Validation) *Validator {
+	return &Validator{rules: rules, now: time.Now}
+}
+
+// * Validate returns one FieldError per broken rule, in a stable order, or nil if the reading is valid *
+func (v *Validator) Validate(data *models.Data) []FieldError {
+	var errs []FieldError
+	invalid := func(field string, format string, args ...any) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Limits of the columns, the same for every sensor
+	if data.DeviceID == "" {
+		invalid("device_id", "is required")
+	} else if len(data.DeviceID) > 50 {
+		invalid("device_id", "must be at most 50 characters")
+	}
+	if len(data.DeviceName) > 50 {
+		invalid("device_name", "must be at most 50 characters")
+	}
+	if len(data.Type) > 20 {
+		invalid("type", "must be at most 20 characters")
+	}
+
+	rules := v.rules.For(data.Type)
+	if data.DateTime.IsZero() {
+		invalid("date_time", "is required")
+	} else if skew := rules.MaxFutureSkew.Std(); skew > 0 && data.DateTime.After(v.now().Add(skew)) {
+		invalid("date_time", "must not be more than %s in the future", skew)
+	}
+
+	for _, field := range rules.Required {
+		if !isSet(data, field) {
+			invalid(field, "is required for type %q", data.Type)
+		}
+	}
+
+	// The unit a value is sent in, ranges are checked against the value in the canonical unit
+	sent := map[string]units.Unit{}
+	for _, field := range config.RangedFields {
+		quantity := config.UnitFields[field]
+		symbol := *unitsOf[field](data)
+		u, err := units.Lookup(quantity, symbol)
+		if err != nil {
+			invalid(unitNames[field], "must be one of %s", strings.Join(units.Symbols(quantity), ", "))
+			continue
+		}
+		sent[field] = u
+		if allowed, ok := rules.Units[field]; ok && !allows(quantity, allowed, u) {
+			invalid(unitNames[field], "must be one of %s for type %q", strings.Join(allowed, ", "), data.Type)
+		}
+	}
+
+	fields := make([]string, 0, len(rules.Ranges))
+	for field := range rules.Ranges {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		u, ok := sent[field]
+		if !ok {
+			continue
+		}
+		r := rules.Ranges[field]
+		if x := u.ToCanonical(*value(data)); r.Min != nil && x < *r.Min || r.Max != nil && x > *r.Max {
+			invalid(field, "must be %s", describe(r, u))
+		}
+	}
+	return errs
+}
+
+// * allows reports whether u is one of the allowed units, which may be given by an alias *
+func allows(quantity units.Quantity, allowed []string, u units.Unit) bool {
+	for _, symbol := range allowed {
+		if a, err := units.Lookup(quantity, symbol); err == nil && a.Symbol == u.Symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// * normalise converts the values of a valid reading to the canonical units *
+func normalise(data *models.Data) {
+	for _, field := range config.RangedFields {
+		quantity := config.UnitFields[field]
+		unit := unitsOf[field](data)
+		if u, err := units.Lookup(quantity, *unit); err == nil {
+			value := values[field](data)
+			*value = u.ToCanonical(*value)
+		}
+		*unit = units.Canonical(quantity).Symbol
+	}
+}
+
+// * Express converts the values of a stored reading from the canonical units to the preferred ones and names the units *
+func Express(data *models.Data, preference units.Preference) {
+	for _, field := range config.RangedFields {
+		u := preference.Unit(config.UnitFields[field])
+		value := values[field](data)
+		*value = u.FromCanonical(*value)
+		*unitsOf[field](data) = u.Symbol
+	}
+}
+
+func isSet(data *models.Data, field string) bool {
+	switch field {
+	case "device_name":
+		return data.DeviceName != ""
+	case "type":
+		return data.Type != ""
+	}
+	if value, ok := values[field]; ok {
+		return *value(data) != 0
+	}
+	return true
+}
+
+// * describe words a range for a message in the unit the value was sent in, e.g. "between 0 and 100" or "at least 32 F" *
+func describe(r config.Range, u units.Unit) string {
+	format := func(f float64) string { return strconv.FormatFloat(u.FromCanonical(f), 'f', -1, 64) }
+	if u.Symbol != units.Canonical(u.Quantity).Symbol {
+		plain := format
+		format = func(f float64) string { return plain(f) + " " + u.Symbol }
+	}
+	switch {
+	case r.Min != nil && r.Max != nil:
+		return "between " + format(*r.Min) + " and " + format(*r.Max)
+	case r.Min != nil:
+		return "at least " + format(*r.Min)
+	default:
+		return "at most " + format(*r.Max)
+	}
+}
@@ -0,0 +1,87 @@
+package data_test
+
+import (
+	"context"
+	"goapi/internal/api/config"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func rules() config.Validation {
+	low, high := -40.0, 85.0
+	rules := config.Default().Validation
+	rules.Sensors["dht22"] = config.SensorRules{
+		Required:      []string{"device_name", "humi_value"},
+		Ranges:        map[string]config.Range{"temp_value": {Min: &low, Max: &high}},
+		Units:         map[string][]string{"temp_value": {"C", "fahrenheit"}},
+		MaxFutureSkew: config.Duration(time.Hour),
+	}
+	return rules
+}
+
+func TestValidatorRules(t *testing.T) {
+	v := service.NewValidator(rules())
+	now := time.Now().UTC()
+	past := models.NewTimestamp(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name string
+		data models.Data
+		errs []service.FieldError
+	}{
+		{"valid", models.Data{DeviceID: "d1", TemperatureValue: -10, HumidityValue: 50, DateTime: past}, nil},
+		{"columns", models.Data{DeviceName: string(make([]byte, 51))}, []service.FieldError{
+			{Field: "device_id", Message: "is required"},
+			{Field: "device_name", Message: "must be at most 50 characters"},
+			{Field: "date_time", Message: "is required"},
+		}},
+		{"default ranges", models.Data{DeviceID: "d1", TemperatureValue: -300, HumidityValue: 101, DateTime: past}, []service.FieldError{
+			{Field: "humi_value", Message: "must be between 0 and 100"},
+			{Field: "temp_value", Message: "must be between -273.15 and 100"},
+		}},
+		{"future", models.Data{DeviceID: "d1", DateTime: models.NewTimestamp(now.Add(time.Hour))}, []service.FieldError{
+			{Field: "date_time", Message: "must not be more than 5m0s in the future"},
+		}},
+		{"units", models.Data{DeviceID: "d1", TemperatureValue: 220, TemperatureUnit: "F", HumidityUnit: "‰", DateTime: past}, []service.FieldError{
+			{Field: "humi_unit", Message: "must be one of %"},
+			{Field: "temp_value", Message: "must be between -459.67 F and 212 F"},
+		}},
+		{"allowed units", models.Data{DeviceID: "d1", Type: "dht22", DeviceName: "hall", TemperatureValue: 400, TemperatureUnit: "K", HumidityValue: 40, DateTime: past}, []service.FieldError{
+			{Field: "temp_unit", Message: `must be one of C, fahrenheit for type "dht22"`},
+			{Field: "temp_value", Message: "must be between 233.15 K and 358.15 K"},
+		}},
+		{"allowed alias", models.Data{DeviceID: "d1", Type: "dht22", DeviceName: "hall", TemperatureValue: 190, TemperatureUnit: "°F", HumidityValue: 40, DateTime: past}, []service.FieldError{
+			{Field: "temp_value", Message: "must be between -40 F and 185 F"},
+		}},
+		{"sensor rules", models.Data{DeviceID: "d1", Type: "dht22", TemperatureValue: 90, DateTime: models.NewTimestamp(now.Add(30 * time.Minute))}, []service.FieldError{
+			{Field: "device_name", Message: `is required for type "dht22"`},
+			{Field: "humi_value", Message: `is required for type "dht22"`},
+			{Field: "temp_value", Message: "must be between -40 and 85"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := v.Validate(&tt.data); !reflect.DeepEqual(errs, tt.errs) {
+				t.Errorf("expected %v, got %v", tt.errs, errs)
+			}
+		})
+	}
+}
+
+// * Create and update reject invalid readings with the same field errors *
+func TestDataServiceValidation(t *testing.T) {
+	ds := service.NewRepositoryDataService(nil, nil)
+	ds.SetValidation(rules())
+	data := &models.Data{DeviceID: "d1", Type: "dht22", DeviceName: "hall", HumidityValue: 120, DateTime: models.NewTimestamp(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))}
+
+	expected := service.DataError{Message: "Invalid data.", Fields: []service.FieldError{{Field: "humi_value", Message: "must be between 0 and 100"}}}
+	if err := ds.Create(data, context.Background()); !reflect.DeepEqual(err, expected) {
+		t.Errorf("expected create to fail with %v, got %v", expected, err)
+	}
+	if _, err := ds.Update(data, context.Background()); !reflect.DeepEqual(err, expected) {
+		t.Errorf("expected update to fail with %v, got %v", expected, err)
+	}
+}
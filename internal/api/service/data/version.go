@@ -0,0 +1,47 @@
+package data
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+)
+
+// * ErrVersionMismatch is returned when a write expects a version the resource no longer has, see models.WithExpectedVersion *
+var ErrVersionMismatch = DataError{Code: CodePreconditionFailed, Message: "The resource has been changed since it was read."}
+
+// * checkVersion compares a stored version with the one the write expects *
+func checkVersion(version int, ctx context.Context) error {
+	if expected := models.ExpectedVersion(ctx); expected != 0 && expected != version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// * staleData tells a write that affected no reading because of its expected version from one of a reading that does not exist *
+func (ds *RepositoryDataService) staleData(id int, ctx context.Context) error {
+	if models.ExpectedVersion(ctx) == 0 {
+		return nil
+	}
+	stored, err := ds.repo.ReadOne(id, ctx)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// * staleThreshold is staleData for thresholds *
+func (ds *RepositoryDataService) staleThreshold(id int, ctx context.Context) error {
+	if models.ExpectedVersion(ctx) == 0 {
+		return nil
+	}
+	stored, err := ds.thresholdRepo.ReadOne(id, ctx)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		return ErrVersionMismatch
+	}
+	return nil
+}
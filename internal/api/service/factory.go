@@ -2,49 +2,162 @@ package service
 
 import (
 	"context"
+	"goapi/internal/api/metrics"
 	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/DAL/PostgreSQL"
 	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/audit"
 	service "goapi/internal/api/service/data"
-	"log"
+	"goapi/internal/api/service/rules"
+	"log/slog"
 )
 
 type DataServiceType int
 
 const (
 	SQLiteDataService DataServiceType = iota
+	PostgresDataService
+	// * MemoryDataService keeps everything in process memory, for tests and demos *
+	MemoryDataService
 )
 
 type ServiceFactory struct {
-	db     DAL.SQLDatabase
-	logger *log.Logger
-	ctx    context.Context
+	db      DAL.SQLDatabase
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+	ctx     context.Context
+
+	// * Shared by every in-memory service so that they see the same rows *
+	memoryStore *Memory.Store
 }
 
 // * Factory for creating data service *
-func NewServiceFactory(db DAL.SQLDatabase, logger *log.Logger, ctx context.Context) *ServiceFactory {
+// * Every repository is instrumented with m, which may be nil *
+func NewServiceFactory(db DAL.SQLDatabase, logger *slog.Logger, m *metrics.Metrics, ctx context.Context) *ServiceFactory {
 	return &ServiceFactory{
-		db:     db,
-		logger: logger,
-		ctx:    ctx,
+		db:      db,
+		logger:  logger,
+		metrics: m,
+		ctx:     ctx,
 	}
 }
 
-func (sf *ServiceFactory) CreateDataService(serviceType DataServiceType) (*service.DataServiceSQLite, error) {
+// * Services holds one service of every kind, they are created once and shared by the route groups and background jobs *
+type Services struct {
+	Data  *service.RepositoryDataService
+	Rules *rules.RepositoryRuleService
+	Audit *audit.RepositoryAuditService
+}
+
+// * CreateServices prepares the repositories of the backend once, every service and the rule engine share them *
+// * The statements of the SQL repositories are closed when the context of the factory is done *
+func (sf *ServiceFactory) CreateServices(serviceType DataServiceType) (*Services, error) {
+	dataRepo, thresholdRepo, err := sf.createDataRepositories(serviceType)
+	if err != nil {
+		return nil, err
+	}
+	// The rule engine is shared by the data and rule services
+	ruleRepo, engine, err := sf.createRuleEngine(serviceType)
+	if err != nil {
+		return nil, err
+	}
+	auditRepo, err := sf.createAuditRepository(serviceType)
+	if err != nil {
+		return nil, err
+	}
+
+	// The services only depend on the repository interfaces and work with every backend
+	ds := service.NewRepositoryDataService(dataRepo, thresholdRepo)
+	ds.AddObserver(metrics.NewIngestionObserver(sf.metrics, thresholdRepo))
+	// Feed every created reading to the rule engine
+	ds.AddObserver(engine)
+
+	return &Services{
+		Data:  ds,
+		Rules: rules.NewRepositoryRuleService(ruleRepo, dataRepo, engine),
+		Audit: audit.NewRepositoryAuditService(auditRepo),
+	}, nil
+}
+
+// * Creates the instrumented data and threshold repositories *
+func (sf *ServiceFactory) createDataRepositories(serviceType DataServiceType) (models.DataRepository, models.ThresholdRepository, error) {
+	var dataRepo models.DataRepository
+	var thresholdRepo models.ThresholdRepository
+	var err error
 	switch serviceType {
 	case SQLiteDataService:
-		// Create both repositories
-		dataRepo, err := SQLite.NewDataRepository(sf.db, sf.ctx)
-		if err != nil {
-			return nil, err
+		if dataRepo, err = SQLite.NewDataRepository(sf.db, sf.ctx); err != nil {
+			return nil, nil, err
+		}
+		if thresholdRepo, err = SQLite.NewThresholdRepository(sf.db, sf.ctx); err != nil {
+			return nil, nil, err
+		}
+	case PostgresDataService:
+		if dataRepo, err = PostgreSQL.NewDataRepository(sf.db, sf.ctx); err != nil {
+			return nil, nil, err
 		}
-		thresholdRepo, err := SQLite.NewThresholdRepository(sf.db, sf.ctx)
-		if err != nil {
-			return nil, err
+		if thresholdRepo, err = PostgreSQL.NewThresholdRepository(sf.db, sf.ctx); err != nil {
+			return nil, nil, err
 		}
-		// Create the DataServiceSQLite with both repositories
-		ds := service.NewDataServiceSQLite(dataRepo, thresholdRepo)
-		return ds, nil
+	case MemoryDataService:
+		dataRepo, thresholdRepo = sf.memory().Data, sf.memory().Thresholds
+	default:
+		return nil, nil, service.DataError{Message: "Invalid data service type."}
+	}
+	return metrics.InstrumentDataRepository(dataRepo, sf.metrics), metrics.InstrumentThresholdRepository(thresholdRepo, sf.metrics), nil
+}
+
+// * Creates the instrumented audit repository *
+func (sf *ServiceFactory) createAuditRepository(serviceType DataServiceType) (models.AuditRepository, error) {
+	var auditRepo models.AuditRepository
+	var err error
+	switch serviceType {
+	case SQLiteDataService:
+		auditRepo, err = SQLite.NewAuditRepository(sf.db, sf.ctx)
+	case PostgresDataService:
+		auditRepo, err = PostgreSQL.NewAuditRepository(sf.db, sf.ctx)
+	case MemoryDataService:
+		auditRepo = sf.memory().Audit
 	default:
-		return nil, service.DataError{Message: "Invalid data service type."}
+		return nil, service.DataError{Message: "Invalid audit service type."}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return metrics.InstrumentAuditRepository(auditRepo, sf.metrics), nil
+}
+
+// * Creates the rule repository and engine and loads the enabled rules *
+func (sf *ServiceFactory) createRuleEngine(serviceType DataServiceType) (models.RuleRepository, *rules.Engine, error) {
+	var ruleRepo models.RuleRepository
+	var err error
+	switch serviceType {
+	case SQLiteDataService:
+		ruleRepo, err = SQLite.NewRuleRepository(sf.db, sf.ctx)
+	case PostgresDataService:
+		ruleRepo, err = PostgreSQL.NewRuleRepository(sf.db, sf.ctx)
+	case MemoryDataService:
+		ruleRepo = sf.memory().Rules
+	default:
+		return nil, nil, service.DataError{Message: "Invalid rule service type."}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	ruleRepo = metrics.InstrumentRuleRepository(ruleRepo, sf.metrics)
+	engine := rules.NewEngine(ruleRepo, sf.logger)
+	if err := engine.Reload(sf.ctx); err != nil {
+		return nil, nil, err
+	}
+	return ruleRepo, engine, nil
+}
+
+// * Creates the in-memory store on first use *
+func (sf *ServiceFactory) memory() *Memory.Store {
+	if sf.memoryStore == nil {
+		sf.memoryStore = Memory.NewStore()
 	}
+	return sf.memoryStore
 }
@@ -0,0 +1,31 @@
+package rules
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+type RuleService interface {
+	Create(rule *models.Rule, ctx context.Context) error
+	ReadOne(id int, ctx context.Context) (*models.Rule, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error)
+	Update(rule *models.Rule, ctx context.Context) (int64, error)
+	Delete(rule *models.Rule, ctx context.Context) (int64, error)
+
+	// Evaluate a rule against stored readings without side effects
+	DryRun(rule *models.Rule, from time.Time, to time.Time, ctx context.Context) (*DryRunResult, error)
+
+	// Device tags used by grouped rules
+	ReadTags(deviceID string, ctx context.Context) ([]string, error)
+	SetTags(deviceID string, tags []string, ctx context.Context) error
+
+	// Events of rules that started firing, newest first
+	ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error)
+}
+
+// * DryRunResult reports how many readings were replayed and which events the rule would have emitted *
+type DryRunResult struct {
+	Readings int                `json:"readings"`
+	Events   []models.RuleEvent `json:"events"`
+}
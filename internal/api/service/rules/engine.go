@@ -0,0 +1,253 @@
+package rules
+
+import (
+	"context"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// * Evaluator holds the per-device state of a set of rules and is fed readings in chronological order *
+// * The same Evaluator is used for live evaluation and for dry-runs against historical data *
+// * A reading is only evaluated by the rules of its tenant, the state is kept per tenant and device *
+type Evaluator struct {
+	rules  []*ruleState
+	window time.Duration
+}
+
+type ruleState struct {
+	rule     *models.Rule
+	tenant   string
+	duration time.Duration
+	since    map[deviceKey]time.Time // device -> when the condition started to hold
+	active   map[deviceKey]bool      // device -> condition has held for the required duration
+	seen     map[deviceKey]time.Time // device -> when its last reading was taken
+	firing   map[deviceKey]bool      // device (or the tenant alone for grouped rules) -> an event was already emitted
+}
+
+// * deviceKey tells apart the devices of different tenants that were given the same device_id *
+type deviceKey struct {
+	tenant   string
+	deviceID string
+}
+
+// * tenantOrDefault maps the empty tenant of rules and callers that don't have one to auth.DefaultTenant *
+func tenantOrDefault(tenant string) string {
+	if tenant == "" {
+		return auth.DefaultTenant
+	}
+	return tenant
+}
+
+// * DefaultGroupWindow is how long a device of a grouped rule counts as active without sending a reading *
+const DefaultGroupWindow = 15 * time.Minute
+
+// * NewEvaluator compiles the given rules, rules with an invalid duration are skipped *
+func NewEvaluator(rules []*models.Rule) *Evaluator {
+	e := &Evaluator{window: DefaultGroupWindow}
+	for _, rule := range rules {
+		duration, err := rule.Duration()
+		if err != nil {
+			continue
+		}
+		e.rules = append(e.rules, &ruleState{
+			rule:     rule,
+			tenant:   tenantOrDefault(rule.Tenant),
+			duration: duration,
+			since:    map[deviceKey]time.Time{},
+			active:   map[deviceKey]bool{},
+			seen:     map[deviceKey]time.Time{},
+			firing:   map[deviceKey]bool{},
+		})
+	}
+	return e
+}
+
+// * SetGroupWindow sets how long a device of a grouped rule counts as active after its last reading, *
+// * rules with a longer duration qualifier keep their devices for that long instead *
+func (e *Evaluator) SetGroupWindow(window time.Duration) {
+	if window > 0 {
+		e.window = window
+	}
+}
+
+// * carryOver keeps the state of the rules that old evaluated with the same definition *
+func (e *Evaluator) carryOver(old *Evaluator) {
+	for _, rs := range e.rules {
+		for _, prev := range old.rules {
+			if prev.rule.ID == rs.rule.ID && prev.tenant == rs.tenant && sameDefinition(prev.rule, rs.rule) {
+				rs.since, rs.active, rs.seen, rs.firing = prev.since, prev.active, prev.seen, prev.firing
+				break
+			}
+		}
+	}
+}
+
+// * sameDefinition reports whether two rules fire on the same readings, the name and timestamps don't matter *
+func sameDefinition(a, b *models.Rule) bool {
+	return a.For == b.For && a.GroupTag == b.GroupTag && a.MinDevices == b.MinDevices && reflect.DeepEqual(a.Condition, b.Condition)
+}
+
+// * Grouped reports whether any rule of the tenant needs device tags to be evaluated *
+func (e *Evaluator) Grouped(tenant string) bool {
+	tenant = tenantOrDefault(tenant)
+	for _, rs := range e.rules {
+		if rs.tenant == tenant && rs.rule.GroupTag != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// * Evaluate feeds one reading of the tenant to its rules and returns the events of rules that started firing *
+func (e *Evaluator) Evaluate(tenant string, data *models.Data, tags []string) []models.RuleEvent {
+	at := data.DateTime.Time
+	if at.IsZero() {
+		at = time.Now().UTC()
+	}
+
+	tenant = tenantOrDefault(tenant)
+	var events []models.RuleEvent
+	for _, rs := range e.rules {
+		if rs.tenant != tenant || rs.rule.GroupTag != "" && !slices.Contains(tags, rs.rule.GroupTag) {
+			continue
+		}
+
+		// * Track for how long the condition has held continuously on this device
+		device := deviceKey{tenant, data.DeviceID}
+		rs.seen[device] = at
+		if rs.rule.Condition.Eval(data) {
+			if _, ok := rs.since[device]; !ok {
+				rs.since[device] = at
+			}
+			rs.active[device] = at.Sub(rs.since[device]) >= rs.duration
+		} else {
+			delete(rs.since, device)
+			delete(rs.active, device)
+			delete(rs.seen, device)
+		}
+
+		if rs.rule.GroupTag == "" {
+			if rs.active[device] && !rs.firing[device] {
+				events = append(events, newEvent(rs.rule, []string{device.deviceID}, at))
+			}
+			rs.firing[device] = rs.active[device]
+			continue
+		}
+
+		// * Grouped rules fire once enough devices of the group are active at the same time,
+		// * a device that stopped reporting is dropped once its last reading is older than the window
+		window := max(rs.duration, e.window)
+		var devices []string
+		for key, active := range rs.active {
+			if at.Sub(rs.seen[key]) > window {
+				delete(rs.since, key)
+				delete(rs.active, key)
+				delete(rs.seen, key)
+				continue
+			}
+			if active {
+				devices = append(devices, key.deviceID)
+			}
+		}
+		minDevices := max(rs.rule.MinDevices, 1)
+		firing := len(devices) >= minDevices
+		group := deviceKey{tenant: tenant}
+		if firing && !rs.firing[group] {
+			slices.Sort(devices)
+			events = append(events, newEvent(rs.rule, devices, at))
+		}
+		rs.firing[group] = firing
+	}
+	return events
+}
+
+func newEvent(rule *models.Rule, devices []string, at time.Time) models.RuleEvent {
+	return models.RuleEvent{
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		DeviceIDs: devices,
+		DateTime:  at.UTC().Format(time.RFC3339),
+	}
+}
+
+// * Engine evaluates the enabled rules against every reading stored through the data service *
+type Engine struct {
+	mu        sync.Mutex
+	repo      models.RuleRepository
+	logger    *slog.Logger
+	evaluator *Evaluator
+	window    time.Duration
+}
+
+func NewEngine(repo models.RuleRepository, logger *slog.Logger) *Engine {
+	return &Engine{
+		repo:      repo,
+		logger:    logger,
+		evaluator: NewEvaluator(nil),
+		window:    DefaultGroupWindow,
+	}
+}
+
+// * SetGroupWindow sets how long a device of a grouped rule counts as active after its last reading *
+func (e *Engine) SetGroupWindow(window time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.window = window
+	e.evaluator.SetGroupWindow(window)
+}
+
+// * GroupWindow returns the window set by SetGroupWindow, dry-runs evaluate with the same one *
+func (e *Engine) GroupWindow() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.window
+}
+
+// * Reload reads the enabled rules of every tenant from the repository, rules whose definition didn't change keep their state *
+func (e *Engine) Reload(ctx context.Context) error {
+	rules, err := e.repo.ReadEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	evaluator := NewEvaluator(rules)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	evaluator.SetGroupWindow(e.window)
+	evaluator.carryOver(e.evaluator)
+	e.evaluator = evaluator
+	return nil
+}
+
+// * Observe is called by the data service after a reading has been stored, the events of rules that start firing are stored *
+// * ctx carries the caller that stored the reading, its tenant selects the rules, tags and where events are stored *
+func (e *Engine) Observe(data *models.Data, ctx context.Context) {
+	tenant := auth.Tenant(ctx)
+
+	// * Tags are read before the lock is taken, so that ingestion isn't serialised on the query
+	e.mu.Lock()
+	grouped := e.evaluator.Grouped(tenant)
+	e.mu.Unlock()
+	var tags []string
+	if grouped {
+		var err error
+		if tags, err = e.repo.ReadTags(data.DeviceID, ctx); err != nil {
+			e.logger.ErrorContext(ctx, "Error reading device tags", "error", err, "device_id", data.DeviceID)
+		}
+	}
+
+	e.mu.Lock()
+	events := e.evaluator.Evaluate(tenant, data, tags)
+	e.mu.Unlock()
+
+	for _, event := range events {
+		e.logger.InfoContext(ctx, "Rule fired", "rule_id", event.RuleID, "rule", event.RuleName, "devices", event.DeviceIDs, "date_time", event.DateTime)
+		if err := e.repo.CreateEvent(&event, ctx); err != nil {
+			e.logger.ErrorContext(ctx, "Error storing rule event", "error", err, "rule_id", event.RuleID)
+		}
+	}
+}
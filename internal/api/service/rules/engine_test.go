@@ -0,0 +1,247 @@
+package rules_test
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/DAL/Memory"
+	"goapi/internal/api/repository/models"
+	dataservice "goapi/internal/api/service/data"
+	"goapi/internal/api/service/rules"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var humidAndWarm = models.Condition{
+	Op: "and",
+	Conditions: []models.Condition{
+		{Metric: "humidity", Op: ">", Value: 80},
+		{Metric: "temperature", Op: ">", Value: 25},
+	},
+}
+
+func reading(device string, temp, humi float64, at string) *models.Data {
+	ts, _ := models.ParseTimestamp(at)
+	return &models.Data{DeviceID: device, TemperatureValue: temp, HumidityValue: humi, DateTime: ts}
+}
+
+// * The rule should only fire after the condition held for the whole duration, and only once *
+func TestEvaluatorDuration(t *testing.T) {
+	e := rules.NewEvaluator([]*models.Rule{{ID: 1, Name: "humid", Condition: humidAndWarm, For: "15m"}})
+
+	steps := []struct {
+		data   *models.Data
+		events int
+	}{
+		{reading("d1", 26, 85, "2024-01-01T12:00:00Z"), 0},
+		{reading("d1", 26, 85, "2024-01-01T12:10:00Z"), 0},
+		{reading("d1", 26, 85, "2024-01-01T12:15:00Z"), 1},
+		{reading("d1", 26, 85, "2024-01-01T12:20:00Z"), 0},
+		{reading("d1", 20, 85, "2024-01-01T12:25:00Z"), 0},
+		{reading("d1", 26, 85, "2024-01-01T12:30:00Z"), 0},
+	}
+	for i, step := range steps {
+		if events := e.Evaluate(auth.DefaultTenant, step.data, nil); len(events) != step.events {
+			t.Errorf("step %d: expected %d events, got %d", i, step.events, len(events))
+		}
+	}
+}
+
+// * A grouped rule fires when the minimum number of tagged devices match at the same time *
+func TestEvaluatorGroup(t *testing.T) {
+	e := rules.NewEvaluator([]*models.Rule{{
+		ID:         2,
+		Name:       "zone a",
+		Condition:  models.Condition{Op: "not", Conditions: []models.Condition{{Metric: "temperature", Op: "<=", Value: 30}}},
+		GroupTag:   "zone-a",
+		MinDevices: 2,
+	}})
+	zoneA := []string{"zone-a"}
+
+	if events := e.Evaluate(auth.DefaultTenant, reading("d1", 35, 0, "2024-01-01T12:00:00Z"), zoneA); len(events) != 0 {
+		t.Fatalf("expected no events for a single device, got %v", events)
+	}
+	if events := e.Evaluate(auth.DefaultTenant, reading("d2", 35, 0, "2024-01-01T12:01:00Z"), nil); len(events) != 0 {
+		t.Fatalf("expected untagged device to be ignored, got %v", events)
+	}
+	events := e.Evaluate(auth.DefaultTenant, reading("d3", 35, 0, "2024-01-01T12:02:00Z"), zoneA)
+	if len(events) != 1 || len(events[0].DeviceIDs) != 2 {
+		t.Fatalf("expected one event with two devices, got %v", events)
+	}
+}
+
+func TestConditionValidate(t *testing.T) {
+	if err := humidAndWarm.Validate(); err != nil {
+		t.Errorf("expected valid condition, got %v", err)
+	}
+	invalid := []models.Condition{
+		{Op: "and"},
+		{Op: "not", Conditions: []models.Condition{humidAndWarm, humidAndWarm}},
+		{Op: ">", Metric: "pressure", Value: 1},
+		{Op: "xor", Conditions: []models.Condition{humidAndWarm}},
+	}
+	for _, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected condition %+v to be invalid", c)
+		}
+	}
+}
+
+// * A device that stops reporting no longer counts towards a grouped rule once the window has passed *
+func TestEvaluatorGroupExpiry(t *testing.T) {
+	e := rules.NewEvaluator([]*models.Rule{{ID: 3, Name: "zone a", Condition: models.Condition{Metric: "temperature", Op: ">", Value: 30}, GroupTag: "zone-a", MinDevices: 2}})
+	e.SetGroupWindow(10 * time.Minute)
+	zoneA := []string{"zone-a"}
+
+	e.Evaluate(auth.DefaultTenant, reading("d1", 35, 0, "2024-01-01T12:00:00Z"), zoneA)
+	if events := e.Evaluate(auth.DefaultTenant, reading("d2", 35, 0, "2024-01-01T12:20:00Z"), zoneA); len(events) != 0 {
+		t.Fatalf("expected the silent device to have expired, got %v", events)
+	}
+	if events := e.Evaluate(auth.DefaultTenant, reading("d1", 35, 0, "2024-01-01T12:25:00Z"), zoneA); len(events) != 1 {
+		t.Fatalf("expected the rule to fire once both devices report, got %v", events)
+	}
+}
+
+// * Reloading keeps the state of unchanged rules, so a firing rule doesn't fire again, and resets edited ones *
+func TestEngineReload(t *testing.T) {
+	ctx := context.Background()
+	repo := Memory.NewRuleRepository()
+	rule := &models.Rule{Name: "humid", Condition: humidAndWarm, For: "15m", Enabled: true}
+	if err := repo.Create(rule, ctx); err != nil {
+		t.Fatal(err)
+	}
+	engine := rules.NewEngine(repo, slog.Default())
+	if err := engine.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:00:00Z"), ctx)
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:15:00Z"), ctx)
+	if err := repo.Create(&models.Rule{Name: "other", Condition: humidAndWarm, Enabled: true}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:20:00Z"), ctx)
+
+	events, err := repo.ReadEvents(1, 10, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].RuleName != "other" || events[1].RuleName != "humid" || events[1].DateTime != "2024-01-01T12:15:00Z" {
+		t.Fatalf("expected humid to fire once before other, got %+v", events)
+	}
+
+	// * An edited rule starts over
+	rule.For = "10m"
+	if _, err := repo.Update(rule, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:25:00Z"), ctx)
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:35:00Z"), ctx)
+	if events, _ := repo.ReadEvents(1, 10, ctx); len(events) != 3 || events[0].RuleName != "humid" {
+		t.Fatalf("expected the edited rule to fire again, got %+v", events)
+	}
+}
+
+// * Readings are only evaluated by the rules of their tenant, devices of different tenants may share a device_id *
+func TestEngineTenants(t *testing.T) {
+	acme := auth.WithPrincipal(context.Background(), auth.Principal{Username: "acme-user", Tenant: "acme"})
+	globex := auth.WithPrincipal(context.Background(), auth.Principal{Username: "globex-user", Tenant: "globex"})
+	repo := Memory.NewRuleRepository()
+	for _, ctx := range []context.Context{acme, globex} {
+		if err := repo.Create(&models.Rule{Name: "humid", Condition: humidAndWarm, For: "15m", Enabled: true}, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Create(&models.Rule{Name: "zone a", Condition: humidAndWarm, GroupTag: "zone-a", MinDevices: 1, Enabled: true}, globex); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetTags("d1", []string{"zone-a"}, acme); err != nil {
+		t.Fatal(err)
+	}
+	engine := rules.NewEngine(repo, slog.Default())
+	if err := engine.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// * d1 of globex only starts to count at 12:15, the tags of d1 of acme don't put it into zone-a
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:00:00Z"), acme)
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:15:00Z"), globex)
+	engine.Observe(reading("d1", 26, 85, "2024-01-01T12:15:00Z"), acme)
+
+	if events, err := repo.ReadEvents(1, 10, acme); err != nil || len(events) != 1 || events[0].RuleName != "humid" || events[0].DateTime != "2024-01-01T12:15:00Z" {
+		t.Fatalf("expected the rule of acme to fire once, got %+v, %v", events, err)
+	}
+	if events, err := repo.ReadEvents(1, 10, globex); err != nil || len(events) != 0 {
+		t.Fatalf("expected no event of globex, got %+v, %v", events, err)
+	}
+}
+
+// * Every invalid field of a rule is reported on its own, under its JSON name *
+func TestValidateRuleFields(t *testing.T) {
+	repo := Memory.NewRuleRepository()
+	rs := rules.NewRepositoryRuleService(repo, Memory.NewDataRepository(), rules.NewEngine(repo, slog.Default()))
+	rule := &models.Rule{Condition: models.Condition{Op: "xor"}, For: "soon", GroupTag: strings.Repeat("a", 51), MinDevices: -1}
+
+	var dataErr dataservice.DataError
+	if err := rs.ValidateRule(rule); !errors.As(err, &dataErr) {
+		t.Fatalf("expected a DataError, got %v", err)
+	}
+	var fields []string
+	for _, field := range dataErr.Fields {
+		fields = append(fields, field.Field)
+	}
+	if expected := []string{"name", "condition", "for", "group_tag", "min_devices"}; !slices.Equal(fields, expected) {
+		t.Errorf("expected errors of %v, got %+v", expected, dataErr.Fields)
+	}
+
+	rule = &models.Rule{Name: "hot", Condition: models.Condition{Metric: "temperature", Op: ">", Value: 30}}
+	if err := rs.ValidateRule(rule); err != nil {
+		t.Errorf("expected the rule to be valid, got %v", err)
+	}
+}
+
+// * A dry run needs both bounds and replays at most 31 days, larger ranges are rejected before any reading is loaded *
+func TestDryRunRange(t *testing.T) {
+	ctx := context.Background()
+	repo, data := Memory.NewRuleRepository(), Memory.NewDataRepository()
+	rs := rules.NewRepositoryRuleService(repo, data, rules.NewEngine(repo, slog.Default()))
+	for _, r := range []*models.Data{reading("dev1", 30, 90, "2024-01-01T10:00:00Z"), reading("dev1", 30, 90, "2024-03-01T10:00:00Z")} {
+		if err := data.Create(r, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rule := &models.Rule{ID: 1, Name: "humid and warm", Condition: humidAndWarm}
+	at := func(s string) time.Time {
+		ts, _ := models.ParseTimestamp(s)
+		return ts.Time
+	}
+
+	for _, tt := range []struct {
+		from, to time.Time
+		field    string
+	}{
+		{time.Time{}, at("2024-01-02T00:00:00Z"), "from"},
+		{at("2024-01-01T00:00:00Z"), time.Time{}, "to"},
+		{at("2024-01-02T00:00:00Z"), at("2024-01-01T00:00:00Z"), "to"},
+		{at("2024-01-01T00:00:00Z"), at("2024-03-02T00:00:00Z"), "to"},
+	} {
+		var dataErr dataservice.DataError
+		if _, err := rs.DryRun(rule, tt.from, tt.to, ctx); !errors.As(err, &dataErr) || len(dataErr.Fields) != 1 || dataErr.Fields[0].Field != tt.field {
+			t.Errorf("%v - %v: expected an error of %s, got %v", tt.from, tt.to, tt.field, err)
+		}
+	}
+
+	result, err := rs.DryRun(rule, at("2024-01-01T00:00:00Z"), at("2024-02-01T00:00:00Z"), ctx)
+	if err != nil || result.Readings != 1 || len(result.Events) != 1 {
+		t.Errorf("expected the reading of January to fire the rule, got %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,136 @@
+package rules
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"time"
+)
+
+// * Mock implementation of RuleService for testing purposes, always returns a successful response and Rule object(s) *
+type MockRuleServiceSuccessful struct{}
+
+func (m *MockRuleServiceSuccessful) Create(rule *models.Rule, ctx context.Context) error {
+	rule.ID = 1
+	return nil
+}
+
+func (m *MockRuleServiceSuccessful) ReadOne(id int, ctx context.Context) (*models.Rule, error) {
+	return &models.Rule{
+		ID:        id,
+		Name:      "rule1",
+		Condition: models.Condition{Metric: "humidity", Op: ">", Value: 80},
+		Enabled:   true,
+	}, nil
+}
+
+func (m *MockRuleServiceSuccessful) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error) {
+	return []*models.Rule{
+		{ID: 1, Name: "rule1", Condition: models.Condition{Metric: "humidity", Op: ">", Value: 80}, Enabled: true},
+	}, nil
+}
+
+func (m *MockRuleServiceSuccessful) Update(rule *models.Rule, ctx context.Context) (int64, error) {
+	return 1, nil
+}
+
+func (m *MockRuleServiceSuccessful) Delete(rule *models.Rule, ctx context.Context) (int64, error) {
+	return 1, nil
+}
+
+func (m *MockRuleServiceSuccessful) DryRun(rule *models.Rule, from time.Time, to time.Time, ctx context.Context) (*DryRunResult, error) {
+	return &DryRunResult{
+		Readings: 2,
+		Events:   []models.RuleEvent{{RuleID: rule.ID, RuleName: rule.Name, DeviceIDs: []string{"device1"}, DateTime: "2021-01-01T00:00:00Z"}},
+	}, nil
+}
+
+func (m *MockRuleServiceSuccessful) ReadTags(deviceID string, ctx context.Context) ([]string, error) {
+	return []string{"zone-a"}, nil
+}
+
+func (m *MockRuleServiceSuccessful) SetTags(deviceID string, tags []string, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockRuleServiceSuccessful) ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error) {
+	return []*models.RuleEvent{{RuleID: 1, RuleName: "rule1", DeviceIDs: []string{"device1"}, DateTime: "2021-01-01T00:00:00Z"}}, nil
+}
+
+// * Mock implementation of RuleService for testing purposes, always returns empty results *
+type MockRuleServiceNotFound struct{}
+
+func (m *MockRuleServiceNotFound) Create(rule *models.Rule, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockRuleServiceNotFound) ReadOne(id int, ctx context.Context) (*models.Rule, error) {
+	return nil, nil
+}
+
+func (m *MockRuleServiceNotFound) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error) {
+	return []*models.Rule{}, nil
+}
+
+func (m *MockRuleServiceNotFound) Update(rule *models.Rule, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockRuleServiceNotFound) Delete(rule *models.Rule, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockRuleServiceNotFound) DryRun(rule *models.Rule, from time.Time, to time.Time, ctx context.Context) (*DryRunResult, error) {
+	return &DryRunResult{Events: []models.RuleEvent{}}, nil
+}
+
+func (m *MockRuleServiceNotFound) ReadTags(deviceID string, ctx context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *MockRuleServiceNotFound) SetTags(deviceID string, tags []string, ctx context.Context) error {
+	return nil
+}
+
+func (m *MockRuleServiceNotFound) ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error) {
+	return []*models.RuleEvent{}, nil
+}
+
+// * Mock implementation of RuleService for testing purposes, always returns an error *
+type MockRuleServiceError struct{}
+
+func (m *MockRuleServiceError) Create(rule *models.Rule, ctx context.Context) error {
+	return service.DataError{Message: "Error creating rule."}
+}
+
+func (m *MockRuleServiceError) ReadOne(id int, ctx context.Context) (*models.Rule, error) {
+	return nil, service.DataError{Message: "Error reading rule."}
+}
+
+func (m *MockRuleServiceError) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error) {
+	return nil, service.DataError{Message: "Error reading rules."}
+}
+
+func (m *MockRuleServiceError) Update(rule *models.Rule, ctx context.Context) (int64, error) {
+	return 0, service.DataError{Message: "Error updating rule."}
+}
+
+func (m *MockRuleServiceError) Delete(rule *models.Rule, ctx context.Context) (int64, error) {
+	return 0, service.DataError{Message: "Error deleting rule."}
+}
+
+func (m *MockRuleServiceError) DryRun(rule *models.Rule, from time.Time, to time.Time, ctx context.Context) (*DryRunResult, error) {
+	return nil, service.DataError{Message: "Error running rule."}
+}
+
+func (m *MockRuleServiceError) ReadTags(deviceID string, ctx context.Context) ([]string, error) {
+	return nil, service.DataError{Message: "Error reading tags."}
+}
+
+func (m *MockRuleServiceError) SetTags(deviceID string, tags []string, ctx context.Context) error {
+	return service.DataError{Message: "Error setting tags."}
+}
+
+func (m *MockRuleServiceError) ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error) {
+	return nil, service.DataError{Message: "Error reading rule events."}
+}
@@ -0,0 +1,177 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"goapi/internal/api/auth"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"time"
+)
+
+// * Implementation of RuleService on the repository interfaces, it works with every backend *
+type RepositoryRuleService struct {
+	repo     models.RuleRepository
+	dataRepo models.DataRepository
+	engine   *Engine
+}
+
+func NewRepositoryRuleService(repo models.RuleRepository, dataRepo models.DataRepository, engine *Engine) *RepositoryRuleService {
+	return &RepositoryRuleService{
+		repo:     repo,
+		dataRepo: dataRepo,
+		engine:   engine,
+	}
+}
+
+func (rs *RepositoryRuleService) Create(rule *models.Rule, ctx context.Context) error {
+	if err := rs.ValidateRule(rule); err != nil {
+		return err
+	}
+	rule.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if err := rs.repo.Create(rule, ctx); err != nil {
+		return err
+	}
+	return rs.engine.Reload(ctx)
+}
+
+func (rs *RepositoryRuleService) ReadOne(id int, ctx context.Context) (*models.Rule, error) {
+	return rs.repo.ReadOne(id, ctx)
+}
+
+func (rs *RepositoryRuleService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Rule, error) {
+	return rs.repo.ReadMany(page, rowsPerPage, ctx)
+}
+
+func (rs *RepositoryRuleService) Update(rule *models.Rule, ctx context.Context) (int64, error) {
+	if err := rs.ValidateRule(rule); err != nil {
+		return 0, err
+	}
+	rule.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	aff, err := rs.repo.Update(rule, ctx)
+	if err != nil || aff == 0 {
+		return aff, err
+	}
+	return aff, rs.engine.Reload(ctx)
+}
+
+func (rs *RepositoryRuleService) Delete(rule *models.Rule, ctx context.Context) (int64, error) {
+	aff, err := rs.repo.Delete(rule, ctx)
+	if err != nil || aff == 0 {
+		return aff, err
+	}
+	return aff, rs.engine.Reload(ctx)
+}
+
+// * A dry run loads the readings of its range at once, so the range is limited *
+const maxDryRunSpan = 31 * 24 * time.Hour
+
+// * DryRun replays the stored readings taken between from and to through a fresh evaluator holding only the given rule *
+// * Both bounds are required and at most maxDryRunSpan apart, both the readings and the rule belong to the tenant of the caller *
+func (rs *RepositoryRuleService) DryRun(rule *models.Rule, from time.Time, to time.Time, ctx context.Context) (*DryRunResult, error) {
+	if err := validateDryRunRange(from, to); err != nil {
+		return nil, err
+	}
+	if err := rs.ValidateRule(rule); err != nil {
+		return nil, err
+	}
+
+	readings, err := rs.dataRepo.ReadRange(from, to, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := auth.Tenant(ctx)
+	rule.Tenant = tenant
+	evaluator := NewEvaluator([]*models.Rule{rule})
+	evaluator.SetGroupWindow(rs.engine.GroupWindow())
+	tagCache := map[string][]string{}
+	result := &DryRunResult{Readings: len(readings), Events: []models.RuleEvent{}}
+	for _, reading := range readings {
+		var tags []string
+		if rule.GroupTag != "" {
+			var ok bool
+			if tags, ok = tagCache[reading.DeviceID]; !ok {
+				if tags, err = rs.repo.ReadTags(reading.DeviceID, ctx); err != nil {
+					return nil, err
+				}
+				tagCache[reading.DeviceID] = tags
+			}
+		}
+		result.Events = append(result.Events, evaluator.Evaluate(tenant, reading, tags)...)
+	}
+	return result, nil
+}
+
+func validateDryRunRange(from time.Time, to time.Time) error {
+	var fields []service.FieldError
+	if from.IsZero() {
+		fields = append(fields, service.FieldError{Field: "from", Message: "is required"})
+	}
+	if to.IsZero() {
+		fields = append(fields, service.FieldError{Field: "to", Message: "is required"})
+	} else if !from.IsZero() && !to.After(from) {
+		fields = append(fields, service.FieldError{Field: "to", Message: "must be after from"})
+	} else if !from.IsZero() && to.Sub(from) > maxDryRunSpan {
+		fields = append(fields, service.FieldError{Field: "to", Message: fmt.Sprintf("must be at most %d days after from", int(maxDryRunSpan.Hours()/24))})
+	}
+	if len(fields) > 0 {
+		return service.DataError{Message: "Invalid time range.", Fields: fields}
+	}
+	return nil
+}
+
+func (rs *RepositoryRuleService) ReadTags(deviceID string, ctx context.Context) ([]string, error) {
+	return rs.repo.ReadTags(deviceID, ctx)
+}
+
+func (rs *RepositoryRuleService) SetTags(deviceID string, tags []string, ctx context.Context) error {
+	if deviceID == "" || len(deviceID) > 50 {
+		return service.DataError{Message: "DeviceID is required and must be less than 50 characters."}
+	}
+	for _, tag := range tags {
+		if tag == "" || len(tag) > 50 {
+			return service.DataError{Message: "Tags must be non-empty and less than 50 characters."}
+		}
+	}
+	return rs.repo.SetTags(deviceID, tags, ctx)
+}
+
+func (rs *RepositoryRuleService) ReadEvents(page int, rowsPerPage int, ctx context.Context) ([]*models.RuleEvent, error) {
+	return rs.repo.ReadEvents(page, rowsPerPage, ctx)
+}
+
+// * SetGroupWindow sets how long a device of a grouped rule counts as active after its last reading *
+func (rs *RepositoryRuleService) SetGroupWindow(window time.Duration) {
+	rs.engine.SetGroupWindow(window)
+}
+
+// * ValidateRule returns a DataError with one FieldError per invalid field, in a stable order, or nil if the rule is valid *
+func (rs *RepositoryRuleService) ValidateRule(rule *models.Rule) error {
+	var fields []service.FieldError
+	invalid := func(field string, message string) {
+		fields = append(fields, service.FieldError{Field: field, Message: message})
+	}
+
+	if rule.Name == "" {
+		invalid("name", "is required")
+	} else if len(rule.Name) > 50 {
+		invalid("name", "must be at most 50 characters")
+	}
+	if err := rule.Condition.Validate(); err != nil {
+		invalid("condition", err.Error())
+	}
+	if d, err := rule.Duration(); err != nil || d < 0 {
+		invalid("for", "must be a positive duration such as 15m")
+	}
+	if len(rule.GroupTag) > 50 {
+		invalid("group_tag", "must be at most 50 characters")
+	}
+	if rule.MinDevices < 0 {
+		invalid("min_devices", "must not be negative")
+	}
+	if len(fields) > 0 {
+		return service.DataError{Message: "Invalid rule.", Fields: fields}
+	}
+	return nil
+}
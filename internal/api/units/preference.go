@@ -0,0 +1,37 @@
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// * Preference is the unit a client wants the values of each quantity in, quantities left out are canonical *
+type Preference map[Quantity]Unit
+
+// * ParsePreference reads a comma separated list of unit symbols, e.g. "F" or "K,%", "" prefers the canonical units *
+func ParsePreference(s string) (Preference, error) {
+	p := Preference{}
+	for _, symbol := range strings.Split(s, ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		u, ok := Find(symbol)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a known unit", symbol)
+		}
+		if other, ok := p[u.Quantity]; ok && other.Symbol != u.Symbol {
+			return nil, fmt.Errorf("both %s and %s are given for %s", other.Symbol, u.Symbol, u.Quantity)
+		}
+		p[u.Quantity] = u
+	}
+	return p, nil
+}
+
+// * Unit returns the preferred unit of q *
+func (p Preference) Unit(q Quantity) Unit {
+	if u, ok := p[q]; ok {
+		return u
+	}
+	return Canonical(q)
+}
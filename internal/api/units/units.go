@@ -0,0 +1,137 @@
+// Package units converts the values of readings and thresholds between units
+// of measure. Values are stored in the canonical unit of their quantity,
+// degrees Celsius for temperature and percent for relative humidity, and are
+// converted when they are received in or asked for in another unit.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// * Quantity is what a value measures, named like the metrics of a reading *
+type Quantity string
+
+const (
+	Temperature Quantity = "temperature"
+	Humidity    Quantity = "humidity"
+)
+
+// * Unit converts values of its quantity from and to the canonical unit *
+type Unit struct {
+	Symbol   string
+	Quantity Quantity
+	// Aliases are other accepted spellings of the symbol, matched case-insensitively
+	Aliases []string
+
+	scale, offset float64
+}
+
+// * canonical = value * scale + offset *
+var (
+	Celsius    = Unit{Symbol: "C", Quantity: Temperature, Aliases: []string{"°C", "celsius", "degC"}, scale: 1}
+	Fahrenheit = Unit{Symbol: "F", Quantity: Temperature, Aliases: []string{"°F", "fahrenheit", "degF"}, scale: 5.0 / 9, offset: -32 * 5.0 / 9}
+	Kelvin     = Unit{Symbol: "K", Quantity: Temperature, Aliases: []string{"kelvin"}, scale: 1, offset: -273.15}
+	Percent    = Unit{Symbol: "%", Quantity: Humidity, Aliases: []string{"percent", "%RH"}, scale: 1}
+)
+
+var known = []Unit{Celsius, Fahrenheit, Kelvin, Percent}
+
+// * Canonical returns the unit values of q are stored in *
+func Canonical(q Quantity) Unit {
+	if q == Humidity {
+		return Percent
+	}
+	return Celsius
+}
+
+// * Lookup finds a unit of q by its symbol or an alias, "" is the canonical unit *
+func Lookup(q Quantity, symbol string) (Unit, error) {
+	if symbol == "" {
+		return Canonical(q), nil
+	}
+	for _, u := range known {
+		if u.Quantity == q && u.matches(symbol) {
+			return u, nil
+		}
+	}
+	return Unit{}, fmt.Errorf("%q is not a unit of %s, use one of %s", symbol, q, strings.Join(Symbols(q), ", "))
+}
+
+// * QuantityOf returns the quantity of a metric as named by thresholds and rules, e.g. Temperature or humi_value *
+func QuantityOf(metric string) (Quantity, bool) {
+	switch strings.ToLower(metric) {
+	case "temperature", "temp_value":
+		return Temperature, true
+	case "humidity", "humi_value":
+		return Humidity, true
+	}
+	return "", false
+}
+
+// * Find finds a unit of any quantity by its symbol or an alias *
+func Find(symbol string) (Unit, bool) {
+	for _, u := range known {
+		if u.matches(symbol) {
+			return u, true
+		}
+	}
+	return Unit{}, false
+}
+
+// * Symbols returns the symbols of the units of q, the canonical one first *
+func Symbols(q Quantity) []string {
+	var symbols []string
+	for _, u := range known {
+		if u.Quantity == q {
+			symbols = append(symbols, u.Symbol)
+		}
+	}
+	return symbols
+}
+
+func (u Unit) matches(symbol string) bool {
+	if symbol == u.Symbol {
+		return true
+	}
+	for _, alias := range u.Aliases {
+		if strings.EqualFold(symbol, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// * ToCanonical converts a value in u to the canonical unit of its quantity *
+func (u Unit) ToCanonical(v float64) float64 {
+	if u.canonical() {
+		return v
+	}
+	return round(v*u.scale + u.offset)
+}
+
+// * FromCanonical converts a value in the canonical unit to u *
+func (u Unit) FromCanonical(v float64) float64 {
+	if u.canonical() {
+		return v
+	}
+	return round((v - u.offset) / u.scale)
+}
+
+func (u Unit) canonical() bool {
+	return u.scale == 1 && u.offset == 0
+}
+
+// * Convert converts a value from one unit to another of the same quantity *
+func Convert(v float64, from Unit, to Unit) float64 {
+	if from.Symbol == to.Symbol {
+		return v
+	}
+	return to.FromCanonical(from.ToCanonical(v))
+}
+
+// * round drops the noise of floating point arithmetic, 70.7 °F is 21.5 °C and not 21.499999999999996 *
+func round(v float64) float64 {
+	return math.Round(v*1e9) / 1e9
+}
@@ -0,0 +1,59 @@
+package units_test
+
+import (
+	"goapi/internal/api/units"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to units.Unit
+		expected float64
+	}{
+		{21.5, units.Celsius, units.Fahrenheit, 70.7},
+		{70.7, units.Fahrenheit, units.Celsius, 21.5},
+		{-40, units.Fahrenheit, units.Celsius, -40},
+		{0, units.Kelvin, units.Fahrenheit, -459.67},
+		{294.65, units.Kelvin, units.Celsius, 21.5},
+		{40, units.Percent, units.Percent, 40},
+	}
+	for _, tt := range tests {
+		if got := units.Convert(tt.value, tt.from, tt.to); got != tt.expected {
+			t.Errorf("%v %s in %s: expected %v, got %v", tt.value, tt.from.Symbol, tt.to.Symbol, tt.expected, got)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for symbol, expected := range map[string]string{"": "C", "F": "F", "°F": "F", "Fahrenheit": "F", "kelvin": "K"} {
+		if u, err := units.Lookup(units.Temperature, symbol); err != nil || u.Symbol != expected {
+			t.Errorf("%q: expected %s, got %s, %v", symbol, expected, u.Symbol, err)
+		}
+	}
+	if _, err := units.Lookup(units.Humidity, "F"); err == nil || err.Error() != `"F" is not a unit of humidity, use one of %` {
+		t.Errorf("expected F to be rejected for humidity, got %v", err)
+	}
+	// Symbols are case-sensitive, k is not K
+	if _, err := units.Lookup(units.Temperature, "k"); err == nil {
+		t.Error("expected k to be rejected")
+	}
+}
+
+func TestParsePreference(t *testing.T) {
+	p, err := units.ParsePreference(" F , % ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Unit(units.Temperature).Symbol != "F" || p.Unit(units.Humidity).Symbol != "%" {
+		t.Errorf("unexpected preference %v", p)
+	}
+	if p, err := units.ParsePreference(""); err != nil || p.Unit(units.Temperature).Symbol != "C" {
+		t.Errorf("expected the canonical units, got %v, %v", p, err)
+	}
+	for _, s := range []string{"R", "F,K"} {
+		if _, err := units.ParsePreference(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}